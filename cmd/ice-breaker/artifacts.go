@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// artifactAttempts is how many times an artifact's upload is tried, and
+// artifactUploadTimeout how long those left for the end of the run get.
+const (
+	artifactAttempts      = 3
+	artifactUploadTimeout = 15 * time.Minute
+)
+
+// artifactUpload is an artifact's upload, once it's been tried.
+type artifactUpload struct {
+	path string
+	uri  string
+	err  error
+}
+
+// uploader returns the uploader for --artifact-s3-uri, or nil without it or
+// before validate has set the credentials up.
+func (o *globalOptions) uploader() *glacierpurge.Uploader {
+	if o.artifactURI == "" || o.settings.Credentials == nil {
+		return nil
+	}
+	if o.artifactUploader == nil {
+		location, _ := glacierpurge.ParseS3URI(o.artifactURI) // checked by validate
+		o.artifactUploader = glacierpurge.NewUploader(&o.settings, location, o.artifactRegion, glacierpurge.S3UploadOptions{
+			ServerSideEncryption: o.artifactSSE,
+			KMSKeyID:             o.artifactKMSKey,
+		})
+		o.artifactUploader.Limiter = o.sharedLimiter()
+		o.artifactRun = fmt.Sprintf("%s-%s", o.command, time.Now().UTC().Format("20060102T150405Z"))
+	}
+	return o.artifactUploader
+}
+
+// saveArtifact uploads a file the run has finished writing, such as a saved
+// inventory, straight away, rather than leaving a big one for the end.
+func (o *globalOptions) saveArtifact(ctx context.Context, path string) {
+	if o.uploader() == nil {
+		return
+	}
+	o.uploadArtifact(ctx, path, filepath.Base(path))
+}
+
+// uploadArtifacts uploads, with --artifact-s3-uri, the files the run appends
+// to until it ends: the audit log, the events file, and a recording. It then
+// prints where every artifact went. Failures are reported but don't fail the
+// run, whose work is done.
+func (o *globalOptions) uploadArtifacts() {
+	if o.uploader() == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), artifactUploadTimeout)
+	defer cancel()
+
+	for _, path := range []string{o.auditPath, o.eventsPath} {
+		if path != "" {
+			o.uploadArtifact(ctx, path, filepath.Base(path))
+		}
+	}
+	if o.recordDir != "" {
+		filepath.WalkDir(o.recordDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(o.recordDir, path)
+			if err != nil {
+				return err
+			}
+			o.uploadArtifact(ctx, path, "recording/"+filepath.ToSlash(rel))
+			return nil
+		})
+	}
+
+	if len(o.artifactUploads) == 0 {
+		return
+	}
+	failed := 0
+	fmt.Fprintln(stderr, "Artifacts:")
+	for _, upload := range o.artifactUploads {
+		if upload.err != nil {
+			failed++
+			fmt.Fprintf(stderr, "  %s%s: not uploaded: %v%s\n", ui.Yellow, upload.path, upload.err, ui.Reset)
+		} else {
+			fmt.Fprintf(stderr, "  %s\n", upload.uri)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(stderr, "%s%d artifact(s) couldn't be uploaded to %s; they're still on this machine.%s\n", ui.Yellow, failed, o.artifactURI, ui.Reset)
+	}
+}
+
+// uploadArtifact uploads the file at path to key under the run's prefix,
+// trying again a few times if it fails.
+func (o *globalOptions) uploadArtifact(ctx context.Context, path, key string) {
+	uploader := o.uploader()
+	upload := artifactUpload{path: path}
+	for attempt := 1; ; attempt++ {
+		upload.uri, upload.err = uploader.Upload(ctx, o.artifactRun+"/"+key, path)
+		if upload.err == nil || attempt == artifactAttempts || ctx.Err() != nil {
+			break
+		}
+		ui.Debugf("Uploading %s failed, trying again: %v", path, upload.err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(attempt) * 2 * time.Second):
+		}
+	}
+	if upload.err == nil {
+		ui.Debugf("Uploaded %s to %s", path, upload.uri)
+	}
+	o.artifactUploads = append(o.artifactUploads, upload)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/rdegges/ice-breaker/internal/audit"
+)
+
+func verifyAuditLogFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+
+	return func(o *globalOptions) error {
+		if o.arg == "" {
+			return errors.New("usage: ice-breaker verify-audit-log PATH")
+		}
+
+		records, head, err := audit.Verify(o.arg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s: %d record(s), chain intact, last hash %s\n", o.arg, records, head)
+		return nil
+	}
+}
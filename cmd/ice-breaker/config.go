@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/ice-breaker/config.yaml, falling
+// back to ~/.config/ice-breaker/config.yaml.
+func defaultConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "ice-breaker", "config.yaml")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "ice-breaker", "config.yaml")
+	}
+	return "config.yaml"
+}
+
+// configKeys returns the name of every flag any command accepts. A key the
+// running command doesn't use is ignored, but one no command knows about is a
+// mistake.
+func configKeys() map[string]bool {
+	keys := make(map[string]bool)
+	for _, c := range commands {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		newGlobalOptions(fs)
+		c.flags(fs)
+		fs.VisitAll(func(f *flag.Flag) {
+			keys[f.Name] = true
+		})
+	}
+	delete(keys, "config")
+	return keys
+}
+
+// loadConfig reads a configuration file mapping flag names to values. A value
+// is either a scalar or, for flags that may be repeated, a list.
+func loadConfig(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	config := make(map[string][]string, len(raw))
+	for key, value := range raw {
+		switch value := value.(type) {
+		case nil:
+		case []any:
+			for _, v := range value {
+				if _, ok := v.(map[string]any); ok {
+					return nil, fmt.Errorf("config file %s: %q must be a value or a list of values", path, key)
+				}
+				config[key] = append(config[key], fmt.Sprint(v))
+			}
+		case map[string]any:
+			return nil, fmt.Errorf("config file %s: %q must be a value or a list of values", path, key)
+		default:
+			config[key] = []string{fmt.Sprint(value)}
+		}
+	}
+
+	return config, nil
+}
+
+// applyConfig sets every flag the command line left alone from the
+// configuration file. A missing file is only an error when --config named it.
+func (o *globalOptions) applyConfig(fs *flag.FlagSet, known map[string]bool) error {
+	config, err := loadConfig(o.configPath)
+	if errors.Is(err, os.ErrNotExist) && o.sources["config"] == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !known[key] {
+			return fmt.Errorf("config file %s: unknown key %q", o.configPath, key)
+		}
+
+		f := fs.Lookup(key)
+		if f == nil || o.sources[key] != "" {
+			continue
+		}
+		for _, value := range config[key] {
+			if err := f.Value.Set(value); err != nil {
+				return fmt.Errorf("config file %s: invalid value %q for %q: %w", o.configPath, value, key, err)
+			}
+		}
+		o.sources[key] = "file"
+	}
+
+	return nil
+}
+
+// runConfig handles 'config show [command] [flags]', printing the value every
+// flag ends up with and where that value came from.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return errors.New("usage: ice-breaker config show [command] [flags]")
+	}
+	args = args[1:]
+
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	o := newGlobalOptions(fs)
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		c := lookupCommand(args[0])
+		if c == nil {
+			return fmt.Errorf("unknown command %q", args[0])
+		}
+		c.flags(fs)
+		args = args[1:]
+	}
+
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if (f.Name == "secret" || f.Name == "session-token") && value != "" {
+			value = "********"
+		}
+		source := o.sources[f.Name]
+		if source == "" {
+			source = "default"
+		}
+
+		if !isBoolFlag(f) {
+			quoted, _ := yaml.Marshal(value)
+			value = strings.TrimSpace(string(quoted))
+		}
+		fmt.Fprintf(stdout, "%s: %s  # %s\n", f.Name, value, source)
+	})
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rdegges/ice-breaker/internal/redact"
+)
+
+// cleanEnv leaves the test without any ICEBREAKER_ or AWS_ variable, or
+// config file, from the environment it was run in, and returns the directory
+// the config file is looked for in.
+func cleanEnv(t *testing.T) string {
+	t.Helper()
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, envPrefix) || strings.HasPrefix(name, "AWS_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	return filepath.Join(dir, "ice-breaker")
+}
+
+// configShow runs 'config show' with args and returns each key's value and
+// source as it prints them.
+func configShow(t *testing.T, args ...string) map[string][2]string {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := stdout
+	stdout = redact.NewWriter(&buf)
+	defer func() { stdout = saved }()
+	if err := runConfig(append([]string{"show"}, args...)); err != nil {
+		t.Fatalf("config show %s: %v", strings.Join(args, " "), err)
+	}
+
+	shown := make(map[string][2]string)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		key, rest, _ := strings.Cut(line, ": ")
+		value, source, _ := strings.Cut(rest, "  # ")
+		shown[key] = [2]string{value, source}
+	}
+	return shown
+}
+
+// TestPrecedence sets a key in every way it can be set, and in fewer, and
+// checks the one that wins: a flag, then an ICEBREAKER_ variable, then an
+// AWS_ variable, then the config file.
+func TestPrecedence(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		args   []string
+		env    map[string]string
+		file   string
+		key    string
+		want   string
+		source string
+	}{
+		{
+			name:   "region from a flag",
+			args:   []string{"--region", "us-east-1"},
+			env:    map[string]string{"ICEBREAKER_REGION": "us-east-2", "AWS_REGION": "us-west-1"},
+			file:   "region: us-west-2\n",
+			key:    "region",
+			want:   "us-east-1",
+			source: "flag",
+		},
+		{
+			name:   "region from ICEBREAKER_REGION",
+			env:    map[string]string{"ICEBREAKER_REGION": "us-east-2", "AWS_REGION": "us-west-1"},
+			file:   "region: us-west-2\n",
+			key:    "region",
+			want:   "us-east-2",
+			source: "env",
+		},
+		{
+			name:   "region from AWS_REGION",
+			env:    map[string]string{"AWS_REGION": "us-west-1", "AWS_DEFAULT_REGION": "eu-west-1"},
+			file:   "region: us-west-2\n",
+			key:    "region",
+			want:   "us-west-1",
+			source: "env (AWS_REGION)",
+		},
+		{
+			name:   "region from AWS_DEFAULT_REGION",
+			env:    map[string]string{"AWS_DEFAULT_REGION": "eu-west-1"},
+			file:   "region: us-west-2\n",
+			key:    "region",
+			want:   "eu-west-1",
+			source: "env (AWS_DEFAULT_REGION)",
+		},
+		{
+			name:   "region from the file",
+			file:   "region: us-west-2\n",
+			key:    "region",
+			want:   "us-west-2",
+			source: "file",
+		},
+		{
+			name:   "region left alone by AWS_REGION when the file sweeps regions",
+			env:    map[string]string{"AWS_REGION": "us-west-1"},
+			file:   "regions: [eu-west-1, eu-central-1]\n",
+			key:    "region",
+			want:   `""`,
+			source: "default",
+		},
+		{
+			name:   "no region",
+			key:    "region",
+			want:   `""`,
+			source: "default",
+		},
+		{
+			name:   "key ID from a flag",
+			args:   []string{"--id", "AKIDFLAG"},
+			env:    map[string]string{"ICEBREAKER_ID": "AKIDICEBREAKER", "AWS_ACCESS_KEY_ID": "AKIDAWS"},
+			file:   "id: AKIDFILE\n",
+			key:    "id",
+			want:   "AKIDFLAG",
+			source: "flag",
+		},
+		{
+			name:   "key ID from ICEBREAKER_ID",
+			env:    map[string]string{"ICEBREAKER_ID": "AKIDICEBREAKER", "AWS_ACCESS_KEY_ID": "AKIDAWS"},
+			file:   "id: AKIDFILE\n",
+			key:    "id",
+			want:   "AKIDICEBREAKER",
+			source: "env",
+		},
+		{
+			name:   "key ID from AWS_ACCESS_KEY_ID",
+			env:    map[string]string{"AWS_ACCESS_KEY_ID": "AKIDAWS"},
+			file:   "id: AKIDFILE\n",
+			key:    "id",
+			want:   "AKIDAWS",
+			source: "env (AWS_ACCESS_KEY_ID)",
+		},
+		{
+			name:   "key ID from the file",
+			file:   "id: AKIDFILE\n",
+			key:    "id",
+			want:   "AKIDFILE",
+			source: "file",
+		},
+		{
+			name:   "secret from AWS_SECRET_ACCESS_KEY",
+			env:    map[string]string{"AWS_SECRET_ACCESS_KEY": "awssecretkey"},
+			file:   "secret: filesecretkey\n",
+			key:    "secret",
+			want:   "'********'",
+			source: "env (AWS_SECRET_ACCESS_KEY)",
+		},
+		{
+			name:   "flag without an AWS_ variable, from a flag",
+			args:   []string{"--fail-fast=false"},
+			env:    map[string]string{"ICEBREAKER_FAIL_FAST": "yes"},
+			file:   "fail-fast: true\n",
+			key:    "fail-fast",
+			want:   "false",
+			source: "flag",
+		},
+		{
+			name:   "flag without an AWS_ variable, from ICEBREAKER_",
+			env:    map[string]string{"ICEBREAKER_FAIL_FAST": "no"},
+			file:   "fail-fast: true\n",
+			key:    "fail-fast",
+			want:   "false",
+			source: "env",
+		},
+		{
+			name:   "flag without an AWS_ variable, from the file",
+			file:   "fail-fast: true\n",
+			key:    "fail-fast",
+			want:   "true",
+			source: "file",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := cleanEnv(t)
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+			if c.file != "" {
+				if err := os.MkdirAll(dir, 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(c.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			shown := configShow(t, append([]string{"purge"}, c.args...)...)
+			if got := shown[c.key]; got != [2]string{c.want, c.source} {
+				t.Errorf("config show gave %s: %s  # %s, want %s  # %s", c.key, got[0], got[1], c.want, c.source)
+			}
+		})
+	}
+}
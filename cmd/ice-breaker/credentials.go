@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/redact"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// credentialQuestions ask for credentials, without echoing the secret ones.
+var credentialQuestions = []ui.Question{
+	{Text: "AWS Access Key ID:"},
+	{Text: "AWS Secret Access Key:", Secret: true},
+	{Text: "AWS session token (blank for none):", Secret: true},
+}
+
+// renewCredentials pauses the run once the credentials have expired, asking
+// for new ones. Without an answer the run stops instead, and resume can
+// finish it once the user has re-authenticated.
+func renewCredentials(ctx context.Context, cause error) (aws.Credentials, error) {
+	ui.Printf("\n%s%sThe AWS credentials have expired or are about to: %v%s\n", ui.Yellow, ui.Bold, cause, ui.Reset)
+	ui.Println("Re-authenticate (e.g. 'aws sts get-session-token') and enter the new credentials to carry on.")
+
+	renew, err := stdin.Confirm(ctx, "Enter new credentials now? Otherwise the run stops here")
+	if err != nil && !errors.Is(err, ui.ErrNoInput) {
+		return aws.Credentials{}, err
+	}
+	if !renew {
+		return aws.Credentials{}, glacierpurge.ErrCredentialsExpired
+	}
+
+	answers, err := stdin.AskAll(ctx, credentialQuestions...)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	if answers[0] == "" || answers[1] == "" {
+		return aws.Credentials{}, errors.New("AWS Access Key ID and Secret Access Key are required")
+	}
+	renewed := aws.Credentials{AccessKeyID: answers[0], SecretAccessKey: answers[1], SessionToken: answers[2]}
+	redact.Add(renewed.SecretAccessKey, renewed.SessionToken)
+	renewed.Source = "entered when the earlier credentials expired"
+	ui.Printf("%sCarrying on with the new credentials.%s\n", ui.Green, ui.Reset)
+	return renewed, nil
+}
+
+// askCredentials asks for the credentials for --prompt-credentials, the
+// secret ones without echoing them. They take the place of any given some
+// other way, and are used exactly as those would have been.
+func (o *globalOptions) askCredentials() error {
+	ui.Println("Enter the AWS credentials to use.")
+	answers, err := stdin.AskAll(context.Background(), credentialQuestions...)
+	if errors.Is(err, io.EOF) {
+		return errors.New("no credentials were entered")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the credentials: %w", err)
+	}
+	if answers[0] == "" || answers[1] == "" {
+		return errors.New("AWS Access Key ID and Secret Access Key are required")
+	}
+	o.settings.AccessKeyID, o.settings.SecretAccessKey, o.settings.SessionToken = answers[0], answers[1], answers[2]
+	redact.Add(o.settings.SecretAccessKey, o.settings.SessionToken)
+	return nil
+}
+
+// warnSecretFlags warns about secrets given as flags, which anyone on the
+// machine can read from ps, and which shell history keeps.
+func warnSecretFlags(sources map[string]string) {
+	var flags []string
+	for _, name := range []string{"secret", "session-token"} {
+		if sources[name] == "flag" {
+			flags = append(flags, "--"+name)
+		}
+	}
+	if len(flags) == 0 {
+		return
+	}
+	ui.Printf("%s%s puts a secret on the command line, where ps shows it to anyone on this machine and shell history keeps it. Use --prompt-credentials, the AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables, or the config file instead.%s\n", ui.Yellow, strings.Join(flags, " and "), ui.Reset)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func deleteArchiveFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	vault := fs.String("vault", "", "Name of the vault holding the archives")
+	var ids stringList
+	fs.Var(&ids, "archive-id", "ID of an archive to delete (may be repeated)")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		region, err := o.selection.single()
+		if problems.add(err) && (region == "" || *vault == "" || len(ids) == 0) {
+			problems.add(errors.New("-region, --vault, and --archive-id are required"))
+		}
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		// An archive named twice is only deleted once. The IDs are few enough
+		// to keep whole, rather than an ArchiveSet's fingerprints of them.
+		seen := make(map[string]bool, len(ids))
+		unique := ids[:0]
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				unique = append(unique, id)
+			}
+		}
+		if repeated := len(ids) - len(unique); repeated > 0 {
+			ui.Printf("%sIgnoring %d repeated archive ID(s)%s\n", ui.Yellow, repeated, ui.Reset)
+		}
+		ids = unique
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		g, err := o.registry().Get(ctx, region)
+		if err != nil {
+			return err
+		}
+		v := &glacierpurge.Vault{Glacier: g, Name: *vault}
+
+		if _, err := v.Describe(ctx); err == nil {
+			ui.Printf("Vault %s\n", v)
+		}
+		showArchives(ctx, v, ids)
+
+		if !*yes {
+			confirmed, err := stdin.Confirm(ctx, fmt.Sprintf("[%s] %s: Delete %d archive(s)?", g.Region, v.Name, len(ids)))
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Println("Nothing was deleted.")
+				return nil
+			}
+		}
+
+		failed := 0
+		for _, id := range ids {
+			archive := &glacierpurge.Archive{Vault: v, Id: id}
+			err := archive.Delete(ctx)
+			if errors.Is(err, glacierpurge.ErrArchiveNotFound) {
+				ui.Printf("%s  GONE    %s/%s: already deleted%s\n", ui.Yellow, v, id, ui.Reset)
+				continue
+			}
+			if err != nil {
+				failed++
+				ui.Printf("%s  FAILED  %s/%s: %v%s\n", ui.Red, v, id, err, ui.Reset)
+				continue
+			}
+			ui.Printf("%s  DELETED %s/%s%s\n", ui.Green, v, id, ui.Reset)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to delete %d of %d archive(s)", failed, len(ids))
+		}
+		return nil
+	}
+}
+
+// showArchives prints what the vault's latest inventory says about each
+// archive, if Glacier still has one. Without an inventory there is nothing to
+// show, which isn't an error.
+func showArchives(ctx context.Context, v *glacierpurge.Vault, ids []string) {
+	job, err := v.LatestInventory(ctx)
+	if err != nil {
+		ui.Debugf("Couldn't look for an inventory of vault %s: %v", v.Name, err)
+		return
+	}
+	if job == nil {
+		ui.Debugf("Vault %s has no recent inventory to show archive details from", v.Name)
+		return
+	}
+
+	// Only the archives asked about are kept, however big the vault.
+	byId := make(map[string]*glacierpurge.Archive, len(ids))
+	for _, id := range ids {
+		byId[id] = nil
+	}
+	err = job.StreamResults(ctx, func(archive *glacierpurge.Archive) error {
+		if _, ok := byId[archive.Id]; ok {
+			byId[archive.Id] = archive
+		}
+		return nil
+	})
+	if err != nil {
+		ui.Debugf("Couldn't read inventory job %s: %v", job.Id, err)
+		return
+	}
+
+	for _, id := range ids {
+		archive := byId[id]
+		if archive == nil {
+			ui.Printf("%s%s: not in the vault's latest inventory%s\n", ui.Yellow, id, ui.Reset)
+			continue
+		}
+		ui.Printf("%s: %d bytes, created %s, %q\n", id, archive.Size, archive.CreationDate.Format("2006-01-02"), archive.Description)
+	}
+}
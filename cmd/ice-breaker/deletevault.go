@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// deleteVaultFlags registers the delete-vault command, which deletes vaults
+// that are already empty straight away, with no inventory job. A vault
+// Glacier still counts archives in is refused.
+func deleteVaultFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	var names stringList
+	fs.Var(&names, "vault", "Comma-separated names of the empty vaults to delete, or patterns such as logs-* (may be repeated)")
+	yes := fs.Bool("yes", false, "Delete the vaults without asking for confirmation")
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		if len(names) == 0 {
+			problems.add(errors.New("--vault is required"))
+		}
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		store, err := o.openState()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		regions, done, err := o.regions(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		vaults, scanned := run.Scan(ctx, o.registry(), regions)
+		vaults = run.FilterVaults(vaults, names)
+		run.SummarizeRegions(scanned)
+		if len(vaults) == 0 {
+			return errors.New("no vaults with those names were found")
+		}
+		run.Enrich(ctx, vaults)
+
+		var empty []*glacierpurge.Vault
+		refused := 0
+		for _, v := range vaults {
+			description, err := v.Describe(ctx)
+			if err != nil {
+				refused++
+				ui.Printf("%s[%s] %s: %v%s\n", ui.Red, v.Glacier.Region, v.Name, err, ui.Reset)
+				continue
+			}
+			if description.NumberOfArchives > 0 {
+				refused++
+				ui.Printf("%s[%s] %s: not empty: Glacier's inventory of %s counts %d archive(s); use 'ice-breaker purge-vault --region %s --vault %s' to delete them and the vault.%s\n", ui.Yellow, v.Glacier.Region, v.Name, description.LastInventoryDate.Local().Format("2006-01-02 15:04"), description.NumberOfArchives, v.Glacier.Region, v.Name, ui.Reset)
+				continue
+			}
+			ui.Printf("[%s] %s: empty\n", v.Glacier.Region, v.Name)
+			empty = append(empty, v)
+		}
+		if len(empty) == 0 {
+			return fmt.Errorf("none of the %d vault(s) are empty; nothing was deleted", len(vaults))
+		}
+
+		if !*yes {
+			confirmed, err := stdin.Confirm(ctx, fmt.Sprintf("Delete these %d empty vault(s)?", len(empty)))
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Println("Nothing was deleted.")
+				return nil
+			}
+		}
+
+		var deleted, notYet, failed int
+		for _, v := range empty {
+			err := v.Delete(ctx)
+			switch {
+			case err == nil:
+				deleted++
+				ui.Printf("%s  DELETED %s%s\n", ui.Green, v, ui.Reset)
+				if err := store.RemoveEmptied(v.Glacier.Region, v.Name); err != nil {
+					ui.Printf("%sCouldn't update the completed-work file: %v%s\n", ui.Yellow, err, ui.Reset)
+				}
+			case errors.Is(err, glacierpurge.ErrVaultNotEmpty):
+				// Written to since its last inventory, which Glacier takes
+				// about once a day.
+				notYet++
+				ui.Printf("%s  NOT YET %s: Glacier won't delete it until its next inventory, about a day from now, shows it empty; run this again then.%s\n", ui.Yellow, v, ui.Reset)
+			default:
+				failed++
+				ui.Printf("%s  FAILED  %s: %v%s\n", ui.Red, v, err, ui.Reset)
+			}
+		}
+
+		ui.Printf("%d vault(s) deleted, %d to try again after their next inventory, %d failed, %d refused.\n", deleted, notYet, failed, refused)
+		if deleted < len(vaults) {
+			return fmt.Errorf("%d of %d vault(s) weren't deleted", len(vaults)-deleted, len(vaults))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// The verdicts of doctor's checks.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// check is one line of doctor's checklist.
+type check struct {
+	Status string   `json:"status"`
+	Name   string   `json:"name"`
+	Detail string   `json:"detail,omitempty"`
+	Denied []string `json:"denied,omitempty"` // the IAM actions missing
+}
+
+// requiredActions are the Glacier actions a run can't finish without, and
+// optionalActions those only some of its options need.
+var (
+	requiredActions = joinActions(glacierpurge.ActionsToList, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve, glacierpurge.ActionsToDeleteArchives, glacierpurge.ActionsToDeleteVaults)
+	optionalActions = joinActions(glacierpurge.ActionsToReadTags, glacierpurge.ActionsToTag)
+)
+
+// doctorFlags registers the doctor command, which checks, before a long run,
+// that the credentials can carry it through: read-only calls for what can be
+// tried safely, and IAM's policy simulator for the rest. It fails if anything
+// a run needs is missing.
+func doctorFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	vault := fs.String("vault", "", "Vault to check the per-vault permissions on; the first one found otherwise")
+
+	return func(o *globalOptions) error {
+		if err := o.validate(); err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		regions, done, err := o.regions(ctx)
+		if err != nil || done {
+			return err
+		}
+		checks := doctor(ctx, o, regions, *vault)
+
+		failed := 0
+		for _, c := range checks {
+			if c.Status == checkFail {
+				failed++
+			}
+		}
+		if o.output == "json" {
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(struct {
+				Version string  `json:"version"`
+				Checks  []check `json:"checks"`
+			}{buildVersion(), checks}); err != nil {
+				return err
+			}
+		} else {
+			printChecks(checks)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d check(s) failed: these credentials can't finish a run", failed)
+		}
+		return nil
+	}
+}
+
+// doctor runs the checks against the regions, and against the named vault or
+// else the first one found.
+func doctor(ctx context.Context, o *globalOptions, regions []string, name string) []check {
+	var checks []check
+	add := func(status, name, detail string) {
+		checks = append(checks, check{Status: status, Name: name, Detail: detail})
+	}
+
+	caller, err := glacierpurge.CallerARN(ctx, regions[0], &o.settings)
+	if err != nil {
+		add(checkFail, "Credentials", fmt.Sprintf("STS couldn't identify them: %v", err))
+		return checks
+	}
+	add(checkPass, "Credentials", caller)
+
+	vaults, scanned := run.Scan(ctx, o.registry(glacierpurge.WithReadOnly()), regions)
+	for _, result := range scanned {
+		if result.Status == run.RegionSkipped {
+			add(checkFail, "ListVaults in "+result.Region, result.Reason)
+		} else {
+			add(checkPass, "ListVaults in "+result.Region, fmt.Sprintf("%d vault(s)", result.Vaults))
+		}
+	}
+
+	var sample *glacierpurge.Vault
+	for _, v := range vaults {
+		if name == "" || v.Name == name {
+			sample = v
+			break
+		}
+	}
+	resource := ""
+	switch {
+	case sample == nil && name != "":
+		add(checkFail, "Vault "+name, "not found in the regions checked")
+	case sample == nil:
+		add(checkWarn, "Vault permissions", "no vaults to try them on; only simulated")
+	default:
+		label := fmt.Sprintf(" on %s in %s", sample.Name, sample.Glacier.Region)
+		if description, err := sample.Refresh(ctx); err != nil {
+			add(checkFail, "DescribeVault"+label, err.Error())
+		} else {
+			add(checkPass, "DescribeVault"+label, fmt.Sprintf("%d archive(s)", description.NumberOfArchives))
+			resource = description.ARN
+		}
+		if _, err := sample.ListJobs(ctx, glacierpurge.ListJobsOptions{InventoryOnly: true}); err != nil {
+			add(checkFail, "ListJobs"+label, err.Error())
+		} else {
+			add(checkPass, "ListJobs"+label, "")
+		}
+		if _, err := sample.Tags(ctx); err != nil {
+			add(checkWarn, "ListTagsForVault"+label, err.Error()+"; --tag and --exclude-tag leave vaults alone without it")
+		} else {
+			add(checkPass, "ListTagsForVault"+label, "")
+		}
+	}
+
+	// InitiateJob, GetJobOutput and the deletes can't be tried without
+	// starting a job or deleting something, so IAM is asked about them.
+	decisions, err := glacierpurge.SimulateActions(ctx, regions[0], &o.settings, append(requiredActions, optionalActions...), resource)
+	if err != nil {
+		add(checkWarn, "IAM policy simulation", fmt.Sprintf("%v; InitiateJob, GetJobOutput, DeleteArchive and DeleteVault are unchecked", err))
+		return checks
+	}
+	var denied, deniedOptional []string
+	for _, d := range decisions {
+		if d.Allowed() {
+			continue
+		}
+		if slices.Contains(optionalActions, d.Action) {
+			deniedOptional = append(deniedOptional, d.Action)
+		} else {
+			denied = append(denied, d.Action)
+		}
+	}
+	if len(denied) > 0 {
+		checks = append(checks, check{Status: checkFail, Name: "IAM policy simulation", Detail: "required actions denied: " + strings.Join(denied, ", "), Denied: denied})
+	} else {
+		add(checkPass, "IAM policy simulation", fmt.Sprintf("every action a run needs is allowed to %s", caller))
+	}
+	if len(deniedOptional) > 0 {
+		checks = append(checks, check{Status: checkWarn, Name: "IAM policy simulation", Detail: "denied, so vaults' tags can't be read or --tag-scheduled used: " + strings.Join(deniedOptional, ", "), Denied: deniedOptional})
+	}
+	return checks
+}
+
+func printChecks(checks []check) {
+	for _, c := range checks {
+		color, label := ui.Green, "PASS"
+		switch c.Status {
+		case checkWarn:
+			color, label = ui.Yellow, "WARN"
+		case checkFail:
+			color, label = ui.Red, "FAIL"
+		}
+		line := fmt.Sprintf("%s%s%s  %s", color, label, ui.Reset, c.Name)
+		if c.Detail != "" {
+			line += ": " + c.Detail
+		}
+		fmt.Fprintln(stdout, line)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func downloadArchiveFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	vault := fs.String("vault", "", "Name of the vault holding the archive")
+	archiveId := fs.String("archive-id", "", "ID of the archive to download")
+	output := fs.String("output-file", "", "File to write the archive to")
+	jobId := fs.String("job-id", "", "Reuse this archive retrieval job instead of starting a new one")
+	resume := fs.Bool("resume", false, "Continue a partial download already in the output file")
+	partSize := fs.Int64("part-size", 0, "Download in ranged requests of this many MiB; 0 downloads in one request")
+	tier := tierFlags(fs, "Standard")
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		region, err := o.selection.single()
+		if problems.add(err) && (region == "" || *vault == "" || *archiveId == "" || *output == "") {
+			problems.add(errors.New("-region, --vault, --archive-id, and --output-file are required"))
+		}
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		flags := os.O_RDWR | os.O_CREATE | os.O_EXCL
+		if *resume {
+			flags = os.O_RDWR | os.O_CREATE
+		}
+		f, err := os.OpenFile(*output, flags, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists; pass --resume to continue downloading into it", *output)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+		defer f.Close()
+
+		offset, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		if err := tier.confirm(ctx); err != nil {
+			return err
+		}
+
+		g, err := o.registry().Get(ctx, region)
+		if err != nil {
+			return err
+		}
+
+		archive := &glacierpurge.Archive{Vault: &glacierpurge.Vault{Glacier: g, Name: *vault}, Id: *archiveId}
+		if offset > 0 {
+			ui.Printf("Resuming download of archive %s at byte %d\n", archive.Id, offset)
+		}
+		result, err := archive.Download(ctx, f, glacierpurge.DownloadOptions{
+			Tier:         tier.tier,
+			TierFallback: tier.fallback,
+			JobId:        *jobId,
+			Offset:       offset,
+			Partial:      io.NewSectionReader(f, 0, offset),
+			PartSize:     *partSize << 20,
+		})
+		if err != nil {
+			if result.JobId != "" {
+				ui.Printf("%sDownload stopped after %d bytes. Run again with --resume --job-id %s to continue.%s\n", ui.Yellow, result.Written, result.JobId, ui.Reset)
+			}
+			return err
+		}
+
+		ui.Printf("%sDownloaded %d bytes to %s (tree hash %s)%s\n", ui.Green, result.Written, *output, result.TreeHash, ui.Reset)
+		return nil
+	}
+}
+
+// tierOptions are the flags choosing the retrieval tier for archive data.
+type tierOptions struct {
+	tier     string
+	fallback bool
+}
+
+func tierFlags(fs *flag.FlagSet, defaultTier string) *tierOptions {
+	t := &tierOptions{}
+	fs.StringVar(&t.tier, "tier", defaultTier, "Retrieval tier for archive data: Expedited, Standard, or Bulk")
+	fs.BoolVar(&t.fallback, "tier-fallback", false, "Fall back to the Standard tier when there is no Expedited capacity")
+	return t
+}
+
+// confirm validates the tier and, since Expedited retrievals cost many times
+// what the other tiers do, asks before going ahead with one.
+func (t *tierOptions) confirm(ctx context.Context) error {
+	if err := glacierpurge.ValidateTier(t.tier); err != nil {
+		return err
+	}
+	if t.tier != glacierpurge.TierExpedited {
+		return nil
+	}
+
+	ui.Printf("%sExpedited retrievals are billed at a much higher rate per GB and per request than Standard or Bulk ones.%s\n", ui.Yellow, ui.Reset)
+	confirmed, err := stdin.Confirm(ctx, "Use the Expedited tier anyway?")
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return errors.New("expedited retrieval not confirmed")
+	}
+	return nil
+}
+
+// salvageFlags registers the flags that turn on salvage mode. The returned
+// function gives the salvage options once they're parsed, or nil when salvage
+// is off.
+func salvageFlags(fs *flag.FlagSet) func(ctx context.Context) (*glacierpurge.SalvageOptions, error) {
+	dir := fs.String("salvage-dir", "", "Download and verify every archive into this directory before deleting any")
+	concurrency := fs.Int("salvage-concurrency", 4, "Archives to retrieve at once when salvaging")
+	tier := tierFlags(fs, "Bulk")
+
+	return func(ctx context.Context) (*glacierpurge.SalvageOptions, error) {
+		if *dir == "" {
+			return nil, nil
+		}
+		if err := tier.confirm(ctx); err != nil {
+			return nil, err
+		}
+		return &glacierpurge.SalvageOptions{Dir: *dir, Concurrency: *concurrency, Tier: tier.tier, TierFallback: tier.fallback}, nil
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// archiveIds returns the IDs of the archives each successful DeleteArchive
+// call deleted, and how many times each was deleted.
+func archiveIds(fake *glaciertest.Fake) map[string]int {
+	deleted := make(map[string]int)
+	for _, call := range fake.Calls(glaciertest.OpDeleteArchive) {
+		if call.Err == nil {
+			deleted[aws.ToString(call.Input.(*glacier.DeleteArchiveInput).ArchiveId)]++
+		}
+	}
+	return deleted
+}
+
+func TestNukeEndToEnd(t *testing.T) {
+	fake := glaciertest.New()
+	fake.InventoryOnRefusal = true
+	fake.AddVault(glaciertest.Vault{Name: "logs-2019", Archives: make([]glaciertest.Archive, 40)})
+	fake.AddVault(glaciertest.Vault{Name: "logs.2020_old", Archives: make([]glaciertest.Archive, 15)})
+	fake.AddVault(glaciertest.Vault{Name: "keep", Archives: make([]glaciertest.Archive, 5)})
+	c := newCLI(t, fake)
+
+	output, err := c.run("nuke", "--yes", "--vault", "logs*")
+	if err != nil {
+		t.Fatalf("nuke failed: %v\n%s", err, output)
+	}
+
+	for _, name := range []string{"logs-2019", "logs.2020_old"} {
+		if fake.HasVault(name) {
+			t.Errorf("vault %s is still there", name)
+		}
+	}
+	if len(fake.Archives("keep")) != 5 {
+		t.Error("vault keep lost archives")
+	}
+	deleted := archiveIds(fake)
+	if len(deleted) != 55 {
+		t.Errorf("deleted %d archives, want 55", len(deleted))
+	}
+	for id, n := range deleted {
+		if n != 1 {
+			t.Errorf("archive %s was deleted %d times", id, n)
+		}
+	}
+	// Each vault's deletion is turned down once, until Glacier takes its
+	// next inventory.
+	if n := fake.Count(glaciertest.OpDeleteVault); n != 4 {
+		t.Errorf("DeleteVault was called %d times, want 4", n)
+	}
+}
+
+func TestThrottledDeletesEndToEnd(t *testing.T) {
+	fake := glaciertest.New()
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 30)})
+	c := newCLI(t, fake)
+
+	// Every archive's first deletion is throttled.
+	var mu sync.Mutex
+	tried := make(map[string]bool)
+	fake.Intercept(glaciertest.OpDeleteArchive, func(input any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		id := aws.ToString(input.(*glacier.DeleteArchiveInput).ArchiveId)
+		if !tried[id] {
+			tried[id] = true
+			return glaciertest.Throttled()
+		}
+		return nil
+	})
+
+	output, err := c.run("nuke", "--yes", "--vault", "vault", "--vault-deletion-wait", "0", "--workers-per-vault", "15")
+	if !strings.Contains(output, "still waiting for Glacier's next inventory") {
+		t.Errorf("nuke didn't leave the vault for Glacier's next inventory: %v\n%s", err, output)
+	}
+	if left := fake.Archives("vault"); len(left) != 0 {
+		t.Errorf("%d archives are left\n%s", len(left), output)
+	}
+	throttled := 0
+	for _, call := range fake.Calls(glaciertest.OpDeleteArchive) {
+		if call.Err != nil {
+			throttled++
+		}
+	}
+	if throttled != 30 {
+		t.Errorf("%d deletions were throttled, want 30", throttled)
+	}
+}
+
+func TestResumeAfterKillEndToEnd(t *testing.T) {
+	fake := glaciertest.New()
+	fake.InventoryOnRefusal = true
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 100)})
+	c := newCLI(t, fake)
+
+	// The run is killed once it has deleted 30 archives; the deletions in
+	// flight then never reach Glacier.
+	var mu sync.Mutex
+	allowed := 30
+	var once sync.Once
+	blocked, release := make(chan struct{}), make(chan struct{})
+	fake.Intercept(glaciertest.OpDeleteArchive, func(any) error {
+		mu.Lock()
+		allowed--
+		left := allowed
+		mu.Unlock()
+		if left >= 0 {
+			return nil
+		}
+		once.Do(func() { close(blocked) })
+		<-release
+		return glaciertest.Unavailable()
+	})
+
+	cmd, output := c.command("nuke", "--yes", "--vault", "vault")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-blocked:
+	case <-time.After(30 * time.Second):
+		cmd.Process.Kill()
+		t.Fatalf("the run never got to 30 deletions\n%s", output)
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Wait()
+	fake.Intercept(glaciertest.OpDeleteArchive, nil)
+	close(release)
+
+	if left := len(fake.Archives("vault")); left != 70 {
+		t.Fatalf("%d archives are left after the kill, want 70", left)
+	}
+
+	// The killed run's lock is found stale rather than held.
+	resumed, err := c.run("resume")
+	if err == nil || !strings.Contains(resumed, "--force-unlock") {
+		t.Fatalf("resume didn't report the killed run's lock: %v\n%s", err, resumed)
+	}
+	resumed, err = c.run("resume", "--force-unlock", "--retry-failed")
+	if err != nil {
+		t.Fatalf("resume failed: %v\n%s", err, resumed)
+	}
+
+	if left := fake.Archives("vault"); len(left) != 0 {
+		t.Errorf("%d archives are left after resuming\n%s", len(left), resumed)
+	}
+	// The resume picks up the killed run's inventory job rather than
+	// waiting for another.
+	if n := fake.Count(glaciertest.OpInitiateJob); n != 1 {
+		t.Errorf("%d inventory jobs were initiated, want 1", n)
+	}
+	for id, n := range archiveIds(fake) {
+		if n != 1 {
+			t.Errorf("archive %s was deleted %d times", id, n)
+		}
+	}
+}
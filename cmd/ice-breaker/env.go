@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const envPrefix = "ICEBREAKER_"
+
+// envName maps a flag name to its environment variable: --fail-fast is
+// ICEBREAKER_FAIL_FAST.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// awsEnv lists the standard AWS variables honored when nothing else sets the
+// flag, in order of preference.
+var awsEnv = map[string][]string{
+	"id":            {"AWS_ACCESS_KEY_ID"},
+	"secret":        {"AWS_SECRET_ACCESS_KEY"},
+	"session-token": {"AWS_SESSION_TOKEN"},
+	"region":        {"AWS_REGION", "AWS_DEFAULT_REGION"},
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// parseBool accepts the usual spellings of a boolean environment variable.
+func parseBool(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "t", "true", "y", "yes", "on":
+		return "true", true
+	case "0", "f", "false", "n", "no", "off", "":
+		return "false", true
+	}
+	return "", false
+}
+
+func setFromEnv(f *flag.Flag, name, value string) error {
+	if isBoolFlag(f) {
+		parsed, ok := parseBool(value)
+		if !ok {
+			return fmt.Errorf("invalid value %q for %s: must be true or false", value, name)
+		}
+		value = parsed
+	}
+	if err := f.Value.Set(value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+	}
+	return nil
+}
+
+// applyEnv sets every flag the command line left alone from its ICEBREAKER_
+// variable, if one is set.
+func (o *globalOptions) applyEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if err != nil || !ok || o.sources[f.Name] != "" {
+			return
+		}
+		if err = setFromEnv(f, name, value); err == nil {
+			o.sources[f.Name] = "env"
+		}
+	})
+	return err
+}
+
+// applyAWSEnv falls back to the standard AWS variables for the credentials and
+// region, which as environment variables beat the config file, so it runs
+// after applyConfig and replaces what the file set. The region is only used
+// when no other region selection was made, in the file or otherwise, since
+// otherwise it would conflict with --regions or replace the sweep the user
+// asked for.
+func (o *globalOptions) applyAWSEnv(fs *flag.FlagSet) error {
+	for _, key := range []string{"id", "secret", "session-token", "region"} {
+		if source := o.sources[key]; source != "" && source != "file" {
+			continue
+		}
+		if key == "region" && (len(o.selection.Positional) > 0 || o.sources["regions"] != "" || o.sources["all-regions"] != "") {
+			continue
+		}
+
+		for _, name := range awsEnv[key] {
+			if value := os.Getenv(name); value != "" {
+				if key == "region" {
+					// -region accumulates; the file's mustn't be kept too.
+					o.selection.Region = nil
+				}
+				if err := setFromEnv(fs.Lookup(key), name, value); err != nil {
+					return err
+				}
+				o.sources[key] = "env (" + name + ")"
+				break
+			}
+		}
+	}
+	return nil
+}
+
+const precedenceHelp = `
+Every flag can also be set with an ICEBREAKER_ environment variable named
+after it (--fail-fast is ICEBREAKER_FAIL_FAST) or by name in the config file.
+A flag on the command line beats the environment, which beats the config file.
+AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, and AWS_REGION
+(or AWS_DEFAULT_REGION) set -id, -secret, -session-token, and -region unless
+a flag or ICEBREAKER_ variable does, and beat the config file as ICEBREAKER_
+variables do. A region from AWS_REGION is only used when -regions,
+-all-regions, and region arguments aren't given, the first two in any of those
+ways, and is then the only one scanned; with no region configured at all,
+every region enabled for the account is.
+`
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/rdegges/ice-breaker/internal/events"
+	"github.com/rdegges/ice-breaker/internal/redact"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// eventsBuffer is how many events the --events-file writer may fall behind
+// by before it starts missing some.
+const eventsBuffer = 4096
+
+// writeEvents writes the run's events to --events-file as JSON lines while
+// vaults are purged. The returned function stops it once every event so far
+// is written.
+func (o *globalOptions) writeEvents() (stop func()) {
+	f := o.eventsFile
+	if f == nil {
+		return func() {}
+	}
+	o.events = events.NewBus()
+	sub := o.events.Subscribe(eventsBuffer)
+	written := make(chan error, 1)
+	go func() {
+		written <- events.WriteJSON(sub, redact.NewWriter(f))
+	}()
+
+	return func() {
+		o.events.Close()
+		err := <-written
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			ui.Printf("%sFailed to write the events to %s: %v%s\n", ui.Yellow, o.eventsPath, err, ui.Reset)
+		}
+		if dropped := sub.Dropped(); dropped > 0 {
+			ui.Printf("%s%d event(s) were left out of %s, which couldn't be written fast enough.%s\n", ui.Yellow, dropped, o.eventsPath, ui.Reset)
+		}
+	}
+}
@@ -0,0 +1,707 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/audit"
+	"github.com/rdegges/ice-breaker/internal/events"
+	"github.com/rdegges/ice-breaker/internal/redact"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/state"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// stdin is shared by every prompt: a Prompter keeps reading ahead, so a second
+// one on the same input would miss lines.
+var stdin = ui.NewPrompter(os.Stdin)
+
+// positionalArgs names the one positional argument of the commands taking
+// one, for their usage line.
+var positionalArgs = map[string]string{
+	"apply":            " PLAN",
+	"verify-audit-log": " PATH",
+}
+
+// regionArgs are the commands that scan regions, which take the names of the
+// regions to scan as arguments.
+var regionArgs = map[string]bool{
+	"doctor":       true,
+	"list":         true,
+	"list-vaults":  true,
+	"inventory":    true,
+	"plan":         true,
+	"purge":        true,
+	"delete-vault": true,
+	"nuke":         true,
+}
+
+// globalOptions are the flags shared by every subcommand: credentials,
+// endpoints, region selection, state, and output.
+type globalOptions struct {
+	settings          glacierpurge.ClientSettings
+	promptCredentials bool
+	profile           string
+	selection         regionSelection
+	timeout           time.Duration
+	stateDir          string
+	stateURI          string
+	backend           state.Backend // where the state is kept, once asked for
+	output            string
+	listRegions       bool
+	noColor           bool
+	configPath        string
+	command           string
+	arg               string     // the positional argument, for the commands taking one
+	vaults            stringList // --vault's names, for the commands taking it
+	auditPath         string
+	journal           *audit.Log
+	eventsPath        string
+	eventsFile        *os.File    // opened by validate
+	events            *events.Bus // of the vaults being purged, once written
+	forceUnlock       bool
+	maxRate           float64
+	limiter           *glacierpurge.Limiter   // shared by every registry, once created
+	meter             *glacierpurge.Meter     // shared by every registry, once created
+	progress          *run.Progress           // of the vaults being purged, once watched
+	pause             *glacierpurge.Pause     // shared by every registry, once created
+	wrapUp            *glacierpurge.WrapUp    // shared by every registry, once created
+	transport         *glacierpurge.Transport // shared by every registry, once created
+	readOnly          bool
+	allowJobs         bool
+	guard             *glacierpurge.Guard // shared by every registry, with --read-only
+	recordDir         string
+	replayDir         string
+	recorder          *glacierpurge.Recorder // opened by validate, with --record
+	replay            *glacierpurge.Replay   // opened by validate, with --replay
+
+	artifactURI      string
+	artifactRegion   string
+	artifactSSE      string
+	artifactKMSKey   string
+	artifactUploader *glacierpurge.Uploader // once the first artifact is uploaded
+	artifactRun      string                 // the key prefix of the run's artifacts
+	artifactUploads  []artifactUpload
+	connections      int // per endpoint for the transport to keep open, if known
+	pprofAddr        string
+	debugAWS         bool
+	debugBodies      bool
+	servers          []*backgroundServer // running alongside the command
+	lock             *state.Lock         // held once openState has been called
+
+	// stopMu guards lock, which the signal handler releases, and cancelRun,
+	// the run context's cancel while context's caller has it.
+	stopMu    sync.Mutex
+	cancelRun context.CancelFunc
+
+	// sources records where each flag's value came from, for config show.
+	sources map[string]string
+}
+
+func newGlobalOptions(fs *flag.FlagSet) *globalOptions {
+	o := &globalOptions{command: fs.Name()}
+	o.settings.AppID = appID()
+	fs.StringVar(&o.settings.AccessKeyID, "id", "", "AWS Access Key ID")
+	fs.StringVar(&o.settings.SecretAccessKey, "secret", "", "AWS Secret Access Key")
+	fs.StringVar(&o.settings.SessionToken, "session-token", "", "AWS session token, for temporary credentials")
+	fs.StringVar(&o.profile, "profile", "", "Take the AWS credentials from this profile of the shared config and credentials files, rather than AWS_PROFILE's or the default one, when no keys are given")
+	fs.BoolVar(&o.promptCredentials, "prompt-credentials", false, "Ask for the AWS credentials on the terminal, the secret ones without echoing them, rather than taking them from flags that shell history and ps would show")
+	fs.BoolVar(&o.settings.UseFIPS, "fips", false, "Use FIPS endpoints for every AWS API call")
+	fs.BoolVar(&o.settings.UseDualStack, "dualstack", false, "Use dual-stack (IPv6) endpoints for every AWS API call")
+	fs.StringVar(&o.settings.EndpointURL, "endpoint-url", "", "Send Glacier requests to this URL instead of AWS (e.g. a local emulator)")
+	fs.BoolVar(&ui.Verbose, "verbose", false, "Log debugging details")
+	fs.BoolVar(&o.noColor, "no-color", false, "Write messages without color, as they are anyway when they don't go to a terminal or NO_COLOR is set")
+	fs.BoolVar(&o.debugAWS, "debug-aws", false, "Log every AWS request and response, less their bodies, and every retry, as the SDK sees them")
+	fs.BoolVar(&o.debugBodies, "debug-aws-bodies", false, "Like --debug-aws, but log the bodies too, job output included, which can be huge")
+	fs.Var(&o.selection.Region, "region", "AWS Region (may be repeated; scanning commands also take region names as arguments)")
+	fs.Var(&o.selection.Regions, "regions", "Comma-separated list of AWS Regions to scan (may be repeated)")
+	fs.Var(&o.selection.ExcludeRegions, "exclude-regions", "Comma-separated list of AWS Regions to skip (may be repeated)")
+	fs.BoolVar(&o.selection.AllRegions, "all-regions", false, "Scan every Glacier region instead of only those enabled for the account")
+	fs.BoolVar(&o.selection.IncludeGov, "include-gov", false, "Also scan the AWS GovCloud (US) regions")
+	fs.BoolVar(&o.selection.IncludeChina, "include-china", false, "Also scan the AWS China regions")
+	fs.BoolVar(&o.listRegions, "list-regions", false, "Print the regions that would be scanned and exit")
+	fs.DurationVar(&o.timeout, "timeout", 0, "Give up on the whole run after this long (e.g. 12h); 0 means no limit")
+	fs.DurationVar(&stdin.Timeout, "prompt-timeout", 0, "Answer no to any question left unanswered this long (e.g. 60s), counting down on a terminal until an answer is entered; 0 waits forever")
+	fs.BoolVar(&stdin.NoInput, "no-input", false, "Fail instead of asking any question, for unattended runs")
+	fs.BoolVar(&o.readOnly, "read-only", false, "Refuse, beneath everything else, every Glacier call that could change anything, logging and counting each one; listings and reports still run")
+	fs.BoolVar(&o.allowJobs, "read-only-jobs", true, "With --read-only, still let inventory and retrieval jobs be started, which change nothing in a vault")
+	fs.Float64Var(&o.maxRate, "max-request-rate", 25, "Most AWS requests per second, to Glacier and S3, across every region and vault; lowered automatically while AWS throttles. 0 means no limit")
+	fs.StringVar(&o.pprofAddr, "pprof-addr", "", "Serve net/http/pprof profiles on this address (e.g. localhost:6060) while the command runs")
+	fs.StringVar(&o.auditPath, "audit-log", "", "Append a hash-chained JSON line to this file for every archive and vault deletion")
+	fs.StringVar(&o.eventsPath, "events-file", "", "Append a JSON line to this file for each vault taken on, inventory job, archive deleted or failed, and vault deleted")
+	fs.StringVar(&o.recordDir, "record", "", "Record every Glacier call and its response into this directory, credentials left out, for the run to be replayed with --replay")
+	fs.StringVar(&o.replayDir, "replay", "", "Run again offline, answering every Glacier call from the recording in this directory")
+	fs.StringVar(&o.artifactURI, "artifact-s3-uri", "", "Upload the run's files, such as the audit log and saved inventories, under this S3 location (s3://bucket/prefix/)")
+	fs.StringVar(&o.artifactRegion, "artifact-s3-region", "", "Region of the --artifact-s3-uri bucket; found by asking S3 if not given")
+	fs.StringVar(&o.artifactSSE, "artifact-sse", "", "Server-side encryption for the uploaded artifacts: AES256, aws:kms, or aws:kms:dsse; the bucket's default if not given")
+	fs.StringVar(&o.artifactKMSKey, "artifact-sse-kms-key-id", "", "KMS key to encrypt the uploaded artifacts with, for --artifact-sse aws:kms or aws:kms:dsse")
+	fs.StringVar(&o.stateDir, "state-dir", state.DefaultDir(), "Directory holding the resume state")
+	fs.StringVar(&o.stateURI, "state-backend", "", "Keep the resume state and its lock under this S3 location (s3://bucket/prefix/) instead of --state-dir, to resume from any machine")
+	fs.BoolVar(&o.forceUnlock, "force-unlock", false, "Break the state directory's lock left by a run that's no longer running (dangerous if it still is)")
+	fs.StringVar(&o.output, "output", "text", "Output format for listings: text or json (some commands also take csv)")
+	fs.StringVar(&o.configPath, "config", defaultConfigPath(), "Configuration file; any flag can be set in it by name")
+	fs.Usage = func() {
+		args := positionalArgs[fs.Name()]
+		if regionArgs[fs.Name()] {
+			args += " [REGION...]"
+		}
+		fmt.Fprintf(fs.Output(), "Usage: ice-breaker %s [flags]%s\n\n", fs.Name(), args)
+		fs.PrintDefaults()
+		fmt.Fprint(fs.Output(), precedenceHelp)
+	}
+	return o
+}
+
+// parse parses args into fs, then fills in anything they didn't set from the
+// environment and then the configuration file.
+func (o *globalOptions) parse(fs *flag.FlagSet, args []string) error {
+	// Collected before parsing: registering the other commands' flags resets
+	// the package-level ones, such as --verbose, to their defaults.
+	known := configKeys()
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, ok := positionalArgs[fs.Name()]; ok && fs.NArg() > 0 {
+		// Flags may follow the argument too.
+		o.arg = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+	}
+	for regionArgs[fs.Name()] && fs.NArg() > 0 {
+		o.selection.Positional = append(o.selection.Positional, fs.Arg(0))
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	o.sources = make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		o.sources[f.Name] = "flag"
+	})
+
+	if err := o.applyEnv(fs); err != nil {
+		return err
+	}
+	if err := o.applyConfig(fs, known); err != nil {
+		return err
+	}
+	if err := o.applyAWSEnv(fs); err != nil {
+		return err
+	}
+	if f := fs.Lookup("vault"); f != nil {
+		o.vaults.Set(f.Value.String())
+	}
+	redact.Add(o.settings.SecretAccessKey, o.settings.SessionToken)
+	o.settings.Redact = redact.String
+	return nil
+}
+
+// validate checks the options that don't need any AWS calls, reporting every
+// problem at once. Commands that can write more than text and json pass the
+// --output formats they support.
+func (o *globalOptions) validate(formats ...string) error {
+	var problems problems
+	if o.replayDir != "" && o.recordDir != "" {
+		problems.add(errors.New("--record and --replay can't be used together"))
+	}
+	if (o.settings.AccessKeyID == "") != (o.settings.SecretAccessKey == "") {
+		problems.add(errors.New("an AWS Access Key ID and Secret Access Key go together: give both, or neither to take the credentials from the AWS configuration, as --profile or AWS_PROFILE says, or the instance's role"))
+	}
+	if o.profile != "" && (o.settings.AccessKeyID != "" || o.promptCredentials) {
+		problems.add(errors.New("--profile can't be combined with credentials given as keys or with --prompt-credentials"))
+	}
+	if o.promptCredentials && stdin.NoInput {
+		problems.add(errors.New("--prompt-credentials needs to ask for them, which --no-input rules out; set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY instead"))
+	}
+
+	// Checked before any AWS call, so a typo fails at once rather than as an
+	// endpoint error after a long pause.
+	if _, err := validateRegions(o.selection.Region); err != nil {
+		if source := o.sources["region"]; strings.HasPrefix(source, "env (") {
+			problems.add(fmt.Errorf("invalid region from %s: %w", strings.TrimSuffix(strings.TrimPrefix(source, "env ("), ")"), err))
+		} else {
+			problems.add(fmt.Errorf("invalid -region: %w", err))
+		}
+	}
+	// A name no vault can have would otherwise just match nothing, which
+	// looks the same as the vault being gone.
+	for _, name := range o.vaults {
+		if strings.ContainsAny(name, "*?[\\") {
+			if _, err := path.Match(name, ""); err != nil {
+				problems.add(fmt.Errorf("invalid --vault pattern %q: %w", name, err))
+			}
+		} else if err := glacierpurge.ValidateVaultName(name); err != nil {
+			problems.add(fmt.Errorf("invalid --vault: %w", err))
+		}
+	}
+	if _, err := validateRegions(o.selection.Positional); err != nil {
+		problems.add(err)
+	}
+	if _, err := validateRegions(o.selection.Regions); err != nil {
+		problems.add(fmt.Errorf("invalid --regions: %w", err))
+	}
+	if _, err := validateRegions(o.selection.ExcludeRegions); err != nil {
+		problems.add(fmt.Errorf("invalid --exclude-regions: %w", err))
+	}
+	if o.listRegions && !regionArgs[o.command] {
+		problems.add(fmt.Errorf("--list-regions only applies to the commands that scan regions; %s doesn't", o.command))
+	}
+
+	if o.settings.EndpointURL != "" {
+		problems.add(glacierpurge.ValidateEndpointURL(o.settings.EndpointURL))
+		// The SDK refuses either alongside a custom endpoint, but only once
+		// the first request is made.
+		if o.settings.UseFIPS {
+			problems.add(errors.New("--fips can't be used with --endpoint-url, which sends every request to that URL; drop one of them"))
+		}
+		if o.settings.UseDualStack {
+			problems.add(errors.New("--dualstack can't be used with --endpoint-url, which sends every request to that URL; drop one of them"))
+		}
+	}
+
+	if o.artifactURI != "" {
+		_, err := glacierpurge.ParseS3URI(o.artifactURI)
+		problems.add(err)
+	}
+	if o.stateURI != "" {
+		if _, err := glacierpurge.ParseS3URI(o.stateURI); err != nil {
+			problems.add(fmt.Errorf("invalid --state-backend: %w", err))
+		}
+		if o.replayDir != "" {
+			problems.add(errors.New("--state-backend can't be used with --replay, which runs offline; use --state-dir"))
+		}
+	}
+	switch o.artifactSSE {
+	case "", "AES256", "aws:kms", "aws:kms:dsse":
+	default:
+		problems.add(fmt.Errorf("invalid --artifact-sse %q: must be AES256, aws:kms, or aws:kms:dsse", o.artifactSSE))
+	}
+	if o.artifactKMSKey != "" && o.artifactSSE != "aws:kms" && o.artifactSSE != "aws:kms:dsse" {
+		problems.add(errors.New("--artifact-sse-kms-key-id needs --artifact-sse aws:kms or aws:kms:dsse"))
+	}
+	if o.artifactRegion != "" {
+		if _, err := validateRegions([]string{o.artifactRegion}); err != nil {
+			problems.add(fmt.Errorf("invalid --artifact-s3-region: %w", err))
+		}
+	}
+
+	problems.add(nonNegative("--timeout", o.timeout))
+	problems.add(nonNegative("--prompt-timeout", stdin.Timeout))
+	if o.maxRate < 0 {
+		problems.add(errors.New("--max-request-rate can't be negative; use 0 for no limit"))
+	}
+
+	if len(formats) == 0 {
+		formats = []string{"text", "json"}
+	}
+	if !slices.Contains(formats, o.output) {
+		problems.add(fmt.Errorf("invalid --output %q: must be one of %s", o.output, strings.Join(formats, ", ")))
+	}
+	if err := problems.err(); err != nil {
+		return err
+	}
+
+	if o.output != "text" {
+		// Keep stdout clean for the machine-readable output.
+		ui.Messages = os.Stderr
+	}
+	ui.DetectColors()
+	if o.noColor {
+		ui.Colors = false
+	}
+	if o.settings.Credentials == nil {
+		if o.promptCredentials && o.replayDir == "" {
+			if err := o.askCredentials(); err != nil {
+				return err
+			}
+		}
+		warnSecretFlags(o.sources)
+		if o.settings.AccessKeyID != "" || o.replayDir != "" {
+			o.settings.Credentials = glacierpurge.NewCredentials(credentials.NewStaticCredentialsProvider(o.settings.AccessKeyID, o.settings.SecretAccessKey, o.settings.SessionToken))
+		} else {
+			// SSO, an assumed role, or the instance's role, which the SDK
+			// refreshes as they expire.
+			creds, err := glacierpurge.NewDefaultCredentials(context.Background(), o.profile)
+			if err != nil {
+				return err
+			}
+			o.settings.Credentials = creds
+		}
+		o.settings.Credentials.Renew = renewCredentials
+	}
+	o.applyDebugAWS()
+
+	if o.auditPath != "" {
+		journal, err := audit.Open(o.auditPath)
+		if err != nil {
+			return err
+		}
+		journal.Version = buildVersion()
+		o.journal = journal
+	}
+	if o.eventsPath != "" {
+		f, err := os.OpenFile(o.eventsPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open --events-file: %w", err)
+		}
+		o.eventsFile = f
+	}
+	if o.recordDir != "" {
+		recorder, err := glacierpurge.NewRecorder(o.recordDir, o.command)
+		if err != nil {
+			return err
+		}
+		recorder.Redact = redact.String
+		o.recorder = recorder
+	}
+	if o.replayDir != "" {
+		replay, err := glacierpurge.OpenReplay(o.replayDir)
+		if err != nil {
+			return err
+		}
+		if replay.Recording.Command != o.command {
+			return fmt.Errorf("%s is a recording of %s, not %s", o.replayDir, replay.Recording.Command, o.command)
+		}
+		o.replay = replay
+	}
+
+	return nil
+}
+
+// applyDebugAWS turns on the SDK's logging with --debug-aws, whatever
+// --verbose says.
+func (o *globalOptions) applyDebugAWS() {
+	switch {
+	case o.debugBodies:
+		o.settings.LogAWS = aws.LogRetries | aws.LogRequestWithBody | aws.LogResponseWithBody
+	case o.debugAWS:
+		o.settings.LogAWS = aws.LogRetries | aws.LogRequest | aws.LogResponse
+	default:
+		return
+	}
+	o.settings.AWSLogger = log.New(ui.MessageWriter, "", log.LstdFlags)
+}
+
+// context returns the run context, which the first of stopSignals cancels so
+// in-flight work can stop cleanly.
+func (o *globalOptions) context() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	o.stopMu.Lock()
+	o.cancelRun = cancel
+	o.stopMu.Unlock()
+	stop := func() {
+		o.stopMu.Lock()
+		o.cancelRun = nil
+		o.stopMu.Unlock()
+		cancel()
+	}
+
+	if o.timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancelTimeout := context.WithTimeout(ctx, o.timeout)
+	return ctx, func() {
+		cancelTimeout()
+		stop()
+	}
+}
+
+// handleSignals handles stopSignals for the rest of the process's life, so
+// none of them can kill it while it holds the state lock. The first cancels
+// the run context, if there is one, and the run stops once the work in
+// flight does. Another, or one with no run to cancel, releases the lock and
+// exits at once.
+func (o *globalOptions) handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, stopSignals...)
+	go func() {
+		for sig := range signals {
+			o.stopMu.Lock()
+			cancel := o.cancelRun
+			o.cancelRun = nil
+			o.stopMu.Unlock()
+			if cancel != nil {
+				ui.Printf("%sStopping once the work in flight does; interrupt again to stop at once.%s\n", ui.Yellow, ui.Reset)
+				cancel()
+				continue
+			}
+
+			o.releaseState()
+			fmt.Fprintf(stderr, "Stopped by %v.\n", sig)
+			status := 1
+			if number, ok := sig.(syscall.Signal); ok {
+				status = 128 + int(number)
+			}
+			os.Exit(status)
+		}
+	}()
+}
+
+// explainRegions says where the region selection came from when it wasn't
+// spelled out with flags, since an exported AWS_REGION quietly narrowing the
+// scan, or its absence widening it, is easy to miss.
+func (o *globalOptions) explainRegions() {
+	source := o.sources["region"]
+	switch {
+	case strings.HasPrefix(source, "env ("):
+		name := strings.TrimSuffix(strings.TrimPrefix(source, "env ("), ")")
+		ui.Printf("Using region %s from %s; pass --all-regions or --regions to scan more.\n", o.selection.Region.String(), name)
+	case source == "" && len(o.selection.Positional) == 0 && o.sources["regions"] == "" && o.sources["all-regions"] == "":
+		ui.Println("No region is configured (--region, --regions, AWS_REGION, or AWS_DEFAULT_REGION), so every region enabled for the account is scanned.")
+	}
+}
+
+// regions resolves the regions to scan and announces them. With
+// --list-regions it prints them instead and reports done.
+func (o *globalOptions) regions(ctx context.Context) (regions []string, done bool, err error) {
+	if o.replay != nil && len(o.replay.Recording.Regions) > 0 {
+		// Found without asking AWS, as the recording has them.
+		regions = o.replay.Recording.Regions
+	} else if regions, err = o.selection.resolve(ctx, &o.settings); err != nil {
+		return nil, false, err
+	}
+	if o.recorder != nil {
+		if err := o.recorder.SetRegions(regions); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if o.listRegions {
+		for _, region := range regions {
+			fmt.Fprintln(stdout, region)
+		}
+		return regions, true, nil
+	}
+
+	o.explainRegions()
+	ui.Printf("Scanning %d region(s): %s\n", len(regions), strings.Join(regions, ", "))
+
+	if o.settings.UseFIPS {
+		ui.Println("FIPS endpoints are in effect for all AWS API calls")
+	}
+	if o.settings.UseDualStack {
+		ui.Println("Dual-stack endpoints are in effect for all AWS API calls")
+	}
+	if o.settings.EndpointURL != "" {
+		ui.Printf("%sSending all Glacier requests to %s instead of AWS%s\n", ui.Yellow, o.settings.EndpointURL, ui.Reset)
+	}
+
+	return regions, false, nil
+}
+
+// registry returns a registry creating clients from the options, plus any
+// extra ones such as glacierpurge.WithReadOnly.
+func (o *globalOptions) registry(extra ...glacierpurge.Option) *glacierpurge.Registry {
+	options := []glacierpurge.Option{
+		glacierpurge.WithSettings(&o.settings),
+		glacierpurge.WithLogger(messageLogger{log.New(ui.MessageWriter, "", log.LstdFlags)}),
+	}
+	if o.journal != nil {
+		options = append(options, glacierpurge.WithJournal(o.journal))
+	}
+	if o.meter == nil {
+		o.meter = glacierpurge.NewMeter()
+	}
+	if o.pause == nil {
+		o.pause = glacierpurge.NewPause()
+	}
+	if o.wrapUp == nil {
+		o.wrapUp = glacierpurge.NewWrapUp()
+	}
+	if o.transport == nil {
+		o.transport = glacierpurge.NewTransport(o.connections)
+	}
+	options = append(options, glacierpurge.WithMeter(o.meter), glacierpurge.WithPause(o.pause), glacierpurge.WithWrapUp(o.wrapUp), glacierpurge.WithTransport(o.transport))
+	if o.recorder != nil {
+		options = append(options, glacierpurge.WithRecorder(o.recorder))
+	}
+	if o.replay != nil {
+		options = append(options, glacierpurge.WithReplay(o.replay))
+	}
+	if o.readOnly {
+		if o.guard == nil {
+			o.guard = glacierpurge.NewGuard(o.allowJobs, messageLogger{log.New(ui.MessageWriter, "", log.LstdFlags)})
+		}
+		options = append(options, glacierpurge.WithGuard(o.guard))
+	}
+	if limiter := o.sharedLimiter(); limiter != nil {
+		options = append(options, glacierpurge.WithLimiter(limiter))
+	}
+	return &glacierpurge.Registry{Options: append(options, extra...)}
+}
+
+// sharedLimiter returns the limiter every client and uploader shares with
+// --max-request-rate, creating it on first use, or nil without it.
+func (o *globalOptions) sharedLimiter() *glacierpurge.Limiter {
+	if o.maxRate > 0 && o.limiter == nil {
+		o.limiter = glacierpurge.NewLimiter(o.maxRate)
+	}
+	return o.limiter
+}
+
+// sizeConnections keeps a connection to each endpoint open for every deletion
+// the run may have in flight at once, and a few more for everything else.
+// It must be called before the first registry is created.
+func (o *globalOptions) sizeConnections(opts run.Options) {
+	workers := opts.WorkersPerVault
+	if opts.AdaptiveWorkers {
+		// The most DeleteOptions.Adaptive lets through by default.
+		workers = 32
+	}
+	o.connections = workers*max(opts.MaxConcurrentVaults, 1) + 4
+}
+
+// logTransport logs, with --verbose, how well the run's connections were
+// reused.
+func (o *globalOptions) logTransport() {
+	if o.transport == nil {
+		return
+	}
+	stats := o.transport.Stats()
+	ui.Debugf("HTTP: %d request(s) over %d connection(s); %d request(s) reused one", stats.Requests, stats.Opened, stats.Reused)
+}
+
+// closeRecording finishes a --record recording, and says how much of a
+// --replay one went unused.
+func (o *globalOptions) closeRecording() {
+	if o.recorder != nil {
+		if err := o.recorder.Close(); err != nil {
+			fmt.Fprintf(stderr, "The recording in %s is incomplete: %v\n", o.recordDir, err)
+		} else {
+			fmt.Fprintf(stderr, "Recorded the run's Glacier calls in %s\n", o.recordDir)
+		}
+	}
+	if o.replay != nil {
+		if unused := o.replay.Unused(); unused > 0 {
+			fmt.Fprintf(stderr, "Replay: %d recorded call(s) weren't made this time\n", unused)
+		}
+	}
+}
+
+// reportGuard says, with --read-only, how many calls were refused.
+func (o *globalOptions) reportGuard() {
+	if o.guard == nil {
+		return
+	}
+	if summary := o.guard.Summary(); summary != "" {
+		fmt.Fprintf(stderr, "Read-only: refused %s\n", summary)
+	} else {
+		fmt.Fprintln(stderr, "Read-only: no calls needed refusing")
+	}
+}
+
+// messageLogger logs the Glacier clients' progress, and with --verbose their
+// debugging details too.
+type messageLogger struct {
+	*log.Logger
+}
+
+func (l messageLogger) Debugf(format string, args ...any) {
+	if ui.Verbose {
+		l.Printf(format, args...)
+	}
+}
+
+// stateBackend returns where the state is kept: under --state-backend's S3
+// location, or else in --state-dir.
+func (o *globalOptions) stateBackend() state.Backend {
+	if o.backend == nil {
+		if o.stateURI == "" {
+			o.backend = state.NewFileBackend(o.stateDir)
+		} else {
+			location, _ := glacierpurge.ParseS3URI(o.stateURI) // checked by validate
+			objects := glacierpurge.NewUploader(&o.settings, location, "", glacierpurge.S3UploadOptions{})
+			objects.Limiter = o.sharedLimiter()
+			o.backend = state.NewS3Backend(objects)
+		}
+	}
+	return o.backend
+}
+
+// openState locks the state directory for the rest of the run, then loads
+// it. main releases the lock.
+func (o *globalOptions) openState() (*state.Store, error) {
+	if o.lock == nil {
+		if o.forceUnlock {
+			ui.Printf("%s%sBreaking the lock on %s. If another ice-breaker run is still using it, both runs will overwrite each other's state and may delete archives twice.%s\n", ui.Red, ui.Bold, o.stateBackend(), ui.Reset)
+		}
+		lock, err := o.stateBackend().Lock(o.forceUnlock)
+		var locked *state.LockedError
+		if errors.As(err, &locked) && locked.Stale {
+			return nil, fmt.Errorf("%w; rerun with --force-unlock to break it", err)
+		}
+		if errors.As(err, &locked) {
+			return nil, fmt.Errorf("%w; wait for it to finish, or if it's no longer running, rerun with --force-unlock", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		o.stopMu.Lock()
+		o.lock = lock
+		o.stopMu.Unlock()
+	}
+	return o.loadState()
+}
+
+// loadState loads the state directory, warning about any file in it that had
+// to be read from its previous generation.
+func (o *globalOptions) loadState() (*state.Store, error) {
+	store, err := state.Open(o.stateBackend())
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range store.Warnings {
+		ui.Printf("%s%s.%s\n", ui.Yellow, warning, ui.Reset)
+	}
+	return store, nil
+}
+
+// releaseState releases the state directory's lock, if the run took it.
+func (o *globalOptions) releaseState() {
+	o.stopMu.Lock()
+	defer o.stopMu.Unlock()
+	if o.lock == nil {
+		return
+	}
+	if err := o.lock.Release(); err != nil {
+		ui.Printf("%s%v%s\n", ui.Yellow, err, ui.Reset)
+	}
+	o.lock = nil
+}
+
+// stringList is a flag.Value that accumulates comma-separated values across
+// repeated uses of the flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
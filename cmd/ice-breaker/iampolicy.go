@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/plan"
+)
+
+// commandActions are the groups of IAM actions each command needs, whatever
+// its flags; iamActions adds those its flags call for.
+var commandActions = map[string][][]string{
+	"doctor":             {glacierpurge.ActionsToList, glacierpurge.ActionsToReadTags, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToSimulate},
+	"list":               {glacierpurge.ActionsToList, glacierpurge.ActionsToReadTags},
+	"list-vaults":        {glacierpurge.ActionsToList},
+	"list-archives":      {glacierpurge.ActionsToList, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve},
+	"download-inventory": {glacierpurge.ActionsToList, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve},
+	"inventory":          {glacierpurge.ActionsToList, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve},
+	"status":             {glacierpurge.ActionsToWatchJobs},
+	"wait":               {glacierpurge.ActionsToWatchJobs},
+	"purge":              {glacierpurge.ActionsToList, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve, glacierpurge.ActionsToDeleteArchives},
+	"plan":               {glacierpurge.ActionsToList},
+	"apply":              {glacierpurge.ActionsToList, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve, glacierpurge.ActionsToDeleteArchives},
+	"resume":             {glacierpurge.ActionsToList, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve, glacierpurge.ActionsToDeleteArchives},
+	"purge-vault":        {glacierpurge.ActionsToList, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve, glacierpurge.ActionsToDeleteArchives, glacierpurge.ActionsToDeleteVaults},
+	"delete-vault":       {glacierpurge.ActionsToList, glacierpurge.ActionsToDeleteVaults},
+	"nuke":               {glacierpurge.ActionsToList, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve, glacierpurge.ActionsToDeleteArchives, glacierpurge.ActionsToDeleteVaults},
+	"download-archive":   {glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve},
+	"delete-archive":     {glacierpurge.ActionsToDeleteArchives},
+	"verify-audit-log":   {},
+}
+
+// stateCommands keep state, in --state-dir or under --state-backend.
+var stateCommands = map[string]bool{
+	"list-archives": true, "inventory": true, "status": true, "wait": true, "purge": true, "plan": true,
+	"apply": true, "resume": true, "purge-vault": true, "delete-vault": true, "nuke": true,
+}
+
+// unscopedActions have no vault to scope them to.
+var unscopedActions = joinActions([]string{"glacier:ListVaults"}, glacierpurge.ActionsToFindRegions, glacierpurge.ActionsToSimulate)
+
+// policyDocument is an IAM policy.
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Sid      string   `json:"Sid"`
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+// runPrintIAMPolicy handles 'print-iam-policy <command> [flags]', printing
+// the least IAM policy that lets the command run with those flags, scoped to
+// the vaults they name where they name any.
+func runPrintIAMPolicy(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("usage: ice-breaker print-iam-policy <command> [flags]")
+	}
+	c := lookupCommand(args[0])
+	if c == nil {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if _, ok := commandActions[c.name]; !ok {
+		return fmt.Errorf("the actions %s needs aren't known", c.name)
+	}
+
+	// Named after the command, so its arguments are parsed as it would parse
+	// them.
+	fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+	o := newGlobalOptions(fs)
+	c.flags(fs)
+	if err := o.parse(fs, args[1:]); err != nil {
+		return err
+	}
+
+	policy, err := iamPolicy(fs, o)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(policy)
+}
+
+// iamPolicy builds the policy for the command fs was parsed for.
+func iamPolicy(fs *flag.FlagSet, o *globalOptions) (*policyDocument, error) {
+	var p *plan.Plan
+	if o.command == "apply" {
+		if o.arg == "" {
+			return nil, errors.New("apply's policy is scoped to the vaults in its plan, so name the plan file")
+		}
+		var err error
+		if p, err = plan.Read(o.arg); err != nil {
+			return nil, err
+		}
+	}
+
+	actions := iamActions(fs, o, p)
+	resources := vaultResources(fs, o, p)
+	var unscoped, scoped []string
+	for _, action := range actions {
+		if slices.Contains(unscopedActions, action) {
+			unscoped = append(unscoped, action)
+		} else {
+			scoped = append(scoped, action)
+		}
+	}
+
+	policy := &policyDocument{Version: "2012-10-17"}
+	if resources == nil {
+		if len(actions) > 0 {
+			policy.Statement = append(policy.Statement, policyStatement{"IceBreaker", "Allow", actions, []string{"*"}})
+		}
+	} else {
+		if len(unscoped) > 0 {
+			policy.Statement = append(policy.Statement, policyStatement{"IceBreakerList", "Allow", unscoped, []string{"*"}})
+		}
+		if len(scoped) > 0 {
+			policy.Statement = append(policy.Statement, policyStatement{"IceBreakerVaults", "Allow", scoped, resources})
+		}
+	}
+	policy.Statement = append(policy.Statement, s3Statements(o)...)
+	return policy, nil
+}
+
+// s3Statements allow the S3 calls for keeping the state under
+// --state-backend and uploading to --artifact-s3-uri, on their prefixes.
+func s3Statements(o *globalOptions) []policyStatement {
+	partition := "aws"
+	if regions := o.selection.named(); len(regions) > 0 {
+		partition = glacierpurge.PartitionOf(regions[0])
+	}
+	var statements []policyStatement
+	if location, err := glacierpurge.ParseS3URI(o.stateURI); err == nil && stateCommands[o.command] {
+		statements = append(statements, policyStatement{"IceBreakerState", "Allow", glacierpurge.ActionsToKeepObjects, location.ARNs(partition)})
+	}
+	if location, err := glacierpurge.ParseS3URI(o.artifactURI); err == nil {
+		statements = append(statements, policyStatement{"IceBreakerArtifacts", "Allow", glacierpurge.ActionsToUpload, location.ARNs(partition)[1:]})
+	}
+	return statements
+}
+
+// iamActions returns the actions the command needs with the flags it was
+// given, in the order the groups list them.
+func iamActions(fs *flag.FlagSet, o *globalOptions, p *plan.Plan) []string {
+	groups := append([][]string{}, commandActions[o.command]...)
+
+	set := func(name string) bool {
+		f := fs.Lookup(name)
+		return f != nil && f.Value.String() != "" && f.Value.String() != "false"
+	}
+	if set("tag") || set("exclude-tag") || set("find-scheduled") {
+		groups = append(groups, glacierpurge.ActionsToReadTags)
+	}
+	if set("tag-scheduled") {
+		groups = append(groups, glacierpurge.ActionsToReadTags, glacierpurge.ActionsToTag)
+	}
+	switch o.command {
+	case "plan":
+		if set("inventory") {
+			groups = append(groups, glacierpurge.ActionsToWatchJobs, glacierpurge.ActionsToRetrieve)
+		}
+	case "apply":
+		if p.DeleteVaults {
+			groups = append(groups, glacierpurge.ActionsToDeleteVaults)
+		}
+	}
+	// With no region named, they're found by asking the account.
+	if regionArgs[o.command] && len(o.selection.named()) == 0 && !o.selection.AllRegions {
+		groups = append(groups, glacierpurge.ActionsToFindRegions)
+	}
+	return joinActions(groups...)
+}
+
+// vaultResources returns the ARNs of the vaults the flags, or the plan, pick,
+// or nil if they don't narrow them down. Regions and the account not known
+// without asking AWS are left as *.
+func vaultResources(fs *flag.FlagSet, o *globalOptions, p *plan.Plan) []string {
+	if p != nil {
+		resources := make([]string, len(p.Vaults))
+		for i, v := range p.Vaults {
+			resources[i] = v.ARN
+		}
+		return resources
+	}
+
+	var names []string
+	if f := fs.Lookup("vault"); f != nil && f.Value.String() != "" {
+		names = strings.Split(f.Value.String(), ",")
+	}
+	regions := o.selection.named()
+	if len(names) == 0 && len(regions) == 0 {
+		return nil
+	}
+	for _, name := range names {
+		// IAM has no character classes, so a pattern with one can't be
+		// narrowed down.
+		if strings.ContainsAny(name, `[]\`) {
+			names = []string{"*"}
+			break
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"*"}
+	}
+
+	var resources []string
+	partitions := map[string]string{"*": "aws"}
+	if len(regions) == 0 {
+		regions = []string{"*"}
+	} else {
+		for _, region := range regions {
+			partitions[region] = glacierpurge.PartitionOf(region)
+		}
+	}
+	for _, region := range regions {
+		for _, name := range names {
+			arn := glacierpurge.VaultARN(partitions[region], region, "*", name)
+			if !slices.Contains(resources, arn) {
+				resources = append(resources, arn)
+			}
+		}
+	}
+	return resources
+}
+
+// joinActions returns the actions in the groups, each once.
+func joinActions(groups ...[]string) []string {
+	var actions []string
+	for _, group := range groups {
+		for _, action := range group {
+			if !slices.Contains(actions, action) {
+				actions = append(actions, action)
+			}
+		}
+	}
+	return actions
+}
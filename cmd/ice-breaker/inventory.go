@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// inventoryFlags registers the inventory command, which initiates the
+// inventory jobs of the vaults picked the way purge picks them, records them
+// for resume, and exits. Its clients are read-only, so it can't delete
+// anything.
+func inventoryFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	var names stringList
+	fs.Var(&names, "vault", "Only inventory the vaults with these comma-separated names (may be repeated); every vault otherwise")
+	noReuse := fs.Bool("no-reuse", false, "Initiate a fresh inventory job even for vaults that already have one in progress or completed")
+	inventory := inventoryOptionFlags(fs)
+	tags := tagFlags(fs)
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		inventoryOptions, err := inventory()
+		problems.add(err)
+		tagFilter, err := tags()
+		problems.add(err)
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		store, err := o.openState()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		regions, done, err := o.regions(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		vaults, scanned := run.Scan(ctx, o.registry(glacierpurge.WithReadOnly()), regions)
+		vaults = run.FilterVaults(vaults, names)
+		if len(vaults) == 0 && len(names) > 0 {
+			run.SummarizeRegions(scanned)
+			return errors.New("none of the named vaults were found")
+		}
+		run.Enrich(ctx, vaults)
+		vaults, _ = run.FilterTags(ctx, vaults, tagFilter)
+		run.SummarizeRegions(scanned)
+		if len(vaults) == 0 {
+			ui.Println("No vaults to inventory.")
+			return nil
+		}
+
+		initiated, reused, err := run.Inventory(ctx, vaults, store, inventoryOptions, !*noReuse)
+		if err != nil {
+			return err
+		}
+		if initiated+reused == 0 {
+			ui.Println("None of the vaults need an inventory.")
+			return nil
+		}
+		ui.Printf("%d inventory retrieval job(s) initiated and %d reused, and recorded in %s. Run 'ice-breaker status' to check on them, and 'ice-breaker resume' to delete the archives once they complete, usually in 3 to 5 hours.\n", initiated, reused, store.Path)
+		return nil
+	}
+}
+
+// inventoryOptionFlags registers the flags narrowing the inventory jobs a
+// command initiates. The returned function gives the options once they're
+// parsed.
+func inventoryOptionFlags(fs *flag.FlagSet) func() (glacierpurge.InventoryOptions, error) {
+	pageSize := fs.Int("inventory-page-size", 0, "Split each vault's inventory into jobs of at most this many archives; 0 uses a single job")
+	startDate := fs.String("inventory-start-date", "", "Only inventory, and so only delete, archives created at or after this RFC 3339 time")
+	endDate := fs.String("inventory-end-date", "", "Only inventory, and so only delete, archives created before this RFC 3339 time")
+	format := fs.String("inventory-format", "auto", "Inventory format to request: JSON, CSV, or auto (CSV for vaults with very many archives)")
+
+	return func() (glacierpurge.InventoryOptions, error) {
+		opts := glacierpurge.InventoryOptions{Limit: *pageSize, Format: *format}
+		if opts.Format != "auto" {
+			opts.Format = strings.ToUpper(opts.Format)
+		}
+		for _, date := range []struct {
+			flag  string
+			value string
+			t     *time.Time
+		}{
+			{"--inventory-start-date", *startDate, &opts.StartDate},
+			{"--inventory-end-date", *endDate, &opts.EndDate},
+		} {
+			if date.value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, date.value)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s %q: must be an RFC 3339 time such as 2020-01-31T00:00:00Z", date.flag, date.value)
+			}
+			*date.t = t
+		}
+
+		if err := opts.Validate(); err != nil {
+			return opts, err
+		}
+		if !opts.StartDate.IsZero() || !opts.EndDate.IsZero() {
+			ui.Printf("%sInventories only cover archives created %s; nothing outside that window will be deleted.%s\n", ui.Yellow, describeWindow(opts), ui.Reset)
+		}
+		return opts, nil
+	}
+}
+
+func describeWindow(opts glacierpurge.InventoryOptions) string {
+	switch {
+	case opts.StartDate.IsZero():
+		return "before " + opts.EndDate.Format(time.RFC3339)
+	case opts.EndDate.IsZero():
+		return "at or after " + opts.StartDate.Format(time.RFC3339)
+	}
+	return "from " + opts.StartDate.Format(time.RFC3339) + " up to " + opts.EndDate.Format(time.RFC3339)
+}
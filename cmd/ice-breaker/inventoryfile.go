@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func downloadInventoryFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	vault := fs.String("vault", "", "Name of the vault whose inventory to download")
+	out := fs.String("out", "", "File to write the raw JSON inventory to")
+	jobId := fs.String("job-id", "", "Download this inventory job instead of the vault's latest one")
+	noWait := fs.Bool("no-wait", false, "Don't wait for a running inventory job; print its ID and exit")
+	format := fs.String("format", "json", "json, or csv to also write a flattened CSV next to --out")
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		region, err := o.selection.single()
+		if problems.add(err) && (region == "" || *vault == "" || *out == "") {
+			problems.add(errors.New("-region, --vault, and --out are required"))
+		}
+		if *format != "json" && *format != "csv" {
+			problems.add(fmt.Errorf("invalid --format %q: must be json or csv", *format))
+		}
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		// Nothing here should ever delete, so make sure nothing can.
+		g, err := o.registry(glacierpurge.WithReadOnly()).Get(ctx, region)
+		if err != nil {
+			return err
+		}
+		v := &glacierpurge.Vault{Glacier: g, Name: *vault}
+
+		job, err := inventoryFor(ctx, o, v, *jobId, true, !*noWait)
+		if err != nil || job == nil {
+			return err
+		}
+
+		f, err := os.OpenFile(*out, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+
+		result, err := job.Download(ctx, f, glacierpurge.DownloadOptions{})
+		if err != nil {
+			return err
+		}
+		ui.Printf("%sWrote the %d-byte inventory from job %s to %s%s\n", ui.Green, result.Written, job.Id, *out, ui.Reset)
+		o.saveArtifact(ctx, *out)
+
+		if *format != "csv" {
+			return nil
+		}
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		csvPath := strings.TrimSuffix(*out, filepath.Ext(*out)) + ".csv"
+		if csvPath == *out {
+			csvPath = *out + ".csv"
+		}
+		c, err := os.Create(csvPath)
+		if err != nil {
+			return fmt.Errorf("failed to create CSV file: %w", err)
+		}
+		defer c.Close()
+		w := newArchiveWriter(c, "csv")
+		if err := glacierpurge.StreamInventory(f, v, w.write); err != nil {
+			return err
+		}
+		if err := w.close(); err != nil {
+			return err
+		}
+		ui.Printf("%sWrote %d archive(s) to %s%s\n", ui.Green, w.written, csvPath, ui.Reset)
+		o.saveArtifact(ctx, csvPath)
+		return nil
+	}
+}
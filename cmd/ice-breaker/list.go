@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+)
+
+func listFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	tags := tagFlags(fs)
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		tagFilter, err := tags()
+		problems.add(err)
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		regions, done, err := o.regions(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		vaults, scanned := run.Scan(ctx, o.registry(glacierpurge.WithReadOnly()), regions)
+		run.FetchTags(ctx, vaults)
+		vaults = run.FilterTagsQuietly(ctx, vaults, tagFilter)
+
+		if o.output == "json" {
+			type vault struct {
+				Region          string            `json:"region"`
+				Name            string            `json:"name"`
+				ARN             string            `json:"arn"`
+				CreationDate    time.Time         `json:"creationDate"`
+				Tags            map[string]string `json:"tags,omitempty"`
+				TagsUnavailable bool              `json:"tagsUnavailable,omitempty"`
+			}
+			out := make([]vault, 0, len(vaults))
+			for _, v := range vaults {
+				tags, err := v.Tags(ctx)
+				out = append(out, vault{v.Glacier.Region, v.Name, v.ARN, v.CreationDate, tags, err != nil})
+			}
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				Version string              `json:"version"`
+				Vaults  []vault             `json:"vaults"`
+				Regions []*run.RegionResult `json:"regions"`
+			}{buildVersion(), out, scanned})
+		}
+
+		for _, v := range vaults {
+			fmt.Fprintf(stdout, "[%s] %s\t%s\t%s\t%s\n", v.Glacier.Region, v.Name, v.ARN, v.CreationDate.Format("2006-01-02"), run.TagLabel(ctx, v))
+		}
+		run.SummarizeRegions(scanned)
+		return nil
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/state"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func listArchivesFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	vault := fs.String("vault", "", "Name of the vault to list")
+	jobId := fs.String("job-id", "", "Read this completed inventory job instead of the vault's latest one")
+	limit := fs.Int("limit", 0, "Stop after this many archives; 0 lists them all")
+	filter := fs.String("filter", "", "Only list archives whose ID or description contains this text")
+	initiate := fs.Bool("initiate", false, "Start an inventory job without asking if there's no completed one")
+	wait := fs.Bool("wait", false, "Wait for the inventory job to complete instead of exiting")
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate("text", "json", "csv"))
+		region, err := o.selection.single()
+		if problems.add(err) && (region == "" || *vault == "") {
+			problems.add(errors.New("-region and --vault are required"))
+		}
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		g, err := o.registry(glacierpurge.WithReadOnly()).Get(ctx, region)
+		if err != nil {
+			return err
+		}
+		v := &glacierpurge.Vault{Glacier: g, Name: *vault}
+
+		job, err := inventoryFor(ctx, o, v, *jobId, *initiate, *wait)
+		if err != nil || job == nil {
+			return err
+		}
+
+		w := newArchiveWriter(stdout, o.output)
+		needle := strings.ToLower(*filter)
+		err = job.StreamResults(ctx, func(archive *glacierpurge.Archive) error {
+			if *limit > 0 && w.written == *limit {
+				return errListed
+			}
+			if needle != "" && !strings.Contains(strings.ToLower(archive.Id), needle) && !strings.Contains(strings.ToLower(archive.Description), needle) {
+				return nil
+			}
+			return w.write(archive)
+		})
+		if err != nil && !errors.Is(err, errListed) {
+			return err
+		}
+		return w.close()
+	}
+}
+
+// errListed stops reading an inventory once --limit archives are listed.
+var errListed = errors.New("listed enough archives")
+
+// inventoryFor finds the completed inventory job to read the vault's archives
+// from. Without one it reports any job still running, or starts one if asked
+// to, and returns nil so the caller can come back once it's done, unless wait
+// is set.
+func inventoryFor(ctx context.Context, o *globalOptions, v *glacierpurge.Vault, jobId string, initiate, wait bool) (*glacierpurge.InventoryJob, error) {
+	var job *glacierpurge.InventoryJob
+	if jobId != "" {
+		job = &glacierpurge.InventoryJob{Vault: v, Id: jobId}
+		completed, err := job.Completed(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if completed {
+			return job, nil
+		}
+	} else {
+		var err error
+		if job, err = v.ReusableInventory(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if job == nil {
+		if !initiate {
+			confirmed, err := stdin.Confirm(ctx, fmt.Sprintf("[%s] %s: No completed inventory is available. Start an inventory retrieval job?", v.Glacier.Region, v.Name))
+			if err != nil {
+				return nil, err
+			}
+			if !confirmed {
+				return nil, nil
+			}
+		}
+
+		var err error
+		if job, err = v.InitiateInventoryRetrievalJob(ctx); err != nil {
+			return nil, err
+		}
+		recordJob(o, job)
+	} else if completed, err := job.Completed(ctx); err != nil || completed {
+		return job, err
+	}
+
+	if !wait {
+		ui.Printf("Inventory retrieval job %s is in progress; it usually takes several hours. Run this again with --job-id %s once it completes.\n", job.Id, job.Id)
+		return nil, nil
+	}
+
+	ui.Printf("Waiting for inventory retrieval job %s to complete...\n", job.Id)
+	if _, err := job.Wait(ctx, glacierpurge.WaitOptions{}); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// recordJob notes an inventory job in the state file so the status and resume
+// commands know about it.
+func recordJob(o *globalOptions, job *glacierpurge.InventoryJob) {
+	store, err := o.openState()
+	if err == nil {
+		err = store.PutJob(state.Job{Region: job.Vault.Glacier.Region, Vault: job.Vault.Name, JobId: job.Id, InitiatedAt: time.Now()})
+	}
+	if err != nil {
+		ui.Printf("%sCouldn't record job %s in the state file: %v%s\n", ui.Yellow, job.Id, err, ui.Reset)
+	}
+}
+
+type archiveRow struct {
+	Id           string    `json:"archiveId"`
+	SizeInBytes  int64     `json:"sizeInBytes"`
+	CreationDate time.Time `json:"creationDate"`
+	Description  string    `json:"description"`
+	TreeHash     string    `json:"treeHash"`
+}
+
+// textFlushEvery is how many rows the text table buffers, to line its
+// columns up, before writing them out.
+const textFlushEvery = 1000
+
+// archiveWriter writes archives to out as text, json, or csv as they're
+// given, rather than collecting them first, so listing a vault of millions
+// of archives takes no more memory than listing a few. Text tables are
+// lined up a block of rows at a time.
+type archiveWriter struct {
+	format  string
+	out     io.Writer
+	csv     *csv.Writer
+	table   *tabwriter.Writer
+	written int
+}
+
+func newArchiveWriter(out io.Writer, format string) *archiveWriter {
+	w := &archiveWriter{format: format, out: out}
+	switch format {
+	case "json":
+	case "csv":
+		w.csv = csv.NewWriter(out)
+		w.csv.Write([]string{"archiveId", "sizeInBytes", "creationDate", "description", "treeHash"})
+	default:
+		w.table = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w.table, "ARCHIVE ID\tSIZE\tCREATED\tDESCRIPTION")
+	}
+	return w
+}
+
+// write writes one archive.
+func (w *archiveWriter) write(a *glacierpurge.Archive) error {
+	row := archiveRow{a.Id, a.Size, a.CreationDate, a.Description, a.TreeHash}
+	w.written++
+	switch w.format {
+	case "json":
+		data, err := json.MarshalIndent(row, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		sep := ",\n  "
+		if w.written == 1 {
+			sep = "[\n  "
+		}
+		_, err = fmt.Fprintf(w.out, "%s%s", sep, data)
+		return err
+	case "csv":
+		w.csv.Write([]string{row.Id, strconv.FormatInt(row.SizeInBytes, 10), row.CreationDate.Format(time.RFC3339), row.Description, row.TreeHash})
+		return w.csv.Error()
+	}
+	fmt.Fprintf(w.table, "%s\t%s\t%s\t%s\n", row.Id, ui.Bytes(row.SizeInBytes), row.CreationDate.Format("2006-01-02"), ui.Printable(row.Description))
+	if w.written%textFlushEvery == 0 {
+		return w.table.Flush()
+	}
+	return nil
+}
+
+// close finishes the output.
+func (w *archiveWriter) close() error {
+	switch w.format {
+	case "json":
+		end := "\n]\n"
+		if w.written == 0 {
+			end = "[]\n"
+		}
+		_, err := io.WriteString(w.out, end)
+		return err
+	case "csv":
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return w.table.Flush()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// vaultRow is one vault in list-vaults' output.
+type vaultRow struct {
+	Region            string            `json:"region"`
+	Name              string            `json:"name"`
+	ARN               string            `json:"arn"`
+	Archives          int64             `json:"archives"`
+	SizeInBytes       int64             `json:"sizeInBytes"`
+	CreationDate      time.Time         `json:"creationDate"`
+	LastInventoryDate *time.Time        `json:"lastInventoryDate,omitempty"`
+	Tags              map[string]string `json:"tags,omitempty"`
+	TagsUnavailable   bool              `json:"tagsUnavailable,omitempty"`
+	Error             string            `json:"error,omitempty"`
+}
+
+type vaultTotals struct {
+	Region      string `json:"region,omitempty"`
+	Vaults      int    `json:"vaults"`
+	Archives    int64  `json:"archives"`
+	SizeInBytes int64  `json:"sizeInBytes"`
+}
+
+// sortFlags registers the flags ordering a command's vaults, defaulting to
+// sorting by defaultSort. The returned function gives the options once
+// they're parsed.
+func sortFlags(fs *flag.FlagSet, defaultSort string) func() (run.SortOptions, error) {
+	sortBy := fs.String("sort", defaultSort, "Sort vaults by name, size, archives, or creation-date")
+	desc := fs.Bool("desc", false, "Reverse the sort order, e.g. biggest vaults first")
+	groupBy := fs.String("group-by", "region", "Group vaults by region, or none to sort them all together")
+
+	return func() (run.SortOptions, error) {
+		opts := run.SortOptions{By: *sortBy, Desc: *desc}
+		switch *groupBy {
+		case "region":
+			opts.ByRegion = true
+		case "none":
+		default:
+			return opts, fmt.Errorf("invalid --group-by %q: must be region or none", *groupBy)
+		}
+		return opts, opts.Validate()
+	}
+}
+
+func listVaultsFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	sorting := sortFlags(fs, "name")
+	tags := tagFlags(fs)
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		sortOptions, err := sorting()
+		problems.add(err)
+		tagFilter, err := tags()
+		problems.add(err)
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		regions, done, err := o.regions(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		vaults, scanned := run.Scan(ctx, o.registry(glacierpurge.WithReadOnly()), regions)
+		run.Enrich(ctx, vaults)
+		vaults = run.FilterTagsQuietly(ctx, vaults, tagFilter)
+		if err := run.SortVaults(ctx, vaults, sortOptions); err != nil {
+			return err
+		}
+		var rows []*vaultRow
+		for _, vault := range vaults {
+			rows = append(rows, describeRow(ctx, vault))
+		}
+		regionTotals, total := totalVaults(regions, rows)
+
+		if o.output == "json" {
+			if rows == nil {
+				rows = []*vaultRow{}
+			}
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				Version string              `json:"version"`
+				Vaults  []*vaultRow         `json:"vaults"`
+				Regions []*vaultTotals      `json:"regions"`
+				Total   *vaultTotals        `json:"total"`
+				Scan    []*run.RegionResult `json:"scan"`
+			}{buildVersion(), rows, regionTotals, total, scanned})
+		}
+
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REGION\tVAULT\tARCHIVES\tSIZE\tCREATED\tLAST INVENTORY\tARN\tTAGS")
+		for _, row := range rows {
+			if row.Error != "" {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t%s\n", row.Region, row.Name, row.Error)
+				continue
+			}
+			last := "never"
+			if row.LastInventoryDate != nil {
+				last = row.LastInventoryDate.Format("2006-01-02")
+			}
+			tags := run.FormatTags(row.Tags)
+			if row.TagsUnavailable {
+				tags = "unavailable"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", row.Region, row.Name, row.Archives, ui.Bytes(row.SizeInBytes), row.CreationDate.Format("2006-01-02"), last, row.ARN, tags)
+		}
+		w.Flush()
+
+		fmt.Fprintln(stdout)
+		for _, t := range regionTotals {
+			fmt.Fprintf(stdout, "%s: %d vault(s), %d archive(s), %s\n", t.Region, t.Vaults, t.Archives, ui.Bytes(t.SizeInBytes))
+		}
+		fmt.Fprintf(stdout, "Total: %d vault(s), %d archive(s), %s\n", total.Vaults, total.Archives, ui.Bytes(total.SizeInBytes))
+		run.SummarizeRegions(scanned)
+		return nil
+	}
+}
+
+func describeRow(ctx context.Context, vault *glacierpurge.Vault) *vaultRow {
+	row := &vaultRow{Region: vault.Glacier.Region, Name: vault.Name, ARN: vault.ARN}
+	if tags, err := vault.Tags(ctx); err != nil {
+		row.TagsUnavailable = true
+	} else {
+		row.Tags = tags
+	}
+	description, err := vault.Describe(ctx)
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+
+	row.ARN = description.ARN
+	row.Archives = description.NumberOfArchives
+	row.SizeInBytes = description.SizeInBytes
+	row.CreationDate = description.CreationDate
+	if !description.LastInventoryDate.IsZero() {
+		row.LastInventoryDate = &description.LastInventoryDate
+	}
+	return row
+}
+
+// totalVaults adds up the vaults per region, in the order the regions were
+// scanned, and overall. Regions without vaults are left out.
+func totalVaults(regions []string, rows []*vaultRow) ([]*vaultTotals, *vaultTotals) {
+	byRegion := make(map[string]*vaultTotals)
+	total := &vaultTotals{}
+	for _, row := range rows {
+		t := byRegion[row.Region]
+		if t == nil {
+			t = &vaultTotals{Region: row.Region}
+			byRegion[row.Region] = t
+		}
+		for _, t := range []*vaultTotals{t, total} {
+			t.Vaults++
+			t.Archives += row.Archives
+			t.SizeInBytes += row.SizeInBytes
+		}
+	}
+
+	var totals []*vaultTotals
+	for _, region := range regions {
+		if t := byRegion[region]; t != nil {
+			totals = append(totals, t)
+		}
+	}
+	return totals, total
+}
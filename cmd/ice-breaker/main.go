@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/redact"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+type command struct {
+	name    string
+	summary string
+	// flags registers the command's own flags and returns the function that
+	// runs it once they've been parsed.
+	flags func(fs *flag.FlagSet) func(o *globalOptions) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"doctor", "Check that the credentials can do everything a run needs, changing nothing", doctorFlags},
+		{"list", "List the vaults in the selected regions", listFlags},
+		{"list-vaults", "Show a table of the vaults in the selected regions with their sizes", listVaultsFlags},
+		{"list-archives", "List a vault's archives from a completed inventory", listArchivesFlags},
+		{"download-inventory", "Save a vault's raw inventory to a file", downloadInventoryFlags},
+		{"inventory", "Initiate inventory retrieval jobs and exit without waiting for them", inventoryFlags},
+		{"status", "Show the status of the inventory jobs recorded in the state file", statusFlags},
+		{"wait", "Wait for an inventory job, or those in the state file, to complete", waitFlags},
+		{"purge", "Choose vaults interactively and delete all of their archives", purgeFlags},
+		{"plan", "Choose vaults and write the plan for destroying them, deleting nothing", planFlags},
+		{"apply", "Destroy exactly the vaults in a plan written by 'plan'", applyFlags},
+		{"resume", "Finish the inventory jobs recorded by an earlier run", resumeFlags},
+		{"purge-vault", "Destroy one named vault: its archives and then the vault itself", purgeVaultFlags},
+		{"delete-vault", "Delete vaults that are already empty, with no inventory", deleteVaultFlags},
+		{"nuke", "Destroy every vault the filters pick, unattended, until none are left", nukeFlags},
+		{"download-archive", "Retrieve one archive's contents to a local file", downloadArchiveFlags},
+		{"delete-archive", "Delete specific archives by ID", deleteArchiveFlags},
+		{"verify-audit-log", "Check that an --audit-log file hasn't been edited", verifyAuditLogFlags},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: ice-breaker <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-19s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "  %-19s %s\n", "config", "Print the effective configuration ('config show [command]')")
+	fmt.Fprintf(os.Stderr, "  %-19s %s\n", "print-iam-policy", "Print the IAM policy a command needs ('print-iam-policy <command> [flags]')")
+	fmt.Fprintf(os.Stderr, "  %-19s %s\n", "version", "Print the version")
+	fmt.Fprintf(os.Stderr, "\nRun 'ice-breaker <command> -h' to see a command's flags.\n")
+}
+
+func main() {
+	defer redact.Panics()
+	log.SetOutput(redact.NewWriter(os.Stderr))
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	case "version", "-version", "--version":
+		fmt.Println("ice-breaker", buildVersion())
+		return
+	}
+
+	// Before there were subcommands, the flags went straight to the binary
+	// and it ran what is now the purge command.
+	if strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Running ice-breaker without a command is deprecated; use 'ice-breaker purge' instead.")
+		args = append([]string{"purge"}, args...)
+	}
+
+	if args[0] == "print-iam-policy" {
+		if err := runPrintIAMPolicy(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if args[0] == "config" {
+		if err := runConfig(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if c := lookupCommand(args[0]); c != nil {
+		// A snapshot asked for before or after the run has nothing to show,
+		// but mustn't kill it either.
+		ignoreSnapshotSignals()
+		fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+		o := newGlobalOptions(fs)
+		run := c.flags(fs)
+		if err := o.parse(fs, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		o.handleSignals()
+		// For a panic, which redact.Panics reports once this has run.
+		defer o.releaseState()
+		if err := o.startServers(); err != nil {
+			log.Fatal(err)
+		}
+		ui.Printf("%sice-breaker %s%s\n", ui.Bold, buildVersion(), ui.Reset)
+		if o.readOnly {
+			ui.Printf("%sRead-only: every Glacier call that could change anything will be refused.%s\n", ui.Yellow, ui.Reset)
+		}
+		err := run(o)
+		o.logTransport()
+		o.reportGuard()
+		o.closeRecording()
+		o.stopServers()
+		resumable := o.lock != nil
+		o.releaseState()
+		if o.journal != nil {
+			// Worth noting somewhere safe: it vouches for every record so far.
+			fmt.Fprintf(stderr, "Audit log %s: last hash %s\n", o.auditPath, o.journal.Head())
+			o.journal.Close()
+		}
+		o.uploadArtifacts()
+		if errors.Is(err, glacierpurge.ErrCredentialsExpired) && resumable {
+			fmt.Fprintln(os.Stderr, "The inventory jobs started so far are recorded; once you've re-authenticated, run 'ice-breaker resume' to finish them.")
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", args[0])
+	usage()
+	os.Exit(2)
+}
+
+func lookupCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// runMainEnv has the test binary run main instead of the tests, so a test can
+// run the command as a process of its own, and kill it.
+const runMainEnv = "ICE_BREAKER_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// cli runs ice-breaker against a fake Glacier served over HTTP, with a state
+// directory and home of its own.
+type cli struct {
+	t        *testing.T
+	fake     *glaciertest.Fake
+	server   *httptest.Server
+	home     string
+	stateDir string
+}
+
+func newCLI(t *testing.T, fake *glaciertest.Fake) *cli {
+	server := glaciertest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return &cli{t: t, fake: fake, server: server, home: t.TempDir(), stateDir: t.TempDir()}
+}
+
+// command returns the process running the command with args, pointed at the
+// fake.
+func (c *cli) command(command string, args ...string) (*exec.Cmd, *bytes.Buffer) {
+	args = append([]string{command,
+		"--region", "us-east-1",
+		"--endpoint-url", c.server.URL,
+		"--id", "AKIDEXAMPLE",
+		"--secret", "secret",
+		"--state-dir", c.stateDir,
+	}, args...)
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(),
+		runMainEnv+"=1",
+		"HOME="+c.home,
+		"XDG_CONFIG_HOME="+c.home,
+		"AWS_CONFIG_FILE="+os.DevNull,
+		"AWS_SHARED_CREDENTIALS_FILE="+os.DevNull,
+		"AWS_EC2_METADATA_DISABLED=true",
+	)
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	return cmd, &output
+}
+
+// run runs the command to the end and returns its output.
+func (c *cli) run(command string, args ...string) (string, error) {
+	c.t.Helper()
+	cmd, output := c.command(command, args...)
+	err := cmd.Run()
+	return output.String(), err
+}
+
+// TestEveryCommandHasUsage runs each command with -h, as a user finding out
+// how to use it would, checking it exits cleanly with a usage line, and a
+// description of every flag, and that the list of commands names it.
+func TestEveryCommandHasUsage(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "help")
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	help, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("help failed: %v\n%s", err, help)
+	}
+	for _, c := range commands {
+		t.Run(c.name, func(t *testing.T) {
+			if c.summary == "" || !strings.Contains(string(help), "  "+c.name+" ") {
+				t.Errorf("help doesn't list %s with a summary:\n%s", c.name, help)
+			}
+
+			cmd := exec.Command(os.Args[0], c.name, "-h")
+			cmd.Env = append(os.Environ(), runMainEnv+"=1")
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("%s -h failed: %v\n%s", c.name, err, output)
+			}
+			usage := fmt.Sprintf("Usage: ice-breaker %s [flags]%s", c.name, positionalArgs[c.name])
+			if !strings.HasPrefix(string(output), usage) {
+				t.Errorf("got usage:\n%s\nwant it to start %q", output, usage)
+			}
+
+			fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+			newGlobalOptions(fs)
+			c.flags(fs)
+			fs.VisitAll(func(f *flag.Flag) {
+				if strings.TrimSpace(f.Usage) == "" {
+					t.Errorf("--%s has no description", f.Name)
+				}
+				if !strings.Contains(string(output), "  -"+f.Name) {
+					t.Errorf("the usage doesn't describe --%s", f.Name)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// vaultDeletionPoll is how often nuke tries again to delete the vaults it has
+// emptied, which Glacier only allows after its next inventory of them.
+const vaultDeletionPoll = time.Hour
+
+// nukeFlags registers the nuke command, which destroys every vault the
+// filters pick without asking anything: their archives, then the vaults
+// themselves, waiting for Glacier to let them go. Killed part way, it picks
+// the vaults' inventory jobs up again when it's run again.
+func nukeFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	var names stringList
+	fs.Var(&names, "vault", "Only destroy the vaults with these comma-separated names, or patterns such as logs-* (may be repeated)")
+	yes := fs.Bool("yes", false, "Destroy the vaults without asking; nuke refuses to run without it")
+	allVaults := fs.Bool("all-vaults", false, "Destroy every vault in the regions, with no --vault or --tag to narrow them down")
+	maxWait := fs.Duration("vault-deletion-wait", 48*time.Hour, "How long to keep trying to delete the emptied vaults, which Glacier only allows after its next inventory of them, about a day later")
+	concurrency := concurrencyFlags(fs, true)
+	checkpoints := checkpointFlags(fs)
+	// Unattended runs are better off going flat out.
+	setDefault(fs, "workers-per-vault", "auto")
+	setDefault(fs, "max-concurrent-vaults", "4")
+	tags := tagFlags(fs)
+	loadPrices := pricesFlag(fs)
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		if !*yes {
+			problems.add(errors.New("nuke destroys vaults without asking, so it needs --yes"))
+		}
+		concurrent, err := concurrency()
+		problems.add(err)
+		checkpoint, err := checkpoints()
+		problems.add(err)
+		tagFilter, err := tags()
+		problems.add(err)
+		filtered := len(names) > 0 || len(tagFilter.Match) > 0 || len(tagFilter.Exclude) > 0
+		if !filtered && !*allVaults {
+			problems.add(errors.New("nuke needs --vault, --tag or --exclude-tag to pick the vaults, or --all-vaults to destroy every one in the regions"))
+		}
+		problems.add(nonNegative("--vault-deletion-wait", *maxWait))
+		prices, err := loadPrices()
+		problems.add(err)
+		if err := problems.err(); err != nil {
+			return err
+		}
+		o.sizeConnections(concurrent)
+		stdin.NoInput = true
+
+		store, err := o.openState()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		regions, done, err := o.regions(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		vaults, scanned := run.Scan(ctx, o.registry(), regions)
+		found := vaults
+		vaults = run.FilterVaults(vaults, names)
+		run.Enrich(ctx, vaults)
+		vaults, left := run.FilterTags(ctx, vaults, tagFilter)
+		if len(vaults) == 0 {
+			run.SummarizeRegions(scanned)
+			ui.Println("No vaults to destroy.")
+			return nil
+		}
+		for _, vault := range vaults {
+			ui.Printf("%s[%s] %s: destroying%s\n", ui.Yellow, vault.Glacier.Region, vault.Name, ui.Reset)
+		}
+		printSelectionSavings(ctx, prices, vaults)
+
+		stopWatching := o.watchRun()
+		results := run.Destroy(ctx, vaults, store, run.Options{
+			DeleteVault:         true,
+			WorkersPerVault:     concurrent.WorkersPerVault,
+			AdaptiveWorkers:     concurrent.AdaptiveWorkers,
+			MaxConcurrentVaults: concurrent.MaxConcurrentVaults,
+			MaxPendingJobs:      concurrent.MaxPendingJobs,
+			Order:               concurrent.Order,
+			Checkpoint:          checkpoint,
+			Progress:            o.progress,
+			Events:              o.events,
+			Prices:              prices,
+			// A killed run's inventory jobs are picked up where it left
+			// them.
+			ReuseInventory: true,
+		})
+
+		var emptied []*glacierpurge.Vault
+		for _, result := range results {
+			if result.Err == nil {
+				emptied = append(emptied, result.Vault)
+			}
+		}
+		var deleteErr error
+		if len(emptied) > 0 && ctx.Err() == nil && !o.wrapUp.Requested() {
+			deleteErr = run.DeleteWhenInventoried(ctx, emptied, store, run.Options{Events: o.events}, vaultDeletionPoll, *maxWait)
+		}
+		stopWatching()
+
+		results = append(results, left...)
+		run.SummarizeRegions(scanned)
+		err = run.Summarize(results)
+		printDeletedSavings(prices, results)
+		if err == nil {
+			err = deleteErr
+		}
+		if rescanErr := o.rescan(ctx, scannedRegions(scanned), found, vaults); rescanErr != nil && err == nil {
+			err = rescanErr
+		}
+		return err
+	}
+}
+
+// setDefault changes the default of a flag already registered, for a
+// command that's better off with another than the one it shares.
+func setDefault(fs *flag.FlagSet, name, value string) {
+	f := fs.Lookup(name)
+	if err := f.Value.Set(value); err != nil {
+		panic(fmt.Sprintf("invalid default %q for --%s: %v", value, name, err))
+	}
+	f.DefValue = value
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+
+	"github.com/rdegges/ice-breaker/internal/redact"
+)
+
+// stdout and stderr are where output goes other than through ui: listings,
+// reports, and the end-of-run notes, with any secret masked.
+var (
+	stdout = redact.NewWriter(os.Stdout)
+	stderr = redact.NewWriter(os.Stderr)
+)
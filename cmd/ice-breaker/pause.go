@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// togglePause pauses deleting, letting the deletions in flight finish, or
+// resumes it. Polling inventory jobs carries on either way.
+func (o *globalOptions) togglePause() {
+	_, since := o.pause.Paused()
+	if o.pause.Toggle() {
+		ui.Printf("%s%sPAUSED deleting once the deletions in flight finish; inventory jobs are still polled. Pause again to resume.%s\n", ui.Yellow, ui.Bold, ui.Reset)
+		return
+	}
+	ui.Printf("%sResumed deleting after a pause of %s.%s\n", ui.Green, time.Since(since).Round(time.Second), ui.Reset)
+}
+
+// requestWrapUp finishes the run early: no more archives are deleted once
+// those in flight are, and vaults still waiting for their inventory stop
+// waiting, leaving their jobs recorded for resume.
+func (o *globalOptions) requestWrapUp() {
+	if o.wrapUp.Requested() {
+		return
+	}
+	o.wrapUp.Request()
+	ui.Printf("%s%sFinishing early: letting the deletions in flight finish, then stopping. Interrupt to stop at once.%s\n", ui.Yellow, ui.Bold, ui.Reset)
+}
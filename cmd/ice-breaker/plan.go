@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/plan"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func planFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	out := fs.String("out", "plan.json", "File to write the plan to")
+	var names stringList
+	fs.Var(&names, "vault", "Only offer the vaults with these comma-separated names (may be repeated)")
+	answersFile := fs.String("answers", "", "File of region/vault patterns answering y or n for each vault, asking only about the vaults it doesn't cover")
+	deleteVaults := fs.Bool("delete-vaults", true, "Plan to delete each vault once its archives are gone")
+	initiate := fs.Bool("inventory", false, "Also initiate each planned vault's inventory retrieval job now, so apply doesn't wait as long")
+	sorting := sortFlags(fs, "")
+	tags := tagFlags(fs)
+	loadPrices := pricesFlag(fs)
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		sortOptions, err := sorting()
+		problems.add(err)
+		tagFilter, err := tags()
+		problems.add(err)
+		prices, err := loadPrices()
+		problems.add(err)
+		var answers *run.Answers
+		if *answersFile != "" {
+			answers, err = run.LoadAnswers(*answersFile)
+			problems.add(err)
+		}
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		regions, done, err := o.regions(ctx)
+		if err != nil || done {
+			return err
+		}
+		if len(regions) == 0 {
+			return errors.New("no regions to scan")
+		}
+
+		accountId, err := glacierpurge.CallerAccount(ctx, regions[0], &o.settings)
+		if err != nil {
+			return fmt.Errorf("failed to look up the account the credentials belong to: %w", err)
+		}
+
+		// Nothing is deleted while planning.
+		vaults, scanned := run.Scan(ctx, o.registry(glacierpurge.WithReadOnly()), regions)
+		vaults = run.FilterVaults(vaults, names)
+		run.Enrich(ctx, vaults)
+		vaults, _ = run.FilterTags(ctx, vaults, tagFilter)
+		if err := run.SortVaults(ctx, vaults, sortOptions); err != nil {
+			return err
+		}
+		run.SummarizeRegions(scanned)
+
+		selected, _, err := run.Select(ctx, stdin, answers, vaults)
+		if err != nil {
+			return err
+		}
+
+		p := &plan.Plan{AccountId: accountId, CreatedAt: time.Now(), DeleteVaults: *deleteVaults}
+		for _, vault := range selected {
+			description, err := vault.Describe(ctx)
+			if err != nil {
+				return err
+			}
+			planned := &plan.Vault{
+				Region:      vault.Glacier.Region,
+				Name:        vault.Name,
+				ARN:         description.ARN,
+				Archives:    description.NumberOfArchives,
+				SizeInBytes: description.SizeInBytes,
+			}
+			if !description.LastInventoryDate.IsZero() {
+				planned.LastInventoryDate = &description.LastInventoryDate
+			}
+			p.Vaults = append(p.Vaults, planned)
+		}
+
+		if *initiate && len(selected) > 0 {
+			store, err := o.openState()
+			if err != nil {
+				return err
+			}
+			if _, _, err := run.Inventory(ctx, selected, store, glacierpurge.InventoryOptions{}, false); err != nil {
+				return err
+			}
+			for _, planned := range p.Vaults {
+				if job, ok := store.Job(planned.Region, planned.Name); ok {
+					planned.InventoryJobId = job.JobId
+				}
+			}
+		}
+
+		if err := plan.Write(*out, p); err != nil {
+			return err
+		}
+		o.saveArtifact(ctx, *out)
+
+		ui.Printf("\n%sPlan for account %s%s\n", ui.Bold, p.AccountId, ui.Reset)
+		printPlan(p)
+		printSelectionSavings(ctx, prices, selected)
+		ui.Printf("Wrote the plan to %s. Review it, then run 'ice-breaker apply %s'.\n", *out, *out)
+		return nil
+	}
+}
+
+func applyFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	yes := fs.Bool("yes", false, "Apply the plan without asking for confirmation")
+	failFast := fs.Bool("fail-fast", false, "Stop the whole run at the first vault that fails instead of carrying on with the rest")
+	rescan := fs.Bool("rescan", false, "Scan the plan's regions before and after the run and report which vaults are gone, left, or new")
+	noReinitiate := fs.Bool("no-reinitiate", false, "Fail a vault whose inventory job has expired instead of initiating a fresh one and waiting for it")
+	salvage := salvageFlags(fs)
+	concurrency := concurrencyFlags(fs, true)
+	checkpoints := checkpointFlags(fs)
+	scheduling := scheduleFlags(fs)
+	loadPrices := pricesFlag(fs)
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		if o.arg == "" {
+			problems.add(errors.New("usage: ice-breaker apply [flags] PLAN"))
+		}
+		prices, err := loadPrices()
+		problems.add(err)
+		concurrent, err := concurrency()
+		problems.add(err)
+		checkpoint, err := checkpoints()
+		problems.add(err)
+		if err := problems.err(); err != nil {
+			return err
+		}
+		o.sizeConnections(concurrent)
+
+		p, err := plan.Read(o.arg)
+		if err != nil {
+			return err
+		}
+		if len(p.Vaults) == 0 {
+			ui.Println("The plan has nothing to do.")
+			return nil
+		}
+
+		store, err := o.openState()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		salvageOptions, err := salvage(ctx)
+		if err != nil {
+			return err
+		}
+
+		accountId, err := glacierpurge.CallerAccount(ctx, p.Vaults[0].Region, &o.settings)
+		if err != nil {
+			return fmt.Errorf("failed to look up the account the credentials belong to: %w", err)
+		}
+		if err := p.Check(accountId); err != nil {
+			return err
+		}
+
+		// Every vault is checked against the plan before anything is done to
+		// any of them.
+		registry := o.registry()
+		var vaults []*glacierpurge.Vault
+		for _, planned := range p.Vaults {
+			g, err := registry.Get(ctx, planned.Region)
+			if err != nil {
+				return err
+			}
+			vault := &glacierpurge.Vault{Glacier: g, Name: planned.Name}
+			if _, err := vault.Describe(ctx); err != nil {
+				return err
+			}
+			if vault.ARN != planned.ARN {
+				return fmt.Errorf("vault %s in region %s is %s, not %s as planned", planned.Name, planned.Region, vault.ARN, planned.ARN)
+			}
+			vaults = append(vaults, vault)
+		}
+
+		ui.Printf("%sApplying the plan made %s for account %s%s\n", ui.Bold, p.CreatedAt.Local().Format("2006-01-02 15:04"), p.AccountId, ui.Reset)
+		printPlan(p)
+		printSelectionSavings(ctx, prices, vaults)
+		if !*yes {
+			confirmed, err := stdin.Confirm(ctx, fmt.Sprintf("Destroy these %d vault(s)?", len(vaults)))
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Println("Nothing was deleted.")
+				return nil
+			}
+		}
+
+		var before []*glacierpurge.Vault
+		var regions []string
+		if *rescan {
+			for _, planned := range p.Vaults {
+				if !slices.Contains(regions, planned.Region) {
+					regions = append(regions, planned.Region)
+				}
+			}
+			var scanned []*run.RegionResult
+			before, scanned = run.Scan(ctx, o.registry(glacierpurge.WithReadOnly()), regions)
+			regions = scannedRegions(scanned)
+		}
+
+		schedule, _ := scheduling()
+		stopWatching := o.watchRun()
+		results := run.Destroy(ctx, vaults, store, run.Options{
+			FailFast:       *failFast,
+			NoReinitiate:   *noReinitiate,
+			Salvage:        salvageOptions,
+			ReuseInventory: true,
+			DeleteVault:    p.DeleteVaults,
+			Inventory:      glacierpurge.InventoryOptions{EndDate: p.CreatedAt},
+			CreatedBefore:  p.CreatedAt,
+
+			WorkersPerVault:     concurrent.WorkersPerVault,
+			AdaptiveWorkers:     concurrent.AdaptiveWorkers,
+			MaxConcurrentVaults: concurrent.MaxConcurrentVaults,
+			MaxPendingJobs:      concurrent.MaxPendingJobs,
+			Order:               concurrent.Order,
+			Checkpoint:          checkpoint,
+			Progress:            o.progress,
+			Events:              o.events,
+			Schedule:            schedule,
+			Prices:              prices,
+		})
+		stopWatching()
+		err = run.Summarize(results)
+		printDeletedSavings(prices, results)
+		if *rescan {
+			if rescanErr := o.rescan(ctx, regions, before, vaults); rescanErr != nil && err == nil {
+				err = rescanErr
+			}
+		}
+		return err
+	}
+}
+
+// printPlan lists what the plan will do to each vault.
+func printPlan(p *plan.Plan) {
+	then := ""
+	if p.DeleteVaults {
+		then = ", then delete the vault"
+	}
+	for _, v := range p.Vaults {
+		ui.Printf("  [%s] %s: delete %d archive(s) totalling %s%s\n", v.Region, v.Name, v.Archives, ui.Bytes(v.SizeInBytes), then)
+	}
+	ui.Printf("%d vault(s) in the plan\n", len(p.Vaults))
+}
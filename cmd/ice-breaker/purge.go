@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func purgeFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	var names stringList
+	fs.Var(&names, "vault", "Only offer the vaults with these comma-separated names (may be repeated)")
+	failFast := fs.Bool("fail-fast", false, "Stop the whole run at the first vault that fails instead of carrying on with the rest")
+	noReinitiate := fs.Bool("no-reinitiate", false, "Fail a vault whose inventory job has expired instead of initiating a fresh one and waiting for it")
+	salvage := salvageFlags(fs)
+	concurrency := concurrencyFlags(fs, true)
+	checkpoints := checkpointFlags(fs)
+	inventory := inventoryOptionFlags(fs)
+	sorting := sortFlags(fs, "")
+	tags := tagFlags(fs)
+	scheduling := scheduleFlags(fs)
+	loadPrices := pricesFlag(fs)
+	findScheduled := fs.Bool("find-scheduled", false, "Only offer the vaults an earlier run tagged as scheduled for deletion, to finish destroying them")
+	answersFile := fs.String("answers", "", "File of region/vault patterns answering y or n for each vault, asking only about the vaults it doesn't cover")
+	rescan := fs.Bool("rescan", false, "Scan the regions again once the run is over and report which vaults are gone, left, or new")
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		concurrent, err := concurrency()
+		problems.add(err)
+		checkpoint, err := checkpoints()
+		problems.add(err)
+		inventoryOptions, err := inventory()
+		problems.add(err)
+		sortOptions, err := sorting()
+		problems.add(err)
+		tagFilter, err := tags()
+		problems.add(err)
+		prices, err := loadPrices()
+		problems.add(err)
+		var answers *run.Answers
+		if *answersFile != "" {
+			answers, err = run.LoadAnswers(*answersFile)
+			problems.add(err)
+		}
+		if err := problems.err(); err != nil {
+			return err
+		}
+		o.sizeConnections(concurrent)
+
+		schedule, scheduledKey := scheduling()
+		if *findScheduled && schedule == nil {
+			// The vaults stay tagged until they're done this time.
+			schedule = run.NewSchedule(scheduledKey)
+		}
+
+		store, err := o.openState()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		salvageOptions, err := salvage(ctx)
+		if err != nil {
+			return err
+		}
+
+		regions, done, err := o.regions(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		runner := &run.Runner{
+			Clients:  o.registry(),
+			Prompter: stdin,
+			Answers:  answers,
+			Store:    store,
+			Options: run.Options{
+				FailFast:            *failFast,
+				NoReinitiate:        *noReinitiate,
+				Salvage:             salvageOptions,
+				Inventory:           inventoryOptions,
+				WorkersPerVault:     concurrent.WorkersPerVault,
+				AdaptiveWorkers:     concurrent.AdaptiveWorkers,
+				MaxConcurrentVaults: concurrent.MaxConcurrentVaults,
+				MaxPendingJobs:      concurrent.MaxPendingJobs,
+				Order:               concurrent.Order,
+				Checkpoint:          checkpoint,
+				Progress:            o.progress,
+				Events:              o.events,
+				Schedule:            schedule,
+				Prices:              prices,
+				// An earlier run's inventory jobs are picked up where it left
+				// them.
+				ReuseInventory: *findScheduled,
+			},
+			Names: names,
+			Tags:  tagFilter,
+			Sort:  sortOptions,
+		}
+		noneScheduled := false
+		if *findScheduled {
+			runner.Narrow = func(ctx context.Context, vaults []*glacierpurge.Vault) []*glacierpurge.Vault {
+				vaults = run.FindScheduled(ctx, vaults, scheduledKey)
+				noneScheduled = len(vaults) == 0
+				return vaults
+			}
+		}
+
+		report := &run.Report{}
+		vaults, err := runner.Offer(ctx, regions, report)
+		if err != nil {
+			return err
+		}
+		if noneScheduled {
+			ui.Printf("No vaults are tagged %s; no earlier run left any unfinished.\n", scheduledKey)
+			run.SummarizeRegions(report.Regions)
+			return nil
+		}
+		if err := runner.Select(ctx, vaults, report); err != nil {
+			return err
+		}
+		if schedule != nil {
+			run.Unschedule(ctx, report.Declined, scheduledKey)
+		}
+		printSelectionSavings(ctx, prices, report.Selected)
+
+		stopWatching := o.watchRun()
+		runner.Destroy(ctx, report)
+		stopWatching()
+		err = runner.Summarize(report)
+		printDeletedSavings(prices, report.Results)
+		if *rescan {
+			if rescanErr := o.rescan(ctx, scannedRegions(report.Regions), report.Found, report.Selected); rescanErr != nil && err == nil {
+				err = rescanErr
+			}
+		}
+		return err
+	}
+}
+
+// checkpointFlags registers the flags for how often each vault's progress
+// through its inventory is recorded, and returns a function giving what they
+// set.
+func checkpointFlags(fs *flag.FlagSet) func() (run.Checkpoint, error) {
+	every := fs.Int("checkpoint-every", 1000, "Record each vault's progress through its inventory every this many archives, so a resume passes over those already deleted (0 to go by --checkpoint-interval alone)")
+	interval := fs.Duration("checkpoint-interval", 30*time.Second, "Also record each vault's progress once this long has passed since it last was (0 to go by --checkpoint-every alone)")
+
+	return func() (run.Checkpoint, error) {
+		var problems problems
+		if *every < 0 {
+			problems.add(errors.New("--checkpoint-every can't be negative"))
+		}
+		if *interval < 0 {
+			problems.add(errors.New("--checkpoint-interval can't be negative"))
+		}
+		return run.Checkpoint{Every: *every, Interval: *interval}, problems.err()
+	}
+}
+
+// concurrencyFlags registers the flags for how much deleting goes on at once,
+// the number of vaults at a time only for commands working through several,
+// and returns a function giving the run options they set.
+func concurrencyFlags(fs *flag.FlagSet, vaults bool) func() (run.Options, error) {
+	workers := fs.String("workers-per-vault", "4", "Archives of each vault to delete at once, or auto to find the most AWS allows without throttling")
+	concurrent, pending, order := new(int), new(int), new(string)
+	if vaults {
+		concurrent = fs.Int("max-concurrent-vaults", 1, "Vaults to delete from at once; above 1, every vault's inventory is waited for side by side and vaults take turns in the order theirs complete")
+		pending = fs.Int("max-pending-jobs", 0, "With --max-concurrent-vaults above 1, inventory jobs to have pending at once, starting more as earlier vaults move on to deleting (0 for no limit)")
+		order = fs.String("order", run.OrderAsSelected, "Order to work through the vaults in: as-selected, smallest-first (quick wins, and early word of whether the permissions are right), or largest-first (the longest started first); by size, vaults also take free --max-concurrent-vaults slots in that order")
+	}
+
+	return func() (run.Options, error) {
+		var problems problems
+		if vaults && *concurrent < 1 {
+			problems.add(errors.New("--max-concurrent-vaults must be at least 1"))
+		}
+		if *pending < 0 {
+			problems.add(errors.New("--max-pending-jobs can't be negative; use 0 for no limit"))
+		} else if *pending > 0 && *concurrent == 1 {
+			problems.add(errors.New("--max-pending-jobs only applies with --max-concurrent-vaults above 1, which it's not; raise that or drop --max-pending-jobs"))
+		}
+		problems.add(run.ValidateOrder(*order))
+		opts := run.Options{MaxConcurrentVaults: *concurrent, MaxPendingJobs: *pending, Order: *order}
+		if *workers == "auto" {
+			opts.AdaptiveWorkers = true
+		} else if n, err := strconv.Atoi(*workers); err != nil || n < 1 {
+			problems.add(fmt.Errorf("invalid --workers-per-vault %q: must be auto or at least 1", *workers))
+		} else {
+			opts.WorkersPerVault = n
+		}
+		if err := problems.err(); err != nil {
+			return run.Options{}, err
+		}
+		return opts, nil
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func purgeVaultFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	vault := fs.String("vault", "", "Name of the vault to destroy")
+	yes := fs.Bool("yes", false, "Destroy the vault without asking for its name to be typed")
+	noReinitiate := fs.Bool("no-reinitiate", false, "Fail a vault whose inventory job has expired instead of initiating a fresh one and waiting for it")
+	salvage := salvageFlags(fs)
+	concurrency := concurrencyFlags(fs, false)
+	checkpoints := checkpointFlags(fs)
+	inventory := inventoryOptionFlags(fs)
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		region, err := o.selection.single()
+		if problems.add(err) && (region == "" || *vault == "") {
+			problems.add(errors.New("-region and --vault are required"))
+		}
+		concurrent, err := concurrency()
+		problems.add(err)
+		checkpoint, err := checkpoints()
+		problems.add(err)
+		inventoryOptions, err := inventory()
+		problems.add(err)
+		if err := problems.err(); err != nil {
+			return err
+		}
+		o.sizeConnections(concurrent)
+
+		store, err := o.openState()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		salvageOptions, err := salvage(ctx)
+		if err != nil {
+			return err
+		}
+
+		g, err := o.registry().Get(ctx, region)
+		if err != nil {
+			return err
+		}
+		v := &glacierpurge.Vault{Glacier: g, Name: *vault}
+
+		description, err := v.Describe(ctx)
+		if err != nil {
+			return err
+		}
+		ui.Printf("Vault %s in region %s\n", v.Name, g.Region)
+		ui.Printf("  ARN:            %s\n", description.ARN)
+		ui.Printf("  Archives:       %d\n", description.NumberOfArchives)
+		ui.Printf("  Size:           %d bytes\n", description.SizeInBytes)
+		ui.Printf("  Created:        %s\n", description.CreationDate.Format("2006-01-02 15:04"))
+		if !description.LastInventoryDate.IsZero() {
+			ui.Printf("  Last inventory: %s\n", description.LastInventoryDate.Format("2006-01-02 15:04"))
+		}
+
+		if !*yes {
+			answer, err := stdin.Ask(ctx, "Type the vault's name to destroy it and every archive in it:")
+			if err != nil {
+				return err
+			}
+			if answer != v.Name {
+				return errors.New("the name didn't match; nothing was deleted")
+			}
+		}
+
+		// Glacier refuses to delete a vault written to since its last
+		// inventory, so when that inventory shows it empty there's no need to
+		// wait hours for a new one.
+		if description.NumberOfArchives == 0 && !description.LastInventoryDate.IsZero() {
+			err := v.Delete(ctx)
+			if err == nil {
+				ui.Printf("%sVault %s deleted from region %s%s\n", ui.Green, v.Name, g.Region, ui.Reset)
+				return nil
+			}
+			if !errors.Is(err, glacierpurge.ErrVaultNotEmpty) {
+				return err
+			}
+		}
+
+		stopWatching := o.watchRun()
+		results := run.Destroy(ctx, []*glacierpurge.Vault{v}, store, run.Options{
+			Salvage:        salvageOptions,
+			ReuseInventory: true,
+			DeleteVault:    true,
+			NoReinitiate:   *noReinitiate,
+			Inventory:      inventoryOptions,
+
+			WorkersPerVault: concurrent.WorkersPerVault,
+			AdaptiveWorkers: concurrent.AdaptiveWorkers,
+			Checkpoint:      checkpoint,
+			Progress:        o.progress,
+			Events:          o.events,
+		})
+		stopWatching()
+		return run.Summarize(results)
+	}
+}
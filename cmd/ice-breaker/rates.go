@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// progressBarWidth is how many characters wide a vault's progress bar is.
+const progressBarWidth = 20
+
+// showRates keeps the run's progress on display while vaults are purged: a
+// line for the run as a whole and one for each vault being worked on, below
+// the messages on a terminal, or logged every MeterWindow otherwise. The
+// returned function stops it.
+func (o *globalOptions) showRates() (stop func()) {
+	if o.meter == nil {
+		o.meter = glacierpurge.NewMeter()
+	}
+	if o.pause == nil {
+		o.pause = glacierpurge.NewPause()
+	}
+	return ui.ShowStatus(glacierpurge.MeterWindow, func() ui.Status {
+		now := time.Now()
+		total, readings := o.meter.Read()
+		rates := map[[2]string]glacierpurge.Reading{}
+		for _, r := range readings {
+			rates[[2]string{r.Region, r.Vault}] = r
+		}
+
+		var status ui.Status
+		if o.wrapUp.Requested() {
+			status.Summary = append(status.Summary, fmt.Sprintf("%s%sFINISHING EARLY%s once the deletions in flight are done", ui.Yellow, ui.Bold, ui.Reset))
+		}
+		if paused, since := o.pause.Paused(); paused {
+			status.Summary = append(status.Summary, fmt.Sprintf("%s%sPAUSED%s for %s", ui.Yellow, ui.Bold, ui.Reset, time.Since(since).Round(time.Second)))
+		}
+
+		vaults := o.progress.Vaults()
+		var active []run.VaultProgress
+		var queued, done, failed int
+		width := 0
+		for _, v := range vaults {
+			switch v.Phase {
+			case run.PhaseQueued:
+				queued++
+			case run.PhaseDone, run.PhaseStopped:
+				done++
+			case run.PhaseFailed:
+				failed++
+			default:
+				active = append(active, v)
+				width = max(width, len(v.Vault)+len(v.Region)+3)
+			}
+		}
+
+		if len(vaults) > 0 || total.Deleted > 0 || total.Active {
+			line := fmt.Sprintf("%d vault(s): %d in progress, %d queued, %d done", len(vaults), len(active), queued, done)
+			if failed > 0 {
+				line += fmt.Sprintf(", %s%d failed%s", ui.Red, failed, ui.Reset)
+			}
+			if total.Deleted > 0 || total.Active {
+				line += "; deleting " + formatRate(total)
+			}
+			status.Summary = append(status.Summary, line)
+		}
+		for _, v := range active {
+			name := fmt.Sprintf("%s [%s]", v.Vault, v.Region)
+			status.Items = append(status.Items, fmt.Sprintf("  %-*s  %s", width, name, vaultStatus(v, rates[[2]string{v.Region, v.Vault}], now)))
+		}
+		return status
+	})
+}
+
+// vaultStatus describes what a vault that's being worked on is doing: how far
+// its deletions have got, or else how long it's been in its phase.
+func vaultStatus(v run.VaultProgress, r glacierpurge.Reading, now time.Time) string {
+	if v.Phase != run.PhaseDeleting {
+		return fmt.Sprintf("%s for %s", v.Phase, since(v.Since, now))
+	}
+	gone := ""
+	if r.Absent > 0 {
+		gone = fmt.Sprintf(" (+%d already gone)", r.Absent)
+	}
+	if v.Archives <= 0 {
+		return fmt.Sprintf("deleting: %d deleted%s, %.1f/s", r.Deleted, gone, r.Recent)
+	}
+
+	// Archives already gone are as done as the deleted ones.
+	done := r.Deleted + r.Absent
+	share := min(float64(done)/float64(v.Archives), 1)
+	filled := int(share * progressBarWidth)
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled)
+	eta := "ETA unknown"
+	if left := v.Archives - int64(done); left <= 0 {
+		eta = "nearly done"
+	} else if r.Recent > 0 {
+		eta = "ETA " + (time.Duration(float64(left)/r.Recent) * time.Second).Round(time.Second).String()
+	}
+	return fmt.Sprintf("deleting [%s] %3.0f%%  %d%s of about %d, %.1f/s, %s", bar, share*100, r.Deleted, gone, v.Archives, r.Recent, eta)
+}
+
+func formatRate(r glacierpurge.Reading) string {
+	gone := ""
+	if r.Absent > 0 {
+		gone = fmt.Sprintf(", %d already gone", r.Absent)
+	}
+	return fmt.Sprintf("%.1f archives/s over the last %s, %.1f/s overall (%d deleted%s), %.0f%% throttled or failed",
+		r.Recent, glacierpurge.MeterWindow, r.Overall, r.Deleted, gone, r.Problems*100)
+}
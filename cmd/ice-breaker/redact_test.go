@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// sentinel is a secret no output may repeat.
+const sentinel = "SENTINEL-SECRET-4f1d9c2b"
+
+// TestSecretsRedactedEndToEnd gives the secret key to a run whose every
+// output is on, and has Glacier repeat it in an error, as a service echoing
+// a request might, and checks each output has it masked rather than not at
+// all.
+func TestSecretsRedactedEndToEnd(t *testing.T) {
+	fake := glaciertest.New()
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 3)})
+	failing := fake.Archives("vault")[1].Id
+	fake.Intercept(glaciertest.OpDeleteArchive, func(input any) error {
+		if aws.ToString(input.(*glacier.DeleteArchiveInput).ArchiveId) == failing {
+			return glaciertest.Error(403, "AccessDeniedException", "not with the key "+sentinel)
+		}
+		return nil
+	})
+	c := newCLI(t, fake)
+	dir := t.TempDir()
+	files := map[string]string{
+		"events file": filepath.Join(dir, "events.jsonl"),
+		"audit log":   filepath.Join(dir, "audit.jsonl"),
+		"recording":   filepath.Join(dir, "recording", "calls.jsonl"),
+	}
+
+	output, _ := c.run("nuke", "--yes", "--vault", "vault", "--secret", sentinel,
+		"--debug-aws-bodies",
+		"--events-file", files["events file"],
+		"--audit-log", files["audit log"],
+		"--record", filepath.Dir(files["recording"]))
+
+	outputs := map[string]string{"log": output}
+	for name, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("the %s wasn't written: %v\n%s", name, err, output)
+		}
+		outputs[name] = string(data)
+	}
+	for name, text := range outputs {
+		if strings.Contains(text, sentinel) {
+			t.Errorf("the %s has the secret:\n%s", name, text)
+		}
+		if !strings.Contains(text, "[redacted]") {
+			t.Errorf("the %s doesn't have the error with the secret masked:\n%s", name, text)
+		}
+	}
+}
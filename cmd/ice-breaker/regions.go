@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// validateRegions checks every name against the known regions, dropping
+// duplicates while preserving the order the user gave them in.
+func validateRegions(names []string) ([]string, error) {
+	seen := make(map[string]bool)
+
+	var regions []string
+	for _, name := range names {
+		if err := glacierpurge.CheckRegion(name); err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			regions = append(regions, name)
+		}
+	}
+
+	return regions, nil
+}
+
+// regionSelection holds the flags that determine which regions are scanned.
+type regionSelection struct {
+	Region         stringList // -region, which may be repeated
+	Positional     []string   // region names given as arguments
+	Regions        stringList
+	ExcludeRegions stringList
+	AllRegions     bool
+	IncludeGov     bool
+	IncludeChina   bool
+}
+
+// named returns every region named by -region, argument, or --regions.
+func (s *regionSelection) named() []string {
+	named := append([]string{}, s.Region...)
+	named = append(named, s.Positional...)
+	return append(named, s.Regions...)
+}
+
+// single returns the region named for the commands that work in one region,
+// or "" if none was. Naming more than one is an error. The names themselves
+// are left for validate to check.
+func (s *regionSelection) single() (string, error) {
+	var named []string
+	for _, name := range s.named() {
+		if !slices.Contains(named, name) {
+			named = append(named, name)
+		}
+	}
+	if len(named) > 1 {
+		return "", fmt.Errorf("this command works in one region, but %d were given: %s", len(named), strings.Join(named, ", "))
+	}
+	if len(named) == 0 {
+		return "", nil
+	}
+	return named[0], nil
+}
+
+// resolve returns the regions to scan, with ExcludeRegions removed from
+// whatever list the other options produce.
+func (s *regionSelection) resolve(ctx context.Context, settings *glacierpurge.ClientSettings) ([]string, error) {
+	excluded, err := validateRegions(s.ExcludeRegions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --exclude-regions: %w", err)
+	}
+	// Naming a region and excluding it can't both be meant.
+	for _, region := range s.named() {
+		if slices.Contains(excluded, region) {
+			return nil, fmt.Errorf("region %s is both asked for and excluded by --exclude-regions", region)
+		}
+	}
+
+	regions, err := s.candidates(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool)
+	for _, region := range excluded {
+		skip[region] = true
+	}
+
+	var scan []string
+	for _, region := range regions {
+		if !skip[region] {
+			scan = append(scan, region)
+		}
+	}
+
+	// A custom endpoint replaces AWS entirely, so there are no real endpoints
+	// or partitions to check against.
+	if settings.EndpointURL != "" {
+		return scan, nil
+	}
+
+	if settings.UseFIPS {
+		if scan, err = s.checkFIPS(scan); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.checkPartitions(ctx, scan, settings)
+}
+
+// checkFIPS removes regions without a Glacier FIPS endpoint. Naming such a
+// region explicitly is an error rather than a silent fallback to the standard
+// endpoint.
+func (s *regionSelection) checkFIPS(regions []string) ([]string, error) {
+	explicit := len(s.named()) > 0
+
+	var supported, unsupported []string
+	for _, region := range regions {
+		if glacierpurge.HasFIPSEndpoint(region) {
+			supported = append(supported, region)
+		} else {
+			unsupported = append(unsupported, region)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		if explicit {
+			return nil, fmt.Errorf("--fips is set but Glacier has no FIPS endpoint in %s", strings.Join(unsupported, ", "))
+		}
+		ui.Printf("%sNot scanning %d region(s) without a Glacier FIPS endpoint: %s%s\n", ui.Yellow, len(unsupported), strings.Join(unsupported, ", "), ui.Reset)
+	}
+
+	return supported, nil
+}
+
+// checkPartitions confirms the credentials are valid in the partition of
+// every region to be scanned. Regions the user asked for by name must all
+// match or the run fails before anything else happens; regions that only came
+// from the default sweep are dropped with a note instead.
+func (s *regionSelection) checkPartitions(ctx context.Context, regions []string, settings *glacierpurge.ClientSettings) ([]string, error) {
+	explicit := len(s.named()) > 0
+
+	byPartition := make(map[string][]string)
+	var order []string
+	for _, region := range regions {
+		partition := glacierpurge.PartitionOf(region)
+		if _, ok := byPartition[partition]; !ok {
+			order = append(order, partition)
+		}
+		byPartition[partition] = append(byPartition[partition], region)
+	}
+
+	var valid []string
+	for _, partition := range order {
+		members := byPartition[partition]
+		credentialPartition, err := glacierpurge.CallerPartition(ctx, members[0], settings)
+		if err != nil && !glacierpurge.IsUnrecognizedCredentials(err) {
+			ui.Printf("%sCould not verify the credentials against the %s partition: %v%s\n", ui.Yellow, partition, err, ui.Reset)
+			valid = append(valid, members...)
+			continue
+		}
+
+		if err == nil && credentialPartition == partition {
+			valid = append(valid, members...)
+			continue
+		}
+
+		if explicit {
+			return nil, fmt.Errorf("the credentials don't belong to the %s partition, so regions %s can't be scanned with them", partition, strings.Join(members, ", "))
+		}
+		ui.Printf("%sNot scanning %d %s region(s): the credentials belong to a different partition.%s\n", ui.Yellow, len(members), partition, ui.Reset)
+	}
+
+	if len(valid) == 0 && len(regions) > 0 {
+		return nil, errors.New("the credentials aren't valid in the partition of any region selected for scanning")
+	}
+
+	return valid, nil
+}
+
+// candidates returns the regions to scan before exclusions. Explicitly
+// requested regions win; otherwise every Glacier region is scanned, narrowed
+// to the regions enabled for the account unless AllRegions is set.
+func (s *regionSelection) candidates(ctx context.Context, settings *glacierpurge.ClientSettings) ([]string, error) {
+	if named := s.named(); len(named) > 0 {
+		return validateRegions(named)
+	}
+
+	regions := glacierpurge.Regions(glacierpurge.PartitionAWS)
+	if !s.AllRegions && settings.EndpointURL == "" {
+		enabled, err := glacierpurge.EnabledRegions(ctx, settings)
+		if err != nil {
+			ui.Printf("%sCould not determine the account's enabled regions, scanning all regions instead: %v%s\n", ui.Yellow, err, ui.Reset)
+		} else {
+			var scan []string
+			for _, region := range regions {
+				if enabled[region] {
+					scan = append(scan, region)
+				}
+			}
+			regions = scan
+		}
+	}
+
+	// GovCloud and China regions live in their own partitions and can only be
+	// reached with credentials from those partitions, so they're opt-in.
+	if s.IncludeGov {
+		regions = append(regions, glacierpurge.Regions(glacierpurge.PartitionGov)...)
+	}
+	if s.IncludeChina {
+		regions = append(regions, glacierpurge.Regions(glacierpurge.PartitionChina)...)
+	}
+
+	return regions, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// rescan scans the regions again, read-only, once a run is over, and reports
+// what became of the vaults found before it: in the summary, and on stdout
+// with --output json.
+func (o *globalOptions) rescan(ctx context.Context, regions []string, before, selected []*glacierpurge.Vault) error {
+	if ctx.Err() != nil {
+		ui.Printf("%sNot rescanning: the run was interrupted.%s\n", ui.Yellow, ui.Reset)
+		return nil
+	}
+	entries, scanned := run.Rescan(ctx, o.registry(glacierpurge.WithReadOnly()), regions, before, selected)
+	run.SummarizeRescan(entries)
+	if o.output != "json" {
+		return nil
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Version string              `json:"version"`
+		Vaults  []*run.RescanEntry  `json:"vaults"`
+		Regions []*run.RegionResult `json:"regions"`
+	}{buildVersion(), entries, scanned})
+}
+
+// scannedRegions returns the regions that were scanned, leaving out those that
+// were skipped: a rescan would find their vaults all new.
+func scannedRegions(results []*run.RegionResult) []string {
+	var regions []string
+	for _, result := range results {
+		if result.Status != run.RegionSkipped {
+			regions = append(regions, result.Region)
+		}
+	}
+	return regions
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/state"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func resumeFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	failFast := fs.Bool("fail-fast", false, "Stop the whole run at the first vault that fails instead of carrying on with the rest")
+	noReinitiate := fs.Bool("no-reinitiate", false, "Fail a vault whose inventory job has expired instead of initiating a fresh one and waiting for it")
+	retryFailed := fs.Bool("retry-failed", false, "Retry the vaults an earlier run failed without asking; otherwise they're listed and you're asked")
+	salvage := salvageFlags(fs)
+	concurrency := concurrencyFlags(fs, true)
+	checkpoints := checkpointFlags(fs)
+	scheduling := scheduleFlags(fs)
+	loadPrices := pricesFlag(fs)
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		concurrent, err := concurrency()
+		problems.add(err)
+		checkpoint, err := checkpoints()
+		problems.add(err)
+		prices, err := loadPrices()
+		problems.add(err)
+		if err := problems.err(); err != nil {
+			return err
+		}
+		o.sizeConnections(concurrent)
+
+		store, err := o.openState()
+		if err != nil {
+			return err
+		}
+		failed := store.Outcomes(state.StatusFailed)
+		if len(store.State.Jobs) == 0 && len(failed) == 0 && len(store.Outcomes(state.StatusDeletePending)) == 0 {
+			ui.Println("No inventory jobs or unfinished vaults are recorded; there is nothing to resume.")
+			return nil
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		retry := *retryFailed
+		if len(failed) > 0 {
+			ui.Printf("%s%d vault(s) failed in an earlier run:%s\n", ui.Yellow, len(failed), ui.Reset)
+			for _, outcome := range failed {
+				ui.Printf("  [%s] %s, at %s: %s\n", outcome.Region, outcome.Vault, outcome.At.Local().Format("2006-01-02 15:04"), outcome.Reason)
+			}
+			if !retry && !stdin.NoInput {
+				retry, err = stdin.Confirm(ctx, fmt.Sprintf("Retry these %d vault(s)?", len(failed)))
+				if err != nil && !errors.Is(err, io.EOF) {
+					return err
+				}
+			}
+			if !retry {
+				ui.Println("Leaving them for now; resume with --retry-failed to retry them.")
+			}
+		}
+
+		salvageOptions, err := salvage(ctx)
+		if err != nil {
+			return err
+		}
+
+		schedule, _ := scheduling()
+		stopWatching := o.watchRun()
+		runner := &run.Runner{Clients: o.registry(), Store: store, Options: run.Options{
+			FailFast:            *failFast,
+			NoReinitiate:        *noReinitiate,
+			RetryFailed:         retry,
+			Salvage:             salvageOptions,
+			WorkersPerVault:     concurrent.WorkersPerVault,
+			AdaptiveWorkers:     concurrent.AdaptiveWorkers,
+			MaxConcurrentVaults: concurrent.MaxConcurrentVaults,
+			MaxPendingJobs:      concurrent.MaxPendingJobs,
+			Order:               concurrent.Order,
+			Checkpoint:          checkpoint,
+			Progress:            o.progress,
+			Events:              o.events,
+			Schedule:            schedule,
+			Prices:              prices,
+		}}
+		report := runner.Resume(ctx)
+		stopWatching()
+		err = runner.Summarize(report)
+		printDeletedSavings(prices, report.Results)
+		return err
+	}
+}
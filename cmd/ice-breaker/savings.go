@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/pricing"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// pricesFlag registers the flag overriding the embedded price table. The
+// returned function loads the table once it's parsed.
+func pricesFlag(fs *flag.FlagSet) func() (*pricing.Table, error) {
+	path := fs.String("prices", "", "JSON file of Glacier storage prices per region to estimate savings with, over the built-in ones")
+
+	return func() (*pricing.Table, error) {
+		return pricing.Load(*path)
+	}
+}
+
+// printSelectionSavings estimates what destroying the selected vaults saves,
+// going by the sizes Glacier reports for them, and what destroying those
+// created less than the minimum storage duration ago costs in early-deletion
+// fees. Their archives are no older than they are, so a vault's fee is at
+// least what it would be were they all as old as it.
+func printSelectionSavings(ctx context.Context, prices *pricing.Table, vaults []*glacierpurge.Vault) {
+	if len(vaults) == 0 {
+		return
+	}
+	now := time.Now()
+	savings := prices.Savings()
+	var fees float64
+	young, unpriced := 0, false
+	for _, vault := range vaults {
+		description, err := vault.Describe(ctx)
+		if err != nil {
+			continue
+		}
+		savings.Add(vault.Glacier.Region, description.SizeInBytes)
+
+		freeFrom := description.CreationDate.Add(prices.MinimumStorage())
+		if !now.Before(freeFrom) || description.SizeInBytes == 0 {
+			continue
+		}
+		young++
+		fee, ok := prices.EarlyDeletionFee(vault.Glacier.Region, description.SizeInBytes, description.CreationDate, now)
+		estimate := "an unknown amount, the price table having no price for the region,"
+		if ok {
+			fees += fee
+			estimate = "an estimated " + prices.Format(fee) + " or more"
+		} else {
+			unpriced = true
+		}
+		ui.Printf("%sVault %s in region %s was created %s, less than %d days ago: deleting its archives now costs %s in early-deletion fees, and nothing from %s.%s\n", ui.Yellow, vault.Name, vault.Glacier.Region, description.CreationDate.Local().Format("2006-01-02"), prices.MinimumStorageDays, estimate, freeFrom.Local().Format("2006-01-02"), ui.Reset)
+	}
+	ui.Printf("%d vault(s) selected, holding %s.\n", len(vaults), savings)
+	if young > 0 {
+		more := ""
+		if unpriced {
+			more = ", not counting those in regions the price table has no price for"
+		}
+		ui.Printf("%sEarly-deletion fees: an estimated %s or more for the %d vault(s) created less than %d days ago%s.%s\n", ui.Yellow, prices.Format(fees), young, prices.MinimumStorageDays, more, ui.Reset)
+	}
+}
+
+// printDeletedSavings estimates what the archives the run deleted save, and
+// the early-deletion fees of those deleted too soon. Archives that were
+// already gone saved nothing this run, so they're left out.
+func printDeletedSavings(prices *pricing.Table, results []*run.VaultResult) {
+	savings := prices.Savings()
+	fees := prices.EarlyFees()
+	deleted, absent := 0, 0
+	var young []*run.VaultResult
+	for _, result := range results {
+		if result.Purge != nil && result.Purge.Deleted > 0 {
+			savings.Add(result.Vault.Glacier.Region, result.Purge.DeletedBytes)
+			deleted += result.Purge.Deleted
+		}
+		if result.Purge != nil {
+			absent += result.Purge.Absent
+		}
+		if result.EarlyFees == nil {
+			continue
+		}
+		if _, archives, _ := result.EarlyFees.Total(); archives > 0 {
+			young = append(young, result)
+			fees.Merge(result.EarlyFees)
+		}
+	}
+	if deleted == 0 {
+		return
+	}
+
+	ui.Printf("Deleted %d archive(s), %s.\n", deleted, savings)
+	if absent > 0 {
+		ui.Printf("%d more archive(s) were already gone, so they're not counted.\n", absent)
+	}
+	if len(young) == 0 {
+		return
+	}
+	ui.Printf("%sIn all, %s:%s\n", ui.Yellow, fees, ui.Reset)
+	for _, result := range young {
+		ui.Printf("%s  [%s] %s: %s%s\n", ui.Yellow, result.Vault.Glacier.Region, result.Vault.Name, result.EarlyFees, ui.Reset)
+	}
+}
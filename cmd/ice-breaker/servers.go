@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rdegges/ice-breaker/internal/redact"
+)
+
+// backgroundServer is an HTTP server that runs alongside a command, such as
+// the pprof one, and is shut down with it.
+type backgroundServer struct {
+	name   string
+	server *http.Server
+}
+
+// startServer listens on addr and serves handler in the background.
+func startServer(name, addr string, handler http.Handler) (*backgroundServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the %s server: %w", name, err)
+	}
+
+	s := &backgroundServer{name: name, server: &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("The %s server stopped: %v", name, err)
+		}
+	}()
+	log.Printf("Serving %s on http://%s/", name, listener.Addr())
+	return s, nil
+}
+
+// stop shuts the server down, giving requests in progress a moment to finish.
+func (s *backgroundServer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.server.Shutdown(ctx)
+}
+
+// pprofHandler serves the net/http/pprof profiles under /debug/pprof/.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// cmdline serves the command line as pprof.Cmdline does, but with the
+// secrets given on it masked.
+func cmdline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, redact.String(strings.Join(os.Args, "\x00")))
+}
+
+// checkLoopback refuses an address that isn't on the loopback interface: the
+// profiles are served to anyone who can reach them, unauthenticated.
+func checkLoopback(flag, addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", flag, addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%s %q would serve profiles to anyone who can reach this machine; use a loopback address such as localhost:6060, and an SSH tunnel to reach it from elsewhere", flag, addr)
+}
+
+// startServers starts the background servers the flags ask for.
+func (o *globalOptions) startServers() error {
+	if o.pprofAddr != "" {
+		if err := checkLoopback("--pprof-addr", o.pprofAddr); err != nil {
+			return err
+		}
+		s, err := startServer("pprof", o.pprofAddr, pprofHandler())
+		if err != nil {
+			return err
+		}
+		o.servers = append(o.servers, s)
+	}
+	return nil
+}
+
+// stopServers shuts down every background server.
+func (o *globalOptions) stopServers() {
+	for _, s := range o.servers {
+		s.stop()
+	}
+	o.servers = nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// snapshotFile is the file in the state directory that asks for a snapshot
+// of the run, for where there's no signal to send.
+const snapshotFile = "snapshot"
+
+// snapshotPoll is how often the state directory is checked for snapshotFile.
+const snapshotPoll = 2 * time.Second
+
+// watchRun shows the deletion rates while vaults are purged, prints a
+// snapshot of the run on snapshotSignals or when snapshotFile is created,
+// pauses or resumes deleting on pauseSignals or p typed on the terminal, and
+// finishes early on q. The returned function stops all of it.
+func (o *globalOptions) watchRun() (stop func()) {
+	if o.progress == nil {
+		o.progress = run.NewProgress()
+	}
+	if o.pause == nil {
+		o.pause = glacierpurge.NewPause()
+	}
+	stopRates := o.showRates()
+	stopEvents := o.writeEvents()
+	started := time.Now()
+
+	stopListening, listening := stdin.Listen(func(text string) {
+		switch text {
+		case "p":
+			o.togglePause()
+		case "q":
+			o.requestWrapUp()
+		}
+	})
+	if listening {
+		ui.Println("Type p and Enter to pause deleting, and again to resume; q and Enter to finish early.")
+	}
+
+	signals := make(chan os.Signal, 1)
+	if len(snapshotSignals) > 0 {
+		signal.Notify(signals, snapshotSignals...)
+	}
+	pauses := make(chan os.Signal, 1)
+	if len(pauseSignals) > 0 {
+		signal.Notify(pauses, pauseSignals...)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(snapshotPoll)
+		defer ticker.Stop()
+		requested := filepath.Join(o.stateDir, snapshotFile)
+		for {
+			select {
+			case <-done:
+				return
+			case <-pauses:
+				o.togglePause()
+				continue
+			case <-signals:
+			case <-ticker.C:
+				if err := os.Remove(requested); err != nil {
+					if !errors.Is(err, os.ErrNotExist) {
+						ui.Debugf("Failed to remove %s: %v", requested, err)
+					}
+					continue
+				}
+			}
+			ui.Report(o.snapshot(started))
+		}
+	}()
+
+	return func() {
+		stopListening()
+		signal.Stop(pauses)
+		ignoreSnapshotSignals()
+		close(done)
+		wg.Wait()
+		stopRates()
+		stopEvents()
+	}
+}
+
+// ignoreSnapshotSignals keeps snapshotSignals from killing the process, as
+// they would by default.
+func ignoreSnapshotSignals() {
+	if len(snapshotSignals) > 0 {
+		signal.Ignore(snapshotSignals...)
+	}
+}
+
+// snapshot describes what every vault of the run is doing.
+func (o *globalOptions) snapshot(started time.Time) string {
+	now := time.Now()
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n=== Snapshot at %s, %s into the run ===\n", now.Format("2006-01-02 15:04:05"), since(started, now))
+	if o.wrapUp.Requested() {
+		b.WriteString("Finishing early, as asked\n")
+	}
+	if paused, at := o.pause.Paused(); paused {
+		fmt.Fprintf(&b, "Deleting is PAUSED, for %s so far\n", since(at, now))
+	}
+
+	total, readings := o.meter.Read()
+	rates := map[[2]string]glacierpurge.Reading{}
+	for _, r := range readings {
+		rates[[2]string{r.Region, r.Vault}] = r
+	}
+	if total.Deleted > 0 || total.Failed > 0 || total.Absent > 0 {
+		fmt.Fprintf(&b, "All vaults: %s\n", formatRate(total))
+	}
+
+	for _, v := range o.progress.Vaults() {
+		fmt.Fprintf(&b, "%s in region %s: %s for %s", v.Vault, v.Region, v.Phase, since(v.Since, now))
+		if v.Err != nil {
+			fmt.Fprintf(&b, ": %v", v.Err)
+		}
+		b.WriteString("\n")
+		if v.JobId != "" && (v.Phase == run.PhaseInventory || v.Phase == run.PhaseQueued) {
+			fmt.Fprintf(&b, "  inventory job %s", v.JobId)
+			if !v.JobSince.IsZero() {
+				fmt.Fprintf(&b, ", initiated %s ago", since(v.JobSince, now))
+			}
+			b.WriteString("\n")
+		}
+		if r, ok := rates[[2]string{v.Region, v.Vault}]; ok {
+			failed := fmt.Sprint(r.Failed)
+			if r.Failed > 0 {
+				failed += " (" + r.FailedBy.String() + ")"
+			}
+			fmt.Fprintf(&b, "  %d deleted, %s failed; %s\n", r.Deleted, failed, formatRate(r))
+		}
+	}
+
+	if errs := o.meter.Errors(); len(errs) > 0 {
+		b.WriteString("Latest failed deletions:\n")
+		for _, e := range errs {
+			fmt.Fprintf(&b, "  %s %s in region %s: %s: %v\n", e.Time.Format("15:04:05"), e.Vault, e.Region, e.Class, e.Err)
+		}
+	}
+	b.WriteString("===\n\n")
+	return b.String()
+}
+
+func since(t, now time.Time) time.Duration {
+	return now.Sub(t).Round(time.Second)
+}
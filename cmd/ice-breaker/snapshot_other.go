@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// snapshotSignals ask for a snapshot of the run. There's no signal to spare
+// here, so only the snapshot file does.
+var snapshotSignals []os.Signal
+
+// pauseSignals pause deleting, or resume it. Typing p does it here.
+var pauseSignals []os.Signal
+
+// stopSignals ask the process to stop.
+var stopSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
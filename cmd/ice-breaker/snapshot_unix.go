@@ -0,0 +1,18 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// snapshotSignals ask for a snapshot of the run.
+var snapshotSignals = []os.Signal{syscall.SIGUSR1}
+
+// pauseSignals pause deleting, or resume it, in place of stopping the process.
+var pauseSignals = []os.Signal{syscall.SIGTSTP}
+
+// stopSignals ask the process to stop: an interrupt, kill's default, and the
+// terminal going away.
+var stopSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/run"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func statusFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+
+	return func(o *globalOptions) error {
+		if err := o.validate(); err != nil {
+			return err
+		}
+
+		// Only reading, so there's no need to wait for a run holding the lock.
+		store, err := o.loadState()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+
+		statuses := run.Status(ctx, o.registry(glacierpurge.WithReadOnly()), store)
+
+		if o.output == "json" {
+			if statuses == nil {
+				statuses = []*run.JobStatus{}
+			}
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(statuses)
+		}
+
+		if len(statuses) == 0 {
+			ui.Println("No inventory jobs are recorded.")
+			return nil
+		}
+		for _, s := range statuses {
+			if s.Err != nil {
+				fmt.Fprintf(stdout, "[%s] %s: %s: %v\n", s.Region, s.Vault, s.JobId, s.Err)
+				continue
+			}
+			fmt.Fprintf(stdout, "[%s] %s: %s: %s (initiated %s)\n", s.Region, s.Vault, s.JobId, s.Status, s.InitiatedAt.Format("2006-01-02 15:04"))
+		}
+		return nil
+	}
+}
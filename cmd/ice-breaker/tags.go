@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+
+	"github.com/rdegges/ice-breaker/internal/run"
+)
+
+// tagFlags registers the flags picking vaults by their tags. The returned
+// function gives the filter once they're parsed.
+func tagFlags(fs *flag.FlagSet) func() (run.TagFilter, error) {
+	var match, exclude stringList
+	fs.Var(&match, "tag", "Only include vaults with every one of these comma-separated key=value tags (may be repeated)")
+	fs.Var(&exclude, "exclude-tag", "Leave alone any vault with one of these comma-separated key=value tags (may be repeated)")
+
+	return func() (run.TagFilter, error) {
+		var filter run.TagFilter
+		var problems problems
+		for _, s := range match {
+			tag, err := run.ParseTag(s)
+			if problems.add(err) {
+				filter.Match = append(filter.Match, tag)
+			}
+		}
+		for _, s := range exclude {
+			tag, err := run.ParseTag(s)
+			if !problems.add(err) {
+				continue
+			}
+			if slices.Contains(filter.Match, tag) {
+				problems.add(fmt.Errorf("--tag and --exclude-tag both give %s, so no vault could be picked; drop one of them", tag))
+			}
+			filter.Exclude = append(filter.Exclude, tag)
+		}
+		return filter, problems.err()
+	}
+}
+
+// scheduleFlags registers the flags tagging a run's vaults as scheduled for
+// deletion. The returned function gives the run's Schedule, nil unless
+// they're to be tagged, and the tag's key either way.
+func scheduleFlags(fs *flag.FlagSet) func() (*run.Schedule, string) {
+	tag := fs.Bool("tag-scheduled", false, "Tag each vault as scheduled for deletion until it's done, so the vaults of an interrupted run can be found with 'purge --find-scheduled'")
+	key := fs.String("scheduled-tag-key", run.DefaultScheduledTag, "Key of the tag --tag-scheduled puts on vaults")
+
+	return func() (*run.Schedule, string) {
+		if !*tag {
+			return nil, *key
+		}
+		return run.NewSchedule(*key), *key
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// problems collects what's wrong with a command's flags, so a run reports
+// them all at once, before any AWS call, rather than one per attempt.
+type problems []error
+
+// add notes err, if it isn't nil, and reports whether it was. The problems
+// in an error from another problems' err are noted one by one.
+func (p *problems) add(err error) bool {
+	if err == nil {
+		return true
+	}
+	if list, ok := err.(problemList); ok {
+		*p = append(*p, list...)
+	} else {
+		*p = append(*p, err)
+	}
+	return false
+}
+
+// err returns nil if there are no problems, the one problem if there's one,
+// and a list of them otherwise.
+func (p problems) err() error {
+	switch len(p) {
+	case 0:
+		return nil
+	case 1:
+		return p[0]
+	}
+	return problemList(p)
+}
+
+// problemList is the error listing several problems.
+type problemList []error
+
+func (l problemList) Error() string {
+	lines := make([]string, len(l))
+	for i, err := range l {
+		lines[i] = "  - " + err.Error()
+	}
+	return fmt.Sprintf("%d problems with the flags:\n%s", len(l), strings.Join(lines, "\n"))
+}
+
+func (l problemList) Unwrap() []error {
+	return l
+}
+
+// nonNegative checks that a duration flag isn't negative.
+func nonNegative(flag string, d time.Duration) error {
+	if d < 0 {
+		return errors.New(flag + " can't be negative; use 0 for no limit")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// validationCase is a command line a command's validation turns down, and
+// the problems it should report, all at once.
+type validationCase struct {
+	name string
+	args []string
+	want []string
+}
+
+// checkValidation runs command with each case's args, pointed at a fake
+// Glacier, and checks it reports the problems wanted before making any call.
+func checkValidation(t *testing.T, command string, cases []validationCase) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cleanEnv(t)
+			messages, colors := ui.Messages, ui.Colors
+			ui.Messages = io.Discard
+			t.Cleanup(func() { ui.Messages, ui.Colors = messages, colors })
+			fake := glaciertest.New()
+			server := glaciertest.NewServer(fake)
+			t.Cleanup(server.Close)
+
+			fs := flag.NewFlagSet(command, flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			o := newGlobalOptions(fs)
+			run := lookupCommand(command).flags(fs)
+			args := append([]string{"--endpoint-url", server.URL, "--state-dir", t.TempDir()}, c.args...)
+			err := o.parse(fs, args)
+			if err == nil {
+				err = run(o)
+			}
+			if err == nil {
+				t.Fatalf("%s %s got no error", command, strings.Join(c.args, " "))
+			}
+			for _, want := range c.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("got %q, want %q in it", err, want)
+				}
+			}
+			if len(c.want) > 1 {
+				if list, ok := err.(problemList); !ok || len(list) != len(c.want) {
+					t.Errorf("got %q, want the %d problems listed together", err, len(c.want))
+				}
+			}
+			if calls := fake.Calls(); len(calls) > 0 {
+				t.Errorf("made %d call(s) before turning the flags down", len(calls))
+			}
+		})
+	}
+}
+
+func TestValidateGlobalFlags(t *testing.T) {
+	checkValidation(t, "list", []validationCase{
+		{name: "key without its secret", args: []string{"--id", "AKIDEXAMPLE"}, want: []string{"Access Key ID and Secret Access Key go together"}},
+		{name: "profile with keys", args: []string{"--profile", "dev", "--id", "AKIDEXAMPLE", "--secret", "secretkey"}, want: []string{"--profile can't be combined"}},
+		{name: "prompt without input", args: []string{"--prompt-credentials", "--no-input"}, want: []string{"--prompt-credentials needs to ask"}},
+		{name: "record and replay", args: []string{"--record", t.TempDir(), "--replay", t.TempDir()}, want: []string{"--record and --replay can't be used together"}},
+		{name: "bad region", args: []string{"--region", "us-nowhere-9"}, want: []string{"invalid -region"}},
+		{name: "fips with an endpoint", args: []string{"--fips", "--dualstack"}, want: []string{"--fips can't be used with --endpoint-url", "--dualstack can't be used with --endpoint-url"}},
+		{name: "bad artifact encryption", args: []string{"--artifact-sse", "rot13", "--artifact-sse-kms-key-id", "key"}, want: []string{`invalid --artifact-sse "rot13"`, "--artifact-sse-kms-key-id needs"}},
+		{name: "negative durations", args: []string{"--timeout", "-1s", "--prompt-timeout", "-1m", "--max-request-rate", "-2"}, want: []string{"--timeout can't be negative", "--prompt-timeout can't be negative", "--max-request-rate can't be negative"}},
+		{name: "output the command can't give", args: []string{"--output", "csv"}, want: []string{`invalid --output "csv": must be one of text, json`}},
+	})
+}
+
+func TestValidateList(t *testing.T) {
+	checkValidation(t, "list", []validationCase{
+		{name: "a tag both wanted and excluded", args: []string{"--tag", "env=prod", "--exclude-tag", "env=prod"}, want: []string{"--tag and --exclude-tag both give env=prod"}},
+	})
+}
+
+func TestValidateListVaults(t *testing.T) {
+	checkValidation(t, "list-vaults", []validationCase{
+		{name: "bad grouping", args: []string{"--group-by", "colour"}, want: []string{`invalid --group-by "colour"`}},
+		{name: "bad grouping and tags", args: []string{"--group-by", "colour", "--tag", "a=b", "--exclude-tag", "a=b"}, want: []string{"invalid --group-by", "--tag and --exclude-tag"}},
+	})
+}
+
+func TestValidateListArchives(t *testing.T) {
+	checkValidation(t, "list-archives", []validationCase{
+		{name: "no vault", args: []string{"--region", "us-east-1"}, want: []string{"-region and --vault are required"}},
+		{name: "two regions", args: []string{"--regions", "us-east-1,us-west-2", "--vault", "photos"}, want: []string{"this command works in one region, but 2 were given"}},
+		{name: "csv allowed, yaml not", args: []string{"--region", "us-east-1", "--vault", "photos", "--output", "yaml"}, want: []string{"must be one of text, json, csv"}},
+	})
+}
+
+func TestValidateDownloadInventory(t *testing.T) {
+	checkValidation(t, "download-inventory", []validationCase{
+		{name: "nothing given", want: []string{"-region, --vault, and --out are required"}},
+		{name: "bad format", args: []string{"--region", "us-east-1", "--vault", "photos", "--out", "inventory.xml", "--format", "xml"}, want: []string{`invalid --format "xml"`}},
+	})
+}
+
+func TestValidateInventory(t *testing.T) {
+	checkValidation(t, "inventory", []validationCase{
+		{name: "bad start date", args: []string{"--inventory-start-date", "yesterday"}, want: []string{`invalid --inventory-start-date "yesterday": must be an RFC 3339 time`}},
+	})
+}
+
+func TestValidateWait(t *testing.T) {
+	checkValidation(t, "wait", []validationCase{
+		{name: "nothing to wait for", want: []string{"--region, --vault and --job-id are required"}},
+		{name: "state and a job", args: []string{"--any-from-state", "--vault", "photos"}, want: []string{"--any-from-state waits for the jobs in the state file"}},
+		{name: "all without state", args: []string{"--region", "us-east-1", "--vault", "photos", "--job-id", "job", "--all"}, want: []string{"--all only applies with --any-from-state"}},
+	})
+}
+
+func TestValidatePurge(t *testing.T) {
+	checkValidation(t, "purge", []validationCase{
+		{name: "no vaults at a time", args: []string{"--max-concurrent-vaults", "0"}, want: []string{"--max-concurrent-vaults must be at least 1"}},
+		{name: "pending jobs without concurrency", args: []string{"--max-pending-jobs", "3"}, want: []string{"--max-pending-jobs only applies with --max-concurrent-vaults above 1"}},
+		{name: "bad workers and checkpoints", args: []string{"--workers-per-vault", "lots", "--checkpoint-every", "-1", "--checkpoint-interval", "-1s"}, want: []string{`invalid --workers-per-vault "lots"`, "--checkpoint-every can't be negative", "--checkpoint-interval can't be negative"}},
+		{name: "bad order", args: []string{"--order", "random"}, want: []string{`invalid order "random": must be as-selected, smallest-first, or largest-first`}},
+		{name: "missing answers", args: []string{"--answers", "no-such-answers-file"}, want: []string{"no-such-answers-file"}},
+	})
+}
+
+func TestValidatePlan(t *testing.T) {
+	checkValidation(t, "plan", []validationCase{
+		{name: "missing prices", args: []string{"--prices", "no-such-prices-file"}, want: []string{"no-such-prices-file"}},
+	})
+}
+
+func TestValidateApply(t *testing.T) {
+	checkValidation(t, "apply", []validationCase{
+		{name: "no plan", want: []string{"usage: ice-breaker apply [flags] PLAN"}},
+		{name: "no plan, no workers", args: []string{"--workers-per-vault", "0"}, want: []string{"usage: ice-breaker apply", "invalid --workers-per-vault"}},
+	})
+}
+
+func TestValidateResume(t *testing.T) {
+	checkValidation(t, "resume", []validationCase{
+		{name: "negative checkpoint", args: []string{"--checkpoint-every", "-5"}, want: []string{"--checkpoint-every can't be negative"}},
+	})
+}
+
+func TestValidatePurgeVault(t *testing.T) {
+	checkValidation(t, "purge-vault", []validationCase{
+		{name: "no vault", args: []string{"--region", "us-east-1"}, want: []string{"-region and --vault are required"}},
+		{name: "no vault, no workers", args: []string{"--region", "us-east-1", "--workers-per-vault", "-1"}, want: []string{"-region and --vault are required", "invalid --workers-per-vault"}},
+	})
+}
+
+func TestValidateDeleteVault(t *testing.T) {
+	checkValidation(t, "delete-vault", []validationCase{
+		{name: "no vault", want: []string{"--vault is required"}},
+		{name: "bad vault pattern", args: []string{"--vault", "logs-[", "--vault", "no/slashes"}, want: []string{`invalid --vault pattern "logs-["`, "invalid --vault:"}},
+	})
+}
+
+func TestValidateNuke(t *testing.T) {
+	checkValidation(t, "nuke", []validationCase{
+		{name: "without yes", args: []string{"--vault", "logs-*"}, want: []string{"it needs --yes"}},
+		{name: "nothing picked", args: []string{"--yes"}, want: []string{"nuke needs --vault, --tag or --exclude-tag"}},
+		{name: "negative wait", args: []string{"--yes", "--all-vaults", "--vault-deletion-wait", "-1h"}, want: []string{"--vault-deletion-wait can't be negative"}},
+		{name: "everything wrong", args: []string{"--vault-deletion-wait", "-1h"}, want: []string{"it needs --yes", "nuke needs --vault", "--vault-deletion-wait can't be negative"}},
+	})
+}
+
+func TestValidateDownloadArchive(t *testing.T) {
+	checkValidation(t, "download-archive", []validationCase{
+		{name: "no archive", args: []string{"--region", "us-east-1", "--vault", "photos"}, want: []string{"-region, --vault, --archive-id, and --output-file are required"}},
+	})
+}
+
+func TestValidateDeleteArchive(t *testing.T) {
+	checkValidation(t, "delete-archive", []validationCase{
+		{name: "no archive", args: []string{"--region", "us-east-1", "--vault", "photos"}, want: []string{"-region, --vault, and --archive-id are required"}},
+	})
+}
+
+func TestValidateVerifyAuditLog(t *testing.T) {
+	checkValidation(t, "verify-audit-log", []validationCase{
+		{name: "no file", want: []string{"usage: ice-breaker verify-audit-log PATH"}},
+	})
+}
+
+func TestNonNegative(t *testing.T) {
+	for _, c := range []struct {
+		d  time.Duration
+		ok bool
+	}{{0, true}, {time.Hour, true}, {-time.Nanosecond, false}} {
+		if err := nonNegative("--timeout", c.d); (err == nil) != c.ok {
+			t.Errorf("nonNegative(%s) = %v", c.d, err)
+		}
+	}
+}
+
+func TestProblems(t *testing.T) {
+	var none problems
+	if none.add(nil); none.err() != nil {
+		t.Errorf("no problems gave %v", none.err())
+	}
+
+	var one problems
+	one.add(os.ErrNotExist)
+	if one.err() != os.ErrNotExist {
+		t.Errorf("one problem gave %v, want it as it was", one.err())
+	}
+
+	// Another's list is taken apart rather than nested.
+	var inner, outer problems
+	inner.add(os.ErrNotExist)
+	inner.add(os.ErrPermission)
+	outer.add(inner.err())
+	outer.add(os.ErrClosed)
+	err := outer.err()
+	if list, ok := err.(problemList); !ok || len(list) != 3 {
+		t.Fatalf("got %#v, want 3 problems", err)
+	}
+	if !strings.HasPrefix(err.Error(), "3 problems with the flags:\n  - ") {
+		t.Errorf("got %q", err)
+	}
+	for _, target := range []error{os.ErrNotExist, os.ErrPermission, os.ErrClosed} {
+		if !errors.Is(err, target) {
+			t.Errorf("%v isn't found in %v", target, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"runtime/debug"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+)
+
+// version is the build's version, set when building a release with
+//
+//	go build -ldflags "-X main.version=1.2.3" ./cmd/ice-breaker
+//
+// Otherwise it's the module version go install records, if any.
+var version string
+
+// buildVersion returns the build's version, or "dev" when it has none.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// appID identifies the tool and its version in the User-Agent of every AWS
+// request, e.g. for CloudTrail. The SDK doesn't allow a '/' in an app ID, so
+// it shows there as app/ice-breaker-1.2.3.
+func appID() string {
+	return glacierpurge.DefaultAppID + "/" + buildVersion()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// Inventory jobs take hours, so wait polls every waitPoll at first, backing
+// off to every waitPollMax.
+const (
+	waitPoll    = time.Minute
+	waitPollMax = 15 * time.Minute
+)
+
+// waitFlags registers the wait command, which waits for an inventory job, or
+// those in the state file, to complete, for chaining in scripts: it fails if
+// the job does, or --timeout passes first.
+func waitFlags(fs *flag.FlagSet) func(o *globalOptions) error {
+	vault := fs.String("vault", "", "Name of the vault the job belongs to")
+	jobId := fs.String("job-id", "", "ID of the job to wait for")
+	fromState := fs.Bool("any-from-state", false, "Wait for the inventory jobs recorded in the state file instead, until one of them completes")
+	all := fs.Bool("all", false, "With --any-from-state, wait until every one of the jobs has completed")
+
+	return func(o *globalOptions) error {
+		var problems problems
+		problems.add(o.validate())
+		var region string
+		if *fromState {
+			if *vault != "" || *jobId != "" {
+				problems.add(errors.New("--any-from-state waits for the jobs in the state file, not --vault and --job-id; drop one or the other"))
+			}
+		} else {
+			var err error
+			region, err = o.selection.single()
+			if problems.add(err) && (region == "" || *vault == "" || *jobId == "") {
+				problems.add(errors.New("--region, --vault and --job-id are required, unless --any-from-state is given"))
+			}
+			if *all {
+				problems.add(errors.New("--all only applies with --any-from-state"))
+			}
+		}
+		if err := problems.err(); err != nil {
+			return err
+		}
+
+		ctx, cancel := o.context()
+		defer cancel()
+		registry := o.registry(glacierpurge.WithReadOnly())
+
+		var jobs []*glacierpurge.InventoryJob
+		if *fromState {
+			// Only reading, so there's no need to wait for a run holding the
+			// lock.
+			store, err := o.loadState()
+			if err != nil {
+				return err
+			}
+			if len(store.State.Jobs) == 0 {
+				return errors.New("no inventory jobs are recorded in the state file")
+			}
+			for _, recorded := range store.State.Jobs {
+				g, err := registry.Get(ctx, recorded.Region)
+				if err != nil {
+					return err
+				}
+				jobs = append(jobs, &glacierpurge.InventoryJob{Vault: &glacierpurge.Vault{Glacier: g, Name: recorded.Vault}, Id: recorded.JobId})
+			}
+		} else {
+			g, err := registry.Get(ctx, region)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, &glacierpurge.InventoryJob{Vault: &glacierpurge.Vault{Glacier: g, Name: *vault}, Id: *jobId})
+		}
+
+		err := waitForJobs(ctx, jobs, *all || len(jobs) == 1)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("gave up waiting after --timeout %s", o.timeout)
+		}
+		return err
+	}
+}
+
+// waitForJobs waits for the jobs side by side, printing each change in their
+// status, until one of them succeeds or, with all, every one of them has. It
+// fails once that can no longer happen.
+func waitForJobs(ctx context.Context, jobs []*glacierpurge.InventoryJob, all bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		job *glacierpurge.InventoryJob
+		err error
+	}
+	outcomes := make(chan outcome, len(jobs))
+	for _, job := range jobs {
+		job := job
+		go func() {
+			label := fmt.Sprintf("[%s] %s: job %s", job.Vault.Glacier.Region, job.Vault.Name, job.Id)
+			var last string
+			result, err := job.Wait(ctx, glacierpurge.WaitOptions{
+				PollInterval: waitPoll,
+				Backoff:      glacierpurge.ExponentialBackoff(2, waitPollMax),
+				Progress: func(elapsed time.Duration, description *glacier.DescribeJobOutput) {
+					if status := string(description.StatusCode); status != last {
+						last = status
+						ui.Printf("%s is %s (%s in)\n", label, status, elapsed.Round(time.Second))
+					}
+				},
+			})
+			if err == nil {
+				ui.Printf("%s%s completed after %s%s\n", ui.Green, label, result.Elapsed.Round(time.Second), ui.Reset)
+			} else if ctx.Err() == nil && len(jobs) > 1 {
+				ui.Printf("%s%s: %v%s\n", ui.Red, label, err, ui.Reset)
+			}
+			outcomes <- outcome{job, err}
+		}()
+	}
+
+	succeeded, failed := 0, 0
+	for range jobs {
+		o := <-outcomes
+		if o.err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			if all {
+				return fmt.Errorf("inventory job %s for vault %s in region %s didn't complete: %w", o.job.Id, o.job.Vault.Name, o.job.Vault.Glacier.Region, o.err)
+			}
+			continue
+		}
+		succeeded++
+		if !all {
+			return nil
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("none of the %d inventory job(s) completed", failed)
+	}
+	ui.Printf("All %d inventory job(s) have completed.\n", succeeded)
+	return nil
+}
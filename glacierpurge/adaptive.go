@@ -0,0 +1,74 @@
+package glacierpurge
+
+import (
+	"sync"
+)
+
+// adaptiveWindow is how many deletions the adaptive limit looks back over to
+// judge the share of them that were throttled.
+const adaptiveWindow = 20
+
+// adaptiveLimit lets a varying number of deletions be in flight, in the manner
+// of TCP congestion control: one more after every window of deletions none of
+// which was throttled, and half as many after one in which a noticeable share
+// was. It's safe for concurrent use.
+type adaptiveLimit struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	max      int
+	inFlight int
+
+	calls, throttled int
+	ratio            float64 // the share of the last window's deletions throttled
+}
+
+func newAdaptiveLimit(start, max int) *adaptiveLimit {
+	a := &adaptiveLimit{limit: min(start, max), max: max}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+// acquire waits until another deletion may be in flight.
+func (a *adaptiveLimit) acquire() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.inFlight >= a.limit {
+		a.cond.Wait()
+	}
+	a.inFlight++
+}
+
+// release ends a deletion acquire let through, adjusting the limit to its
+// outcome.
+func (a *adaptiveLimit) release(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inFlight--
+	defer a.cond.Broadcast()
+
+	a.calls++
+	if isThrottling(err) {
+		a.throttled++
+	}
+	if a.calls < adaptiveWindow {
+		return
+	}
+
+	a.ratio = float64(a.throttled) / float64(a.calls)
+	switch {
+	case a.ratio >= throttleRatio:
+		a.limit = max(a.limit/2, 1)
+	case a.throttled == 0:
+		a.limit = min(a.limit+1, a.max)
+	}
+	a.calls, a.throttled = 0, 0
+}
+
+// status returns the current limit and the share of the last window's
+// deletions that were throttled.
+func (a *adaptiveLimit) status() (int, float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit, a.ratio
+}
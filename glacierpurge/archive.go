@@ -0,0 +1,243 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+type Archive struct {
+	Vault *Vault
+	Id    string
+
+	// Filled in from the inventory, when the archive came from one.
+	Description  string
+	CreationDate time.Time
+	Size         int64
+	TreeHash     string
+}
+
+func (a *Archive) Delete(ctx context.Context) error {
+	return a.delete(ctx, a.Vault.journalARN(ctx))
+}
+
+// delete deletes the archive, journaling it with arn as its vault's ARN.
+func (a *Archive) delete(ctx context.Context, arn string) error {
+	err := a.Vault.journaled(arn, JournalEntry{Op: "DeleteArchive", ArchiveId: a.Id, Size: a.Size}, func() (middleware.Metadata, error) {
+		output, err := a.Vault.Glacier.Client.DeleteArchive(ctx, &glacier.DeleteArchiveInput{
+			VaultName: aws.String(a.Vault.Name),
+			ArchiveId: aws.String(a.Id),
+		})
+		if err != nil {
+			return middleware.Metadata{}, err
+		}
+		return output.ResultMetadata, nil
+	})
+
+	if isNotFound(err) && !isVaultNotFound(err) {
+		return fmt.Errorf("failed to delete archive: %w: %w", ErrArchiveNotFound, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete archive: %w", err)
+	}
+
+	return nil
+}
+
+// ErrArchiveNotFound is returned by Delete for an archive that's already
+// gone, such as one an interrupted run deleted after its inventory was
+// taken. Its deletion has nothing left to do, but freed nothing either.
+var ErrArchiveNotFound = errors.New("the archive is already gone")
+
+// Retrieval tiers, from fastest and most expensive to slowest and cheapest.
+const (
+	TierExpedited = "Expedited"
+	TierStandard  = "Standard"
+	TierBulk      = "Bulk"
+)
+
+// ValidateTier checks that tier is one Glacier accepts.
+func ValidateTier(tier string) error {
+	switch tier {
+	case TierExpedited, TierStandard, TierBulk:
+		return nil
+	}
+	return fmt.Errorf("invalid retrieval tier %q: must be %s, %s, or %s", tier, TierExpedited, TierStandard, TierBulk)
+}
+
+// DownloadOptions controls Download. The zero value retrieves the whole
+// archive with a new Standard job in a single request.
+type DownloadOptions struct {
+	Tier string // Expedited, Standard, or Bulk; defaults to Standard
+
+	// TierFallback retries with the Standard tier when Glacier has no
+	// Expedited capacity to spare.
+	TierFallback bool
+
+	// JobId reuses an archive retrieval job started earlier, typically by a
+	// Download that failed part way, instead of initiating a new one.
+	JobId string
+
+	// Offset is how many bytes of the archive an earlier attempt already
+	// wrote; the download continues from there. Partial must supply those
+	// bytes so the checksum can cover the whole archive.
+	Offset  int64
+	Partial io.Reader
+
+	// PartSize splits the download into ranged requests of this many bytes,
+	// rounded down to a whole number of MiB. Zero fetches the rest of the
+	// archive in one request.
+	PartSize int64
+
+	Wait WaitOptions
+
+	// Initiated, if set, is called with the ID of the archive retrieval job
+	// as soon as Download starts it, so it can be recorded for resuming.
+	Initiated func(jobId string)
+}
+
+// DownloadResult reports what Download did. On failure it records how far the
+// download got, so it can be resumed with JobId and Offset.
+type DownloadResult struct {
+	JobId    string
+	Size     int64  // the archive's size in bytes
+	Written  int64  // bytes of the archive written so far, including Offset
+	TreeHash string // the verified SHA-256 tree hash
+}
+
+// ErrChecksumMismatch is returned by Download when the downloaded bytes don't
+// hash to the archive's tree hash.
+var ErrChecksumMismatch = errors.New("downloaded archive doesn't match its checksum")
+
+// Download retrieves the archive's contents into w. Glacier first has to stage
+// the archive, which takes hours on the Standard and Bulk tiers; Download
+// blocks until it has, then streams it to w and verifies it against the tree
+// hash Glacier reports.
+func (a *Archive) Download(ctx context.Context, w io.Writer, opts DownloadOptions) (*DownloadResult, error) {
+	result := &DownloadResult{JobId: opts.JobId, Written: opts.Offset}
+
+	if result.JobId == "" {
+		tier := opts.Tier
+		if tier == "" {
+			tier = TierStandard
+		}
+		if err := ValidateTier(tier); err != nil {
+			return result, err
+		}
+
+		jobId, err := a.initiateRetrieval(ctx, tier)
+		if err != nil && tier == TierExpedited && opts.TierFallback && isInsufficientCapacity(err) {
+			a.Vault.Glacier.Logger.Printf("No Expedited capacity for archive %s; falling back to the Standard tier", a.Id)
+			jobId, err = a.initiateRetrieval(ctx, TierStandard)
+		}
+		if err != nil {
+			return result, err
+		}
+		result.JobId = jobId
+		a.Vault.Glacier.Logger.Printf("Archive retrieval job initiated for archive %s in vault %s, job ID: %s", a.Id, a.Vault.Name, result.JobId)
+		if opts.Initiated != nil {
+			opts.Initiated(result.JobId)
+		}
+	}
+
+	wait := opts.Wait
+	if wait.Progress == nil {
+		wait.Progress = func(elapsed time.Duration, description *glacier.DescribeJobOutput) {
+			if !description.Completed {
+				a.Vault.Glacier.Logger.Printf("Waiting for archive retrieval job %s to complete", result.JobId)
+			}
+		}
+	}
+	waited, err := waitForJob(ctx, a.Vault, result.JobId, wait)
+	if err != nil {
+		return result, err
+	}
+	result.Size = aws.ToInt64(waited.Description.ArchiveSizeInBytes)
+	expected := aws.ToString(waited.Description.ArchiveSHA256TreeHash)
+
+	if err := downloadJobOutput(ctx, a.Vault, w, result, opts, expected); err != nil {
+		return result, fmt.Errorf("failed to download archive %s: %w", a.Id, err)
+	}
+	return result, nil
+}
+
+// downloadJobOutput streams a completed job's output of result.Size bytes to
+// w, starting at result.Written, and stores its tree hash in result. Each part
+// is checked against the checksum Glacier sends with it, if any, and the
+// whole against expected, if given.
+func downloadJobOutput(ctx context.Context, vault *Vault, w io.Writer, result *DownloadResult, opts DownloadOptions, expected string) error {
+	hash := &treeHash{}
+	if opts.Offset > 0 {
+		if opts.Partial == nil {
+			return errors.New("resuming a download needs the bytes already written")
+		}
+		if n, err := io.CopyN(hash, opts.Partial, opts.Offset); err != nil {
+			return fmt.Errorf("failed to read the partial download (got %d of %d bytes): %w", n, opts.Offset, err)
+		}
+	}
+
+	partSize := opts.PartSize / treeHashChunkSize * treeHashChunkSize
+	if partSize <= 0 {
+		partSize = result.Size
+	}
+
+	for result.Written < result.Size {
+		end := result.Written + partSize
+		if end > result.Size {
+			end = result.Size
+		}
+
+		output, err := vault.Glacier.Client.GetJobOutput(ctx, &glacier.GetJobOutputInput{
+			AccountId: aws.String("-"),
+			VaultName: aws.String(vault.Name),
+			JobId:     aws.String(result.JobId),
+			Range:     aws.String(fmt.Sprintf("bytes=%d-%d", result.Written, end-1)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get job output: %w", err)
+		}
+
+		part := &treeHash{}
+		n, err := io.Copy(io.MultiWriter(w, hash, part), output.Body)
+		output.Body.Close()
+		result.Written += n
+		if err != nil {
+			return err
+		}
+		if result.Written != end {
+			return fmt.Errorf("expected %d bytes, got %d", end, result.Written)
+		}
+		if sum := aws.ToString(output.Checksum); sum != "" && sum != part.Sum() {
+			return fmt.Errorf("%w: bytes %d-%d should hash to %s, got %s", ErrChecksumMismatch, end-n, end-1, sum, part.Sum())
+		}
+	}
+
+	result.TreeHash = hash.Sum()
+	if expected != "" && result.TreeHash != expected {
+		return fmt.Errorf("%w: expected tree hash %s, got %s", ErrChecksumMismatch, expected, result.TreeHash)
+	}
+	return nil
+}
+
+func (a *Archive) initiateRetrieval(ctx context.Context, tier string) (string, error) {
+	output, err := a.Vault.Glacier.Client.InitiateJob(ctx, &glacier.InitiateJobInput{
+		AccountId: aws.String("-"),
+		VaultName: aws.String(a.Vault.Name),
+		JobParameters: &types.JobParameters{
+			Type:      aws.String("archive-retrieval"),
+			ArchiveId: aws.String(a.Id),
+			Tier:      aws.String(tier),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate archive retrieval job: %w", err)
+	}
+	return aws.ToString(output.JobId), nil
+}
@@ -0,0 +1,41 @@
+package glacierpurge
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/smithy-go/logging"
+)
+
+// Redactor masks secrets in what the package writes itself rather than to
+// the Logger it's given: the SDK's log, and recordings. Only the caller
+// knows its secrets; without one, nothing but the signatures and session
+// tokens in the SDK's log is masked.
+type Redactor func(string) string
+
+func (r Redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+	return r(s)
+}
+
+// redactedMask is what the SDK's log has in place of a signature or session
+// token.
+const redactedMask = "[redacted]"
+
+// credentialHeaders matches the lines of a dumped request carrying its
+// signature or session token.
+var credentialHeaders = regexp.MustCompile(`(?im)^((?:Authorization|X-Amz-Security-Token):)[^\r\n]*`)
+
+// sdkLogger passes the SDK's own log on to a Logger, blanking the
+// credentials in the requests it dumps.
+type sdkLogger struct {
+	logger Logger
+	redact Redactor
+}
+
+func (l sdkLogger) Logf(classification logging.Classification, format string, args ...any) {
+	message := credentialHeaders.ReplaceAllString(l.redact.redact(fmt.Sprintf(format, args...)), "$1 "+redactedMask)
+	l.logger.Printf("AWS SDK %s: %s", classification, message)
+}
@@ -0,0 +1,83 @@
+package glacierpurge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go/logging"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// sentinel is a secret the caller's Redactor masks.
+const sentinel = "SENTINEL-SECRET-4f1d9c2b"
+
+func maskSentinel(s string) string {
+	return strings.ReplaceAll(s, sentinel, "[masked]")
+}
+
+// printfLogger keeps what's logged to it.
+type printfLogger struct {
+	lines []string
+}
+
+func (l *printfLogger) Printf(format string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestSDKLoggerRedacts(t *testing.T) {
+	dump := "POST / HTTP/1.1\r\nAuthorization: AWS4-HMAC-SHA256 Signature=abc\r\nX-Amz-Security-Token: token\r\n\r\n{\"message\": \"" + sentinel + "\"}"
+	for _, c := range []struct {
+		name   string
+		redact Redactor
+		want   string
+	}{
+		{"without a redactor", nil, sentinel},
+		{"with one", maskSentinel, "[masked]"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			logger := &printfLogger{}
+			sdkLogger{logger, c.redact}.Logf(logging.Debug, "Request\n%s", dump)
+			got := strings.Join(logger.lines, "\n")
+			if strings.Contains(got, "Signature=abc") || strings.Contains(got, "token\r") || strings.Count(got, redactedMask) != 2 {
+				t.Errorf("the credentials weren't blanked:\n%s", got)
+			}
+			if !strings.Contains(got, c.want) {
+				t.Errorf("logged:\n%s\nwant %s in it", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRecorderRedacts(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, "purge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Redact = maskSentinel
+	fake := glaciertest.New()
+	fake.AddVault(glaciertest.Vault{Name: "vault"})
+	fake.Intercept(glaciertest.OpDescribeVault, func(any) error {
+		return glaciertest.Error(403, "AccessDeniedException", "not with the key "+sentinel)
+	})
+	g, _ := newTestGlacier(t, fake, WithRecorder(recorder))
+
+	if _, err := (&Vault{Glacier: g, Name: "vault"}).Describe(context.Background()); err == nil {
+		t.Fatal("got no error, want the access denied")
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, callsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), sentinel) || !strings.Contains(string(data), "not with the key [masked]") {
+		t.Errorf("recorded %s, want the error with the secret masked", data)
+	}
+}
@@ -0,0 +1,160 @@
+package glacierpurge
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+)
+
+// benchSizes are the archive counts the inventory benchmarks run at, up to
+// the millions a long-lived vault holds.
+var benchSizes = []int{1_000, 100_000, 1_000_000}
+
+// benchInventories caches the synthetic inventories by format and size, as
+// the largest take a while to build.
+var benchInventories = make(map[string][]byte)
+
+// benchInventory returns a synthetic inventory of n archives in format, "JSON"
+// or "CSV", shaped like the ones Glacier writes.
+func benchInventory(b *testing.B, format string, n int) []byte {
+	b.Helper()
+	key := format + strconv.Itoa(n)
+	if inventory, ok := benchInventories[key]; ok {
+		return inventory
+	}
+
+	created := testStart.Add(-365 * 24 * time.Hour)
+	entries := make([]inventoryEntry, n)
+	for i := range entries {
+		entries[i] = inventoryEntry{
+			ArchiveId:          fmt.Sprintf("%0138d", i),
+			ArchiveDescription: fmt.Sprintf(`{"path":"backups/2019/%07d.tar.gz","type":"file"}`, i),
+			CreationDate:       created.Add(time.Duration(i) * time.Second).Format(time.RFC3339),
+			Size:               int64(i) * 4096,
+			SHA256TreeHash:     fmt.Sprintf("%064x", i),
+		}
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "JSON":
+		err := json.NewEncoder(&buf).Encode(struct {
+			VaultARN      string
+			InventoryDate string
+			ArchiveList   []inventoryEntry
+		}{"arn:aws:glacier:us-east-1:123456789012:vaults/bench", testStart.Format(time.RFC3339), entries})
+		if err != nil {
+			b.Fatal(err)
+		}
+	case "CSV":
+		w := csv.NewWriter(&buf)
+		w.Write(csvInventoryColumns)
+		for _, entry := range entries {
+			w.Write([]string{entry.ArchiveId, entry.ArchiveDescription, entry.CreationDate, strconv.FormatInt(entry.Size, 10), entry.SHA256TreeHash})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	benchInventories[key] = buf.Bytes()
+	return buf.Bytes()
+}
+
+func BenchmarkStreamInventory(b *testing.B) {
+	vault := &Vault{Name: "bench"}
+	for _, format := range []string{"JSON", "CSV"} {
+		for _, n := range benchSizes {
+			b.Run(fmt.Sprintf("%s/%d", format, n), func(b *testing.B) {
+				inventory := benchInventory(b, format, n)
+				b.SetBytes(int64(len(inventory)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					count := 0
+					err := StreamInventory(bytes.NewReader(inventory), vault, func(*Archive) error {
+						count++
+						return nil
+					})
+					if err != nil || count != n {
+						b.Fatalf("read %d archives: %v", count, err)
+					}
+				}
+				b.ReportMetric(float64(n)*float64(b.N)/b.Elapsed().Seconds(), "archives/s")
+			})
+		}
+	}
+}
+
+// BenchmarkParseInventory collects the whole inventory as WaitForResults
+// does, for comparison with streaming it.
+func BenchmarkParseInventory(b *testing.B) {
+	vault := &Vault{Name: "bench"}
+	for _, format := range []string{"JSON", "CSV"} {
+		for _, n := range benchSizes {
+			b.Run(fmt.Sprintf("%s/%d", format, n), func(b *testing.B) {
+				inventory := benchInventory(b, format, n)
+				b.SetBytes(int64(len(inventory)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					archives, err := ParseInventory(bytes.NewReader(inventory), vault)
+					if err != nil || len(archives) != n {
+						b.Fatalf("read %d archives: %v", len(archives), err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// nopAPI deletes every archive instantly, so a benchmark of deleteArchives
+// measures the worker pool and its bookkeeping rather than the client.
+type nopAPI struct {
+	API
+}
+
+func (nopAPI) DeleteArchive(context.Context, *glacier.DeleteArchiveInput, ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error) {
+	return &glacier.DeleteArchiveOutput{}, nil
+}
+
+func BenchmarkDeleteArchives(b *testing.B) {
+	g, err := New(context.Background(), "us-east-1", WithClient(nopAPI{}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	vault := &Vault{Glacier: g, Name: "bench"}
+	job := &InventoryJob{Vault: vault, Id: "bench"}
+	const n = 100_000
+	archives := make([]*Archive, n)
+	for i := range archives {
+		archives[i] = &Archive{Vault: vault, Id: fmt.Sprintf("%0138d", i), Size: 4096}
+	}
+
+	for _, opts := range []struct {
+		name string
+		DeleteOptions
+	}{
+		{"workers=4", DeleteOptions{Workers: 4}},
+		{"workers=32", DeleteOptions{Workers: 32}},
+		{"adaptive", DeleteOptions{Adaptive: true}},
+	} {
+		b.Run(opts.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				result, err := job.DeleteArchives(context.Background(), archives, opts.DeleteOptions)
+				if err != nil || result.Deleted != n {
+					b.Fatalf("deleted %d archives: %v", result.Deleted, err)
+				}
+			}
+			b.ReportMetric(float64(n)*float64(b.N)/b.Elapsed().Seconds(), "archives/s")
+		})
+	}
+}
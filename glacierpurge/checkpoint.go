@@ -0,0 +1,48 @@
+package glacierpurge
+
+import "sync"
+
+// head tracks how many archives at the head of an inventory, in the order it
+// lists them, have all been dealt with, as the deletions deal with them out
+// of order. Those dealt with past the head are kept as bits of a bitmap
+// rather than a set of their IDs, and only until the head passes them.
+type head struct {
+	mu   sync.Mutex
+	n    int64    // the archives before position n have all been dealt with
+	base int64    // the position bits starts at, a multiple of 64
+	bits []uint64 // which archives from base on have been dealt with
+}
+
+// newHead returns a head that starts with the first n archives dealt with.
+func newHead(n int64) *head {
+	return &head{n: n, base: n - n%64}
+}
+
+// add notes the archive at pos dealt with, returning how many at the head
+// have been and whether that grew.
+func (h *head) add(pos int64) (n int64, grew bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if pos < h.n {
+		return h.n, false
+	}
+	i := pos - h.base
+	for int64(len(h.bits))*64 <= i {
+		h.bits = append(h.bits, 0)
+	}
+	h.bits[i/64] |= 1 << (i % 64)
+
+	start := h.n
+	for {
+		i := h.n - h.base
+		if i/64 >= int64(len(h.bits)) || h.bits[i/64]&(1<<(i%64)) == 0 {
+			break
+		}
+		h.n++
+	}
+	if drop := (h.n - h.base) / 64; drop > 0 {
+		h.bits = append(h.bits[:0], h.bits[drop:]...)
+		h.base += drop * 64
+	}
+	return h.n, h.n != start
+}
@@ -0,0 +1,129 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// ErrorClass is what a failed call's error comes down to, such as access
+// being denied or the network letting it down, so failures can be counted by
+// what would fix them. The retries, the circuit breaker, and the counts all
+// go by it, so they can't disagree about an error.
+type ErrorClass string
+
+const (
+	ClassAccessDenied       ErrorClass = "AccessDenied"
+	ClassThrottling         ErrorClass = "Throttling"
+	ClassNotFound           ErrorClass = "NotFound"
+	ClassInvalidCredentials ErrorClass = "InvalidCredentials"
+	ClassExpiredCredentials ErrorClass = "ExpiredCredentials"
+	ClassReadOnly           ErrorClass = "ReadOnly"
+	ClassInvalidParameter   ErrorClass = "InvalidParameter"
+	ClassNoCapacity         ErrorClass = "InsufficientCapacity"
+	ClassServerError        ErrorClass = "ServerError" // a 5xx from AWS
+	ClassTimeout            ErrorClass = "Timeout"
+	ClassNetwork            ErrorClass = "Network"
+	ClassCanceled           ErrorClass = "Canceled"
+	ClassOther              ErrorClass = "Other"
+)
+
+// Classify returns err's class. An error AWS gave a code this package has
+// no class for is classed by the code, and one with no code by its HTTP
+// status, as "HTTP 409". A nil error has no class.
+func Classify(err error) ErrorClass {
+	var apiErr smithy.APIError
+	var statusErr interface{ HTTPStatusCode() int }
+	var sendErr *smithyhttp.RequestSendError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrReadOnly):
+		return ClassReadOnly
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case isThrottling(err):
+		return ClassThrottling
+	case isAccessDenied(err):
+		return ClassAccessDenied
+	case IsUnrecognizedCredentials(err):
+		return ClassInvalidCredentials
+	case IsExpiredCredentials(err):
+		return ClassExpiredCredentials
+	case isNotFound(err):
+		return ClassNotFound
+	case isInvalidParameter(err):
+		return ClassInvalidParameter
+	case isInsufficientCapacity(err):
+		return ClassNoCapacity
+	case errors.As(err, &statusErr) && statusErr.HTTPStatusCode() >= 500:
+		return ClassServerError
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() != "":
+		return ErrorClass(apiErr.ErrorCode())
+	case errors.As(err, &statusErr):
+		return ErrorClass(fmt.Sprintf("HTTP %d", statusErr.HTTPStatusCode()))
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ClassTimeout
+	case errors.As(err, &sendErr), errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return ClassNetwork
+	}
+	return ClassOther
+}
+
+// Transient reports whether a failure of the class is likely gone by the
+// next attempt: throttling, a 5xx from AWS, or the network letting a request
+// down.
+func (c ErrorClass) Transient() bool {
+	switch c {
+	case ClassThrottling, ClassServerError, ClassTimeout, ClassNetwork:
+		return true
+	}
+	return false
+}
+
+// Permanent reports whether a deletion failing with the class won't go away
+// by itself, so moving on to the next archive won't help either.
+func (c ErrorClass) Permanent() bool {
+	switch c {
+	case ClassAccessDenied, ClassNotFound, ClassInvalidCredentials, ClassReadOnly:
+		return true
+	}
+	return false
+}
+
+// ClassCounts counts failures by class.
+type ClassCounts map[ErrorClass]int
+
+// Add adds other's counts to c.
+func (c ClassCounts) Add(other ClassCounts) {
+	for class, n := range other {
+		c[class] += n
+	}
+}
+
+// String lists the counts, most first, as "403 AccessDenied, 12 Throttling".
+func (c ClassCounts) String() string {
+	classes := make([]ErrorClass, 0, len(c))
+	for class := range c {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if c[classes[i]] != c[classes[j]] {
+			return c[classes[i]] > c[classes[j]]
+		}
+		return classes[i] < classes[j]
+	})
+	parts := make([]string, len(classes))
+	for i, class := range classes {
+		parts[i] = fmt.Sprintf("%d %s", c[class], class)
+	}
+	return strings.Join(parts, ", ")
+}
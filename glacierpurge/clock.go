@@ -0,0 +1,40 @@
+package glacierpurge
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the source of time for everything that waits: polling jobs and
+// pacing calls. It exists so they can be driven by something other than the
+// wall clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// WallClock is the real clock, which a Clock left unset defaults to.
+var WallClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clock returns what the client waits by.
+func (g *Glacier) clock() Clock {
+	if g.Clock == nil {
+		return WallClock
+	}
+	return g.Clock
+}
+
+// sleepFor waits d by clock, or until ctx ends.
+func sleepFor(ctx context.Context, clock Clock, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,226 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/smithy-go"
+)
+
+// ErrCredentialsExpired is returned once the credentials have expired and
+// couldn't be renewed.
+var ErrCredentialsExpired = errors.New("the AWS credentials have expired")
+
+// IsExpiredCredentials reports whether err is AWS turning a call down because
+// the credentials' session has expired or its token is no longer valid.
+func IsExpiredCredentials(err error) bool {
+	if errors.Is(err, ErrCredentialsExpired) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ExpiredToken", "ExpiredTokenException", "InvalidToken", "TokenRefreshRequired":
+			return true
+		}
+	}
+	return false
+}
+
+// Credentials supplies the credentials of every client it's given to, so
+// credentials renewed for one are used by all of them. Temporary credentials
+// are refreshed from their provider when they expire, if it can, and
+// otherwise by calling Renew. It's safe for concurrent use.
+type Credentials struct {
+	// Renew, if set, is called when the credentials have expired and the
+	// provider can't refresh them, e.g. the keys of an MFA session. It
+	// returns new credentials, or an error to give up with. No calls are made
+	// while it runs.
+	Renew func(ctx context.Context, cause error) (aws.Credentials, error)
+
+	mu        sync.Mutex
+	cache     *aws.CredentialsCache
+	renewedAt time.Time
+	gaveUp    error // once renewing has failed, every later attempt does too
+}
+
+// NewCredentials returns credentials drawn from provider. A provider that's
+// already an *aws.CredentialsCache, as the SDK's configuration gives, is used
+// as it is, so invalidating it refreshes from the provider it caches.
+func NewCredentials(provider aws.CredentialsProvider) *Credentials {
+	cache, ok := provider.(*aws.CredentialsCache)
+	if !ok {
+		cache = aws.NewCredentialsCache(provider)
+	}
+	return &Credentials{cache: cache}
+}
+
+// NewDefaultCredentials returns credentials drawn from the SDK's default
+// chain: the AWS_ environment variables, then profile in the shared config
+// and credentials files (AWS_PROFILE's, or default, if profile is empty),
+// with its SSO, role to assume, or process, then web identity, and finally
+// the ECS task's or EC2 instance's role. Those that expire are refreshed from
+// the chain before they do.
+func NewDefaultCredentials(ctx context.Context, profile string) (*Credentials, error) {
+	var options []func(*config.LoadOptions) error
+	if profile != "" {
+		options = append(options, config.WithSharedConfigProfile(profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the AWS configuration: %w", err)
+	}
+	if cfg.Credentials == nil {
+		return nil, errors.New("the AWS configuration gives no way to find credentials")
+	}
+	return NewCredentials(cfg.Credentials), nil
+}
+
+// Retrieve returns the current credentials. It satisfies
+// aws.CredentialsProvider.
+func (c *Credentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Retrieve(ctx)
+}
+
+// Refresh replaces credentials AWS has turned down as expired, by cause, in a
+// call made at since. Credentials renewed since then are kept as they are.
+func (c *Credentials) Refresh(ctx context.Context, since time.Time, cause error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.renewedAt.After(since) {
+		return nil
+	}
+	if c.gaveUp != nil {
+		return c.gaveUp
+	}
+
+	stale, _ := c.cache.Retrieve(ctx)
+	c.cache.Invalidate()
+	if fresh, err := c.cache.Retrieve(ctx); err == nil && !fresh.Expired() && !sameCredentials(fresh, stale) {
+		c.renewedAt = time.Now()
+		return nil
+	}
+	return c.renew(ctx, cause)
+}
+
+// refreshBefore makes sure, as far as it can, that the credentials are still
+// good at t, first by refreshing them from the provider and then by renewing
+// them. Credentials without an expiry are left alone.
+func (c *Credentials) refreshBefore(ctx context.Context, t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, err := c.cache.Retrieve(ctx)
+	if err != nil || !current.CanExpire || current.Expires.After(t) {
+		return nil
+	}
+	if c.gaveUp != nil {
+		return c.gaveUp
+	}
+
+	c.cache.Invalidate()
+	if fresh, err := c.cache.Retrieve(ctx); err == nil && (!fresh.CanExpire || fresh.Expires.After(t)) {
+		c.renewedAt = time.Now()
+		return nil
+	}
+	return c.renew(ctx, fmt.Errorf("the credentials expire at %s", current.Expires.Local().Format("2006-01-02 15:04:05")))
+}
+
+// renew replaces the credentials with Renew's. The caller must hold c.mu.
+func (c *Credentials) renew(ctx context.Context, cause error) error {
+	if c.Renew == nil {
+		c.gaveUp = fmt.Errorf("%w: %v", ErrCredentialsExpired, cause)
+		return c.gaveUp
+	}
+	renewed, err := c.Renew(ctx, cause)
+	if err != nil {
+		if !errors.Is(err, ErrCredentialsExpired) {
+			err = fmt.Errorf("%w: %w", ErrCredentialsExpired, err)
+		}
+		if ctx.Err() == nil {
+			c.gaveUp = err
+		}
+		return err
+	}
+	c.cache = aws.NewCredentialsCache(credentials.StaticCredentialsProvider{Value: renewed})
+	c.renewedAt = time.Now()
+	return nil
+}
+
+func sameCredentials(a, b aws.Credentials) bool {
+	return a.AccessKeyID == b.AccessKeyID && a.SecretAccessKey == b.SecretAccessKey && a.SessionToken == b.SessionToken
+}
+
+// renewingAPI retries, once, a call turned down for expired credentials after
+// refreshing them.
+type renewingAPI struct {
+	API
+	credentials *Credentials
+}
+
+func renewing[In, Out any](ctx context.Context, c *Credentials, call func(context.Context, In, ...func(*glacier.Options)) (Out, error), params In, optFns []func(*glacier.Options)) (Out, error) {
+	since := time.Now()
+	out, err := call(ctx, params, optFns...)
+	if err == nil || !IsExpiredCredentials(err) {
+		return out, err
+	}
+	if err := c.Refresh(ctx, since, err); err != nil {
+		var zero Out
+		return zero, err
+	}
+	return call(ctx, params, optFns...)
+}
+
+func (a renewingAPI) ListVaults(ctx context.Context, params *glacier.ListVaultsInput, optFns ...func(*glacier.Options)) (*glacier.ListVaultsOutput, error) {
+	return renewing(ctx, a.credentials, a.API.ListVaults, params, optFns)
+}
+
+func (a renewingAPI) DescribeVault(ctx context.Context, params *glacier.DescribeVaultInput, optFns ...func(*glacier.Options)) (*glacier.DescribeVaultOutput, error) {
+	return renewing(ctx, a.credentials, a.API.DescribeVault, params, optFns)
+}
+
+func (a renewingAPI) ListTagsForVault(ctx context.Context, params *glacier.ListTagsForVaultInput, optFns ...func(*glacier.Options)) (*glacier.ListTagsForVaultOutput, error) {
+	return renewing(ctx, a.credentials, a.API.ListTagsForVault, params, optFns)
+}
+
+func (a renewingAPI) AddTagsToVault(ctx context.Context, params *glacier.AddTagsToVaultInput, optFns ...func(*glacier.Options)) (*glacier.AddTagsToVaultOutput, error) {
+	return renewing(ctx, a.credentials, a.API.AddTagsToVault, params, optFns)
+}
+
+func (a renewingAPI) RemoveTagsFromVault(ctx context.Context, params *glacier.RemoveTagsFromVaultInput, optFns ...func(*glacier.Options)) (*glacier.RemoveTagsFromVaultOutput, error) {
+	return renewing(ctx, a.credentials, a.API.RemoveTagsFromVault, params, optFns)
+}
+
+func (a renewingAPI) InitiateJob(ctx context.Context, params *glacier.InitiateJobInput, optFns ...func(*glacier.Options)) (*glacier.InitiateJobOutput, error) {
+	return renewing(ctx, a.credentials, a.API.InitiateJob, params, optFns)
+}
+
+func (a renewingAPI) DescribeJob(ctx context.Context, params *glacier.DescribeJobInput, optFns ...func(*glacier.Options)) (*glacier.DescribeJobOutput, error) {
+	return renewing(ctx, a.credentials, a.API.DescribeJob, params, optFns)
+}
+
+func (a renewingAPI) GetJobOutput(ctx context.Context, params *glacier.GetJobOutputInput, optFns ...func(*glacier.Options)) (*glacier.GetJobOutputOutput, error) {
+	return renewing(ctx, a.credentials, a.API.GetJobOutput, params, optFns)
+}
+
+func (a renewingAPI) ListJobs(ctx context.Context, params *glacier.ListJobsInput, optFns ...func(*glacier.Options)) (*glacier.ListJobsOutput, error) {
+	return renewing(ctx, a.credentials, a.API.ListJobs, params, optFns)
+}
+
+func (a renewingAPI) DeleteArchive(ctx context.Context, params *glacier.DeleteArchiveInput, optFns ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error) {
+	return renewing(ctx, a.credentials, a.API.DeleteArchive, params, optFns)
+}
+
+func (a renewingAPI) DeleteVault(ctx context.Context, params *glacier.DeleteVaultInput, optFns ...func(*glacier.Options)) (*glacier.DeleteVaultOutput, error) {
+	return renewing(ctx, a.credentials, a.API.DeleteVault, params, optFns)
+}
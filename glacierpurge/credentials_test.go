@@ -0,0 +1,191 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// expiringProvider hands out credentials expiring at each of expires in turn,
+// and at the last from then on.
+type expiringProvider struct {
+	expires []time.Time
+	calls   atomic.Int32
+}
+
+func (p *expiringProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	n := int(p.calls.Add(1))
+	expires := p.expires[min(n, len(p.expires))-1]
+	return aws.Credentials{
+		AccessKeyID:     fmt.Sprintf("AKID%d", n),
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		CanExpire:       true,
+		Expires:         expires,
+	}, nil
+}
+
+func TestCredentialsRefreshBeforeExpiry(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		name     string
+		expires  []time.Time
+		wantKey  string
+		wantCall int32
+		renewed  bool
+	}{
+		{
+			name:     "refreshed from the provider",
+			expires:  []time.Time{now.Add(10 * time.Minute), now.Add(2 * time.Hour)},
+			wantKey:  "AKID2",
+			wantCall: 2,
+		},
+		{
+			name:     "good for long enough",
+			expires:  []time.Time{now.Add(2 * time.Hour)},
+			wantKey:  "AKID1",
+			wantCall: 1,
+		},
+		{
+			name:     "the provider can't do better",
+			expires:  []time.Time{now.Add(10 * time.Minute)},
+			wantKey:  "AKIDRENEWED",
+			wantCall: 2,
+			renewed:  true,
+		},
+	} {
+		// Given as the SDK's configuration gives them, already cached.
+		for _, cached := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s, cached %t", c.name, cached), func(t *testing.T) {
+				provider := &expiringProvider{expires: c.expires}
+				var creds *Credentials
+				if cached {
+					creds = NewCredentials(aws.NewCredentialsCache(provider))
+				} else {
+					creds = NewCredentials(provider)
+				}
+				renewed := false
+				creds.Renew = func(_ context.Context, cause error) (aws.Credentials, error) {
+					renewed = true
+					if !strings.Contains(cause.Error(), "the credentials expire at") {
+						t.Errorf("renewed because %v", cause)
+					}
+					return aws.Credentials{AccessKeyID: "AKIDRENEWED", SecretAccessKey: "secret"}, nil
+				}
+				if _, err := creds.Retrieve(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := creds.refreshBefore(context.Background(), now.Add(30*time.Minute)); err != nil {
+					t.Fatal(err)
+				}
+				got, err := creds.Retrieve(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.AccessKeyID != c.wantKey || provider.calls.Load() != c.wantCall || renewed != c.renewed {
+					t.Errorf("got %s after %d retrievals, renewed %t; want %s after %d, renewed %t", got.AccessKeyID, provider.calls.Load(), renewed, c.wantKey, c.wantCall, c.renewed)
+				}
+			})
+		}
+	}
+}
+
+func TestCredentialsGiveUpWithoutRenew(t *testing.T) {
+	provider := &expiringProvider{expires: []time.Time{time.Now().Add(time.Minute)}}
+	creds := NewCredentials(provider)
+	err := creds.refreshBefore(context.Background(), time.Now().Add(time.Hour))
+	if !errors.Is(err, ErrCredentialsExpired) {
+		t.Fatalf("got %v, want ErrCredentialsExpired", err)
+	}
+	// Having given up, it doesn't ask the provider again.
+	calls := provider.calls.Load()
+	if err := creds.refreshBefore(context.Background(), time.Now().Add(time.Hour)); !errors.Is(err, ErrCredentialsExpired) {
+		t.Errorf("got %v the second time, want ErrCredentialsExpired", err)
+	}
+	if provider.calls.Load() != calls {
+		t.Errorf("the provider was asked again after giving up")
+	}
+}
+
+// TestDefaultCredentialsRefreshBeforeExpiry takes credentials from a profile
+// whose credential_process hands out temporary ones, as SSO and assumed roles
+// do, and checks they're refreshed from it before they expire.
+func TestDefaultCredentialsRefreshBeforeExpiry(t *testing.T) {
+	sh, err := os.Stat("/bin/sh")
+	if err != nil || sh.IsDir() {
+		t.Skip("no /bin/sh for the credential process")
+	}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "AWS_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	dir := t.TempDir()
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	now := time.Now().UTC()
+	soon, later := now.Add(10*time.Minute).Format(time.RFC3339), now.Add(2*time.Hour).Format(time.RFC3339)
+	count := filepath.Join(dir, "count")
+	script := filepath.Join(dir, "process.sh")
+	if err := os.WriteFile(script, []byte(`#!/bin/sh
+echo x >> `+count+`
+n=$(wc -l < `+count+` | tr -d ' ')
+expires=`+later+`
+[ "$n" = 1 ] && expires=`+soon+`
+printf '{"Version": 1, "AccessKeyId": "AKIDPROCESS%s", "SecretAccessKey": "secret", "SessionToken": "token", "Expiration": "%s"}' "$n" "$expires"
+`), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config")
+	if err := os.WriteFile(config, []byte("[profile temporary]\ncredential_process = /bin/sh "+script+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", config)
+
+	creds, err := NewDefaultCredentials(context.Background(), "temporary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds.Renew = func(context.Context, error) (aws.Credentials, error) {
+		t.Error("asked to renew credentials the profile refreshes")
+		return aws.Credentials{}, ErrCredentialsExpired
+	}
+	first, err := creds.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.AccessKeyID != "AKIDPROCESS1" {
+		t.Fatalf("got key %s, want the profile's", first.AccessKeyID)
+	}
+
+	if err := creds.refreshBefore(context.Background(), now.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := creds.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.AccessKeyID != "AKIDPROCESS2" || !refreshed.Expires.After(now.Add(time.Hour)) {
+		t.Errorf("got key %s expiring at %s, want the profile's next", refreshed.AccessKeyID, refreshed.Expires)
+	}
+}
+
+func TestDefaultCredentialsUnknownProfile(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	if _, err := NewDefaultCredentials(context.Background(), "no-such-profile"); err == nil {
+		t.Error("got no error for a profile that doesn't exist")
+	}
+}
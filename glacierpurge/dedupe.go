@@ -0,0 +1,81 @@
+package glacierpurge
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// ArchiveSet remembers which archive IDs it has been given, so an archive an
+// inventory lists twice, as one stitched together from pages or edited by
+// hand can, is only deleted and counted once. It keeps an 8-byte fingerprint
+// of each ID rather than the ID itself, a small fraction of the memory, so it
+// can't tell an ID it has seen from a different one sharing its fingerprint.
+// That's vanishingly unlikely even among millions, but an ID Add turns down
+// is only a suspect: its users deal with it again once its first is done,
+// which for a duplicate changes nothing, rather than pass over a different
+// archive. The fingerprints are kept in an open-addressed table of their
+// own, which takes about half the memory a map of them would. It's safe for
+// concurrent use.
+type ArchiveSet struct {
+	mu    sync.Mutex
+	seed  maphash.Seed
+	slots []uint64 // a power of two of them; 0 marks an empty one
+	n     int      // fingerprints in slots
+	zero  bool     // whether the fingerprint 0, which can't have a slot, was added
+}
+
+// fingerprint is what ArchiveSet keeps of an ID; tests make it collide.
+var fingerprint = func(seed maphash.Seed, id string) uint64 { return maphash.String(seed, id) }
+
+// archiveSetSlots is how many slots a set starts with.
+const archiveSetSlots = 1024
+
+// NewArchiveSet returns an empty set.
+func NewArchiveSet() *ArchiveSet {
+	return &ArchiveSet{seed: maphash.MakeSeed(), slots: make([]uint64, archiveSetSlots)}
+}
+
+// Add adds id to the set and reports whether it's new. False means its
+// fingerprint was seen before: almost certainly for id itself, but not
+// certainly.
+func (s *ArchiveSet) Add(id string) bool {
+	sum := fingerprint(s.seed, id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sum == 0 {
+		added := !s.zero
+		s.zero = true
+		return added
+	}
+	// Kept at most three quarters full, so looking past a fingerprint's
+	// slot for it or an empty one stays short.
+	if 4*(s.n+1) > 3*len(s.slots) {
+		s.grow()
+	}
+	return s.insert(sum)
+}
+
+func (s *ArchiveSet) insert(sum uint64) bool {
+	mask := uint64(len(s.slots) - 1)
+	for i := sum & mask; ; i = (i + 1) & mask {
+		switch s.slots[i] {
+		case sum:
+			return false
+		case 0:
+			s.slots[i] = sum
+			s.n++
+			return true
+		}
+	}
+}
+
+// grow doubles the slots, moving every fingerprint into the new ones.
+func (s *ArchiveSet) grow() {
+	old := s.slots
+	s.slots, s.n = make([]uint64, 2*len(old)), 0
+	for _, sum := range old {
+		if sum != 0 {
+			s.insert(sum)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package glacierpurge
+
+import (
+	"context"
+	"hash/maphash"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+func TestArchiveSet(t *testing.T) {
+	s := NewArchiveSet()
+	// Enough to grow the table several times over.
+	const n = 100_000
+	for i := 0; i < n; i++ {
+		if !s.Add(strconv.Itoa(i)) {
+			t.Fatalf("archive %d was taken for one already added", i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if s.Add(strconv.Itoa(i)) {
+			t.Fatalf("archive %d was taken for a new one after the table grew", i)
+		}
+	}
+	if s.n != n || len(s.slots) < n {
+		t.Errorf("holding %d fingerprints in %d slots", s.n, len(s.slots))
+	}
+}
+
+// collide has ArchiveSet take IDs with the same first letter for the same,
+// as a fingerprint's collision would, for the rest of the test.
+func collide(t *testing.T) {
+	saved := fingerprint
+	fingerprint = func(_ maphash.Seed, id string) uint64 { return uint64(id[0]) }
+	t.Cleanup(func() { fingerprint = saved })
+}
+
+// listing emits archives, as an inventory would, for deleteArchives.
+func listing(archives []*Archive) func(emit func(*Archive) error) error {
+	return func(emit func(*Archive) error) error {
+		for _, archive := range archives {
+			if err := emit(archive); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// TestDeleteArchivesCollision lists an archive twice, and another whose
+// fingerprint collides with it, and checks both are deleted and the
+// checkpoint gets past them, rather than the second being passed over.
+func TestDeleteArchivesCollision(t *testing.T) {
+	collide(t)
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: []glaciertest.Archive{{Id: "a1", Size: 1}, {Id: "a2", Size: 2}, {Id: "b1", Size: 3}}})
+	vault := &Vault{Glacier: g, Name: "vault"}
+
+	var archives []*Archive
+	for _, id := range []string{"a1", "b1", "a1", "a2"} {
+		archives = append(archives, &Archive{Vault: vault, Id: id})
+	}
+	var done int64
+	result, err := (&InventoryJob{Vault: vault}).deleteArchives(context.Background(), listing(archives), int64(len(archives)), DeleteOptions{
+		Workers: 2,
+		Done:    func(n int64) { atomic.StoreInt64(&done, n) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left := fake.Archives("vault"); len(left) != 0 {
+		t.Errorf("left %+v, want every archive deleted", left)
+	}
+	if result.Archives != 2 || result.Deleted != 2 || result.Duplicates != 2 || result.Failed != 0 {
+		t.Errorf("got %+v, want 2 deleted and 2 found gone again", result)
+	}
+	if done != 4 {
+		t.Errorf("checkpointed %d, want 4", done)
+	}
+}
+
+func TestDeleteArchivesCollisionFails(t *testing.T) {
+	collide(t)
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: []glaciertest.Archive{{Id: "a1"}, {Id: "a2"}}})
+	vault := &Vault{Glacier: g, Name: "vault"}
+	fake.Intercept(glaciertest.OpDeleteArchive, func(input any) error {
+		if aws.ToString(input.(*glacier.DeleteArchiveInput).ArchiveId) == "a2" {
+			return glaciertest.Throttled()
+		}
+		return nil
+	})
+
+	archives := []*Archive{{Vault: vault, Id: "a1"}, {Vault: vault, Id: "a2"}}
+	var done int64
+	result, err := (&InventoryJob{Vault: vault}).deleteArchives(context.Background(), listing(archives), int64(len(archives)), DeleteOptions{
+		Workers: 1,
+		Done:    func(n int64) { atomic.StoreInt64(&done, n) },
+	})
+	if err == nil {
+		t.Error("got no error, want the failed deletion reported")
+	}
+	// Counted as the failure it is, not as a duplicate.
+	if result.Archives != 2 || result.Deleted != 1 || result.Failed != 1 || result.Duplicates != 0 {
+		t.Errorf("got %+v, want 1 deleted and 1 failed", result)
+	}
+	if done != 1 {
+		t.Errorf("checkpointed %d, want 1", done)
+	}
+}
+
+// TestSalvageCollision salvages a vault with two archives whose fingerprints
+// collide, and checks both are saved.
+func TestSalvageCollision(t *testing.T) {
+	collide(t)
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: []glaciertest.Archive{
+		{Id: "a1", Content: []byte("one")}, {Id: "a2", Content: []byte("two")}, {Id: "b1", Content: []byte("three")},
+	}})
+	vault := &Vault{Glacier: g, Name: "vault"}
+	job, err := vault.InitiateInventoryRetrievalJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := job.WaitLogged(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if _, err := job.Salvage(context.Background(), nil, SalvageOptions{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := loadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a1", "a2", "b1"} {
+		if entry := manifest.Archives[id]; entry == nil || !entry.Verified {
+			t.Errorf("archive %s wasn't salvaged: %+v", id, entry)
+		}
+	}
+}
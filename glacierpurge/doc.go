@@ -0,0 +1,40 @@
+// Package glacierpurge lists Amazon Glacier vaults and empties them: it
+// retrieves a vault's inventory and deletes every archive in it. It is the
+// library behind the ice-breaker command and never writes to stdout itself;
+// progress goes to the Logger given with WithLogger.
+//
+// Listing the vaults in a region:
+//
+//	g, err := glacierpurge.New(ctx, "us-east-1",
+//		glacierpurge.WithCredentials(accessKeyID, secretAccessKey),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//
+//	vaults, err := g.GetVaults(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	for _, vault := range vaults {
+//		fmt.Println(vault.Name)
+//	}
+//
+// Purging a vault, which blocks for the hours Glacier needs to produce the
+// inventory:
+//
+//	g, err := glacierpurge.New(ctx, "eu-west-1",
+//		glacierpurge.WithCredentials(accessKeyID, secretAccessKey),
+//		glacierpurge.WithLogger(log.Default()),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//
+//	vault := &glacierpurge.Vault{Glacier: g, Name: "old-photos"}
+//	result, err := vault.Purge(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Printf("deleted %d of %d archives\n", result.Deleted, result.Archives)
+package glacierpurge
@@ -0,0 +1,128 @@
+package glacierpurge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// newServedGlacier returns a client sending its requests through the SDK to
+// a server answering from fake.
+func newServedGlacier(t *testing.T, fake *glaciertest.Fake) (*Glacier, *glaciertest.Clock) {
+	t.Helper()
+	server := glaciertest.NewServer(fake)
+	t.Cleanup(server.Close)
+	clock := glaciertest.NewClock(testStart)
+	clock.Auto = true
+	fake.Now = clock.Now
+	g, err := New(context.Background(), "us-east-1", WithEndpointURL(server.URL), WithCredentials("AKIDEXAMPLE", "secret"), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, clock
+}
+
+func TestPurgeThroughTheSDK(t *testing.T) {
+	fake := glaciertest.New()
+	fake.PageSize = 2
+	fake.JobPolls = 3
+	fake.InventoryOnRefusal = true
+	g, clock := newServedGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "other"})
+	fake.AddVault(glaciertest.Vault{Name: "photos.2019_Backup-v2", Archives: append(make([]glaciertest.Archive, 20), awkwardArchives...)})
+	fake.AddVault(glaciertest.Vault{Name: "zzz"})
+
+	vaults, err := g.GetVaults(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vaults) != 3 || vaults[1].Name != "photos.2019_Backup-v2" {
+		t.Fatalf("got vaults %v", vaults)
+	}
+	vault := vaults[1]
+
+	job, err := vault.InitiateInventoryJob(context.Background(), InventoryOptions{Limit: 10, Format: "CSV"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := job.Purge(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Archives != 25 || result.Deleted != 25 || result.Pages != 3 {
+		t.Errorf("got %+v, want 25 archives deleted over 3 pages", result)
+	}
+	// Each page's job is in progress for three polls.
+	if n := len(clock.Waits()); n != 9 {
+		t.Errorf("waited %d times, want 9", n)
+	}
+
+	// Glacier's inventory still counts the archives the first time.
+	if err := vault.Delete(context.Background()); !errors.Is(err, ErrVaultNotEmpty) {
+		t.Errorf("got %v, want ErrVaultNotEmpty", err)
+	}
+	if err := vault.Delete(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fake.HasVault(vault.Name) {
+		t.Error("the vault is still there")
+	}
+	if _, err := vault.Refresh(context.Background()); !errors.Is(err, ErrVaultNotFound) {
+		t.Errorf("got %v, want ErrVaultNotFound", err)
+	}
+}
+
+func TestDownloadThroughTheSDK(t *testing.T) {
+	fake := glaciertest.New()
+	fake.JobPolls = 1
+	g, _ := newServedGlacier(t, fake)
+	content := bytes.Repeat([]byte("0123456789abcdef"), 160*1024) // 2.5 MiB
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: []glaciertest.Archive{{Id: "archive", Content: content}}})
+	archive := &Archive{Vault: &Vault{Glacier: g, Name: "vault"}, Id: "archive"}
+
+	var buf bytes.Buffer
+	result, err := archive.Download(context.Background(), &buf, DownloadOptions{PartSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) || result.TreeHash != glaciertest.TreeHash(content) {
+		t.Errorf("downloaded %d bytes hashing to %s, want %d hashing to %s", buf.Len(), result.TreeHash, len(content), glaciertest.TreeHash(content))
+	}
+	if n := fake.Count(glaciertest.OpGetJobOutput); n != 3 {
+		t.Errorf("got the output in %d parts, want 3", n)
+	}
+}
+
+func TestErrorsThroughTheSDK(t *testing.T) {
+	fake := glaciertest.New()
+	g, _ := newServedGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 1)})
+	vault := &Vault{Glacier: g, Name: "vault"}
+
+	if err := (&Archive{Vault: vault, Id: "missing"}).Delete(context.Background()); !errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("got %v, want ErrArchiveNotFound", err)
+	}
+	if _, err := (&Vault{Glacier: g, Name: "missing"}).Describe(context.Background()); !errors.Is(err, ErrVaultNotFound) {
+		t.Errorf("got %v, want ErrVaultNotFound", err)
+	}
+
+	fake.Script(glaciertest.OpDeleteVault, glaciertest.Response{Err: glaciertest.AccessDenied()})
+	var permissionErr *PermissionError
+	if err := vault.Delete(context.Background()); !errors.As(err, &permissionErr) {
+		t.Errorf("got %v, want a *PermissionError", err)
+	}
+
+	// The SDK retries throttling itself, three attempts in all.
+	fake.Script(glaciertest.OpDescribeVault, glaciertest.Response{Err: glaciertest.Throttled()}, glaciertest.Response{Err: glaciertest.Throttled()})
+	start := time.Now()
+	if _, err := vault.Refresh(context.Background()); err != nil {
+		t.Errorf("got %v after %s, want the throttling retried away", err, time.Since(start))
+	}
+	if n := fake.Count(glaciertest.OpDescribeVault); n != 4 {
+		t.Errorf("DescribeVault was called %d times, want 4", n)
+	}
+}
@@ -0,0 +1,78 @@
+package glacierpurge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/smithy-go"
+)
+
+// PermissionError is returned when AWS refuses an operation because the
+// credentials aren't allowed to perform it.
+type PermissionError struct {
+	Op    string // the Glacier operation, e.g. "DescribeVault"
+	Vault string
+	Err   error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("not permitted to call %s on vault %s: %v", e.Op, e.Vault, e.Err)
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// isAccessDenied reports whether err is AWS refusing the call for lack of
+// permission.
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "AccessDeniedException", "AccessDenied":
+			return true
+		}
+	}
+	return false
+}
+
+// IsAccessDenied reports whether err is AWS refusing a call because the
+// credentials, though valid, aren't allowed to make it.
+func IsAccessDenied(err error) bool {
+	return isAccessDenied(err)
+}
+
+// isNotFound reports whether err is AWS saying the vault, archive, or job
+// doesn't exist. Glacier forgets jobs about a day after they complete.
+func isNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ResourceNotFoundException"
+}
+
+// isVaultNotFound reports whether err is Glacier saying the vault itself
+// doesn't exist, as opposed to the archive or job asked about in it.
+func isVaultNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return isNotFound(err) && errors.As(err, &apiErr) && strings.HasPrefix(strings.ToLower(apiErr.ErrorMessage()), "vault not found")
+}
+
+// isInsufficientCapacity reports whether err is Glacier turning down an
+// Expedited retrieval for lack of capacity.
+func isInsufficientCapacity(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InsufficientCapacityException"
+}
+
+// isInvalidParameter reports whether err is Glacier rejecting a request's
+// parameters, which is also how DeleteVault reports a vault that isn't empty.
+func isInvalidParameter(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidParameterValueException"
+}
+
+// isTransient reports whether err is a failure that's likely gone by the
+// next attempt; see ErrorClass.Transient.
+func isTransient(err error) bool {
+	return Classify(err).Transient()
+}
@@ -0,0 +1,100 @@
+package glacierpurge_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// newExampleGlacier returns a Glacier whose calls go to a fake with a few
+// vaults in it, as WithCredentials would have them go to AWS.
+func newExampleGlacier(ctx context.Context) *glacierpurge.Glacier {
+	fake := glaciertest.New()
+	fake.AddVault(glaciertest.Vault{Name: "old-photos", Archives: make([]glaciertest.Archive, 3)})
+	fake.AddVault(glaciertest.Vault{Name: "backups-2019", Archives: []glaciertest.Archive{
+		{Description: "monday.tar", Content: []byte("monday's backup")},
+		{Description: "tuesday.tar", Content: []byte("tuesday's backup")},
+	}})
+	fake.AddVault(glaciertest.Vault{Name: "empty"})
+
+	g, err := glacierpurge.New(ctx, "us-east-1", glacierpurge.WithClient(fake))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return g
+}
+
+func ExampleGlacier_GetVaults() {
+	ctx := context.Background()
+	g := newExampleGlacier(ctx)
+
+	vaults, err := g.GetVaults(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, vault := range vaults {
+		fmt.Println(vault.Name)
+	}
+	// Output:
+	// backups-2019
+	// empty
+	// old-photos
+}
+
+func ExampleVault_Purge() {
+	ctx := context.Background()
+	g := newExampleGlacier(ctx)
+
+	vault := &glacierpurge.Vault{Glacier: g, Name: "old-photos"}
+	result, err := vault.Purge(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("deleted %d of %d archives\n", result.Deleted, result.Archives)
+	// Output:
+	// deleted 3 of 3 archives
+}
+
+func ExampleInventoryJob_DeleteAll() {
+	ctx := context.Background()
+	g := newExampleGlacier(ctx)
+
+	// A job initiated earlier, such as by another run, once it's completed.
+	vault := &glacierpurge.Vault{Glacier: g, Name: "backups-2019"}
+	job, err := vault.InitiateInventoryRetrievalJob(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := job.WaitLogged(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// Deleted is called from the goroutines deleting, hence the lock.
+	var (
+		mu      sync.Mutex
+		deleted []string
+	)
+	result, err := job.DeleteAll(ctx, glacierpurge.DeleteOptions{
+		Workers: 2,
+		Deleted: func(archive *glacierpurge.Archive) {
+			mu.Lock()
+			defer mu.Unlock()
+			deleted = append(deleted, archive.Description)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Strings(deleted)
+	fmt.Println(strings.Join(deleted, ", "))
+	fmt.Printf("deleted %d of %d archives, %d bytes\n", result.Deleted, result.Archives, result.DeletedBytes)
+	// Output:
+	// monday.tar, tuesday.tar
+	// deleted 2 of 2 archives, 31 bytes
+}
@@ -0,0 +1,101 @@
+package glacierpurge
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// jsonInventorySeeds are JSON inventories as Glacier writes them, and ways
+// they go wrong.
+var jsonInventorySeeds = []string{
+	`{"VaultARN":"arn:aws:glacier:us-east-1:123456789012:vaults/examplevault","InventoryDate":"2024-03-01T12:00:00Z","ArchiveList":[{"ArchiveId":"kKB7ymWJVpPSwhGP6ycSOAekp9ZYe_--zM_mw6k76ZFGEIWQX-ybtRDvc2VkPSDtfKmQrj0IRQLSGsNuDp-AJVlu2ccmDSyDUmZwKbwbpAdGATGDiB3hHO0bjbGehXTcApVud_wyDw","ArchiveDescription":"multipart upload test","CreationDate":"2012-06-22T22:56:19Z","Size":3145728,"SHA256TreeHash":"9628195fcdbcbbe76cdde932d4646fa7de5f219fb39823836d81f0cc0e18aa67"}]}`,
+	`{"VaultARN":"arn:aws:glacier:us-east-1:123456789012:vaults/v","InventoryDate":"2024-03-01T12:00:00Z","ArchiveList":[{"ArchiveId":"quoted","ArchiveDescription":"say \"cheese\", then, a comma","CreationDate":"2024-02-28T12:00:00Z","Size":1099511627776,"SHA256TreeHash":""},{"ArchiveId":"multiline","ArchiveDescription":"line one\nline two\r\n\ttabbed \\ backslash \u0000","CreationDate":"2024-02-29T12:00:00Z","Size":0,"SHA256TreeHash":""},{"ArchiveId":"unicode","ArchiveDescription":"фото — 写真 — 📷 📷","CreationDate":"2024-03-01T11:00:00Z","Size":12,"SHA256TreeHash":""}]}`,
+	`{"ArchiveList":[{"ArchiveId":"no optional fields"},{"ArchiveId":"no description","CreationDate":"2024-03-01T11:00:00Z","Size":1}]}`,
+	`  {"InventoryDate":"2024-03-01T12:00:00Z","ArchiveList":[],"Extra":{"nested":[1,2,{"x":null}]}}`,
+	`{"ArchiveList":null}`,
+	`{"ArchiveList":[{"ArchiveId":"a","CreationDate":"yesterday"}]}`,
+	`{"ArchiveList":[{"ArchiveId":"a","Size":"big"}]}`,
+	`{"ArchiveList":[{"ArchiveId":"a"},`,
+	`{"VaultARN":"arn:aws:glacier:us-east-1:123456789012:vaults/v","ArchiveList":[{"ArchiveId":"a","ArchiveDescription":"trunc`,
+	`{`,
+	``,
+}
+
+// csvInventorySeeds are CSV inventories as Glacier writes them, and ways they
+// go wrong.
+var csvInventorySeeds = []string{
+	"ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\r\nkKB7ymWJVpPSwhGP6ycSOAekp9ZYe_--zM_mw6k76ZFGEIWQX,multipart upload test,2012-06-22T22:56:19Z,3145728,9628195fcdbcbbe76cdde932d4646fa7de5f219fb39823836d81f0cc0e18aa67\r\n",
+	"ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\nquoted,\"say \"\"cheese\"\", then, a comma\",2024-02-28T12:00:00Z,1099511627776,\nmultiline,\"line one\nline two\r\n\ttabbed \\ backslash\",2024-02-29T12:00:00Z,0,\nunicode,фото — 写真 — 📷,2024-03-01T11:00:00Z,12,\n",
+	"SHA256TreeHash, Size ,CreationDate,ArchiveDescription,ArchiveId\n,1,,,reordered\n",
+	"ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\nempty,,,0,\n",
+	"ArchiveId,ArchiveDescription,CreationDate,Size\nmissing,,,0\n",
+	"ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\na,,2024-03-01T11:00:00Z,-,\n",
+	"ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\na,,yesterday,1,\n",
+	"ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\na,\"unterminated,2024-03-01T11:00:00Z,1,\n",
+	"ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\na,b,2024-03-01T11:00:00Z\n",
+	"ArchiveId,ArchiveDesc",
+	"",
+}
+
+// inventoryErrors are how StreamInventory's errors start, each saying what
+// was wrong with the inventory.
+var inventoryErrors = []string{
+	"failed to read job output: ",
+	"failed to decode job output: ",
+	"failed to read inventory header: ",
+	"failed to read inventory: ",
+	"inventory is missing the ",
+	"archive ",
+}
+
+// checkStream reads data with stream, failing if what it reads doesn't fit
+// in data or an error doesn't say what was wrong. A panic fails the fuzz
+// target by itself.
+func checkStream(t *testing.T, data string, stream func(r io.Reader, vault *Vault, fn func(*Archive) error) error) {
+	vault := &Vault{Name: "fuzz"}
+	count := 0
+	err := stream(strings.NewReader(data), vault, func(archive *Archive) error {
+		count++
+		if archive.Vault != vault {
+			t.Fatalf("archive %q isn't of the vault", archive.Id)
+		}
+		if len(archive.Id) > len(data) || len(archive.Description) > len(data) || len(archive.TreeHash) > len(data) {
+			t.Fatalf("archive %q has fields longer than the input", archive.Id)
+		}
+		return nil
+	})
+	// Each archive takes two bytes at the very least.
+	if count > len(data)/2+1 {
+		t.Fatalf("read %d archives from %d bytes", count, len(data))
+	}
+	if err == nil {
+		return
+	}
+	for _, prefix := range inventoryErrors {
+		if strings.HasPrefix(err.Error(), prefix) && len(err.Error()) > len(prefix) {
+			return
+		}
+	}
+	t.Fatalf("undescriptive error: %v", err)
+}
+
+func FuzzStreamInventoryJSON(f *testing.F) {
+	for _, seed := range jsonInventorySeeds {
+		f.Add(seed)
+		f.Add(seed[:len(seed)/2])
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		checkStream(t, data, StreamInventory)
+	})
+}
+
+func FuzzStreamInventoryCSV(f *testing.F) {
+	for _, seed := range csvInventorySeeds {
+		f.Add(seed)
+		f.Add(seed[:len(seed)/2])
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		checkStream(t, data, streamCSVInventory)
+	})
+}
@@ -0,0 +1,173 @@
+//go:build ignore
+
+// gen_regions writes regions_gen.go, the table of AWS partitions and the
+// regions in which Glacier can be reached, from the metadata the AWS SDK for
+// Go v2 modules in go.mod ship: the partitions from the core module's
+// partitions.json, and Glacier's endpoints from the glacier module's
+// endpoint resolver. Run it with go generate after bumping either module.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	core, glacier := moduleDir("github.com/aws/aws-sdk-go-v2"), moduleDir("github.com/aws/aws-sdk-go-v2/service/glacier")
+
+	data, err := os.ReadFile(filepath.Join(core, "internal", "endpoints", "awsrulesfn", "partitions.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var metadata struct {
+		Partitions []struct {
+			ID          string
+			RegionRegex string
+			Regions     map[string]json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		log.Fatalf("failed to parse partitions.json: %v", err)
+	}
+	endpoints := glacierEndpoints(filepath.Join(glacier, "internal", "endpoints", "endpoints.go"))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by gen_regions.go from %s and %s; DO NOT EDIT.\n\n", filepath.Base(core), filepath.Base(glacier))
+	fmt.Fprintf(&out, "package glacierpurge\n\n")
+	fmt.Fprintf(&out, "var partitions = []partition{\n")
+	for _, p := range metadata.Partitions {
+		var regions []string
+		for region := range p.Regions {
+			// Each partition has a pseudo-region for its global endpoints.
+			if !strings.HasSuffix(region, "-global") {
+				regions = append(regions, region)
+			}
+		}
+		sort.Strings(regions)
+		e := endpoints[p.ID]
+		if e == nil {
+			e = &partitionEndpoints{}
+		}
+		fmt.Fprintf(&out, "\t{\n\t\tID: %q,\n\t\tRegionRegex: regexp.MustCompile(%q),\n", p.ID, p.RegionRegex)
+		fmt.Fprintf(&out, "\t\tRegions: %#v,\n\t\tGlacier: %t,\n\t\tGlacierFIPS: %#v,\n\t},\n", regions, e.regional, e.fips)
+	}
+	fmt.Fprintf(&out, "}\n")
+
+	src := bytes.Replace(out.Bytes(), []byte("package glacierpurge\n"), []byte("package glacierpurge\n\nimport \"regexp\"\n"), 1)
+	src, err = format.Source(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("regions_gen.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// moduleDir returns the directory of the module at the version go.mod
+// requires, downloading it if need be.
+func moduleDir(path string) string {
+	out, err := exec.Command("go", "mod", "download", "-json", path).Output()
+	if err != nil {
+		log.Fatalf("failed to find module %s: %v", path, err)
+	}
+	var module struct{ Dir string }
+	if err := json.Unmarshal(out, &module); err != nil {
+		log.Fatal(err)
+	}
+	return module.Dir
+}
+
+// partitionEndpoints is what a partition's Glacier endpoints say: whether
+// it has any regional ones, and the regions with a FIPS one.
+type partitionEndpoints struct {
+	regional bool
+	fips     []string
+}
+
+// glacierEndpoints reads the endpoints of each partition from the glacier
+// module's resolver, whose defaultPartitions lists them as literals.
+func glacierEndpoints(path string) map[string]*partitionEndpoints {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	partitions := make(map[string]*partitionEndpoints)
+	ast.Inspect(file, func(n ast.Node) bool {
+		partition, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		id := stringField(partition, "ID")
+		if id == "" {
+			return true
+		}
+		e := &partitionEndpoints{}
+		partitions[id] = e
+		endpoints := field(partition, "Endpoints")
+		if endpoints == nil {
+			return false
+		}
+		ast.Inspect(endpoints, func(n ast.Node) bool {
+			key, ok := n.(*ast.CompositeLit)
+			if !ok || !isSelector(key.Type, "endpoints", "EndpointKey") {
+				return true
+			}
+			region := stringField(key, "Region")
+			switch variant := field(key, "Variant"); {
+			case variant == nil:
+				// The fips-* regions are old aliases of the FIPS endpoints,
+				// not regions.
+				e.regional = e.regional || !strings.HasPrefix(region, "fips-")
+			case isSelector(variant, "endpoints", "FIPSVariant"):
+				e.fips = append(e.fips, region)
+			}
+			return false
+		})
+		sort.Strings(e.fips)
+		return false
+	})
+	if len(partitions) == 0 {
+		log.Fatalf("no partitions found in %s", path)
+	}
+	return partitions
+}
+
+func field(lit *ast.CompositeLit, name string) ast.Expr {
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == name {
+				return kv.Value
+			}
+		}
+	}
+	return nil
+}
+
+func stringField(lit *ast.CompositeLit, name string) string {
+	if value, ok := field(lit, name).(*ast.BasicLit); ok && value.Kind == token.STRING {
+		s, _ := strconv.Unquote(value.Value)
+		return s
+	}
+	return ""
+}
+
+func isSelector(expr ast.Expr, pkg, name string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == pkg && sel.Sel.Name == name
+}
@@ -0,0 +1,286 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+)
+
+// API is the subset of the Glacier client the package relies on. Glacier
+// holds this rather than a *glacier.Client so the rest of the code can be run
+// against something other than AWS.
+type API interface {
+	ListVaults(ctx context.Context, params *glacier.ListVaultsInput, optFns ...func(*glacier.Options)) (*glacier.ListVaultsOutput, error)
+	DescribeVault(ctx context.Context, params *glacier.DescribeVaultInput, optFns ...func(*glacier.Options)) (*glacier.DescribeVaultOutput, error)
+	ListTagsForVault(ctx context.Context, params *glacier.ListTagsForVaultInput, optFns ...func(*glacier.Options)) (*glacier.ListTagsForVaultOutput, error)
+	AddTagsToVault(ctx context.Context, params *glacier.AddTagsToVaultInput, optFns ...func(*glacier.Options)) (*glacier.AddTagsToVaultOutput, error)
+	RemoveTagsFromVault(ctx context.Context, params *glacier.RemoveTagsFromVaultInput, optFns ...func(*glacier.Options)) (*glacier.RemoveTagsFromVaultOutput, error)
+	InitiateJob(ctx context.Context, params *glacier.InitiateJobInput, optFns ...func(*glacier.Options)) (*glacier.InitiateJobOutput, error)
+	DescribeJob(ctx context.Context, params *glacier.DescribeJobInput, optFns ...func(*glacier.Options)) (*glacier.DescribeJobOutput, error)
+	GetJobOutput(ctx context.Context, params *glacier.GetJobOutputInput, optFns ...func(*glacier.Options)) (*glacier.GetJobOutputOutput, error)
+	ListJobs(ctx context.Context, params *glacier.ListJobsInput, optFns ...func(*glacier.Options)) (*glacier.ListJobsOutput, error)
+	DeleteArchive(ctx context.Context, params *glacier.DeleteArchiveInput, optFns ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error)
+	DeleteVault(ctx context.Context, params *glacier.DeleteVaultInput, optFns ...func(*glacier.Options)) (*glacier.DeleteVaultOutput, error)
+}
+
+// ErrReadOnly is returned for destructive calls on a client created with
+// WithReadOnly.
+var ErrReadOnly = errors.New("refusing to delete anything: the client is read-only")
+
+// readOnlyAPI passes every call through except the deletes.
+type readOnlyAPI struct {
+	API
+}
+
+func (readOnlyAPI) DeleteArchive(context.Context, *glacier.DeleteArchiveInput, ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error) {
+	return nil, ErrReadOnly
+}
+
+func (readOnlyAPI) DeleteVault(context.Context, *glacier.DeleteVaultInput, ...func(*glacier.Options)) (*glacier.DeleteVaultOutput, error) {
+	return nil, ErrReadOnly
+}
+
+// Logger receives the package's progress messages. *log.Logger satisfies it.
+// A Logger with a Debugf method is also sent debugging details.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Printf(string, ...any) {}
+
+// debugf logs debugging details through the logger's Debugf method, for
+// loggers that have one.
+func (g *Glacier) debugf(format string, args ...any) {
+	if logger, ok := g.Logger.(interface{ Debugf(string, ...any) }); ok {
+		logger.Debugf(format, args...)
+	}
+}
+
+// Glacier is a Glacier client bound to a single region.
+type Glacier struct {
+	Client   API
+	Region   string
+	Endpoint string // the resolved endpoint URL, empty when Client was supplied
+	Logger   Logger
+	Journal  Journal  // records deletions, if set
+	Limiter  *Limiter // paces the client's calls, if set
+	Meter    *Meter   // counts the client's deletions, if set
+	Pause    *Pause   // holds the client's deletions back, if set
+	WrapUp   *WrapUp  // stops the client's deletions early, if set
+	// Credentials, if set, are what the client's calls are signed with and
+	// are renewed when they expire.
+	Credentials *Credentials
+	Clock       Clock // what the client waits by; the wall clock if nil
+}
+
+type options struct {
+	settings  ClientSettings
+	client    API
+	logger    Logger
+	readOnly  bool
+	guard     *Guard
+	recorder  *Recorder
+	replay    *Replay
+	journal   Journal
+	limiter   *Limiter
+	meter     *Meter
+	pause     *Pause
+	wrapUp    *WrapUp
+	transport *Transport
+	clock     Clock
+}
+
+// Option configures a Glacier client created by New.
+type Option func(*options)
+
+// WithCredentials signs requests with a static access key pair.
+func WithCredentials(accessKeyID, secretAccessKey string) Option {
+	return func(o *options) {
+		o.settings.AccessKeyID = accessKeyID
+		o.settings.SecretAccessKey = secretAccessKey
+	}
+}
+
+// WithRenewingCredentials signs requests with credentials, which are meant
+// to be shared by every client and are renewed when they expire. It takes
+// the place of any keys.
+func WithRenewingCredentials(credentials *Credentials) Option {
+	return func(o *options) {
+		o.settings.Credentials = credentials
+	}
+}
+
+// WithSettings applies every field of settings at once.
+func WithSettings(settings *ClientSettings) Option {
+	return func(o *options) {
+		o.settings = *settings
+	}
+}
+
+// WithFIPS resolves FIPS endpoints, failing in regions that have none.
+func WithFIPS() Option {
+	return func(o *options) {
+		o.settings.UseFIPS = true
+	}
+}
+
+// WithDualStack resolves dual-stack (IPv4 and IPv6) endpoints.
+func WithDualStack() Option {
+	return func(o *options) {
+		o.settings.UseDualStack = true
+	}
+}
+
+// WithEndpointURL sends Glacier requests to endpoint instead of AWS.
+func WithEndpointURL(endpoint string) Option {
+	return func(o *options) {
+		o.settings.EndpointURL = endpoint
+	}
+}
+
+// WithClient uses client as-is instead of constructing an SDK client.
+func WithClient(client API) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithLogger sends progress messages to logger. They're discarded by default.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithReadOnly refuses every call that would delete an archive or a vault,
+// for callers that must be guaranteed not to destroy anything.
+func WithReadOnly() Option {
+	return func(o *options) {
+		o.readOnly = true
+	}
+}
+
+// WithClock has the client wait by clock, rather than the wall clock, when it
+// polls jobs.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// New returns a Glacier client for region.
+func New(ctx context.Context, region string, opts ...Option) (*Glacier, error) {
+	o := &options{logger: discardLogger{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.replay != nil {
+		o.client = replayAPI{o.replay, region}
+		if o.clock == nil {
+			o.clock = o.replay.Clock()
+		}
+	}
+
+	g := &Glacier{Region: region, Logger: o.logger, Journal: o.journal, Limiter: o.limiter, Meter: o.meter, Pause: o.pause, WrapUp: o.wrapUp, Credentials: o.settings.Credentials, Clock: o.clock}
+	if o.client != nil {
+		g.Client = o.client
+		if o.guard != nil {
+			g.Client = guardedAPI{g.Client, o.guard, region}
+		}
+		g.wrapClient(o, region)
+		return g, nil
+	}
+
+	cfg, err := LoadConfig(ctx, region, &o.settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// The resolver is always given the real region so requests are signed for
+	// it, even when BaseEndpoint points them somewhere else.
+	params := glacier.EndpointParameters{
+		Region:       aws.String(region),
+		UseFIPS:      aws.Bool(o.settings.UseFIPS),
+		UseDualStack: aws.Bool(o.settings.UseDualStack),
+	}
+	if o.settings.EndpointURL != "" {
+		params.Endpoint = aws.String(o.settings.EndpointURL)
+	} else if o.settings.UseFIPS && !HasFIPSEndpoint(region) {
+		return nil, fmt.Errorf("glacier has no FIPS endpoint in region %s", region)
+	}
+
+	endpoint, err := glacier.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve Glacier endpoint for region %s: %w", region, err)
+	}
+
+	transport := o.transport
+	if transport == nil {
+		transport = defaultTransport()
+	}
+	cfg.HTTPClient = transport
+
+	g.Endpoint = endpoint.URI.String()
+	g.Client = glacier.NewFromConfig(cfg, func(opts *glacier.Options) {
+		opts.BaseEndpoint = params.Endpoint
+		if o.guard != nil {
+			opts.APIOptions = append(opts.APIOptions, o.guard.middleware(region))
+		}
+		if o.limiter != nil {
+			// Every client's retries come out of the limiter's one budget.
+			opts.Retryer = retry.NewStandard(func(so *retry.StandardOptions) {
+				so.RateLimiter = o.limiter.retries
+			})
+		}
+	})
+	g.wrapClient(o, region)
+	return g, nil
+}
+
+// wrapClient layers the options' recording, pacing, credential renewal, and
+// read-only guard over the client.
+func (g *Glacier) wrapClient(o *options, region string) {
+	if o.recorder != nil {
+		g.Client = recordingAPI{g.Client, o.recorder, region}
+	}
+	if o.limiter != nil {
+		g.Client = limitedAPI{g.Client, o.limiter}
+	}
+	if o.settings.Credentials != nil {
+		g.Client = renewingAPI{g.Client, o.settings.Credentials}
+	}
+	if o.readOnly {
+		g.Client = readOnlyAPI{g.Client}
+	}
+}
+
+// GetVaults lists every vault in the client's region.
+func (g *Glacier) GetVaults(ctx context.Context) ([]*Vault, error) {
+	var vaults []*Vault
+	paginator := glacier.NewListVaultsPaginator(g.Client, &glacier.ListVaultsInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing Glacier vaults in region %s: %w", g.Region, err)
+		}
+
+		for _, vault := range output.VaultList {
+			v := &Vault{Glacier: g, Name: *vault.VaultName, ARN: aws.ToString(vault.VaultARN)}
+			// ListVaults reports everything DescribeVault would, so seed the
+			// cache rather than describing every vault again.
+			if description, err := newVaultDescription(vault.VaultARN, vault.SizeInBytes, vault.NumberOfArchives, vault.CreationDate, vault.LastInventoryDate); err == nil {
+				v.description = description
+				v.CreationDate = description.CreationDate
+			}
+			vaults = append(vaults, v)
+		}
+	}
+
+	return vaults, nil
+}
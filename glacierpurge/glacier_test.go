@@ -0,0 +1,114 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+var _ API = (*glaciertest.Fake)(nil)
+
+// testStart is when the tests' fake clocks start.
+var testStart = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestGlacier returns a client of fake whose waits are measured by an
+// auto-advancing clock, which is returned too.
+func newTestGlacier(t testing.TB, fake *glaciertest.Fake, opts ...Option) (*Glacier, *glaciertest.Clock) {
+	t.Helper()
+	clock := glaciertest.NewClock(testStart)
+	clock.Auto = true
+	fake.Now = clock.Now
+	g, err := New(context.Background(), "us-east-1", append([]Option{WithClient(fake), WithClock(clock)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, clock
+}
+
+func TestGetVaultsFollowsEveryPage(t *testing.T) {
+	fake := glaciertest.New()
+	fake.PageSize = 2
+	g, _ := newTestGlacier(t, fake)
+	for _, name := range []string{"e", "a", "d", "c", "b"} {
+		fake.AddVault(glaciertest.Vault{Name: name, Archives: make([]glaciertest.Archive, len(name)+1)})
+	}
+
+	vaults, err := g.GetVaults(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, vault := range vaults {
+		names = append(names, vault.Name)
+		if vault.ARN != fake.ARN(vault.Name) {
+			t.Errorf("vault %s has ARN %q, want %q", vault.Name, vault.ARN, fake.ARN(vault.Name))
+		}
+	}
+	if fmt.Sprint(names) != "[a b c d e]" {
+		t.Errorf("got vaults %v, want [a b c d e]", names)
+	}
+
+	calls := fake.Calls(glaciertest.OpListVaults)
+	var markers []string
+	for _, call := range calls {
+		markers = append(markers, aws.ToString(call.Input.(*glacier.ListVaultsInput).Marker))
+	}
+	want := fmt.Sprint([]string{"", fake.ARN("c"), fake.ARN("e")})
+	if fmt.Sprint(markers) != want {
+		t.Errorf("ListVaults was called with markers %v, want %v", markers, want)
+	}
+
+	// Listing seeds each vault's description, so none is described again.
+	description, err := vaults[0].Describe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.NumberOfArchives != 2 || description.LastInventoryDate != testStart {
+		t.Errorf("got description %+v, want 2 archives inventoried at %s", description, testStart)
+	}
+	if n := fake.Count(glaciertest.OpDescribeVault); n != 0 {
+		t.Errorf("DescribeVault was called %d times, want 0", n)
+	}
+}
+
+func TestGetVaultsFailsOnAFailedPage(t *testing.T) {
+	fake := glaciertest.New()
+	fake.PageSize = 1
+	g, _ := newTestGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "a"})
+	fake.AddVault(glaciertest.Vault{Name: "b"})
+	fake.Script(glaciertest.OpListVaults, glaciertest.Response{}, glaciertest.Response{Err: glaciertest.AccessDenied()})
+
+	vaults, err := g.GetVaults(context.Background())
+	if err == nil {
+		t.Fatalf("got vaults %v, want an error", vaults)
+	}
+	if !IsAccessDenied(err) {
+		t.Errorf("got error %v, want access denied", err)
+	}
+}
+
+func TestReadOnlyRefusesDeletes(t *testing.T) {
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake, WithReadOnly())
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: []glaciertest.Archive{{Id: "archive"}}})
+	vault := &Vault{Glacier: g, Name: "vault"}
+
+	if err := (&Archive{Vault: vault, Id: "archive"}).Delete(context.Background()); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("deleting an archive got %v, want ErrReadOnly", err)
+	}
+	if err := vault.Delete(context.Background()); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("deleting the vault got %v, want ErrReadOnly", err)
+	}
+	if n := len(fake.Calls(glaciertest.OpDeleteArchive, glaciertest.OpDeleteVault)); n != 0 {
+		t.Errorf("%d deletions reached Glacier, want 0", n)
+	}
+}
@@ -0,0 +1,114 @@
+package glaciertest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a glacierpurge.Clock that only moves when it's told to, so hours
+// of polling run in microseconds and the waits asked of it can be checked.
+//
+// A Clock with Auto set moves itself: each After advances it by the wait and
+// fires at once, which suits code that waits from one goroutine at a time.
+// Otherwise waits fire as Advance passes them.
+type Clock struct {
+	// Auto has every After advance the clock by its wait. Set it before the
+	// clock is used.
+	Auto bool
+
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*timer
+	waits   []time.Duration
+	changed chan struct{} // closed, and replaced, whenever a wait is added
+}
+
+type timer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewClock returns a Clock reading now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now, changed: make(chan struct{})}
+}
+
+// Now returns the clock's time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the time once the clock has moved d
+// on.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if c.Auto && d > 0 {
+		c.now = c.now.Add(d)
+	}
+	if d <= 0 || c.Auto {
+		ch <- c.now
+	} else {
+		c.timers = append(c.timers, &timer{at: c.now.Add(d), ch: ch})
+	}
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return ch
+}
+
+// Advance moves the clock d on, firing the waits it passes in the order they
+// end.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- t.at
+	}
+	c.timers = pending
+}
+
+// Pending returns how many waits haven't fired yet.
+func (c *Clock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil waits until at least n waits are pending, so a test can advance
+// the clock knowing the code under test is waiting on it. It gives up, and
+// returns false, once timeout passes in real time.
+func (c *Clock) BlockUntil(n int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		c.mu.Lock()
+		pending, changed := len(c.timers), c.changed
+		c.mu.Unlock()
+		if pending >= n {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// Waits returns every wait asked of the clock, in order.
+func (c *Clock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
@@ -0,0 +1,66 @@
+package glaciertest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/smithy-go"
+)
+
+// APIError is an error response from Glacier, as the SDK decodes one: it
+// satisfies smithy.APIError and reports the response's HTTP status, so it's
+// classified as the real error would be.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error %s: %s", e.Code, e.Message)
+}
+
+func (e *APIError) ErrorCode() string    { return e.Code }
+func (e *APIError) ErrorMessage() string { return e.Message }
+func (e *APIError) HTTPStatusCode() int  { return e.Status }
+
+func (e *APIError) ErrorFault() smithy.ErrorFault {
+	if e.Status >= 500 {
+		return smithy.FaultServer
+	}
+	return smithy.FaultClient
+}
+
+// Error returns Glacier's error response with the status, code, and message.
+func Error(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// Throttled is Glacier turning a call down for its rate.
+func Throttled() *APIError {
+	return Error(http.StatusBadRequest, "ThrottlingException", "Rate exceeded")
+}
+
+// AccessDenied is Glacier refusing a call the credentials aren't allowed to
+// make.
+func AccessDenied() *APIError {
+	return Error(http.StatusForbidden, "AccessDeniedException", "User is not authorized to perform this action")
+}
+
+// Unavailable is a 5xx from Glacier.
+func Unavailable() *APIError {
+	return Error(http.StatusServiceUnavailable, "ServiceUnavailableException", "Service is unavailable, try again later")
+}
+
+// NotFound is Glacier not finding the resource the message describes.
+func NotFound(message string) *APIError {
+	return Error(http.StatusNotFound, "ResourceNotFoundException", message)
+}
+
+func vaultNotFound(arn string) *APIError {
+	return NotFound("Vault not found for ARN: " + arn)
+}
+
+func invalidParameter(message string) *APIError {
+	return Error(http.StatusBadRequest, "InvalidParameterValueException", message)
+}
@@ -0,0 +1,847 @@
+// Package glaciertest provides an in-memory Glacier for testing code built on
+// glacierpurge without AWS: Fake, a glacierpurge.API whose vaults, archives,
+// and jobs behave as Glacier's do, whose calls can be scripted one by one and
+// are all recorded; Server, which serves a Fake over Glacier's REST API for
+// the real SDK client; and Clock, which only moves when it's told to.
+package glaciertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+)
+
+// Operation names, as Calls and Script know them.
+const (
+	OpListVaults          = "ListVaults"
+	OpDescribeVault       = "DescribeVault"
+	OpListTagsForVault    = "ListTagsForVault"
+	OpAddTagsToVault      = "AddTagsToVault"
+	OpRemoveTagsFromVault = "RemoveTagsFromVault"
+	OpInitiateJob         = "InitiateJob"
+	OpDescribeJob         = "DescribeJob"
+	OpGetJobOutput        = "GetJobOutput"
+	OpListJobs            = "ListJobs"
+	OpDeleteArchive       = "DeleteArchive"
+	OpDeleteVault         = "DeleteVault"
+)
+
+// Vault is a vault to create in a Fake.
+type Vault struct {
+	Name         string
+	CreationDate time.Time // defaults to a week before the fake's now
+	// InventoryDate is when Glacier last inventoried the vault, which is
+	// what its archive count and inventory jobs reflect; defaults to now.
+	InventoryDate time.Time
+	Archives      []Archive
+	Tags          map[string]string
+}
+
+// Archive is an archive in a Fake's vault. Any field left zero but the
+// description is filled in when it's added.
+type Archive struct {
+	Id           string
+	Description  string
+	CreationDate time.Time // defaults to the vault's
+	Content      []byte
+	Size         int64  // defaults to the content's length
+	TreeHash     string // defaults to the content's tree hash
+}
+
+// Response is a scripted reply to one call. Output, if set, must be the
+// operation's output type, such as *glacier.ListVaultsOutput. A Response
+// with neither Output nor Err lets the call through to the fake.
+type Response struct {
+	Output any
+	Err    error
+}
+
+// Call is a call made of a Fake, with the error it returned, if any.
+type Call struct {
+	Op    string
+	Input any // the operation's input, such as *glacier.DeleteArchiveInput
+	Err   error
+}
+
+// Fake is an in-memory Glacier account in one region. Its zero value isn't
+// usable; create one with New.
+//
+// Like Glacier, a vault's archive count, its inventory jobs' output, and
+// whether it may be deleted go by its last inventory, which only
+// TakeInventory brings up to date; archives deleted since still count, and
+// are still listed. Jobs stay in progress for JobPolls descriptions of them.
+type Fake struct {
+	Region  string           // named in ARNs; us-east-1 unless set
+	Account string           // named in ARNs; 123456789012 unless set
+	Now     func() time.Time // what the fake stamps dates with; time.Now unless set
+	// JobPolls is how many times DescribeJob reports a job in progress
+	// before it completes.
+	JobPolls int
+	// PageSize is how many vaults or jobs a page lists when the call gives
+	// no limit; Glacier's own defaults of 10 and 50 unless set.
+	PageSize int
+	// InventoryOnRefusal has a DeleteVault turned down because the vault's
+	// inventory still lists archives take its inventory, as a day passing
+	// would, so that once emptied the vault goes at the next attempt.
+	InventoryOnRefusal bool
+
+	mu        sync.Mutex
+	vaults    map[string]*vault
+	jobs      map[string]*job
+	jobOrder  []string
+	ids       int
+	scripts   map[string][]Response
+	intercept map[string]func(input any) error
+	calls     []Call
+}
+
+type vault struct {
+	name        string
+	created     time.Time
+	archives    []Archive // as they are now
+	inventory   []Archive // as of the last inventory
+	inventoried time.Time
+	tags        map[string]string
+}
+
+type job struct {
+	id, vault  string
+	action     types.ActionCode
+	created    time.Time
+	completed  time.Time
+	status     types.StatusCode
+	message    string
+	polls      int
+	output     []byte
+	retrieval  *types.InventoryRetrievalJobDescription
+	archive    *Archive
+	tier       string
+	outputType string
+}
+
+// New returns a Fake with no vaults.
+func New() *Fake {
+	return &Fake{
+		vaults:    make(map[string]*vault),
+		jobs:      make(map[string]*job),
+		scripts:   make(map[string][]Response),
+		intercept: make(map[string]func(input any) error),
+	}
+}
+
+func (f *Fake) now() time.Time {
+	if f.Now != nil {
+		return f.Now().UTC().Truncate(time.Second)
+	}
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+func (f *Fake) region() string {
+	if f.Region == "" {
+		return "us-east-1"
+	}
+	return f.Region
+}
+
+func (f *Fake) account() string {
+	if f.Account == "" {
+		return "123456789012"
+	}
+	return f.Account
+}
+
+// ARN returns the ARN of the vault named name.
+func (f *Fake) ARN(name string) string {
+	return fmt.Sprintf("arn:aws:glacier:%s:%s:vaults/%s", f.region(), f.account(), name)
+}
+
+func (f *Fake) newId(kind string) string {
+	f.ids++
+	return fmt.Sprintf("%s-%06d", kind, f.ids)
+}
+
+// AddVault creates a vault, as Glacier has inventoried it.
+func (f *Fake) AddVault(v Vault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	created, inventoried := v.CreationDate, v.InventoryDate
+	if created.IsZero() {
+		created = now.Add(-7 * 24 * time.Hour)
+	}
+	if inventoried.IsZero() {
+		inventoried = now
+	}
+	state := &vault{name: v.Name, created: created.UTC(), inventoried: inventoried.UTC(), tags: make(map[string]string)}
+	for key, value := range v.Tags {
+		state.tags[key] = value
+	}
+	for _, archive := range v.Archives {
+		state.archives = append(state.archives, f.fill(archive, state.created))
+	}
+	state.inventory = append([]Archive(nil), state.archives...)
+	f.vaults[v.Name] = state
+}
+
+// fill completes an archive being added.
+func (f *Fake) fill(archive Archive, created time.Time) Archive {
+	if archive.Id == "" {
+		archive.Id = f.newId("archive")
+	}
+	if archive.CreationDate.IsZero() {
+		archive.CreationDate = created
+	}
+	archive.CreationDate = archive.CreationDate.UTC().Truncate(time.Second)
+	if archive.Size == 0 {
+		archive.Size = int64(len(archive.Content))
+	}
+	if archive.TreeHash == "" {
+		archive.TreeHash = TreeHash(archive.Content)
+	}
+	return archive
+}
+
+// Upload adds archives to a vault. Its inventory won't list them until
+// TakeInventory.
+func (f *Fake) Upload(name string, archives ...Archive) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v := f.vaults[name]
+	for _, archive := range archives {
+		v.archives = append(v.archives, f.fill(archive, f.now()))
+	}
+}
+
+// TakeInventory brings a vault's inventory up to date, as Glacier does about
+// once a day.
+func (f *Fake) TakeInventory(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v := f.vaults[name]
+	v.inventory = append([]Archive(nil), v.archives...)
+	v.inventoried = f.now()
+}
+
+// HasVault reports whether the vault exists.
+func (f *Fake) HasVault(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.vaults[name] != nil
+}
+
+// Archives returns the archives a vault holds now.
+func (f *Fake) Archives(name string) []Archive {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if v := f.vaults[name]; v != nil {
+		return append([]Archive(nil), v.archives...)
+	}
+	return nil
+}
+
+// Tags returns a vault's tags.
+func (f *Fake) Tags(name string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tags := make(map[string]string)
+	if v := f.vaults[name]; v != nil {
+		for key, value := range v.tags {
+			tags[key] = value
+		}
+	}
+	return tags
+}
+
+// FailJob has a job fail with message, if it hasn't completed yet.
+func (f *Fake) FailJob(id, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if j := f.jobs[id]; j != nil && j.status == types.StatusCodeInProgress {
+		j.status, j.message, j.completed = types.StatusCodeFailed, message, f.now()
+	}
+}
+
+// ExpireJob forgets a job, as Glacier does about a day after it completes.
+func (f *Fake) ExpireJob(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.jobs, id)
+}
+
+// Script queues responses for the next calls of op, one call each, ahead of
+// any queued already.
+func (f *Fake) Script(op string, responses ...Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scripts[op] = append(f.scripts[op], responses...)
+}
+
+// Intercept has every call of op that isn't scripted go through fn first;
+// one it returns an error for fails with that error instead of reaching the
+// fake. fn is called from the goroutines making the calls, and may call the
+// fake's other methods. A nil fn stops intercepting op.
+func (f *Fake) Intercept(op string, fn func(input any) error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if fn == nil {
+		delete(f.intercept, op)
+		return
+	}
+	f.intercept[op] = fn
+}
+
+// Calls returns the calls made so far, in order; only those of ops, if any
+// are given.
+func (f *Fake) Calls(ops ...string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []Call
+	for _, call := range f.calls {
+		if len(ops) == 0 || contains(ops, call.Op) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Count returns how many calls of op have been made.
+func (f *Fake) Count(op string) int {
+	return len(f.Calls(op))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// call answers a call of op: with the next scripted response, if there is
+// one, or the interceptor's error, or else by handling it.
+func call[In, Out any](f *Fake, op string, input In, handle func(In) (Out, error)) (Out, error) {
+	f.mu.Lock()
+	var scripted *Response
+	if queue := f.scripts[op]; len(queue) > 0 {
+		scripted, f.scripts[op] = &queue[0], queue[1:]
+	}
+	intercept := f.intercept[op]
+	f.mu.Unlock()
+
+	var output Out
+	var err error
+	switch {
+	case scripted != nil && (scripted.Output != nil || scripted.Err != nil):
+		if scripted.Output != nil {
+			output = scripted.Output.(Out)
+		}
+		err = scripted.Err
+	case scripted == nil && intercept != nil && func() bool { err = intercept(input); return err != nil }():
+	default:
+		f.mu.Lock()
+		output, err = handle(input)
+		f.mu.Unlock()
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Op: op, Input: input, Err: err})
+	f.mu.Unlock()
+	if err != nil {
+		var zero Out
+		return zero, err
+	}
+	return output, nil
+}
+
+// vault returns the named vault, or Glacier's error for a missing one.
+func (f *Fake) vault(name *string) (*vault, error) {
+	v := f.vaults[aws.ToString(name)]
+	if v == nil {
+		return nil, vaultNotFound(f.ARN(aws.ToString(name)))
+	}
+	return v, nil
+}
+
+func (f *Fake) describeVault(v *vault) types.DescribeVaultOutput {
+	var size int64
+	for _, archive := range v.inventory {
+		size += archive.Size
+	}
+	return types.DescribeVaultOutput{
+		VaultName:         aws.String(v.name),
+		VaultARN:          aws.String(f.ARN(v.name)),
+		CreationDate:      date(v.created),
+		LastInventoryDate: date(v.inventoried),
+		NumberOfArchives:  int64(len(v.inventory)),
+		SizeInBytes:       size,
+	}
+}
+
+// date formats t as Glacier does, or returns nil for the zero time.
+func date(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	return aws.String(t.UTC().Format(time.RFC3339))
+}
+
+// page returns the slice of n items starting at marker, found by key, and
+// the marker of the next page, if any.
+func page[T any](items []T, key func(T) string, marker string, limit int) ([]T, string, error) {
+	start := 0
+	if marker != "" {
+		start = -1
+		for i, item := range items {
+			if key(item) == marker {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return nil, "", invalidParameter("Invalid marker: " + marker)
+		}
+	}
+	end := len(items)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	next := ""
+	if end < len(items) {
+		next = key(items[end])
+	}
+	return items[start:end], next, nil
+}
+
+func (f *Fake) ListVaults(ctx context.Context, params *glacier.ListVaultsInput, optFns ...func(*glacier.Options)) (*glacier.ListVaultsOutput, error) {
+	return call(f, OpListVaults, params, func(params *glacier.ListVaultsInput) (*glacier.ListVaultsOutput, error) {
+		names := make([]string, 0, len(f.vaults))
+		for name := range f.vaults {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		limit := int(aws.ToInt32(params.Limit))
+		if limit <= 0 {
+			limit = f.pageSize(10)
+		}
+		// Glacier's marker is the ARN of the next vault to list.
+		listed, next, err := page(names, f.ARN, aws.ToString(params.Marker), limit)
+		if err != nil {
+			return nil, err
+		}
+		output := &glacier.ListVaultsOutput{}
+		for _, name := range listed {
+			output.VaultList = append(output.VaultList, f.describeVault(f.vaults[name]))
+		}
+		if next != "" {
+			output.Marker = aws.String(next)
+		}
+		return output, nil
+	})
+}
+
+func (f *Fake) pageSize(glacierDefault int) int {
+	if f.PageSize > 0 {
+		return f.PageSize
+	}
+	return glacierDefault
+}
+
+func (f *Fake) DescribeVault(ctx context.Context, params *glacier.DescribeVaultInput, optFns ...func(*glacier.Options)) (*glacier.DescribeVaultOutput, error) {
+	return call(f, OpDescribeVault, params, func(params *glacier.DescribeVaultInput) (*glacier.DescribeVaultOutput, error) {
+		v, err := f.vault(params.VaultName)
+		if err != nil {
+			return nil, err
+		}
+		description := f.describeVault(v)
+		return &glacier.DescribeVaultOutput{
+			VaultName:         description.VaultName,
+			VaultARN:          description.VaultARN,
+			CreationDate:      description.CreationDate,
+			LastInventoryDate: description.LastInventoryDate,
+			NumberOfArchives:  description.NumberOfArchives,
+			SizeInBytes:       description.SizeInBytes,
+		}, nil
+	})
+}
+
+func (f *Fake) ListTagsForVault(ctx context.Context, params *glacier.ListTagsForVaultInput, optFns ...func(*glacier.Options)) (*glacier.ListTagsForVaultOutput, error) {
+	return call(f, OpListTagsForVault, params, func(params *glacier.ListTagsForVaultInput) (*glacier.ListTagsForVaultOutput, error) {
+		v, err := f.vault(params.VaultName)
+		if err != nil {
+			return nil, err
+		}
+		tags := make(map[string]string, len(v.tags))
+		for key, value := range v.tags {
+			tags[key] = value
+		}
+		return &glacier.ListTagsForVaultOutput{Tags: tags}, nil
+	})
+}
+
+func (f *Fake) AddTagsToVault(ctx context.Context, params *glacier.AddTagsToVaultInput, optFns ...func(*glacier.Options)) (*glacier.AddTagsToVaultOutput, error) {
+	return call(f, OpAddTagsToVault, params, func(params *glacier.AddTagsToVaultInput) (*glacier.AddTagsToVaultOutput, error) {
+		v, err := f.vault(params.VaultName)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range params.Tags {
+			v.tags[key] = value
+		}
+		return &glacier.AddTagsToVaultOutput{}, nil
+	})
+}
+
+func (f *Fake) RemoveTagsFromVault(ctx context.Context, params *glacier.RemoveTagsFromVaultInput, optFns ...func(*glacier.Options)) (*glacier.RemoveTagsFromVaultOutput, error) {
+	return call(f, OpRemoveTagsFromVault, params, func(params *glacier.RemoveTagsFromVaultInput) (*glacier.RemoveTagsFromVaultOutput, error) {
+		v, err := f.vault(params.VaultName)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range params.TagKeys {
+			delete(v.tags, key)
+		}
+		return &glacier.RemoveTagsFromVaultOutput{}, nil
+	})
+}
+
+func (f *Fake) InitiateJob(ctx context.Context, params *glacier.InitiateJobInput, optFns ...func(*glacier.Options)) (*glacier.InitiateJobOutput, error) {
+	return call(f, OpInitiateJob, params, func(params *glacier.InitiateJobInput) (*glacier.InitiateJobOutput, error) {
+		v, err := f.vault(params.VaultName)
+		if err != nil {
+			return nil, err
+		}
+		if params.JobParameters == nil {
+			return nil, invalidParameter("Job parameters are required")
+		}
+		j := &job{id: f.newId("job"), vault: v.name, created: f.now(), status: types.StatusCodeInProgress}
+		switch aws.ToString(params.JobParameters.Type) {
+		case "inventory-retrieval":
+			j.action = types.ActionCodeInventoryRetrieval
+			if err := f.inventory(j, v, params.JobParameters); err != nil {
+				return nil, err
+			}
+		case "archive-retrieval":
+			j.action = types.ActionCodeArchiveRetrieval
+			id := aws.ToString(params.JobParameters.ArchiveId)
+			for _, archive := range v.archives {
+				if archive.Id == id {
+					archive := archive
+					j.archive, j.output = &archive, archive.Content
+				}
+			}
+			if j.archive == nil {
+				return nil, NotFound("Archive not found for ID: " + id)
+			}
+			j.tier = aws.ToString(params.JobParameters.Tier)
+			if j.tier == "" {
+				j.tier = "Standard"
+			}
+		default:
+			return nil, invalidParameter(fmt.Sprintf("Invalid job type: %s", aws.ToString(params.JobParameters.Type)))
+		}
+		f.jobs[j.id] = j
+		f.jobOrder = append(f.jobOrder, j.id)
+		return &glacier.InitiateJobOutput{JobId: aws.String(j.id)}, nil
+	})
+}
+
+// inventoryEntry is an archive as an inventory lists it.
+type inventoryEntry struct {
+	ArchiveId          string
+	ArchiveDescription string
+	CreationDate       string
+	Size               int64
+	SHA256TreeHash     string
+}
+
+// inventory writes an inventory job's output from the vault's last
+// inventory, narrowed as the job asks.
+func (f *Fake) inventory(j *job, v *vault, params *types.JobParameters) error {
+	format := aws.ToString(params.Format)
+	if format == "" {
+		format = "JSON"
+	}
+	if format != "JSON" && format != "CSV" {
+		return invalidParameter("Invalid format: " + format)
+	}
+	j.outputType = format
+	j.retrieval = &types.InventoryRetrievalJobDescription{Format: aws.String(format)}
+
+	var start, end time.Time
+	var marker string
+	var limit int
+	if retrieval := params.InventoryRetrievalParameters; retrieval != nil {
+		var err error
+		if start, err = parseDate(retrieval.StartDate); err != nil {
+			return invalidParameter("Invalid start date: " + aws.ToString(retrieval.StartDate))
+		}
+		if end, err = parseDate(retrieval.EndDate); err != nil {
+			return invalidParameter("Invalid end date: " + aws.ToString(retrieval.EndDate))
+		}
+		if retrieval.Limit != nil {
+			if limit, err = strconv.Atoi(*retrieval.Limit); err != nil || limit <= 0 {
+				return invalidParameter("Invalid limit: " + *retrieval.Limit)
+			}
+		}
+		marker = aws.ToString(retrieval.Marker)
+		j.retrieval.StartDate, j.retrieval.EndDate, j.retrieval.Limit = retrieval.StartDate, retrieval.EndDate, retrieval.Limit
+	}
+
+	var listed []Archive
+	for _, archive := range v.inventory {
+		if (start.IsZero() || !archive.CreationDate.Before(start)) && (end.IsZero() || archive.CreationDate.Before(end)) {
+			listed = append(listed, archive)
+		}
+	}
+	listed, next, err := page(listed, func(archive Archive) string { return archive.Id }, marker, limit)
+	if err != nil {
+		return err
+	}
+	if next != "" {
+		j.retrieval.Marker = aws.String(next)
+	}
+
+	entries := make([]inventoryEntry, len(listed))
+	for i, archive := range listed {
+		entries[i] = inventoryEntry{
+			ArchiveId:          archive.Id,
+			ArchiveDescription: archive.Description,
+			CreationDate:       *date(archive.CreationDate),
+			Size:               archive.Size,
+			SHA256TreeHash:     archive.TreeHash,
+		}
+	}
+	if format == "CSV" {
+		j.output = inventoryCSV(entries)
+		return nil
+	}
+	output, err := json.Marshal(struct {
+		VaultARN      string
+		InventoryDate string
+		ArchiveList   []inventoryEntry
+	}{f.ARN(v.name), *date(v.inventoried), entries})
+	if err != nil {
+		return err
+	}
+	j.output = output
+	return nil
+}
+
+func inventoryCSV(entries []inventoryEntry) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"ArchiveId", "ArchiveDescription", "CreationDate", "Size", "SHA256TreeHash"})
+	for _, entry := range entries {
+		w.Write([]string{entry.ArchiveId, entry.ArchiveDescription, entry.CreationDate, strconv.FormatInt(entry.Size, 10), entry.SHA256TreeHash})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+func parseDate(value *string) (time.Time, error) {
+	if aws.ToString(value) == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, *value)
+}
+
+// job returns the vault's job, or Glacier's error for a missing one.
+func (f *Fake) job(vaultName, id *string) (*job, error) {
+	if _, err := f.vault(vaultName); err != nil {
+		return nil, err
+	}
+	j := f.jobs[aws.ToString(id)]
+	if j == nil || j.vault != aws.ToString(vaultName) {
+		return nil, NotFound("The job ID was not found: " + aws.ToString(id))
+	}
+	return j, nil
+}
+
+func (f *Fake) describeJob(j *job) types.GlacierJobDescription {
+	description := types.GlacierJobDescription{
+		JobId:                        aws.String(j.id),
+		Action:                       j.action,
+		VaultARN:                     aws.String(f.ARN(j.vault)),
+		CreationDate:                 date(j.created),
+		StatusCode:                   j.status,
+		Completed:                    j.status != types.StatusCodeInProgress,
+		CompletionDate:               date(j.completed),
+		InventoryRetrievalParameters: j.retrieval,
+	}
+	if j.message != "" {
+		description.StatusMessage = aws.String(j.message)
+	}
+	if j.archive != nil {
+		description.ArchiveId = aws.String(j.archive.Id)
+		description.ArchiveSizeInBytes = aws.Int64(j.archive.Size)
+		description.ArchiveSHA256TreeHash = aws.String(j.archive.TreeHash)
+		description.Tier = aws.String(j.tier)
+	}
+	if j.status == types.StatusCodeSucceeded && j.action == types.ActionCodeInventoryRetrieval {
+		description.InventorySizeInBytes = aws.Int64(int64(len(j.output)))
+	}
+	return description
+}
+
+func (f *Fake) DescribeJob(ctx context.Context, params *glacier.DescribeJobInput, optFns ...func(*glacier.Options)) (*glacier.DescribeJobOutput, error) {
+	return call(f, OpDescribeJob, params, func(params *glacier.DescribeJobInput) (*glacier.DescribeJobOutput, error) {
+		j, err := f.job(params.VaultName, params.JobId)
+		if err != nil {
+			return nil, err
+		}
+		if j.status == types.StatusCodeInProgress {
+			if j.polls >= f.JobPolls {
+				j.status, j.completed = types.StatusCodeSucceeded, f.now()
+			}
+			j.polls++
+		}
+		d := f.describeJob(j)
+		return &glacier.DescribeJobOutput{
+			JobId:                        d.JobId,
+			Action:                       d.Action,
+			VaultARN:                     d.VaultARN,
+			CreationDate:                 d.CreationDate,
+			StatusCode:                   d.StatusCode,
+			StatusMessage:                d.StatusMessage,
+			Completed:                    d.Completed,
+			CompletionDate:               d.CompletionDate,
+			InventoryRetrievalParameters: d.InventoryRetrievalParameters,
+			InventorySizeInBytes:         d.InventorySizeInBytes,
+			ArchiveId:                    d.ArchiveId,
+			ArchiveSizeInBytes:           d.ArchiveSizeInBytes,
+			ArchiveSHA256TreeHash:        d.ArchiveSHA256TreeHash,
+			Tier:                         d.Tier,
+		}, nil
+	})
+}
+
+func (f *Fake) GetJobOutput(ctx context.Context, params *glacier.GetJobOutputInput, optFns ...func(*glacier.Options)) (*glacier.GetJobOutputOutput, error) {
+	return call(f, OpGetJobOutput, params, func(params *glacier.GetJobOutputInput) (*glacier.GetJobOutputOutput, error) {
+		j, err := f.job(params.VaultName, params.JobId)
+		if err != nil {
+			return nil, err
+		}
+		if j.status != types.StatusCodeSucceeded {
+			return nil, invalidParameter("The job is not currently available for download: " + j.id)
+		}
+
+		body, size := j.output, int64(len(j.output))
+		output := &glacier.GetJobOutputOutput{Status: 200, AcceptRanges: aws.String("bytes")}
+		if j.outputType == "CSV" {
+			output.ContentType = aws.String("text/csv")
+		} else if j.outputType == "JSON" {
+			output.ContentType = aws.String("application/json")
+		} else {
+			output.ContentType = aws.String("application/octet-stream")
+		}
+		if r := aws.ToString(params.Range); r != "" {
+			var start, end int64
+			if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil || start < 0 || start > end || end >= size {
+				return nil, invalidParameter("Invalid range: " + r)
+			}
+			body = body[start : end+1]
+			output.Status = 206
+			output.ContentRange = aws.String(fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+			// Glacier only sends a range's checksum when it's aligned to
+			// the tree hash's chunks.
+			if start%treeHashChunk == 0 && ((end+1)%treeHashChunk == 0 || end+1 == size) {
+				output.Checksum = aws.String(TreeHash(body))
+			}
+		} else if j.archive != nil {
+			output.Checksum = aws.String(j.archive.TreeHash)
+		}
+		if j.archive != nil {
+			output.ArchiveDescription = aws.String(j.archive.Description)
+		}
+		output.Body = io.NopCloser(bytes.NewReader(body))
+		return output, nil
+	})
+}
+
+func (f *Fake) ListJobs(ctx context.Context, params *glacier.ListJobsInput, optFns ...func(*glacier.Options)) (*glacier.ListJobsOutput, error) {
+	return call(f, OpListJobs, params, func(params *glacier.ListJobsInput) (*glacier.ListJobsOutput, error) {
+		v, err := f.vault(params.VaultName)
+		if err != nil {
+			return nil, err
+		}
+		var matching []*job
+		for _, id := range f.jobOrder {
+			j := f.jobs[id]
+			if j == nil || j.vault != v.name {
+				continue
+			}
+			if status := aws.ToString(params.Statuscode); status != "" && status != string(j.status) {
+				continue
+			}
+			if completed := aws.ToString(params.Completed); completed != "" && completed != strconv.FormatBool(j.status != types.StatusCodeInProgress) {
+				continue
+			}
+			matching = append(matching, j)
+		}
+
+		limit := int(aws.ToInt32(params.Limit))
+		if limit <= 0 {
+			limit = f.pageSize(50)
+		}
+		listed, next, err := page(matching, func(j *job) string { return j.id }, aws.ToString(params.Marker), limit)
+		if err != nil {
+			return nil, err
+		}
+		output := &glacier.ListJobsOutput{}
+		for _, j := range listed {
+			output.JobList = append(output.JobList, f.describeJob(j))
+		}
+		if next != "" {
+			output.Marker = aws.String(next)
+		}
+		return output, nil
+	})
+}
+
+func (f *Fake) DeleteArchive(ctx context.Context, params *glacier.DeleteArchiveInput, optFns ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error) {
+	return call(f, OpDeleteArchive, params, func(params *glacier.DeleteArchiveInput) (*glacier.DeleteArchiveOutput, error) {
+		v, err := f.vault(params.VaultName)
+		if err != nil {
+			return nil, err
+		}
+		id := aws.ToString(params.ArchiveId)
+		for i, archive := range v.archives {
+			if archive.Id == id {
+				v.archives = append(v.archives[:i:i], v.archives[i+1:]...)
+				return &glacier.DeleteArchiveOutput{}, nil
+			}
+		}
+		return nil, NotFound("Archive not found for ID: " + id)
+	})
+}
+
+func (f *Fake) DeleteVault(ctx context.Context, params *glacier.DeleteVaultInput, optFns ...func(*glacier.Options)) (*glacier.DeleteVaultOutput, error) {
+	return call(f, OpDeleteVault, params, func(params *glacier.DeleteVaultInput) (*glacier.DeleteVaultOutput, error) {
+		v, err := f.vault(params.VaultName)
+		if err != nil {
+			return nil, err
+		}
+		if len(v.inventory) > 0 || len(v.archives) > 0 {
+			if f.InventoryOnRefusal {
+				v.inventory = append([]Archive(nil), v.archives...)
+				v.inventoried = f.now()
+			}
+			return nil, invalidParameter("Vault not empty or recently written to: " + f.ARN(v.name))
+		}
+		delete(f.vaults, v.name)
+		return &glacier.DeleteVaultOutput{}, nil
+	})
+}
@@ -0,0 +1,243 @@
+package glaciertest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+)
+
+// NewServer starts a server speaking as much of Glacier's REST API as
+// glacierpurge uses, answered by fake, so the SDK's own client can be pointed
+// at it with WithEndpointURL. Requests aren't authenticated. Calls reach the
+// fake just as a client's would, scripted and recorded alike. The caller
+// closes the server.
+func NewServer(fake *Fake) *httptest.Server {
+	return httptest.NewServer(&handler{fake})
+}
+
+type handler struct {
+	fake *Fake
+}
+
+// ServeHTTP routes a request by its path: /{account}/vaults, then the vault,
+// then its tags, jobs, a job, its output, or an archive.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var path []string
+	for _, segment := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			writeError(w, invalidParameter("Invalid path: "+r.URL.Path))
+			return
+		}
+		path = append(path, unescaped)
+	}
+	if len(path) < 2 || path[1] != "vaults" {
+		writeError(w, Error(http.StatusNotFound, "UnknownOperationException", "Unknown operation: "+r.Method+" "+r.URL.Path))
+		return
+	}
+
+	ctx, query := r.Context(), r.URL.Query()
+	var vault *string
+	if len(path) > 2 {
+		vault = aws.String(path[2])
+	}
+	route := r.Method + " " + strings.Join(append([]string{""}, shape(path[2:])...), "/")
+	switch route {
+	case "GET ":
+		output, err := h.fake.ListVaults(ctx, &glacier.ListVaultsInput{AccountId: aws.String(path[0]), Limit: limit(query), Marker: optional(query.Get("marker"))})
+		writeJSON(w, http.StatusOK, err, func() any {
+			return struct {
+				Marker    *string
+				VaultList []types.DescribeVaultOutput
+			}{output.Marker, output.VaultList}
+		})
+	case "GET /vault":
+		output, err := h.fake.DescribeVault(ctx, &glacier.DescribeVaultInput{AccountId: aws.String(path[0]), VaultName: vault})
+		writeJSON(w, http.StatusOK, err, func() any {
+			return types.DescribeVaultOutput{VaultName: output.VaultName, VaultARN: output.VaultARN, CreationDate: output.CreationDate, LastInventoryDate: output.LastInventoryDate, NumberOfArchives: output.NumberOfArchives, SizeInBytes: output.SizeInBytes}
+		})
+	case "DELETE /vault":
+		_, err := h.fake.DeleteVault(ctx, &glacier.DeleteVaultInput{AccountId: aws.String(path[0]), VaultName: vault})
+		writeJSON(w, http.StatusNoContent, err, nil)
+	case "GET /vault/tags":
+		output, err := h.fake.ListTagsForVault(ctx, &glacier.ListTagsForVaultInput{AccountId: aws.String(path[0]), VaultName: vault})
+		writeJSON(w, http.StatusOK, err, func() any { return struct{ Tags map[string]string }{output.Tags} })
+	case "POST /vault/tags":
+		var body struct {
+			Tags    map[string]string
+			TagKeys []string
+		}
+		if !readJSON(w, r, &body) {
+			return
+		}
+		var err error
+		switch query.Get("operation") {
+		case "add":
+			_, err = h.fake.AddTagsToVault(ctx, &glacier.AddTagsToVaultInput{AccountId: aws.String(path[0]), VaultName: vault, Tags: body.Tags})
+		case "remove":
+			_, err = h.fake.RemoveTagsFromVault(ctx, &glacier.RemoveTagsFromVaultInput{AccountId: aws.String(path[0]), VaultName: vault, TagKeys: body.TagKeys})
+		default:
+			err = invalidParameter("Invalid operation: " + query.Get("operation"))
+		}
+		writeJSON(w, http.StatusNoContent, err, nil)
+	case "POST /vault/jobs":
+		var parameters types.JobParameters
+		if !readJSON(w, r, &parameters) {
+			return
+		}
+		output, err := h.fake.InitiateJob(ctx, &glacier.InitiateJobInput{AccountId: aws.String(path[0]), VaultName: vault, JobParameters: &parameters})
+		if err == nil {
+			w.Header().Set("x-amz-job-id", aws.ToString(output.JobId))
+			w.Header().Set("Location", "/"+path[0]+"/vaults/"+url.PathEscape(path[2])+"/jobs/"+aws.ToString(output.JobId))
+		}
+		writeJSON(w, http.StatusAccepted, err, nil)
+	case "GET /vault/jobs":
+		output, err := h.fake.ListJobs(ctx, &glacier.ListJobsInput{
+			AccountId:  aws.String(path[0]),
+			VaultName:  vault,
+			Completed:  optional(query.Get("completed")),
+			Statuscode: optional(query.Get("statuscode")),
+			Limit:      limit(query),
+			Marker:     optional(query.Get("marker")),
+		})
+		writeJSON(w, http.StatusOK, err, func() any {
+			return struct {
+				JobList []types.GlacierJobDescription
+				Marker  *string
+			}{output.JobList, output.Marker}
+		})
+	case "GET /vault/jobs/job":
+		output, err := h.fake.DescribeJob(ctx, &glacier.DescribeJobInput{AccountId: aws.String(path[0]), VaultName: vault, JobId: aws.String(path[4])})
+		writeJSON(w, http.StatusOK, err, func() any {
+			return types.GlacierJobDescription{
+				JobId:                        output.JobId,
+				Action:                       output.Action,
+				VaultARN:                     output.VaultARN,
+				CreationDate:                 output.CreationDate,
+				StatusCode:                   output.StatusCode,
+				StatusMessage:                output.StatusMessage,
+				Completed:                    output.Completed,
+				CompletionDate:               output.CompletionDate,
+				InventoryRetrievalParameters: output.InventoryRetrievalParameters,
+				InventorySizeInBytes:         output.InventorySizeInBytes,
+				ArchiveId:                    output.ArchiveId,
+				ArchiveSizeInBytes:           output.ArchiveSizeInBytes,
+				ArchiveSHA256TreeHash:        output.ArchiveSHA256TreeHash,
+				Tier:                         output.Tier,
+			}
+		})
+	case "GET /vault/jobs/job/output":
+		output, err := h.fake.GetJobOutput(ctx, &glacier.GetJobOutputInput{AccountId: aws.String(path[0]), VaultName: vault, JobId: aws.String(path[4]), Range: optional(r.Header.Get("Range"))})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer output.Body.Close()
+		for name, value := range map[string]*string{
+			"Accept-Ranges":             output.AcceptRanges,
+			"Content-Range":             output.ContentRange,
+			"Content-Type":              output.ContentType,
+			"x-amz-sha256-tree-hash":    output.Checksum,
+			"x-amz-archive-description": output.ArchiveDescription,
+		} {
+			if value != nil {
+				w.Header().Set(name, *value)
+			}
+		}
+		w.WriteHeader(int(output.Status))
+		io.Copy(w, output.Body)
+	case "DELETE /vault/archives/archive":
+		_, err := h.fake.DeleteArchive(ctx, &glacier.DeleteArchiveInput{AccountId: aws.String(path[0]), VaultName: vault, ArchiveId: aws.String(path[4])})
+		writeJSON(w, http.StatusNoContent, err, nil)
+	default:
+		writeError(w, Error(http.StatusNotFound, "UnknownOperationException", "Unknown operation: "+r.Method+" "+r.URL.Path))
+	}
+}
+
+// shape names the path's segments after the vault by what they are, keeping
+// the fixed ones, so "v/jobs/123" routes as "vault/jobs/job".
+func shape(path []string) []string {
+	shaped := make([]string, len(path))
+	for i, segment := range path {
+		switch {
+		case i == 0:
+			shaped[i] = "vault"
+		case i == 2 && path[1] == "jobs":
+			shaped[i] = "job"
+		case i == 2 && path[1] == "archives":
+			shaped[i] = "archive"
+		default:
+			shaped[i] = segment
+		}
+	}
+	return shaped
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
+
+func limit(query url.Values) *int32 {
+	n, err := strconv.ParseInt(query.Get("limit"), 10, 32)
+	if err != nil {
+		return nil
+	}
+	return aws.Int32(int32(n))
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, invalidParameter("Invalid request body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+// writeJSON writes the error, if there is one, or else the status and the
+// body, if there is one, as JSON.
+func writeJSON(w http.ResponseWriter, status int, err error, body func() any) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body())
+}
+
+// writeError writes err as Glacier would: an *APIError as itself, and
+// anything else as a 500.
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = Error(http.StatusInternalServerError, "ServiceUnavailableException", err.Error())
+		if errors.Is(err, context.Canceled) {
+			apiErr = Error(http.StatusRequestTimeout, "RequestTimeoutException", err.Error())
+		}
+	}
+	fault := "Client"
+	if apiErr.Status >= 500 {
+		fault = "Server"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-amzn-RequestId", "fake-request")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(map[string]string{"code": apiErr.Code, "message": apiErr.Message, "type": fault})
+}
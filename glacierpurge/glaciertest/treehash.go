@@ -0,0 +1,32 @@
+package glaciertest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const treeHashChunk = 1 << 20
+
+// TreeHash returns the hex-encoded SHA-256 tree hash Glacier checksums data
+// with: the hashes of each 1 MiB chunk combined pairwise until one remains.
+func TreeHash(data []byte) string {
+	var hashes [][]byte
+	for start := 0; start < len(data) || len(hashes) == 0; start += treeHashChunk {
+		end := min(start+treeHashChunk, len(data))
+		sum := sha256.Sum256(data[start:end])
+		hashes = append(hashes, sum[:])
+	}
+	for len(hashes) > 1 {
+		var next [][]byte
+		for i := 0; i < len(hashes); i += 2 {
+			if i+1 == len(hashes) {
+				next = append(next, hashes[i])
+				continue
+			}
+			sum := sha256.Sum256(append(append([]byte(nil), hashes[i]...), hashes[i+1]...))
+			next = append(next, sum[:])
+		}
+		hashes = next
+	}
+	return hex.EncodeToString(hashes[0])
+}
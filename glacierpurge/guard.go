@@ -0,0 +1,165 @@
+package glacierpurge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Guard refuses every Glacier call that could change anything, in the SDK's
+// own middleware beneath the rest of the client, so nothing the tool does
+// above it can get a delete through. Only the calls known to be reads pass.
+// It counts and logs each call it refuses, and is safe for concurrent use.
+type Guard struct {
+	allowJobs bool
+	logger    Logger
+
+	mu      sync.Mutex
+	refused map[string]int // by operation
+}
+
+// GuardError is returned for a call a Guard refused. It wraps ErrReadOnly.
+type GuardError struct {
+	Op string
+}
+
+func (e *GuardError) Error() string {
+	return fmt.Sprintf("refusing to call %s: the client is read-only", e.Op)
+}
+
+func (e *GuardError) Unwrap() error {
+	return ErrReadOnly
+}
+
+// NewGuard returns a guard logging to logger. With allowJobs, InitiateJob is
+// let through too: a job retrieves, but changes nothing in the vault.
+func NewGuard(allowJobs bool, logger Logger) *Guard {
+	if logger == nil {
+		logger = discardLogger{}
+	}
+	return &Guard{allowJobs: allowJobs, logger: logger, refused: map[string]int{}}
+}
+
+// WithGuard has guard refuse the client's calls that could change anything.
+// It's meant to be shared by every client.
+func WithGuard(guard *Guard) Option {
+	return func(o *options) {
+		o.guard = guard
+	}
+}
+
+// allows reports whether the call taking params only reads.
+func (g *Guard) allows(params any) bool {
+	switch params.(type) {
+	case *glacier.ListVaultsInput, *glacier.DescribeVaultInput, *glacier.ListTagsForVaultInput,
+		*glacier.ListJobsInput, *glacier.DescribeJobInput, *glacier.GetJobOutputInput,
+		*glacier.ListMultipartUploadsInput, *glacier.ListPartsInput, *glacier.ListProvisionedCapacityInput,
+		*glacier.GetVaultAccessPolicyInput, *glacier.GetVaultLockInput, *glacier.GetVaultNotificationsInput,
+		*glacier.GetDataRetrievalPolicyInput:
+		return true
+	case *glacier.InitiateJobInput:
+		return g.allowJobs
+	}
+	return false
+}
+
+// check returns a GuardError for a call that isn't allowed, counting and
+// logging it.
+func (g *Guard) check(region string, params any) error {
+	if g.allows(params) {
+		return nil
+	}
+	op := operationName(params)
+	g.mu.Lock()
+	g.refused[op]++
+	g.mu.Unlock()
+	g.logger.Printf("Read-only: refused %s in region %s", op, region)
+	return &GuardError{Op: op}
+}
+
+// Refused returns how many calls were refused, by operation.
+func (g *Guard) Refused() map[string]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	refused := make(map[string]int, len(g.refused))
+	for op, n := range g.refused {
+		refused[op] = n
+	}
+	return refused
+}
+
+// Summary describes the refused calls, such as "DeleteArchive 12,
+// DeleteVault 1", or returns "" if there were none.
+func (g *Guard) Summary() string {
+	refused := g.Refused()
+	ops := make([]string, 0, len(refused))
+	for op := range refused {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for i, op := range ops {
+		ops[i] = fmt.Sprintf("%s %d", op, refused[op])
+	}
+	return strings.Join(ops, ", ")
+}
+
+// middleware returns the SDK middleware refusing the calls for region.
+func (g *Guard) middleware(region string) func(*middleware.Stack) error {
+	guard := middleware.InitializeMiddlewareFunc("IceBreakerReadOnly", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		if err := g.check(region, in.Parameters); err != nil {
+			return middleware.InitializeOutput{}, middleware.Metadata{}, err
+		}
+		return next.HandleInitialize(ctx, in)
+	})
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(guard, middleware.Before)
+	}
+}
+
+// guardedAPI puts a Guard in front of a client that isn't the SDK's, whose
+// middleware it can't be added to.
+type guardedAPI struct {
+	API
+	guard  *Guard
+	region string
+}
+
+func (a guardedAPI) AddTagsToVault(ctx context.Context, params *glacier.AddTagsToVaultInput, optFns ...func(*glacier.Options)) (*glacier.AddTagsToVaultOutput, error) {
+	if err := a.guard.check(a.region, params); err != nil {
+		return nil, err
+	}
+	return a.API.AddTagsToVault(ctx, params, optFns...)
+}
+
+func (a guardedAPI) RemoveTagsFromVault(ctx context.Context, params *glacier.RemoveTagsFromVaultInput, optFns ...func(*glacier.Options)) (*glacier.RemoveTagsFromVaultOutput, error) {
+	if err := a.guard.check(a.region, params); err != nil {
+		return nil, err
+	}
+	return a.API.RemoveTagsFromVault(ctx, params, optFns...)
+}
+
+func (a guardedAPI) InitiateJob(ctx context.Context, params *glacier.InitiateJobInput, optFns ...func(*glacier.Options)) (*glacier.InitiateJobOutput, error) {
+	if err := a.guard.check(a.region, params); err != nil {
+		return nil, err
+	}
+	return a.API.InitiateJob(ctx, params, optFns...)
+}
+
+func (a guardedAPI) DeleteArchive(ctx context.Context, params *glacier.DeleteArchiveInput, optFns ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error) {
+	if err := a.guard.check(a.region, params); err != nil {
+		return nil, err
+	}
+	return a.API.DeleteArchive(ctx, params, optFns...)
+}
+
+func (a guardedAPI) DeleteVault(ctx context.Context, params *glacier.DeleteVaultInput, optFns ...func(*glacier.Options)) (*glacier.DeleteVaultOutput, error) {
+	if err := a.guard.check(a.region, params); err != nil {
+		return nil, err
+	}
+	return a.API.DeleteVault(ctx, params, optFns...)
+}
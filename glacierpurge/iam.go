@@ -0,0 +1,152 @@
+package glacierpurge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// ErrCannotSimulate is returned by SimulateActions for a principal IAM can't
+// simulate the policies of, such as the root user or a federated one, or
+// when requests go to --endpoint-url.
+var ErrCannotSimulate = errors.New("IAM can't simulate this principal's policies")
+
+// ActionDecision is IAM's verdict on whether a principal may call an action.
+type ActionDecision struct {
+	Action   string // such as glacier:DeleteArchive
+	Decision string // allowed, explicitDeny or implicitDeny
+}
+
+// Allowed reports whether the action is allowed.
+func (d ActionDecision) Allowed() bool {
+	return d.Decision == "allowed"
+}
+
+// SimulateActions asks IAM whether the credentials' principal may call each
+// of the actions on resource, without calling any of them, using IAM's
+// policy simulator. The credentials need iam:SimulatePrincipalPolicy for it.
+func SimulateActions(ctx context.Context, region string, settings *ClientSettings, actions []string, resource string) ([]ActionDecision, error) {
+	if settings.EndpointURL != "" {
+		return nil, fmt.Errorf("%w: requests go to --endpoint-url", ErrCannotSimulate)
+	}
+	caller, err := callerIdentity(ctx, region, settings)
+	if err != nil {
+		return nil, err
+	}
+	principal, err := policySource(caller)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"Action":          {"SimulatePrincipalPolicy"},
+		"Version":         {"2010-05-08"},
+		"PolicySourceArn": {principal},
+	}
+	for i, action := range actions {
+		form.Set("ActionNames.member."+strconv.Itoa(i+1), action)
+	}
+	if resource != "" {
+		form.Set("ResourceArns.member.1", resource)
+	}
+
+	var response struct {
+		Results []struct {
+			Action   string `xml:"EvalActionName"`
+			Decision string `xml:"EvalDecision"`
+		} `xml:"SimulatePrincipalPolicyResult>EvaluationResults>member"`
+	}
+	if err := callIAM(ctx, region, settings, caller.Partition, form, &response); err != nil {
+		return nil, err
+	}
+	decisions := make([]ActionDecision, len(response.Results))
+	for i, result := range response.Results {
+		decisions[i] = ActionDecision{Action: result.Action, Decision: result.Decision}
+	}
+	return decisions, nil
+}
+
+// policySource returns the ARN of the IAM user or role whose policies apply
+// to the caller. A role's path isn't in the ARN of a session assuming it, so
+// only roles without one are found.
+func policySource(caller arn.ARN) (string, error) {
+	switch {
+	case strings.HasPrefix(caller.Resource, "user/"):
+		return caller.String(), nil
+	case caller.Service == "sts" && strings.HasPrefix(caller.Resource, "assumed-role/"):
+		role, _, _ := strings.Cut(strings.TrimPrefix(caller.Resource, "assumed-role/"), "/")
+		return arn.ARN{Partition: caller.Partition, Service: "iam", AccountID: caller.AccountID, Resource: "role/" + role}.String(), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrCannotSimulate, caller)
+}
+
+// iamEndpoints are IAM's endpoint and signing region in each partition.
+var iamEndpoints = map[string][2]string{
+	"aws":        {"https://iam.amazonaws.com/", "us-east-1"},
+	"aws-us-gov": {"https://iam.us-gov.amazonaws.com/", "us-gov-west-1"},
+	"aws-cn":     {"https://iam.cn-north-1.amazonaws.com.cn/", "cn-north-1"},
+}
+
+// iamError is the body of IAM's error responses.
+type iamError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// callIAM makes a call of IAM's query API, which the SDK has no client for
+// here, and decodes its XML response into v.
+func callIAM(ctx context.Context, region string, settings *ClientSettings, partition string, form url.Values, v any) error {
+	endpoint, ok := iamEndpoints[partition]
+	if !ok {
+		return fmt.Errorf("%w: no IAM endpoint known for partition %s", ErrCannotSimulate, partition)
+	}
+	cfg, err := LoadConfig(ctx, region, settings)
+	if err != nil {
+		return err
+	}
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	body := form.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint[0], strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	sum := sha256.Sum256([]byte(body))
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(sum[:]), "iam", endpoint[1], time.Now()); err != nil {
+		return fmt.Errorf("failed to sign IAM request: %w", err)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call IAM %s: %w", form.Get("Action"), err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read IAM %s response: %w", form.Get("Action"), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e iamError
+		if xml.Unmarshal(data, &e) != nil || e.Code == "" {
+			return fmt.Errorf("IAM %s failed: %s", form.Get("Action"), resp.Status)
+		}
+		return fmt.Errorf("IAM %s failed: %s: %s", form.Get("Action"), e.Code, e.Message)
+	}
+	return xml.Unmarshal(data, v)
+}
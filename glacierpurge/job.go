@@ -0,0 +1,730 @@
+package glacierpurge
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+)
+
+const pollingInterval = 1 * time.Minute
+
+type InventoryJobOutput struct {
+	ArchiveList []inventoryEntry `json:"ArchiveList"`
+}
+
+type inventoryEntry struct {
+	ArchiveId          string `json:"ArchiveId"`
+	ArchiveDescription string `json:"ArchiveDescription"`
+	CreationDate       string `json:"CreationDate"`
+	Size               int64  `json:"Size"`
+	SHA256TreeHash     string `json:"SHA256TreeHash"`
+}
+
+type InventoryJob struct {
+	Vault   *Vault
+	Id      string
+	Options InventoryOptions // how the job was initiated, when known
+}
+
+// Describe returns Glacier's current view of the job.
+func (j *InventoryJob) Describe(ctx context.Context) (*glacier.DescribeJobOutput, error) {
+	description, err := j.Vault.Glacier.Client.DescribeJob(ctx, &glacier.DescribeJobInput{
+		JobId:     aws.String(j.Id),
+		VaultName: aws.String(j.Vault.Name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe job: %w", err)
+	}
+
+	return description, nil
+}
+
+// Completed reports whether Glacier has finished the job.
+func (j *InventoryJob) Completed(ctx context.Context) (bool, error) {
+	description, err := j.Describe(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return description.Completed, nil
+}
+
+// Purge waits for the job to complete, then deletes every archive in the
+// inventory it produced. It behaves like Vault.Purge for a job that has
+// already been initiated, such as one recorded by an earlier run.
+// A paginated inventory is followed page by page, deleting each page's
+// archives before moving on to the next.
+func (j *InventoryJob) Purge(ctx context.Context) (*PurgeResult, error) {
+	result := &PurgeResult{JobId: j.Id}
+	seen := NewArchiveSet()
+	for job := j; job != nil; {
+		if err := job.WaitLogged(ctx); err != nil {
+			return result, err
+		}
+
+		page, err := job.DeleteAll(ctx, DeleteOptions{Seen: seen})
+		result.Add(page)
+		if err != nil {
+			return result, err
+		}
+
+		if job, err = job.Next(ctx); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// More reports whether the completed job's inventory is one page of several,
+// leaving the rest for Next to initiate a job for.
+func (j *InventoryJob) More(ctx context.Context) (bool, error) {
+	description, err := j.Describe(ctx)
+	if err != nil {
+		return false, err
+	}
+	return hasMore(description), nil
+}
+
+// hasMore reports whether a job's description has the marker of the next
+// page.
+func hasMore(description *glacier.DescribeJobOutput) bool {
+	params := description.InventoryRetrievalParameters
+	return params != nil && aws.ToString(params.Marker) != ""
+}
+
+// Next initiates the job for the next page of a paginated inventory, with the
+// same limit and date window, and returns it. It returns nil once the job, which must have
+// completed, covered the rest of the vault.
+func (j *InventoryJob) Next(ctx context.Context) (*InventoryJob, error) {
+	description, err := j.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasMore(description) {
+		return nil, nil
+	}
+	params := description.InventoryRetrievalParameters
+
+	opts := j.Options
+	opts.Marker = aws.ToString(params.Marker)
+	if limit, err := strconv.Atoi(aws.ToString(params.Limit)); err == nil {
+		opts.Limit = limit
+	}
+	// A job found by listing rather than initiated here only has its window
+	// in Glacier's description.
+	if date, err := parseDate(params.StartDate); err == nil && opts.StartDate.IsZero() {
+		opts.StartDate = date
+	}
+	if date, err := parseDate(params.EndDate); err == nil && opts.EndDate.IsZero() {
+		opts.EndDate = date
+	}
+
+	next, err := j.Vault.InitiateInventoryJob(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	j.Vault.Glacier.Logger.Printf("Inventory retrieval job %s initiated for the next page of vault %s", next.Id, j.Vault.Name)
+	return next, nil
+}
+
+// WaitForResults waits for the job to complete, logging while it does, and
+// returns the archives in the inventory it produced.
+func (j *InventoryJob) WaitForResults(ctx context.Context) ([]*Archive, error) {
+	if err := j.WaitLogged(ctx); err != nil {
+		return nil, err
+	}
+
+	archives, err := j.GetResults(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory job results: %w", err)
+	}
+	return archives, nil
+}
+
+// WaitLogged waits for the job to complete, logging while it does.
+func (j *InventoryJob) WaitLogged(ctx context.Context) error {
+	log := j.Vault.Glacier.Logger
+
+	_, err := j.Wait(ctx, WaitOptions{
+		Progress: func(elapsed time.Duration, description *glacier.DescribeJobOutput) {
+			if !description.Completed {
+				log.Printf("Waiting for inventory retrieval job to complete")
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Inventory retrieval job completed")
+	return nil
+}
+
+// DeleteOptions controls how DeleteAll and DeleteArchives delete archives.
+type DeleteOptions struct {
+	Workers int // deletions in flight at once; defaults to 4, or 32 when Adaptive
+	// Adaptive starts with a couple of deletions in flight and lets more
+	// through while none are throttled, halving them when many are, up to
+	// Workers.
+	Adaptive bool
+	// Buffer is how many archives parsing may get ahead of the deletions,
+	// which bounds memory however big the inventory; defaults to 1000.
+	Buffer int
+	// Filter, if set, leaves alone the archives it returns false for.
+	Filter func(*Archive) bool
+	// BreakAfter is how many deletions in a row may fail for a reason that
+	// won't go away by itself, such as access being denied, before the rest
+	// of the vault's archives are given up on; defaults to 25.
+	BreakAfter int
+	// Deleted, if set, is called with each archive once it's been deleted,
+	// from the goroutines deleting them.
+	Deleted func(*Archive)
+	// Failed, if set, is called with each archive that failed to delete and
+	// why, from the goroutines deleting them. Nothing else of a failure is
+	// kept but its count by class, so a vault whose every deletion fails
+	// takes no more memory than one whose every deletion succeeds.
+	Failed func(*Archive, error)
+	// Absent, if set, is called with each archive that was already gone,
+	// from the goroutines deleting them.
+	Absent func(*Archive)
+	// Seen holds the archives already handed to the deletions, so one listed
+	// twice is only deleted once. Sharing it between the pages of an
+	// inventory keeps that true across them; a fresh set is used if nil.
+	Seen *ArchiveSet
+	// Skip is how many archives at the head of the inventory, in the order
+	// it lists them, an earlier run's checkpoint found dealt with. They're
+	// passed over rather than deleted again.
+	Skip int64
+	// Done, if set, is called with how many archives at the head of the
+	// inventory have all been dealt with, whenever that grows: deleted,
+	// found already gone, or left alone. It's what Skip takes to carry on
+	// from there. It's called from the goroutines deleting the archives.
+	Done func(n int64)
+}
+
+// ErrJobExpired is returned when Glacier no longer has a job, or its output,
+// which it keeps for only about a day after the job completes.
+var ErrJobExpired = errors.New("the job has expired")
+
+// jobExpired wraps err with ErrJobExpired if it's Glacier not finding a job
+// of a vault that's still there.
+func jobExpired(ctx context.Context, vault *Vault, err error) error {
+	if !isNotFound(err) {
+		return err
+	}
+	if _, vaultErr := vault.Refresh(ctx); vaultErr != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrJobExpired, err)
+}
+
+// ErrBreakerTripped is returned when the deletions from a vault were stopped
+// by DeleteOptions.BreakAfter.
+var ErrBreakerTripped = errors.New("stopped deleting after repeated failures")
+
+// isPermanent reports whether a deletion failed for a reason retrying the
+// next archive won't fix.
+func isPermanent(err error) bool {
+	return Classify(err).Permanent()
+}
+
+// progressEvery is how many deletions go by between progress messages.
+const progressEvery = 1000
+
+// DeleteAll deletes every archive in the completed job's inventory, deleting
+// each one as soon as it's parsed rather than reading the whole inventory
+// first. An error is returned if the inventory can't be read, any archive
+// fails to delete, or ctx ends first.
+func (j *InventoryJob) DeleteAll(ctx context.Context, opts DeleteOptions) (*PurgeResult, error) {
+	output, err := j.Vault.Glacier.Client.GetJobOutput(ctx, &glacier.GetJobOutputInput{
+		JobId:     aws.String(j.Id),
+		VaultName: aws.String(j.Vault.Name),
+	})
+	if err != nil {
+		return &PurgeResult{JobId: j.Id}, fmt.Errorf("failed to get inventory job results: %w", jobExpired(ctx, j.Vault, err))
+	}
+	defer output.Body.Close()
+	return j.DeleteFrom(ctx, output.Body, opts)
+}
+
+// DeleteFrom deletes every archive in the job's inventory as DeleteAll does,
+// but reads the inventory from r, such as a copy Download saved, rather than
+// from Glacier.
+func (j *InventoryJob) DeleteFrom(ctx context.Context, r io.Reader, opts DeleteOptions) (*PurgeResult, error) {
+	// The vault's archive count is only a guess at the total: paginated or
+	// windowed jobs list fewer, and it lags behind uploads and deletions.
+	var estimate int64
+	if j.Options.Limit == 0 && j.Options.StartDate.IsZero() && j.Options.EndDate.IsZero() {
+		if description, err := j.Vault.Describe(ctx); err == nil {
+			estimate = description.NumberOfArchives
+		}
+	}
+
+	return j.deleteArchives(ctx, func(emit func(*Archive) error) error {
+		return StreamInventory(r, j.Vault, emit)
+	}, estimate, opts)
+}
+
+// DeleteArchives deletes the given archives, typically the job's results,
+// from the vault. An error is returned if any of them fails to delete or ctx
+// ends first. The options' Filter, Buffer, Skip and Done don't apply.
+func (j *InventoryJob) DeleteArchives(ctx context.Context, archives []*Archive, opts DeleteOptions) (*PurgeResult, error) {
+	return j.deleteArchives(ctx, func(emit func(*Archive) error) error {
+		for _, archive := range archives {
+			if err := emit(archive); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, int64(len(archives)), DeleteOptions{Workers: opts.Workers, Adaptive: opts.Adaptive, BreakAfter: opts.BreakAfter, Deleted: opts.Deleted, Failed: opts.Failed, Absent: opts.Absent, Seen: opts.Seen})
+}
+
+// listedArchive is an archive and its position in the inventory listing it.
+// again marks one whose ID's fingerprint was seen earlier in the inventory.
+type listedArchive struct {
+	archive *Archive
+	pos     int64
+	again   bool
+}
+
+// deleteArchives feeds the archives produce emits through a bounded channel
+// to a pool of workers deleting them.
+func (j *InventoryJob) deleteArchives(ctx context.Context, produce func(emit func(*Archive) error) error, estimate int64, opts DeleteOptions) (*PurgeResult, error) {
+	log := j.Vault.Glacier.Logger
+	workers, buffer, breakAfter := opts.Workers, opts.Buffer, opts.BreakAfter
+	if workers <= 0 {
+		workers = 4
+		if opts.Adaptive {
+			workers = 32
+		}
+	}
+	var adaptive *adaptiveLimit
+	if opts.Adaptive {
+		adaptive = newAdaptiveLimit(2, workers)
+	}
+	if buffer <= 0 {
+		buffer = 1000
+	}
+	if breakAfter <= 0 {
+		breakAfter = 25
+	}
+	seen := opts.Seen
+	if seen == nil {
+		seen = NewArchiveSet()
+	}
+
+	var (
+		listed, deleted, failed, absent, unattempted atomic.Int64
+		deletedBytes, duplicates                     atomic.Int64
+		parsed                                       atomic.Bool // the whole inventory has been read
+		wg                                           sync.WaitGroup
+		archives                                     = make(chan listedArchive, buffer)
+
+		breaker     sync.Mutex
+		consecutive int
+		tripped     error       // the failure that tripped the breaker
+		failedBy    ClassCounts = ClassCounts{}
+	)
+	// dealt notes the archive at pos dealt with, for opts.Done.
+	dealt := func(int64) {}
+	if opts.Done != nil {
+		head := newHead(opts.Skip)
+		dealt = func(pos int64) {
+			if n, grew := head.add(pos); grew {
+				opts.Done(n)
+			}
+		}
+	}
+	isTripped := func() bool {
+		breaker.Lock()
+		defer breaker.Unlock()
+		return tripped != nil
+	}
+	// record notes a deletion's outcome for the breaker and the counts.
+	record := func(err error) {
+		class := Classify(err)
+		breaker.Lock()
+		defer breaker.Unlock()
+		if err != nil {
+			failedBy[class]++
+		}
+		if err == nil || !class.Permanent() {
+			consecutive = 0
+			return
+		}
+		consecutive++
+		if consecutive >= breakAfter && tripped == nil {
+			tripped = err
+			log.Printf("Giving up on vault %s after %d deletions in a row failed: %v", j.Vault, consecutive, err)
+		}
+	}
+	wrapUp := j.Vault.Glacier.WrapUp
+	total := func() string {
+		if n := listed.Load(); parsed.Load() || n >= estimate {
+			if parsed.Load() {
+				return strconv.FormatInt(n, 10)
+			}
+			return "at least " + strconv.FormatInt(n, 10)
+		}
+		return "about " + strconv.FormatInt(estimate, 10)
+	}
+
+	// Looked up here rather than by each worker, which would race to, and
+	// ask again for every archive should the lookup fail.
+	arn := j.Vault.journalARN(ctx)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range archives {
+				archive := item.archive
+				if ctx.Err() != nil {
+					continue // drain without deleting
+				}
+				if isTripped() {
+					unattempted.Add(1)
+					continue
+				}
+				if wrapUp.Requested() {
+					continue // leave it to the resume
+				}
+				if pause := j.Vault.Glacier.Pause; pause != nil && pause.wait(ctx, wrapUp.Done()) != nil {
+					continue
+				}
+				if adaptive != nil {
+					adaptive.acquire()
+				}
+				err := archive.delete(ctx, arn)
+				if adaptive != nil {
+					adaptive.release(err)
+				}
+				if meter := j.Vault.Glacier.Meter; meter != nil {
+					meter.observe(j.Vault, err)
+				}
+				if item.again && (err == nil || errors.Is(err, ErrArchiveNotFound)) {
+					// Deleting an archive twice is harmless, so whether it
+					// was the same one listed again or, by a fingerprint's
+					// collision, another, it's gone now.
+					record(nil)
+					log.Printf("Archive %s, listed again, is gone from vault %s", archive.Id, j.Vault)
+					listed.Add(-1)
+					duplicates.Add(1)
+					dealt(item.pos)
+					continue
+				}
+				if errors.Is(err, ErrArchiveNotFound) {
+					// Nothing's wrong with the vault or the credentials.
+					record(nil)
+					log.Printf("Archive %s was already gone from vault %s", archive.Id, j.Vault)
+					absent.Add(1)
+					if opts.Absent != nil {
+						opts.Absent(archive)
+					}
+					dealt(item.pos)
+					continue
+				}
+				record(err)
+				if err != nil {
+					log.Printf("Error deleting archive %s from vault %s (%s): %v", archive.Id, j.Vault, Classify(err), err)
+					failed.Add(1)
+					if opts.Failed != nil {
+						opts.Failed(archive, err)
+					}
+					continue
+				}
+				log.Printf("Archive %s successfully deleted from vault %s", archive.Id, j.Vault)
+				deletedBytes.Add(archive.Size)
+				if opts.Deleted != nil {
+					opts.Deleted(archive)
+				}
+				dealt(item.pos)
+				if n := deleted.Add(1); n%progressEvery == 0 {
+					var pace []string
+					if limiter := j.Vault.Glacier.Limiter; limiter != nil {
+						pace = append(pace, fmt.Sprintf("limited to %.1f requests/s", limiter.Rate()))
+					}
+					if adaptive != nil {
+						limit, ratio := adaptive.status()
+						pace = append(pace, fmt.Sprintf("%d deletion(s) in flight, %.0f%% of recent ones throttled", limit, ratio*100))
+					}
+					paced := ""
+					if len(pace) > 0 {
+						paced = " (" + strings.Join(pace, "; ") + ")"
+					}
+					log.Printf("Deleted %d of %s archive(s) from vault %s%s", n, total(), j.Vault.Name, paced)
+				}
+			}
+		}()
+	}
+
+	skipped := 0
+	var pos, passed int64 // the position of the archive in the inventory, and those passed over
+	err := produce(func(archive *Archive) error {
+		defer func() { pos++ }()
+		again := !seen.Add(archive.Id)
+		if pos < opts.Skip {
+			if again {
+				duplicates.Add(1)
+				dealt(pos)
+			} else {
+				passed++
+			}
+			return nil
+		}
+		if opts.Filter != nil && !opts.Filter(archive) {
+			skipped++
+			dealt(pos)
+			return nil
+		}
+		if wrapUp.Requested() {
+			return ErrWrappedUp
+		}
+		listed.Add(1)
+		select {
+		case archives <- listedArchive{archive, pos, again}:
+			return nil
+		case <-wrapUp.Done():
+			return ErrWrappedUp
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	parsed.Store(err == nil)
+	close(archives)
+	wg.Wait()
+
+	result := &PurgeResult{
+		JobId:        j.Id,
+		Archives:     int(listed.Load()),
+		Deleted:      int(deleted.Load()),
+		Absent:       int(absent.Load()),
+		FailedBy:     failedBy,
+		DeletedBytes: deletedBytes.Load(),
+		Failed:       int(failed.Load()),
+		Skipped:      skipped,
+		Duplicates:   int(duplicates.Load()),
+		Passed:       int(passed),
+
+		Unattempted: int(unattempted.Load()),
+	}
+	switch {
+	case ctx.Err() != nil:
+		return result, ctx.Err()
+	case wrapUp.Requested():
+		return result, fmt.Errorf("%w, after deleting %d of %s archive(s)", ErrWrappedUp, result.Deleted, total())
+	case tripped != nil:
+		result.Breaker = tripped.Error()
+		return result, fmt.Errorf("%w: %d archive(s) not attempted after %d deletions in a row failed: %w", ErrBreakerTripped, result.Unattempted, breakAfter, tripped)
+	case err != nil:
+		return result, fmt.Errorf("failed to read inventory: %w", err)
+	case result.Failed > 0:
+		return result, fmt.Errorf("failed to delete %d of %d archives", result.Failed, result.Archives)
+	}
+	return result, nil
+}
+
+func (j *InventoryJob) GetResults(ctx context.Context) ([]*Archive, error) {
+	var archives []*Archive
+	err := j.StreamResults(ctx, func(archive *Archive) error {
+		archives = append(archives, archive)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return archives, nil
+}
+
+// StreamResults reads the completed job's inventory like GetResults, but
+// hands each archive to fn as soon as it's read, as StreamInventory does, so
+// a vault of millions of archives takes no more memory than one of a few. It
+// stops at the first error fn returns.
+func (j *InventoryJob) StreamResults(ctx context.Context, fn func(*Archive) error) error {
+	output, err := j.Vault.Glacier.Client.GetJobOutput(ctx, &glacier.GetJobOutputInput{
+		JobId:     aws.String(j.Id),
+		VaultName: aws.String(j.Vault.Name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get job output: %w", jobExpired(ctx, j.Vault, err))
+	}
+
+	defer output.Body.Close()
+	return StreamInventory(output.Body, j.Vault, fn)
+}
+
+// ParseInventory reads an inventory Glacier produced for vault, such as one
+// saved by Download, in either of the formats Glacier writes them in.
+func ParseInventory(r io.Reader, vault *Vault) ([]*Archive, error) {
+	var archives []*Archive
+	err := StreamInventory(r, vault, func(archive *Archive) error {
+		archives = append(archives, archive)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return archives, nil
+}
+
+// StreamInventory reads an inventory like ParseInventory, but hands each
+// archive to fn as soon as it's read instead of collecting them, so an
+// inventory of any size takes the same memory. It stops at the first error
+// fn returns.
+func StreamInventory(r io.Reader, vault *Vault, fn func(*Archive) error) error {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return fmt.Errorf("failed to read job output: %w", err)
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			br.ReadByte()
+			continue
+		}
+		if b[0] != '{' {
+			return streamCSVInventory(br, vault, fn)
+		}
+		break
+	}
+
+	// Walk the top-level object so the archive list is decoded an entry at a
+	// time.
+	dec := json.NewDecoder(br)
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("failed to decode job output: %w", err)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to decode job output: %w", err)
+		}
+		if key != "ArchiveList" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("failed to decode job output: %w", err)
+			}
+			continue
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("failed to decode job output: %w", err)
+		}
+		for dec.More() {
+			var entry inventoryEntry
+			if err := dec.Decode(&entry); err != nil {
+				return fmt.Errorf("failed to decode job output: %w", err)
+			}
+			created, err := parseDate(&entry.CreationDate)
+			if err != nil {
+				return fmt.Errorf("archive %s has an invalid creation date: %w", entry.ArchiveId, err)
+			}
+			err = fn(&Archive{
+				Vault:        vault,
+				Id:           entry.ArchiveId,
+				Description:  entry.ArchiveDescription,
+				CreationDate: created,
+				Size:         entry.Size,
+				TreeHash:     entry.SHA256TreeHash,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("failed to decode job output: %w", err)
+		}
+	}
+	return nil
+}
+
+// csvInventoryColumns are the columns of a CSV inventory.
+var csvInventoryColumns = []string{"ArchiveId", "ArchiveDescription", "CreationDate", "Size", "SHA256TreeHash"}
+
+// streamCSVInventory reads a CSV inventory a record at a time. Columns are
+// found by their header, so their order doesn't matter.
+func streamCSVInventory(r io.Reader, vault *Vault, fn func(*Archive) error) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read inventory header: %w", err)
+	}
+	column := make(map[string]int)
+	for i, name := range header {
+		column[strings.TrimSpace(name)] = i
+	}
+	for _, name := range csvInventoryColumns {
+		if _, ok := column[name]; !ok {
+			return fmt.Errorf("inventory is missing the %s column", name)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read inventory: %w", err)
+		}
+
+		id := record[column["ArchiveId"]]
+		size, err := strconv.ParseInt(record[column["Size"]], 10, 64)
+		if err != nil {
+			return fmt.Errorf("archive %s has an invalid size: %w", id, err)
+		}
+		created, err := parseDate(&record[column["CreationDate"]])
+		if err != nil {
+			return fmt.Errorf("archive %s has an invalid creation date: %w", id, err)
+		}
+		err = fn(&Archive{
+			Vault:        vault,
+			Id:           id,
+			Description:  record[column["ArchiveDescription"]],
+			CreationDate: created,
+			Size:         size,
+			TreeHash:     record[column["SHA256TreeHash"]],
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Download writes the job's raw inventory to w exactly as Glacier returns it.
+// The job must have completed. Offset, Partial, and PartSize in opts work as
+// they do for Archive.Download; the other options are ignored.
+func (j *InventoryJob) Download(ctx context.Context, w io.Writer, opts DownloadOptions) (*DownloadResult, error) {
+	result := &DownloadResult{JobId: j.Id, Written: opts.Offset}
+
+	description, err := j.Describe(ctx)
+	if err != nil {
+		return result, err
+	}
+	if !description.Completed {
+		return result, fmt.Errorf("inventory retrieval job %s hasn't completed", j.Id)
+	}
+	result.Size = aws.ToInt64(description.InventorySizeInBytes)
+
+	if err := downloadJobOutput(ctx, j.Vault, w, result, opts, ""); err != nil {
+		return result, fmt.Errorf("failed to download inventory: %w", err)
+	}
+	return result, nil
+}
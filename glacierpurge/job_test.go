@@ -0,0 +1,462 @@
+package glacierpurge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// awkwardArchives are archives whose descriptions need escaping in both
+// inventory formats.
+var awkwardArchives = []glaciertest.Archive{
+	{Id: "plain", Description: "backup 2019-04-01", CreationDate: testStart.Add(-72 * time.Hour), Size: 1024, TreeHash: strings.Repeat("ab", 32)},
+	{Id: "quoted", Description: `say "cheese", then, a comma`, CreationDate: testStart.Add(-48 * time.Hour), Size: 1 << 40},
+	{Id: "multiline", Description: "line one\nline two\r\n\ttabbed \\ backslash", CreationDate: testStart.Add(-24 * time.Hour)},
+	{Id: "unicode", Description: "фото — 写真 — 📷", CreationDate: testStart.Add(-time.Hour), Content: []byte("some content")},
+	{Id: "empty"},
+}
+
+func TestGetResultsDecodesBothFormats(t *testing.T) {
+	for _, format := range []string{"JSON", "CSV"} {
+		t.Run(format, func(t *testing.T) {
+			fake := glaciertest.New()
+			g, _ := newTestGlacier(t, fake)
+			fake.AddVault(glaciertest.Vault{Name: "vault", CreationDate: testStart.Add(-100 * time.Hour), Archives: awkwardArchives})
+			vault := &Vault{Glacier: g, Name: "vault"}
+
+			job, err := vault.InitiateInventoryJob(context.Background(), InventoryOptions{Format: format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			archives, err := job.WaitForResults(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := fake.Archives("vault")
+			if len(archives) != len(want) {
+				t.Fatalf("got %d archives, want %d", len(archives), len(want))
+			}
+			for i, archive := range archives {
+				w := want[i]
+				description := w.Description
+				if format == "CSV" {
+					// encoding/csv reads a quoted \r\n as \n.
+					description = strings.ReplaceAll(description, "\r\n", "\n")
+				}
+				if archive.Vault != vault || archive.Id != w.Id || archive.Description != description ||
+					!archive.CreationDate.Equal(w.CreationDate) || archive.Size != w.Size || archive.TreeHash != w.TreeHash {
+					t.Errorf("archive %d is %+v, want %+v", i, archive, w)
+				}
+			}
+		})
+	}
+}
+
+func TestGetResultsOfAnExpiredJob(t *testing.T) {
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake)
+	job := startJob(t, g, fake)
+	if err := job.WaitLogged(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fake.ExpireJob(job.Id)
+
+	if _, err := job.GetResults(context.Background()); !errors.Is(err, ErrJobExpired) {
+		t.Errorf("got %v, want ErrJobExpired", err)
+	}
+}
+
+// TestPurgeJobFailures has the inventory job fail to start, or its output
+// fail to decode, and checks Purge returns the failure wrapped as what it
+// is, having deleted nothing, rather than an empty vault.
+func TestPurgeJobFailures(t *testing.T) {
+	output := func(body string) []glaciertest.Response {
+		return []glaciertest.Response{{Output: &glacier.GetJobOutputOutput{Body: io.NopCloser(strings.NewReader(body))}}}
+	}
+	for _, c := range []struct {
+		name         string
+		op           string
+		responses    []glaciertest.Response
+		prefix       string
+		check        func(err error) bool
+		jobInitiated bool
+	}{
+		{
+			name:      "initiate unavailable",
+			op:        glaciertest.OpInitiateJob,
+			responses: []glaciertest.Response{{Err: glaciertest.Unavailable()}},
+			prefix:    "failed to initiate inventory retrieval job: ",
+			check: func(err error) bool {
+				var apiErr *glaciertest.APIError
+				return errors.As(err, &apiErr) && apiErr.Code == "ServiceUnavailableException"
+			},
+		},
+		{
+			name:      "initiate denied",
+			op:        glaciertest.OpInitiateJob,
+			responses: []glaciertest.Response{{Err: glaciertest.AccessDenied()}},
+			prefix:    "failed to initiate inventory retrieval job: ",
+			check:     isAccessDenied,
+		},
+		{
+			name:      "truncated output",
+			op:        glaciertest.OpGetJobOutput,
+			responses: output(`{"VaultARN":"arn","ArchiveList":[{"ArchiveId":"a","Creat`),
+			prefix:    "failed to read inventory: failed to decode job output: ",
+			check: func(err error) bool {
+				return errors.Is(err, io.ErrUnexpectedEOF)
+			},
+			jobInitiated: true,
+		},
+		{
+			name:      "output of the wrong shape",
+			op:        glaciertest.OpGetJobOutput,
+			responses: output(`{"ArchiveList":[{"ArchiveId":"a","Size":"big"}]}`),
+			prefix:    "failed to read inventory: failed to decode job output: ",
+			check: func(err error) bool {
+				var typeErr *json.UnmarshalTypeError
+				return errors.As(err, &typeErr) && typeErr.Field == "Size"
+			},
+			jobInitiated: true,
+		},
+		{
+			name:      "output that isn't an inventory",
+			op:        glaciertest.OpGetJobOutput,
+			responses: output("<html>Service Unavailable</html>"),
+			prefix:    "failed to read inventory: ",
+			check: func(err error) bool {
+				return strings.Contains(err.Error(), "missing the ArchiveId column")
+			},
+			jobInitiated: true,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			fake := glaciertest.New()
+			fake.AddVault(glaciertest.Vault{Name: "photos", Archives: make([]glaciertest.Archive, 3)})
+			g, _ := newTestGlacier(t, fake)
+			fake.Script(c.op, c.responses...)
+
+			result, err := (&Vault{Glacier: g, Name: "photos"}).Purge(context.Background())
+			if err == nil || !strings.HasPrefix(err.Error(), c.prefix) || !c.check(err) {
+				t.Fatalf("got %v, want it wrapped as %q", err, c.prefix)
+			}
+			if result == nil || result.Deleted != 0 || result.Failed != 0 {
+				t.Errorf("got result %+v, want nothing deleted", result)
+			}
+			if (result.JobId != "") != c.jobInitiated {
+				t.Errorf("got job %q; want one: %t", result.JobId, c.jobInitiated)
+			}
+			if n := len(fake.Archives("photos")); n != 3 {
+				t.Errorf("%d archives left, want all 3", n)
+			}
+		})
+	}
+}
+
+func TestParseInventoryErrors(t *testing.T) {
+	tests := []struct {
+		name, inventory, want string
+	}{
+		{"empty", "", "failed to read job output"},
+		{"truncated JSON", `{"ArchiveList":[{"ArchiveId":"a","CreationDate":"2024-01-01T00:00:00Z"},{"Archi`, "failed to decode job output"},
+		{"unclosed list", `{"ArchiveList":[`, "failed to decode job output"},
+		{"bad JSON date", `{"ArchiveList":[{"ArchiveId":"a","CreationDate":"yesterday"}]}`, "archive a has an invalid creation date"},
+		{"wrong JSON type", `{"ArchiveList":[{"ArchiveId":"a","Size":"big"}]}`, "failed to decode job output"},
+		{"CSV missing a column", "ArchiveId,ArchiveDescription,CreationDate,Size\na,,2024-01-01T00:00:00Z,1\n", "missing the SHA256TreeHash column"},
+		{"bad CSV size", "ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\na,,2024-01-01T00:00:00Z,big,h\n", "archive a has an invalid size"},
+		{"bad CSV date", "ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\na,,yesterday,1,h\n", "archive a has an invalid creation date"},
+		{"truncated CSV quote", "ArchiveId,ArchiveDescription,CreationDate,Size,SHA256TreeHash\na,\"unfinished", "failed to read inventory"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archives, err := ParseInventory(strings.NewReader(test.inventory), &Vault{Name: "vault"})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %d archives and error %v, want an error containing %q", len(archives), err, test.want)
+			}
+		})
+	}
+}
+
+func TestParseInventoryAcceptsMissingOptionalFields(t *testing.T) {
+	inventory := ` {"VaultARN":"arn","InventoryDate":"2024-01-01T00:00:00Z","Unknown":{"nested":[1,2]},"ArchiveList":[{"ArchiveId":"a"}]}`
+	archives, err := ParseInventory(strings.NewReader(inventory), &Vault{Name: "vault"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 || archives[0].Id != "a" || !archives[0].CreationDate.IsZero() {
+		t.Errorf("got %+v, want archive a with no creation date", archives)
+	}
+
+	// CSV columns are found by their header, in any order.
+	inventory = "Size, ArchiveId,SHA256TreeHash,CreationDate,ArchiveDescription\n7,b,h,2024-01-01T00:00:00Z,\n"
+	archives, err = ParseInventory(strings.NewReader(inventory), &Vault{Name: "vault"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 || archives[0].Id != "b" || archives[0].Size != 7 {
+		t.Errorf("got %+v, want archive b of 7 bytes", archives)
+	}
+}
+
+// deletionCounts returns how many times each archive's deletion was
+// attempted.
+func deletionCounts(fake *glaciertest.Fake) map[string]int {
+	counts := make(map[string]int)
+	for _, call := range fake.Calls(glaciertest.OpDeleteArchive) {
+		counts[aws.ToString(call.Input.(*glacier.DeleteArchiveInput).ArchiveId)]++
+	}
+	return counts
+}
+
+func TestDeleteAllDeletesEveryArchiveOnce(t *testing.T) {
+	for _, opts := range []DeleteOptions{{Workers: 1}, {Workers: 16}, {Adaptive: true}, {Workers: 8, Buffer: 1}} {
+		t.Run(fmt.Sprintf("%+v", opts), func(t *testing.T) {
+			fake := glaciertest.New()
+			g, _ := newTestGlacier(t, fake)
+			fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 500)})
+			vault := &Vault{Glacier: g, Name: "vault"}
+			job, err := vault.InitiateInventoryRetrievalJob(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := job.WaitLogged(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			var mu sync.Mutex
+			deleted := make(map[string]bool)
+			opts.Deleted = func(archive *Archive) {
+				mu.Lock()
+				defer mu.Unlock()
+				deleted[archive.Id] = true
+			}
+			result, err := job.DeleteAll(context.Background(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Archives != 500 || result.Deleted != 500 || result.Failed != 0 || len(deleted) != 500 {
+				t.Errorf("got %+v with %d reported deleted, want all 500 deleted", result, len(deleted))
+			}
+			if left := fake.Archives("vault"); len(left) != 0 {
+				t.Errorf("%d archives are left", len(left))
+			}
+			for id, n := range deletionCounts(fake) {
+				if n != 1 {
+					t.Errorf("archive %s was deleted %d times", id, n)
+				}
+			}
+		})
+	}
+}
+
+func TestHead(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		start int64
+		add   []int64
+		want  []int64 // the head after each
+	}{
+		{"in order", 0, []int64{0, 1, 2, 3}, []int64{1, 2, 3, 4}},
+		{"out of order", 0, []int64{2, 1, 3, 0}, []int64{0, 0, 0, 4}},
+		{"with a gap", 0, []int64{0, 2, 3}, []int64{1, 1, 1}},
+		{"behind the head", 10, []int64{3, 10, 9}, []int64{10, 11, 11}},
+		{"across words", 60, []int64{130, 64, 62, 63, 61, 60}, []int64{60, 60, 60, 60, 60, 65}},
+		{"far ahead", 0, []int64{1000, 999}, []int64{0, 0}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			h := newHead(c.start)
+			for i, pos := range c.add {
+				if n, _ := h.add(pos); n != c.want[i] {
+					t.Errorf("after adding %d, the head is at %d, want %d", pos, n, c.want[i])
+				}
+			}
+		})
+	}
+
+	// Far enough ahead, the words behind the head are let go.
+	h := newHead(0)
+	for pos := int64(0); pos < 100_000; pos++ {
+		h.add(pos)
+	}
+	if h.n != 100_000 || len(h.bits) > 1 {
+		t.Errorf("the head is at %d with %d word(s) of bits, want 100000 with at most 1", h.n, len(h.bits))
+	}
+}
+
+func TestDeleteAllCheckpoints(t *testing.T) {
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 500)})
+	vault := &Vault{Glacier: g, Name: "vault"}
+	job, err := vault.InitiateInventoryRetrievalJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := job.WaitLogged(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	failing := fake.Archives("vault")[300].Id
+	fake.Intercept(glaciertest.OpDeleteArchive, func(input any) error {
+		if aws.ToString(input.(*glacier.DeleteArchiveInput).ArchiveId) == failing {
+			return glaciertest.Throttled()
+		}
+		return nil
+	})
+
+	var mu sync.Mutex
+	var done int64
+	opts := DeleteOptions{Workers: 16, Done: func(n int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if n <= done {
+			t.Errorf("the checkpoint went from %d to %d", done, n)
+		}
+		done = n
+	}}
+	if _, err := job.DeleteAll(context.Background(), opts); err == nil {
+		t.Fatal("got no error, want the throttled deletion reported")
+	}
+	// Everything after the failed archive is gone, but a checkpoint past it
+	// would have a resume leave it behind.
+	if done != 300 {
+		t.Fatalf("checkpointed %d, want 300", done)
+	}
+
+	fake.Intercept(glaciertest.OpDeleteArchive, nil)
+	calls := fake.Count(glaciertest.OpDeleteArchive)
+	opts.Skip = done
+	result, err := job.DeleteAll(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Passed != 300 || result.Deleted != 1 || result.Absent != 199 || done != 500 {
+		t.Errorf("got %+v, checkpointed %d; want 300 passed over, 1 deleted, 199 already gone and 500 checkpointed", result, done)
+	}
+	if n := fake.Count(glaciertest.OpDeleteArchive) - calls; n != 200 {
+		t.Errorf("made %d deletions carrying on, want 200", n)
+	}
+	if left := fake.Archives("vault"); len(left) != 0 {
+		t.Errorf("%d archives are left", len(left))
+	}
+}
+
+func TestDeleteArchivesSortsOutcomes(t *testing.T) {
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: []glaciertest.Archive{
+		{Id: "a", Size: 1}, {Id: "b", Size: 10}, {Id: "throttled", Size: 100}, {Id: "c", Size: 1000},
+	}})
+	vault := &Vault{Glacier: g, Name: "vault"}
+	fake.Intercept(glaciertest.OpDeleteArchive, func(input any) error {
+		if aws.ToString(input.(*glacier.DeleteArchiveInput).ArchiveId) == "throttled" {
+			return glaciertest.Throttled()
+		}
+		return nil
+	})
+
+	var archives []*Archive
+	for _, id := range []string{"a", "b", "throttled", "gone", "b", "c"} {
+		archives = append(archives, &Archive{Vault: vault, Id: id, Size: int64(len(id))})
+	}
+	var mu sync.Mutex
+	var failed, absent []string
+	result, err := (&InventoryJob{Vault: vault}).DeleteArchives(context.Background(), archives, DeleteOptions{
+		Workers: 3,
+		Failed: func(archive *Archive, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, archive.Id)
+		},
+		Absent: func(archive *Archive) {
+			mu.Lock()
+			defer mu.Unlock()
+			absent = append(absent, archive.Id)
+		},
+	})
+	if err == nil {
+		t.Error("got no error, want the failed deletion reported")
+	}
+
+	want := PurgeResult{Archives: 5, Deleted: 3, Failed: 1, Absent: 1, Duplicates: 1, DeletedBytes: 3}
+	got := *result
+	got.FailedBy = nil
+	if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if result.FailedBy[ClassThrottling] != 1 {
+		t.Errorf("got failures %v, want one throttled", result.FailedBy)
+	}
+	if fmt.Sprint(failed, absent) != "[throttled] [gone]" {
+		t.Errorf("got failed %v and absent %v", failed, absent)
+	}
+	if left := fake.Archives("vault"); len(left) != 1 || left[0].Id != "throttled" {
+		t.Errorf("left %+v, want only the throttled archive", left)
+	}
+}
+
+func TestDeleteArchivesTripsTheBreaker(t *testing.T) {
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 100)})
+	vault := &Vault{Glacier: g, Name: "vault"}
+	fake.Intercept(glaciertest.OpDeleteArchive, func(any) error { return glaciertest.AccessDenied() })
+
+	var archives []*Archive
+	for _, archive := range fake.Archives("vault") {
+		archives = append(archives, &Archive{Vault: vault, Id: archive.Id})
+	}
+	result, err := (&InventoryJob{Vault: vault}).DeleteArchives(context.Background(), archives, DeleteOptions{Workers: 1, BreakAfter: 5})
+	if !errors.Is(err, ErrBreakerTripped) || !IsAccessDenied(err) {
+		t.Errorf("got %v, want ErrBreakerTripped by access being denied", err)
+	}
+	if n := fake.Count(glaciertest.OpDeleteArchive); n != 5 {
+		t.Errorf("attempted %d deletions, want 5", n)
+	}
+	if result.Failed != 5 || result.Unattempted != 95 || result.Breaker == "" {
+		t.Errorf("got %+v, want 5 failed and 95 unattempted", result)
+	}
+}
+
+func TestDeleteArchivesStopsWhenCanceled(t *testing.T) {
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake)
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 100)})
+	vault := &Vault{Glacier: g, Name: "vault"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake.Intercept(glaciertest.OpDeleteArchive, func(any) error {
+		if fake.Count(glaciertest.OpDeleteArchive) == 9 {
+			cancel()
+		}
+		return nil
+	})
+
+	var archives []*Archive
+	for _, archive := range fake.Archives("vault") {
+		archives = append(archives, &Archive{Vault: vault, Id: archive.Id})
+	}
+	result, err := (&InventoryJob{Vault: vault}).DeleteArchives(ctx, archives, DeleteOptions{Workers: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if result.Deleted != 10 {
+		t.Errorf("deleted %d archives, want 10", result.Deleted)
+	}
+	if left := len(fake.Archives("vault")); left != 90 {
+		t.Errorf("%d archives are left, want 90", left)
+	}
+}
@@ -0,0 +1,133 @@
+package glacierpurge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+)
+
+// Job is a Glacier job as reported by ListJobs. Glacier keeps jobs for about
+// a day after they complete.
+type Job struct {
+	Id                   string
+	Action               types.ActionCode
+	StatusCode           types.StatusCode
+	StatusMessage        string
+	CreationDate         time.Time
+	CompletionDate       time.Time // zero until the job completes
+	InventorySizeInBytes int64     // only set for completed inventory retrievals
+}
+
+// ListJobsOptions narrows the jobs ListJobs returns. The zero value returns
+// every job.
+type ListJobsOptions struct {
+	StatusCode    types.StatusCode // only jobs with this status; empty for any
+	InventoryOnly bool             // only inventory retrieval jobs
+}
+
+// ListJobs returns the vault's jobs, following every page of results.
+func (v *Vault) ListJobs(ctx context.Context, opts ListJobsOptions) ([]*Job, error) {
+	params := &glacier.ListJobsInput{
+		AccountId: aws.String("-"),
+		VaultName: aws.String(v.Name),
+	}
+	if opts.StatusCode != "" {
+		params.Statuscode = aws.String(string(opts.StatusCode))
+	}
+
+	var jobs []*Job
+	paginator := glacier.NewListJobsPaginator(v.Glacier.Client, params)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if isAccessDenied(err) {
+			return nil, &PermissionError{Op: "ListJobs", Vault: v.Name, Err: err}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs for vault %s: %w", v.Name, err)
+		}
+
+		for _, description := range output.JobList {
+			if opts.InventoryOnly && description.Action != types.ActionCodeInventoryRetrieval {
+				continue
+			}
+
+			job, err := newJob(description)
+			if err != nil {
+				return nil, fmt.Errorf("vault %s: %w", v.Name, err)
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, nil
+}
+
+func newJob(description types.GlacierJobDescription) (*Job, error) {
+	job := &Job{
+		Id:                   aws.ToString(description.JobId),
+		Action:               description.Action,
+		StatusCode:           description.StatusCode,
+		StatusMessage:        aws.ToString(description.StatusMessage),
+		InventorySizeInBytes: aws.ToInt64(description.InventorySizeInBytes),
+	}
+
+	var err error
+	if job.CreationDate, err = parseDate(description.CreationDate); err != nil {
+		return nil, fmt.Errorf("job %s has an invalid creation date: %w", job.Id, err)
+	}
+	if job.CompletionDate, err = parseDate(description.CompletionDate); err != nil {
+		return nil, fmt.Errorf("job %s has an invalid completion date: %w", job.Id, err)
+	}
+	return job, nil
+}
+
+// LatestInventory returns the vault's most recent inventory retrieval job
+// that has succeeded and whose output Glacier still holds, or nil if there
+// isn't one.
+func (v *Vault) LatestInventory(ctx context.Context) (*InventoryJob, error) {
+	jobs, err := v.ListJobs(ctx, ListJobsOptions{StatusCode: types.StatusCodeSucceeded, InventoryOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Job
+	for _, job := range jobs {
+		if latest == nil || job.CompletionDate.After(latest.CompletionDate) {
+			latest = job
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	return &InventoryJob{Vault: v, Id: latest.Id}, nil
+}
+
+// ReusableInventory returns an inventory retrieval job that can be used
+// instead of starting a new one: the latest that has succeeded, or failing
+// that the latest still in progress. It returns nil if there is neither.
+func (v *Vault) ReusableInventory(ctx context.Context) (*InventoryJob, error) {
+	job, err := v.LatestInventory(ctx)
+	if err != nil || job != nil {
+		return job, err
+	}
+
+	jobs, err := v.ListJobs(ctx, ListJobsOptions{StatusCode: types.StatusCodeInProgress, InventoryOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Job
+	for _, job := range jobs {
+		if latest == nil || job.CreationDate.After(latest.CreationDate) {
+			latest = job
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	return &InventoryJob{Vault: v, Id: latest.Id}, nil
+}
@@ -0,0 +1,135 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// newJobsVault returns a vault in a fake listing 2 jobs a page, with an
+// inventory job started each hour, n in all, every one but the last
+// completed, and an archive retrieval started along with the first.
+func newJobsVault(t *testing.T, n int) (*Vault, *glaciertest.Fake, []string) {
+	t.Helper()
+	fake := glaciertest.New()
+	fake.PageSize = 2
+	fake.AddVault(glaciertest.Vault{Name: "photos", Archives: make([]glaciertest.Archive, 1)})
+	g, clock := newTestGlacier(t, fake)
+	vault := &Vault{Glacier: g, Name: "photos"}
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < n; i++ {
+		job, err := vault.InitiateInventoryRetrievalJob(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, job.Id)
+		if i == 0 {
+			_, err := fake.InitiateJob(ctx, &glacier.InitiateJobInput{
+				VaultName:     aws.String("photos"),
+				JobParameters: &types.JobParameters{Type: aws.String("archive-retrieval"), ArchiveId: aws.String(fake.Archives("photos")[0].Id)},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if i < n-1 {
+			if _, err := job.Describe(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		clock.Advance(time.Hour)
+	}
+	return vault, fake, ids
+}
+
+func TestListJobsFollowsEveryPage(t *testing.T) {
+	vault, fake, ids := newJobsVault(t, 5)
+
+	jobs, err := vault.ListJobs(context.Background(), ListJobsOptions{InventoryOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, job := range jobs {
+		got = append(got, job.Id)
+		if job.Action != types.ActionCodeInventoryRetrieval {
+			t.Errorf("job %s is a %s", job.Id, job.Action)
+		}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(ids) {
+		t.Errorf("got jobs %v, want %v", got, ids)
+	}
+
+	// Six jobs, the archive retrieval among them, two a page.
+	var markers []string
+	for _, call := range fake.Calls(glaciertest.OpListJobs) {
+		markers = append(markers, aws.ToString(call.Input.(*glacier.ListJobsInput).Marker))
+	}
+	if want := fmt.Sprint([]string{"", ids[1], ids[3]}); fmt.Sprint(markers) != want {
+		t.Errorf("ListJobs was called with markers %v, want %v", markers, want)
+	}
+
+	// The latest to complete is on the last page, and the one after it is
+	// still in progress.
+	latest, err := vault.LatestInventory(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest == nil || latest.Id != ids[3] {
+		t.Errorf("got latest inventory %+v, want %s", latest, ids[3])
+	}
+}
+
+func TestListJobsLaterPageFails(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		err   error
+		check func(error) bool
+	}{
+		{
+			name: "unavailable",
+			err:  glaciertest.Unavailable(),
+			check: func(err error) bool {
+				return strings.HasPrefix(err.Error(), "failed to list jobs for vault photos: ") && Classify(err) == Classify(glaciertest.Unavailable())
+			},
+		},
+		{
+			name: "access denied",
+			err:  glaciertest.AccessDenied(),
+			check: func(err error) bool {
+				var permission *PermissionError
+				return errors.As(err, &permission) && permission.Op == "ListJobs" && permission.Vault == "photos"
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			vault, fake, _ := newJobsVault(t, 5)
+			fake.Script(glaciertest.OpListJobs, glaciertest.Response{}, glaciertest.Response{}, glaciertest.Response{Err: c.err})
+
+			// What the pages before listed isn't returned as if it were all.
+			jobs, err := vault.ListJobs(context.Background(), ListJobsOptions{})
+			if jobs != nil || err == nil || !c.check(err) {
+				t.Errorf("got %d jobs and %v", len(jobs), err)
+			}
+			if n := fake.Count(glaciertest.OpListJobs); n != 3 {
+				t.Errorf("ListJobs was called %d times, want 3", n)
+			}
+
+			fake.Script(glaciertest.OpListJobs, glaciertest.Response{}, glaciertest.Response{Err: c.err})
+			if latest, err := vault.LatestInventory(context.Background()); latest != nil || err == nil || !c.check(err) {
+				t.Errorf("LatestInventory = %+v, %v", latest, err)
+			}
+		})
+	}
+}
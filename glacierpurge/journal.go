@@ -0,0 +1,89 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Journal is told about every archive and vault deletion: once before the
+// call is made and again once its outcome is known. Record must have stored
+// the entry durably before it returns. If it fails, the deletion isn't
+// attempted, or isn't reported as done, since an unrecorded deletion would
+// defeat the journal.
+type Journal interface {
+	Record(entry JournalEntry) error
+}
+
+// Deletion outcomes recorded in a Journal.
+const (
+	OutcomeAttempted = "attempted"
+	OutcomeDeleted   = "deleted"
+	OutcomeFailed    = "failed"
+)
+
+// JournalEntry describes one step of a deletion.
+type JournalEntry struct {
+	Op        string // DeleteArchive or DeleteVault
+	Region    string
+	Vault     string
+	VaultARN  string
+	ArchiveId string // for DeleteArchive
+	Size      int64  // the archive's size, when the inventory gave it
+	Outcome   string
+	RequestId string // AWS's request ID, once the call has been made
+	Error     string
+}
+
+// WithJournal records every deletion in journal.
+func WithJournal(journal Journal) Option {
+	return func(o *options) {
+		o.journal = journal
+	}
+}
+
+// journalARN returns the vault's ARN for its journal entries, describing the
+// vault for it the first time if GetVaults didn't give it. It's only for the
+// record, so a failed lookup is remembered and the entries go without the ARN,
+// rather than each deletion asking again. Deleting archives side by side, it's
+// called once before they start.
+func (v *Vault) journalARN(ctx context.Context) string {
+	if v.Glacier.Journal == nil || v.ARN != "" || v.arnErr != nil {
+		return v.ARN
+	}
+	if _, err := v.Describe(ctx); err != nil {
+		v.Glacier.Logger.Printf("Journaling deletions from vault %s without its ARN: %v", v, err)
+		v.arnErr = err
+	}
+	return v.ARN
+}
+
+// journaled makes a deletion through call, recording it in the vault's
+// journal, if it has one, before and after, with arn as the vault's ARN.
+func (v *Vault) journaled(arn string, entry JournalEntry, call func() (middleware.Metadata, error)) error {
+	journal := v.Glacier.Journal
+	if journal == nil {
+		_, err := call()
+		return err
+	}
+	entry.Region, entry.Vault, entry.VaultARN = v.Glacier.Region, v.Name, arn
+
+	entry.Outcome = OutcomeAttempted
+	if err := journal.Record(entry); err != nil {
+		return err
+	}
+
+	metadata, err := call()
+	entry.Outcome = OutcomeDeleted
+	entry.RequestId, _ = awsmiddleware.GetRequestIDMetadata(metadata)
+	if err != nil {
+		entry.Outcome, entry.Error = OutcomeFailed, err.Error()
+		var requestErr interface{ ServiceRequestID() string }
+		if errors.As(err, &requestErr) {
+			entry.RequestId = requestErr.ServiceRequestID()
+		}
+	}
+	return errors.Join(err, journal.Record(entry))
+}
@@ -0,0 +1,154 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// memoryJournal keeps the entries recorded in it, failing from the failAt'th
+// on if failAt is set.
+type memoryJournal struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+	failAt  int
+}
+
+func (j *memoryJournal) Record(entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.failAt > 0 && len(j.entries)+1 >= j.failAt {
+		return errors.New("the journal's disk is full")
+	}
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+// outcomes returns the outcomes recorded for each archive, or for the vault
+// under "", in order.
+func (j *memoryJournal) outcomes() map[string][]string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	outcomes := make(map[string][]string)
+	for _, entry := range j.entries {
+		outcomes[entry.ArchiveId] = append(outcomes[entry.ArchiveId], entry.Outcome)
+	}
+	return outcomes
+}
+
+func TestJournalRecordsDeletions(t *testing.T) {
+	journal := &memoryJournal{}
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake, WithJournal(journal))
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: []glaciertest.Archive{{Id: "a", Size: 1}, {Id: "b", Size: 2}, {Id: "throttled", Size: 3}}})
+	fake.Intercept(glaciertest.OpDeleteArchive, func(input any) error {
+		if aws.ToString(input.(*glacier.DeleteArchiveInput).ArchiveId) == "throttled" {
+			return glaciertest.Throttled()
+		}
+		return nil
+	})
+	// As the commands that take a vault's name make it, without its ARN.
+	vault := &Vault{Glacier: g, Name: "vault"}
+
+	var archives []*Archive
+	for _, archive := range fake.Archives("vault") {
+		archives = append(archives, &Archive{Vault: vault, Id: archive.Id, Size: archive.Size})
+	}
+	if _, err := (&InventoryJob{Vault: vault}).DeleteArchives(context.Background(), archives, DeleteOptions{Workers: 3}); err == nil {
+		t.Error("got no error, want the throttled deletion reported")
+	}
+	if err := vault.Delete(context.Background()); !errors.Is(err, ErrVaultNotEmpty) {
+		t.Errorf("deleting the vault got %v, want ErrVaultNotEmpty", err)
+	}
+
+	want := map[string][]string{
+		"a":         {OutcomeAttempted, OutcomeDeleted},
+		"b":         {OutcomeAttempted, OutcomeDeleted},
+		"throttled": {OutcomeAttempted, OutcomeFailed},
+		"":          {OutcomeAttempted, OutcomeFailed},
+	}
+	got := journal.outcomes()
+	for id, outcomes := range want {
+		if len(got[id]) != len(outcomes) || got[id][0] != outcomes[0] || got[id][1] != outcomes[1] {
+			t.Errorf("archive %q was journaled %v, want %v", id, got[id], outcomes)
+		}
+	}
+	for _, entry := range journal.entries {
+		if entry.Region != "us-east-1" || entry.Vault != "vault" || entry.VaultARN != fake.ARN("vault") {
+			t.Errorf("entry %+v doesn't name the vault", entry)
+		}
+		if entry.Outcome == OutcomeFailed && entry.Error == "" {
+			t.Errorf("entry %+v doesn't say why it failed", entry)
+		}
+		if entry.ArchiveId == "b" && (entry.Op != "DeleteArchive" || entry.Size != 2) {
+			t.Errorf("entry %+v doesn't describe archive b", entry)
+		}
+	}
+	// The ARN is looked up once for every deletion.
+	if n := fake.Count(glaciertest.OpDescribeVault); n != 1 {
+		t.Errorf("DescribeVault was called %d times, want once", n)
+	}
+}
+
+func TestJournalWithoutTheARN(t *testing.T) {
+	journal := &memoryJournal{}
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake, WithJournal(journal))
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 200)})
+	fake.Intercept(glaciertest.OpDescribeVault, func(any) error { return glaciertest.AccessDenied() })
+	vault := &Vault{Glacier: g, Name: "vault"}
+
+	var archives []*Archive
+	for _, archive := range fake.Archives("vault") {
+		archives = append(archives, &Archive{Vault: vault, Id: archive.Id})
+	}
+	// In pages, as a paginated job deletes them.
+	job := &InventoryJob{Vault: vault}
+	for page := 0; page < 4; page++ {
+		result, err := job.DeleteArchives(context.Background(), archives[page*50:(page+1)*50], DeleteOptions{Workers: 8})
+		if err != nil || result.Deleted != 50 {
+			t.Fatalf("page %d: got %+v, %v; want every archive deleted", page, result, err)
+		}
+	}
+
+	if n := fake.Count(glaciertest.OpDescribeVault); n != 1 {
+		t.Errorf("DescribeVault was called %d times for %d deletions, want once", n, len(archives))
+	}
+	if len(journal.entries) != 2*len(archives) {
+		t.Errorf("journaled %d entries, want %d", len(journal.entries), 2*len(archives))
+	}
+	for _, entry := range journal.entries {
+		if entry.VaultARN != "" || entry.Vault != "vault" {
+			t.Errorf("entry %+v, want the vault named without an ARN", entry)
+		}
+	}
+}
+
+func TestJournalFailureStopsTheDeletion(t *testing.T) {
+	// The attempt is journaled, but not its outcome.
+	journal := &memoryJournal{failAt: 2}
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake, WithJournal(journal))
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: []glaciertest.Archive{{Id: "a"}, {Id: "b"}}})
+	vault := &Vault{Glacier: g, Name: "vault", ARN: fake.ARN("vault")}
+
+	if err := (&Archive{Vault: vault, Id: "a"}).Delete(context.Background()); err == nil {
+		t.Error("deleting archive a got no error, want the journal's")
+	}
+	if err := (&Archive{Vault: vault, Id: "b"}).Delete(context.Background()); err == nil {
+		t.Error("deleting archive b got no error, want the journal's")
+	}
+	// Only a's deletion was attempted, once journaled.
+	if counts := deletionCounts(fake); len(counts) != 1 || counts["a"] != 1 {
+		t.Errorf("deletions attempted %v, want only a's", counts)
+	}
+	if n := fake.Count(glaciertest.OpDescribeVault); n != 0 {
+		t.Errorf("DescribeVault was called %d times for a vault with its ARN", n)
+	}
+}
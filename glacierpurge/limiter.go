@@ -0,0 +1,209 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// limiterWindow is how many calls the limiter looks back over to judge the
+// share of them that were throttled.
+const limiterWindow = 50
+
+// throttleRatio is the share of throttled calls in a window that has the
+// limiter halve its rate.
+const throttleRatio = 0.1
+
+// Limiter paces every AWS call made through the clients and uploaders it's
+// given to, across all regions and vaults, to at most its rate. The rate is
+// halved whenever AWS starts throttling a noticeable share of calls and
+// creeps back up to the maximum once it stops. The clients' retries also come
+// out of one shared budget, so a throttling storm can't multiply the load.
+// It's safe for concurrent use.
+type Limiter struct {
+	// Clock is what Wait paces calls by; the wall clock if nil. Set it
+	// before the limiter is first used.
+	Clock Clock
+
+	max, min float64 // requests per second
+
+	mu        sync.Mutex
+	rate      float64
+	tokens    float64
+	last      time.Time
+	calls     int
+	throttled int
+
+	retries retry.RateLimiter
+}
+
+// NewLimiter returns a limiter allowing up to rate requests per second.
+func NewLimiter(rate float64) *Limiter {
+	return &Limiter{
+		max:     rate,
+		min:     rate / 32,
+		rate:    rate,
+		tokens:  1,
+		retries: ratelimit.NewTokenRateLimit(retry.DefaultRetryRateTokens),
+	}
+}
+
+// Rate returns the requests per second currently allowed.
+func (l *Limiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Wait blocks until the next call may be made, or ctx ends.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		clock := l.Clock
+		if clock == nil {
+			clock = WallClock
+		}
+		now := clock.Now()
+		if l.last.IsZero() {
+			l.last = now
+		}
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if burst := max(l.rate, 1); l.tokens > burst {
+			l.tokens = burst
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		if err := sleepFor(ctx, clock, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// observe adjusts the rate to a call's outcome.
+func (l *Limiter) observe(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls++
+	if isThrottling(err) {
+		l.throttled++
+	}
+	if l.calls < limiterWindow {
+		return
+	}
+
+	switch ratio := float64(l.throttled) / float64(l.calls); {
+	case ratio >= throttleRatio:
+		l.rate = max(l.rate/2, l.min)
+	case l.throttled == 0:
+		l.rate = min(l.rate*1.25, l.max)
+	}
+	l.calls, l.throttled = 0, 0
+}
+
+// isThrottling reports whether err is AWS turning a call down for its rate.
+func isThrottling(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "Throttling", "TooManyRequestsException", "RequestLimitExceeded", "SlowDown":
+			return true
+		}
+	}
+	return false
+}
+
+// WithLimiter paces the client's calls through limiter, which is meant to be
+// shared by every client.
+func WithLimiter(limiter *Limiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// middleware waits for the limiter before every call an SDK client other
+// than Glacier's makes, such as S3's, whose API isn't wrapped in limitedAPI.
+func (l *Limiter) middleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("IceBreakerLimiter", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		if err := l.Wait(ctx); err != nil {
+			return middleware.InitializeOutput{}, middleware.Metadata{}, err
+		}
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		l.observe(err)
+		return out, metadata, err
+	}), middleware.Before)
+}
+
+// limitedAPI waits for the limiter before every call.
+type limitedAPI struct {
+	API
+	limiter *Limiter
+}
+
+func limited[In, Out any](ctx context.Context, l *Limiter, call func(context.Context, In, ...func(*glacier.Options)) (Out, error), params In, optFns []func(*glacier.Options)) (Out, error) {
+	if err := l.Wait(ctx); err != nil {
+		var zero Out
+		return zero, err
+	}
+	out, err := call(ctx, params, optFns...)
+	l.observe(err)
+	return out, err
+}
+
+func (a limitedAPI) ListVaults(ctx context.Context, params *glacier.ListVaultsInput, optFns ...func(*glacier.Options)) (*glacier.ListVaultsOutput, error) {
+	return limited(ctx, a.limiter, a.API.ListVaults, params, optFns)
+}
+
+func (a limitedAPI) DescribeVault(ctx context.Context, params *glacier.DescribeVaultInput, optFns ...func(*glacier.Options)) (*glacier.DescribeVaultOutput, error) {
+	return limited(ctx, a.limiter, a.API.DescribeVault, params, optFns)
+}
+
+func (a limitedAPI) ListTagsForVault(ctx context.Context, params *glacier.ListTagsForVaultInput, optFns ...func(*glacier.Options)) (*glacier.ListTagsForVaultOutput, error) {
+	return limited(ctx, a.limiter, a.API.ListTagsForVault, params, optFns)
+}
+
+func (a limitedAPI) AddTagsToVault(ctx context.Context, params *glacier.AddTagsToVaultInput, optFns ...func(*glacier.Options)) (*glacier.AddTagsToVaultOutput, error) {
+	return limited(ctx, a.limiter, a.API.AddTagsToVault, params, optFns)
+}
+
+func (a limitedAPI) RemoveTagsFromVault(ctx context.Context, params *glacier.RemoveTagsFromVaultInput, optFns ...func(*glacier.Options)) (*glacier.RemoveTagsFromVaultOutput, error) {
+	return limited(ctx, a.limiter, a.API.RemoveTagsFromVault, params, optFns)
+}
+
+func (a limitedAPI) InitiateJob(ctx context.Context, params *glacier.InitiateJobInput, optFns ...func(*glacier.Options)) (*glacier.InitiateJobOutput, error) {
+	return limited(ctx, a.limiter, a.API.InitiateJob, params, optFns)
+}
+
+func (a limitedAPI) DescribeJob(ctx context.Context, params *glacier.DescribeJobInput, optFns ...func(*glacier.Options)) (*glacier.DescribeJobOutput, error) {
+	return limited(ctx, a.limiter, a.API.DescribeJob, params, optFns)
+}
+
+func (a limitedAPI) GetJobOutput(ctx context.Context, params *glacier.GetJobOutputInput, optFns ...func(*glacier.Options)) (*glacier.GetJobOutputOutput, error) {
+	return limited(ctx, a.limiter, a.API.GetJobOutput, params, optFns)
+}
+
+func (a limitedAPI) ListJobs(ctx context.Context, params *glacier.ListJobsInput, optFns ...func(*glacier.Options)) (*glacier.ListJobsOutput, error) {
+	return limited(ctx, a.limiter, a.API.ListJobs, params, optFns)
+}
+
+func (a limitedAPI) DeleteArchive(ctx context.Context, params *glacier.DeleteArchiveInput, optFns ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error) {
+	return limited(ctx, a.limiter, a.API.DeleteArchive, params, optFns)
+}
+
+func (a limitedAPI) DeleteVault(ctx context.Context, params *glacier.DeleteVaultInput, optFns ...func(*glacier.Options)) (*glacier.DeleteVaultOutput, error) {
+	return limited(ctx, a.limiter, a.API.DeleteVault, params, optFns)
+}
@@ -0,0 +1,195 @@
+package glacierpurge
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MeterWindow is how far back a Meter's recent rates look.
+const MeterWindow = 30 * time.Second
+
+// meterErrors is how many of the latest failed deletions a Meter keeps.
+const meterErrors = 10
+
+// Meter counts the deletions made through the clients it's given to, per
+// vault, so their rate can be shown as they go. It's safe for concurrent use.
+type Meter struct {
+	mu     sync.Mutex
+	first  time.Time // of the first deletion from any vault
+	vaults map[meterKey]*vaultMeter
+	pause  *Pause        // the deletions' pause, once one is seen
+	paused time.Duration // the pause's total at the first deletion
+	errors []MeterError  // the latest failures, oldest first
+}
+
+// MeterError is a failed deletion.
+type MeterError struct {
+	Time          time.Time
+	Region, Vault string
+	Class         ErrorClass
+	Err           error
+}
+
+type meterKey struct{ region, vault string }
+
+// vaultMeter holds one vault's counts: in total, and per second over the
+// last window.
+type vaultMeter struct {
+	first, last                 time.Time
+	pausedFirst, pausedLast     time.Duration // the pause's total at first and last
+	deleted, attempts, problems int
+	absent                      int
+	failedBy                    ClassCounts
+	seconds                     [int(MeterWindow / time.Second)]meterSecond
+}
+
+type meterSecond struct {
+	unix                        int64
+	deleted, attempts, problems int
+}
+
+// Reading is the deletion rate of a vault, or of every vault together.
+type Reading struct {
+	Region, Vault string // empty for every vault together
+
+	Deleted  int
+	Failed   int
+	Absent   int         // archives found already gone, which aren't deletions
+	FailedBy ClassCounts // the failures by the class of their error
+	Recent   float64     // deletions per second over the last MeterWindow
+	Overall  float64     // deletions per second since the first
+	Problems float64     // the share of the last MeterWindow's attempts throttled or failed
+	Active   bool        // whether there were any attempts in the last MeterWindow
+}
+
+// NewMeter returns a meter with nothing counted yet.
+func NewMeter() *Meter {
+	return &Meter{vaults: map[meterKey]*vaultMeter{}}
+}
+
+// WithMeter counts the client's deletions in meter, which is meant to be
+// shared by every client.
+func WithMeter(meter *Meter) Option {
+	return func(o *options) {
+		o.meter = meter
+	}
+}
+
+// observe counts a deletion's outcome.
+func (m *Meter) observe(vault *Vault, err error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var paused time.Duration
+	if vault.Glacier.Pause != nil {
+		m.pause = vault.Glacier.Pause
+		paused = m.pause.Total()
+	}
+	if m.first.IsZero() {
+		m.first, m.paused = now, paused
+	}
+	key := meterKey{vault.Glacier.Region, vault.Name}
+	v := m.vaults[key]
+	if v == nil {
+		v = &vaultMeter{first: now, pausedFirst: paused}
+		m.vaults[key] = v
+	}
+	v.last, v.pausedLast = now, paused
+
+	s := &v.seconds[now.Unix()%int64(len(v.seconds))]
+	if s.unix != now.Unix() {
+		*s = meterSecond{unix: now.Unix()}
+	}
+	v.attempts++
+	s.attempts++
+	if errors.Is(err, ErrArchiveNotFound) {
+		v.absent++
+		return
+	}
+	if err == nil {
+		v.deleted++
+		s.deleted++
+	} else {
+		v.problems++
+		s.problems++
+		class := Classify(err)
+		if v.failedBy == nil {
+			v.failedBy = ClassCounts{}
+		}
+		v.failedBy[class]++
+		if len(m.errors) == meterErrors {
+			m.errors = m.errors[1:]
+		}
+		m.errors = append(m.errors, MeterError{Time: now, Region: key.region, Vault: key.vault, Class: class, Err: err})
+	}
+}
+
+// Errors returns the latest failed deletions, oldest first.
+func (m *Meter) Errors() []MeterError {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MeterError(nil), m.errors...)
+}
+
+// Read returns the rate of every vault together and of each vault, in order
+// of region and name.
+func (m *Meter) Read() (Reading, []Reading) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	total := Reading{FailedBy: ClassCounts{}}
+	var recentAttempts, recentProblems int
+	vaults := make([]Reading, 0, len(m.vaults))
+	for key, v := range m.vaults {
+		r := Reading{Region: key.region, Vault: key.vault, Deleted: v.deleted, Failed: v.problems, Absent: v.absent, FailedBy: ClassCounts{}}
+		r.FailedBy.Add(v.failedBy)
+		var deleted, attempts, problems int
+		for _, s := range v.seconds {
+			if now.Unix()-s.unix < int64(len(v.seconds)) {
+				deleted += s.deleted
+				attempts += s.attempts
+				problems += s.problems
+			}
+		}
+		r.Recent = float64(deleted) / MeterWindow.Seconds()
+		r.Active = attempts > 0
+		if attempts > 0 {
+			r.Problems = float64(problems) / float64(attempts)
+		}
+		// A vault no longer deleting keeps the rate it finished with. Time
+		// spent paused doesn't count.
+		r.Overall = float64(v.deleted) / max((v.last.Sub(v.first)-(v.pausedLast-v.pausedFirst)).Seconds(), 1)
+		vaults = append(vaults, r)
+
+		total.Deleted += v.deleted
+		total.Failed += v.problems
+		total.Absent += v.absent
+		total.FailedBy.Add(v.failedBy)
+		total.Recent += r.Recent
+		recentAttempts += attempts
+		recentProblems += problems
+	}
+	if recentAttempts > 0 {
+		total.Problems = float64(recentProblems) / float64(recentAttempts)
+	}
+	total.Active = recentAttempts > 0
+	if !m.first.IsZero() {
+		elapsed := now.Sub(m.first)
+		if m.pause != nil {
+			elapsed -= m.pause.Total() - m.paused
+		}
+		total.Overall = float64(total.Deleted) / max(elapsed.Seconds(), 1)
+	}
+
+	sort.Slice(vaults, func(i, j int) bool {
+		if vaults[i].Region != vaults[j].Region {
+			return vaults[i].Region < vaults[j].Region
+		}
+		return vaults[i].Vault < vaults[j].Vault
+	})
+	return total, vaults
+}
@@ -0,0 +1,68 @@
+package glacierpurge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxVaultNameLength is the longest name Glacier gives a vault, in bytes.
+const MaxVaultNameLength = 255
+
+// ValidateVaultName checks name against the names Glacier gives vaults: 1 to
+// 255 of a-z, A-Z, 0-9, '_', '-', and '.'. Glacier compares names byte for
+// byte, case included, so a name failing this, such as one with a space or a
+// letter that only looks like an ASCII one, can't be any vault's.
+func ValidateVaultName(name string) error {
+	if name == "" {
+		return errors.New("a vault name can't be empty")
+	}
+	if len(name) > MaxVaultNameLength {
+		return fmt.Errorf("vault name %q is %d bytes long; Glacier's are at most %d", name, len(name), MaxVaultNameLength)
+	}
+	for i, r := range name {
+		if !isVaultNameChar(r) {
+			return fmt.Errorf("vault name %q has %U at byte %d; Glacier's only hold a-z, A-Z, 0-9, '_', '-', and '.'", name, r, i)
+		}
+	}
+	return nil
+}
+
+func isVaultNameChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.'
+}
+
+// vaultFileSuffix matches the end of a name VaultFileName has made unique.
+var vaultFileSuffix = regexp.MustCompile(`\.[0-9a-f]{12}$`)
+
+// windowsDevices are the names Windows keeps for devices, with or without an
+// extension.
+var windowsDevices = regexp.MustCompile(`^(con|prn|aux|nul|com[0-9]|lpt[0-9])(\.|$)`)
+
+// VaultFileName names a file or directory of a vault's own, such as its
+// salvage directory. It's the vault's name where that's safe on every
+// filesystem; otherwise, as for a name with capitals, which a filesystem
+// ignoring case could confuse with another vault's, or one that's all dots,
+// it's the name made safe with the start of a hash of the exact name
+// appended. Names that could pass for one of those get the hash too, so no
+// two vaults share a file name.
+func VaultFileName(name string) string {
+	safe := unsafeFileChars.ReplaceAllString(name, "_")
+	if safe == name && name == strings.ToLower(name) && strings.Trim(name, ".") != "" &&
+		!strings.HasSuffix(name, ".") && !windowsDevices.MatchString(name) && !vaultFileSuffix.MatchString(name) {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	prefix := strings.Trim(safe, ".")
+	if prefix == "" || windowsDevices.MatchString(strings.ToLower(prefix)) {
+		prefix = "vault_" + prefix
+	}
+	if len(prefix) > 100 {
+		prefix = prefix[:100]
+	}
+	return prefix + "." + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,94 @@
+package glacierpurge
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateVaultName(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		err  string // part of the error; empty if the name is valid
+	}{
+		{"photos", ""},
+		{"Photos.2019_raw-1", ""},
+		{".", ""},
+		{"...", ""},
+		{"-_-", ""},
+		{strings.Repeat("a", MaxVaultNameLength), ""},
+		{"", "can't be empty"},
+		{strings.Repeat("a", MaxVaultNameLength+1), "256 bytes long"},
+		{"my photos", "U+0020 at byte 2"},
+		{"photos/2019", "U+002F at byte 6"},
+		{"photos*", "U+002A at byte 6"},
+		{"tab\t", "U+0009 at byte 3"},
+		{"фото", "U+0444 at byte 0"},
+		{"Ｐhotos", "U+FF30 at byte 0"},  // a fullwidth P
+		{"photos​", "U+200B at byte 6"}, // a zero-width space
+		{"café", "U+0301 at byte 4"},   // an e and a combining accent
+	} {
+		err := ValidateVaultName(c.name)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("ValidateVaultName(%q) = %v, want it valid", c.name, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("ValidateVaultName(%q) = %v, want an error saying %q", c.name, err, c.err)
+		}
+	}
+}
+
+func TestVaultFileName(t *testing.T) {
+	for _, c := range []struct {
+		name, want string
+	}{
+		{"photos", "photos"},
+		{"photos.2019_raw-1", "photos.2019_raw-1"},
+		{"Photos", "Photos.5e3147ab51e0"},
+		{"photos.", "photos.b501a41bbc63"},
+		{".", "vault_.cdb4ee2aea69"},
+		{"con", "vault_con.1143da2bc54c"},
+	} {
+		if got := VaultFileName(c.name); got != c.want {
+			t.Errorf("VaultFileName(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+// TestVaultFileNameCollisions checks that vault names as alike as Glacier
+// allows never share a file name, even on a filesystem that ignores case, and
+// that each is one every filesystem takes.
+func TestVaultFileNameCollisions(t *testing.T) {
+	long := strings.Repeat("a", MaxVaultNameLength-1)
+	names := []string{
+		"photos", "Photos", "PHOTOS", "pHoToS",
+		"photos.", "photos..", "Photos.",
+		".", "..", "...", "-", "_", ".-.",
+		"con", "CON", "Con", "con.txt", "nul", "aux.", "com1", "COM1.log", "lpt9", "prn.tar.gz", "conx",
+		long + "a", long + "b", long + "A", long + ".",
+		// Names that look like ones already made unique.
+		VaultFileName("Photos"), VaultFileName("photos."), VaultFileName("."), VaultFileName("con"),
+		"photos.0123456789ab", "photos.0123456789AB",
+	}
+
+	seen := make(map[string]string) // by lower-cased file name
+	for _, name := range names {
+		if err := ValidateVaultName(name); err != nil {
+			t.Fatalf("fixture %q isn't a vault name: %v", name, err)
+		}
+		file := VaultFileName(name)
+		if other, ok := seen[strings.ToLower(file)]; ok {
+			t.Errorf("vaults %q and %q share the file name %q", other, name, file)
+		}
+		seen[strings.ToLower(file)] = name
+
+		if unsafeFileChars.MatchString(file) || strings.HasSuffix(file, ".") || strings.Trim(file, ".") == "" {
+			t.Errorf("VaultFileName(%q) = %q, which not every filesystem takes", name, file)
+		}
+		if windowsDevices.MatchString(strings.ToLower(file)) {
+			t.Errorf("VaultFileName(%q) = %q, a name Windows keeps for a device", name, file)
+		}
+		if len(file) > MaxVaultNameLength {
+			t.Errorf("VaultFileName(%q) is %d bytes long", name, len(file))
+		}
+	}
+}
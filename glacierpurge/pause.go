@@ -0,0 +1,82 @@
+package glacierpurge
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pause holds back the deletions of every client it's given to while it's
+// paused; deletions already in flight finish, and everything else, such as
+// polling jobs, carries on. It's safe for concurrent use.
+type Pause struct {
+	mu     sync.Mutex
+	paused chan struct{} // closed on resuming; nil while not paused
+	since  time.Time
+	total  time.Duration // spent paused before since
+}
+
+// NewPause returns a pause that isn't paused.
+func NewPause() *Pause {
+	return &Pause{}
+}
+
+// WithPause holds the client's deletions back while pause is paused. It's
+// meant to be shared by every client.
+func WithPause(pause *Pause) Option {
+	return func(o *options) {
+		o.pause = pause
+	}
+}
+
+// Toggle pauses deletions if they're running and resumes them if they're
+// paused, reporting whether they're now paused.
+func (p *Pause) Toggle() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused != nil {
+		close(p.paused)
+		p.paused = nil
+		p.total += time.Since(p.since)
+		return false
+	}
+	p.paused = make(chan struct{})
+	p.since = time.Now()
+	return true
+}
+
+// Paused reports whether deletions are paused, and if so since when.
+func (p *Pause) Paused() (bool, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused != nil, p.since
+}
+
+// Total returns how long deletions have been paused altogether, counting
+// the current pause so far.
+func (p *Pause) Total() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused != nil {
+		return p.total + time.Since(p.since)
+	}
+	return p.total
+}
+
+// wait returns once deletions aren't paused, or ctx ends or stop is closed.
+func (p *Pause) wait(ctx context.Context, stop <-chan struct{}) error {
+	p.mu.Lock()
+	paused := p.paused
+	p.mu.Unlock()
+	if paused == nil {
+		return nil
+	}
+	select {
+	case <-paused:
+		return nil
+	case <-stop:
+		return ErrWrappedUp
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
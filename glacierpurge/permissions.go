@@ -0,0 +1,78 @@
+package glacierpurge
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The IAM actions behind the calls the package makes, grouped by what
+// they're for. Every method of API is in exactly one Glacier group, which is
+// checked when the package starts, so a call can't be added without saying
+// what it needs.
+var (
+	// ActionsToList list and describe vaults; ListVaults has no resource of
+	// its own.
+	ActionsToList = []string{"glacier:ListVaults", "glacier:DescribeVault"}
+	// ActionsToReadTags read vaults' tags, for the tag filters.
+	ActionsToReadTags = []string{"glacier:ListTagsForVault"}
+	// ActionsToTag tag vaults as scheduled for deletion and untag them.
+	ActionsToTag = []string{"glacier:AddTagsToVault", "glacier:RemoveTagsFromVault"}
+	// ActionsToWatchJobs look up the jobs a vault has and how they're doing.
+	ActionsToWatchJobs = []string{"glacier:ListJobs", "glacier:DescribeJob"}
+	// ActionsToRetrieve start inventory and archive retrieval jobs and
+	// download their output.
+	ActionsToRetrieve = []string{"glacier:InitiateJob", "glacier:GetJobOutput"}
+	// ActionsToDeleteArchives delete archives.
+	ActionsToDeleteArchives = []string{"glacier:DeleteArchive"}
+	// ActionsToDeleteVaults delete emptied vaults.
+	ActionsToDeleteVaults = []string{"glacier:DeleteVault"}
+
+	// ActionsToFindRegions find the regions enabled for the account, when
+	// none are named; either one is enough. Neither has a resource.
+	ActionsToFindRegions = []string{"account:ListRegions", "ec2:DescribeRegions"}
+	// ActionsToSimulate ask IAM's policy simulator about the rest, for
+	// SimulateActions.
+	ActionsToSimulate = []string{"iam:SimulatePrincipalPolicy"}
+
+	// ActionsToUpload upload files to S3, in parts if they're big.
+	ActionsToUpload = []string{"s3:PutObject", "s3:AbortMultipartUpload"}
+	// ActionsToKeepObjects read, write and delete single objects in S3.
+	// Without s3:ListBucket on the bucket, S3 says a missing object is
+	// forbidden rather than missing.
+	ActionsToKeepObjects = []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:ListBucket"}
+)
+
+func init() {
+	groups := [][]string{ActionsToList, ActionsToReadTags, ActionsToTag, ActionsToWatchJobs, ActionsToRetrieve, ActionsToDeleteArchives, ActionsToDeleteVaults}
+	grouped := make(map[string]int)
+	for _, group := range groups {
+		for _, action := range group {
+			grouped[action]++
+		}
+	}
+	api := reflect.TypeOf((*API)(nil)).Elem()
+	for i := 0; i < api.NumMethod(); i++ {
+		action := "glacier:" + api.Method(i).Name
+		if grouped[action] != 1 {
+			panic(fmt.Sprintf("%s is in %d groups of actions, rather than one", action, grouped[action]))
+		}
+		delete(grouped, action)
+	}
+	if len(grouped) > 0 {
+		panic(fmt.Sprintf("actions grouped that aren't methods of API: %v", grouped))
+	}
+}
+
+// ARNs returns the ARNs of the location's bucket and of the objects under
+// it, in the partition given.
+func (l S3Location) ARNs(partition string) []string {
+	bucket := fmt.Sprintf("arn:%s:s3:::%s", partition, l.Bucket)
+	return []string{bucket, bucket + "/" + l.Prefix + "*"}
+}
+
+// VaultARN returns the ARN of the vault named name, in the partition,
+// region and account given. Any of these may be * to match every one, and
+// name may be a pattern IAM understands.
+func VaultARN(partition, region, account, name string) string {
+	return fmt.Sprintf("arn:%s:glacier:%s:%s:vaults/%s", partition, region, account, name)
+}
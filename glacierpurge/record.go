@@ -0,0 +1,339 @@
+package glacierpurge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// RecordingVersion is the format of the recordings written by this build.
+const RecordingVersion = 1
+
+// The files of a recording directory: the run's details, a JSON line per
+// call, and a file per job output body.
+const (
+	recordingFile = "recording.json"
+	callsFile     = "calls.jsonl"
+	bodiesDir     = "bodies"
+)
+
+// Recording describes a recorded run.
+type Recording struct {
+	Version int       `json:"version"`
+	Started time.Time `json:"started"`
+	Command string    `json:"command"`
+	Regions []string  `json:"regions,omitempty"`
+}
+
+// recordedCall is one Glacier call and what came back from it.
+type recordedCall struct {
+	Seq     int64           `json:"seq"`
+	Time    time.Time       `json:"time"`
+	Region  string          `json:"region"`
+	Op      string          `json:"op"`
+	Params  json.RawMessage `json:"params"`
+	Output  json.RawMessage `json:"output,omitempty"`
+	Headers http.Header     `json:"headers,omitempty"` // of the response, less anything secret
+	Status  int             `json:"status,omitempty"`
+	Err     *recordedError  `json:"error,omitempty"`
+
+	// GetJobOutput's body, in a file of its own under bodies.
+	Body       string `json:"body,omitempty"`
+	BodySize   int64  `json:"bodySize,omitempty"`
+	BodySHA256 string `json:"bodySha256,omitempty"`
+}
+
+// recordedError is enough of an error to return one that's handled the same
+// way when it's replayed.
+type recordedError struct {
+	Kind    string `json:"kind"` // api, send, canceled, deadline, read-only, or other
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Fault   string `json:"fault,omitempty"`
+	Text    string `json:"text"`
+}
+
+// secretHeaders are left out of recordings.
+var secretHeaders = []string{"Authorization", "X-Amz-Security-Token", "Set-Cookie", "Cookie"}
+
+// Recorder writes every Glacier call made through the clients it's given to
+// into a directory, for the run to be replayed offline. Requests are recorded
+// above the SDK, so they hold neither credentials nor signatures. It's safe
+// for concurrent use.
+type Recorder struct {
+	// Redact, if set, masks the caller's secrets in the calls recorded, such
+	// as one an error message repeats. Set it before the recorder is used.
+	Redact Redactor
+
+	dir string
+
+	mu        sync.Mutex
+	recording Recording
+	calls     *os.File
+	seq       int64
+	err       error // the first failure to write, reported by Close
+}
+
+// NewRecorder starts a recording of command in dir, which mustn't hold one
+// already.
+func NewRecorder(dir, command string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Join(dir, bodiesDir), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	calls, err := os.OpenFile(filepath.Join(dir, callsFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%s already holds a recording; record into an empty directory", dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	r := &Recorder{dir: dir, calls: calls, recording: Recording{Version: RecordingVersion, Started: time.Now().UTC(), Command: command}}
+	if err := r.writeRecording(); err != nil {
+		calls.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// WithRecorder records the client's calls in recorder, which is meant to be
+// shared by every client.
+func WithRecorder(recorder *Recorder) Option {
+	return func(o *options) {
+		o.recorder = recorder
+	}
+}
+
+// SetRegions records the regions the run scans, which a replay scans too.
+func (r *Recorder) SetRegions(regions []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Regions = regions
+	return r.writeRecording()
+}
+
+func (r *Recorder) writeRecording() error {
+	data, err := json.MarshalIndent(r.recording, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, recordingFile), append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
+
+// Close finishes the recording, returning the first error writing it met.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.calls.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// next numbers a call.
+func (r *Recorder) next() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	return r.seq
+}
+
+// write appends a call to the recording.
+func (r *Recorder) write(call *recordedCall) {
+	data, err := json.Marshal(call)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		_, err = r.calls.Write(append([]byte(r.Redact.redact(string(data))), '\n'))
+	}
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to record %s: %w", call.Op, err)
+	}
+}
+
+// operationName returns the name of the Glacier operation taking params.
+func operationName(params any) string {
+	return strings.TrimSuffix(reflect.TypeOf(params).Elem().Name(), "Input")
+}
+
+// newRecordedCall records a call's outcome, all but a job output's body. The
+// parameters are marshalled before the call, as the SDK fills some in.
+func (r *Recorder) newRecordedCall(region string, params any, marshalled []byte, out any, err error) *recordedCall {
+	call := &recordedCall{Seq: r.next(), Time: time.Now().UTC(), Region: region, Op: operationName(params), Params: marshalled}
+
+	var response *smithyhttp.Response
+	if err != nil {
+		call.Err = newRecordedError(err)
+		var responseErr *smithyhttp.ResponseError
+		if errors.As(err, &responseErr) {
+			response = responseErr.Response
+		}
+	} else {
+		if output, ok := out.(*glacier.GetJobOutputOutput); ok {
+			// The body is recorded on its own, as it's read.
+			copied := *output
+			copied.Body = nil
+			out = &copied
+		}
+		call.Output, _ = json.Marshal(out)
+		if metadata := reflect.ValueOf(out).Elem().FieldByName("ResultMetadata"); metadata.IsValid() {
+			response, _ = awsmiddleware.GetRawResponse(metadata.Interface().(middleware.Metadata)).(*smithyhttp.Response)
+		}
+	}
+	if response != nil {
+		call.Status = response.StatusCode
+		call.Headers = response.Header.Clone()
+		for _, name := range secretHeaders {
+			call.Headers.Del(name)
+		}
+	}
+	return call
+}
+
+func newRecordedError(err error) *recordedError {
+	e := &recordedError{Kind: "other", Text: err.Error()}
+	var apiErr smithy.APIError
+	var sendErr *smithyhttp.RequestSendError
+	switch {
+	case errors.Is(err, ErrReadOnly):
+		e.Kind = "read-only"
+	case errors.Is(err, context.Canceled):
+		e.Kind = "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Kind = "deadline"
+	case errors.As(err, &apiErr):
+		e.Kind, e.Code, e.Message, e.Fault = "api", apiErr.ErrorCode(), apiErr.ErrorMessage(), apiErr.ErrorFault().String()
+	case errors.As(err, &sendErr):
+		e.Kind = "send"
+	}
+	return e
+}
+
+// recordingAPI records every call, beneath the client's other wrappers.
+type recordingAPI struct {
+	API
+	recorder *Recorder
+	region   string
+}
+
+func recorded[In, Out any](ctx context.Context, a recordingAPI, call func(context.Context, In, ...func(*glacier.Options)) (Out, error), params In, optFns []func(*glacier.Options)) (Out, error) {
+	marshalled, _ := json.Marshal(params)
+	out, err := call(ctx, params, optFns...)
+	a.recorder.write(a.recorder.newRecordedCall(a.region, params, marshalled, out, err))
+	return out, err
+}
+
+func (a recordingAPI) ListVaults(ctx context.Context, params *glacier.ListVaultsInput, optFns ...func(*glacier.Options)) (*glacier.ListVaultsOutput, error) {
+	return recorded(ctx, a, a.API.ListVaults, params, optFns)
+}
+
+func (a recordingAPI) DescribeVault(ctx context.Context, params *glacier.DescribeVaultInput, optFns ...func(*glacier.Options)) (*glacier.DescribeVaultOutput, error) {
+	return recorded(ctx, a, a.API.DescribeVault, params, optFns)
+}
+
+func (a recordingAPI) ListTagsForVault(ctx context.Context, params *glacier.ListTagsForVaultInput, optFns ...func(*glacier.Options)) (*glacier.ListTagsForVaultOutput, error) {
+	return recorded(ctx, a, a.API.ListTagsForVault, params, optFns)
+}
+
+func (a recordingAPI) AddTagsToVault(ctx context.Context, params *glacier.AddTagsToVaultInput, optFns ...func(*glacier.Options)) (*glacier.AddTagsToVaultOutput, error) {
+	return recorded(ctx, a, a.API.AddTagsToVault, params, optFns)
+}
+
+func (a recordingAPI) RemoveTagsFromVault(ctx context.Context, params *glacier.RemoveTagsFromVaultInput, optFns ...func(*glacier.Options)) (*glacier.RemoveTagsFromVaultOutput, error) {
+	return recorded(ctx, a, a.API.RemoveTagsFromVault, params, optFns)
+}
+
+func (a recordingAPI) InitiateJob(ctx context.Context, params *glacier.InitiateJobInput, optFns ...func(*glacier.Options)) (*glacier.InitiateJobOutput, error) {
+	return recorded(ctx, a, a.API.InitiateJob, params, optFns)
+}
+
+func (a recordingAPI) DescribeJob(ctx context.Context, params *glacier.DescribeJobInput, optFns ...func(*glacier.Options)) (*glacier.DescribeJobOutput, error) {
+	return recorded(ctx, a, a.API.DescribeJob, params, optFns)
+}
+
+// GetJobOutput records its call once the body has been read and closed, with
+// the body in a file of its own.
+func (a recordingAPI) GetJobOutput(ctx context.Context, params *glacier.GetJobOutputInput, optFns ...func(*glacier.Options)) (*glacier.GetJobOutputOutput, error) {
+	marshalled, _ := json.Marshal(params)
+	out, err := a.API.GetJobOutput(ctx, params, optFns...)
+	call := a.recorder.newRecordedCall(a.region, params, marshalled, out, err)
+	if err != nil {
+		a.recorder.write(call)
+		return out, err
+	}
+	call.Body = filepath.Join(bodiesDir, fmt.Sprintf("%d", call.Seq))
+	file, err := os.OpenFile(filepath.Join(a.recorder.dir, call.Body), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		out.Body.Close()
+		return nil, fmt.Errorf("failed to record job output: %w", err)
+	}
+	out.Body = &recordingBody{ReadCloser: out.Body, file: file, sum: sha256.New(), call: call, recorder: a.recorder}
+	return out, nil
+}
+
+func (a recordingAPI) ListJobs(ctx context.Context, params *glacier.ListJobsInput, optFns ...func(*glacier.Options)) (*glacier.ListJobsOutput, error) {
+	return recorded(ctx, a, a.API.ListJobs, params, optFns)
+}
+
+func (a recordingAPI) DeleteArchive(ctx context.Context, params *glacier.DeleteArchiveInput, optFns ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error) {
+	return recorded(ctx, a, a.API.DeleteArchive, params, optFns)
+}
+
+func (a recordingAPI) DeleteVault(ctx context.Context, params *glacier.DeleteVaultInput, optFns ...func(*glacier.Options)) (*glacier.DeleteVaultOutput, error) {
+	return recorded(ctx, a, a.API.DeleteVault, params, optFns)
+}
+
+// recordingBody copies a job output's body to its file as it's read.
+type recordingBody struct {
+	io.ReadCloser
+	file     *os.File
+	sum      hash.Hash
+	size     int64
+	call     *recordedCall
+	recorder *Recorder
+	once     sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.file.Write(p[:n])
+		b.sum.Write(p[:n])
+		b.size += int64(n)
+	}
+	if err != nil && err != io.EOF {
+		// A body cut short is replayed cut short too.
+		b.call.Err = newRecordedError(err)
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.file.Close()
+		b.call.BodySize, b.call.BodySHA256 = b.size, hex.EncodeToString(b.sum.Sum(nil))
+		b.recorder.write(b.call)
+	})
+	return err
+}
@@ -0,0 +1,246 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/account"
+	accounttypes "github.com/aws/aws-sdk-go-v2/service/account/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+//go:generate go run gen_regions.go
+
+// partition is an AWS partition as the SDK's metadata describes it, with
+// what Glacier's endpoint metadata says of it. regions_gen.go lists them.
+type partition struct {
+	ID          string
+	RegionRegex *regexp.Regexp // the names its regions have, known yet or not
+	Regions     []string       // sorted
+	// Glacier is whether Glacier is offered in the partition, and
+	// GlacierFIPS the regions with a Glacier FIPS endpoint.
+	Glacier     bool
+	GlacierFIPS []string
+}
+
+// The partitions regions are chosen from by default, or opted in to.
+const (
+	PartitionAWS   = "aws"
+	PartitionGov   = "aws-us-gov"
+	PartitionChina = "aws-cn"
+)
+
+// Regions returns, in sorted order, the regions of the partition with the
+// given ID in which Glacier can be reached. Glacier's own endpoint list
+// stopped growing once endpoints moved to the partition defaults, so every
+// region of a partition that offers the service is included; partitions
+// without Glacier at all yield nothing.
+func Regions(id string) []string {
+	for _, partition := range partitions {
+		if partition.ID == id && partition.Glacier {
+			return append([]string(nil), partition.Regions...)
+		}
+	}
+	return nil
+}
+
+// KnownRegions returns every region the SDK knows about, across all partitions.
+func KnownRegions() map[string]bool {
+	known := make(map[string]bool)
+	for _, partition := range partitions {
+		for _, id := range partition.Regions {
+			known[id] = true
+		}
+	}
+	return known
+}
+
+// CheckRegion returns an error if the SDK doesn't know region, suggesting the
+// known region it's closest to when that's near enough to be a typo.
+func CheckRegion(region string) error {
+	known := KnownRegions()
+	if known[region] {
+		return nil
+	}
+	if closest := closestRegion(region, known); closest != "" {
+		return fmt.Errorf("unknown region %q (did you mean %s?)", region, closest)
+	}
+	return fmt.Errorf("unknown region %q", region)
+}
+
+// closestRegion returns the known region fewest edits away from region, or ""
+// if none is within a few edits. Ties go to the first alphabetically.
+func closestRegion(region string, known map[string]bool) string {
+	candidates := make([]string, 0, len(known))
+	for id := range known {
+		candidates = append(candidates, id)
+	}
+	sort.Strings(candidates)
+
+	closest, best := "", min(3, len(region)/2)+1
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(region), candidate); d < best {
+			closest, best = candidate, d
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// PartitionOf returns the ID of the partition region belongs to.
+func PartitionOf(region string) string {
+	if partition := partitionOf(region); partition != nil {
+		return partition.ID
+	}
+	return "unknown"
+}
+
+// partitionOf returns the partition that lists region, or failing that, the
+// first whose region names it fits, as a region newer than the metadata
+// does; nil if there's none.
+func partitionOf(region string) *partition {
+	for i := range partitions {
+		if slices.Contains(partitions[i].Regions, region) {
+			return &partitions[i]
+		}
+	}
+	for i := range partitions {
+		if partitions[i].RegionRegex.MatchString(region) {
+			return &partitions[i]
+		}
+	}
+	return nil
+}
+
+// EnabledRegions returns the regions enabled for the account the credentials
+// belong to. It asks account:ListRegions first and falls back to
+// ec2:DescribeRegions, which only reports enabled regions, if that's denied.
+func EnabledRegions(ctx context.Context, settings *ClientSettings) (map[string]bool, error) {
+	cfg, err := LoadConfig(ctx, "us-east-1", settings)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool)
+	paginator := account.NewListRegionsPaginator(account.NewFromConfig(cfg), &account.ListRegionsInput{
+		RegionOptStatusContains: []accounttypes.RegionOptStatus{
+			accounttypes.RegionOptStatusEnabled,
+			accounttypes.RegionOptStatusEnabledByDefault,
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return enabledRegionsFromEC2(ctx, cfg, err)
+		}
+		for _, region := range page.Regions {
+			enabled[aws.ToString(region.RegionName)] = true
+		}
+	}
+
+	return enabled, nil
+}
+
+func enabledRegionsFromEC2(ctx context.Context, cfg aws.Config, accountErr error) (map[string]bool, error) {
+	output, err := ec2.NewFromConfig(cfg).DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("account:ListRegions failed (%v) and ec2:DescribeRegions failed: %w", accountErr, err)
+	}
+
+	enabled := make(map[string]bool)
+	for _, region := range output.Regions {
+		enabled[aws.ToString(region.RegionName)] = true
+	}
+
+	return enabled, nil
+}
+
+// CallerPartition returns the partition of the identity the credentials
+// resolve to, as reported by STS in region.
+func CallerPartition(ctx context.Context, region string, settings *ClientSettings) (string, error) {
+	identity, err := callerIdentity(ctx, region, settings)
+	if err != nil {
+		return "", err
+	}
+	return identity.Partition, nil
+}
+
+// CallerAccount returns the ID of the account the credentials belong to, as
+// reported by STS in region.
+func CallerAccount(ctx context.Context, region string, settings *ClientSettings) (string, error) {
+	identity, err := callerIdentity(ctx, region, settings)
+	if err != nil {
+		return "", err
+	}
+	return identity.AccountID, nil
+}
+
+// CallerARN returns the ARN the credentials are identified by, as reported
+// by STS in region.
+func CallerARN(ctx context.Context, region string, settings *ClientSettings) (string, error) {
+	identity, err := callerIdentity(ctx, region, settings)
+	if err != nil {
+		return "", err
+	}
+	return identity.String(), nil
+}
+
+func callerIdentity(ctx context.Context, region string, settings *ClientSettings) (arn.ARN, error) {
+	cfg, err := LoadConfig(ctx, region, settings)
+	if err != nil {
+		return arn.ARN{}, err
+	}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return arn.ARN{}, err
+	}
+
+	parsed, err := arn.Parse(aws.ToString(identity.Arn))
+	if err != nil {
+		return arn.ARN{}, fmt.Errorf("failed to parse caller identity ARN: %w", err)
+	}
+	return parsed, nil
+}
+
+// IsUnrecognizedCredentials reports whether err is the authentication failure
+// AWS returns when the credentials don't exist in the region's partition.
+func IsUnrecognizedCredentials(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "UnrecognizedClientException", "InvalidClientTokenId":
+			return true
+		}
+	}
+	return false
+}
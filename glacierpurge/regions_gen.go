@@ -0,0 +1,57 @@
+// Code generated by gen_regions.go from aws-sdk-go-v2@v1.24.1 and glacier@v1.19.6; DO NOT EDIT.
+
+package glacierpurge
+
+import "regexp"
+
+var partitions = []partition{
+	{
+		ID:          "aws",
+		RegionRegex: regexp.MustCompile("^(us|eu|ap|sa|ca|me|af|il)\\-\\w+\\-\\d+$"),
+		Regions:     []string{"af-south-1", "ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-south-2", "ap-southeast-1", "ap-southeast-2", "ap-southeast-3", "ap-southeast-4", "ca-central-1", "ca-west-1", "eu-central-1", "eu-central-2", "eu-north-1", "eu-south-1", "eu-south-2", "eu-west-1", "eu-west-2", "eu-west-3", "il-central-1", "me-central-1", "me-south-1", "sa-east-1", "us-east-1", "us-east-2", "us-west-1", "us-west-2"},
+		Glacier:     true,
+		GlacierFIPS: []string{"ca-central-1", "us-east-1", "us-east-2", "us-west-1", "us-west-2"},
+	},
+	{
+		ID:          "aws-cn",
+		RegionRegex: regexp.MustCompile("^cn\\-\\w+\\-\\d+$"),
+		Regions:     []string{"cn-north-1", "cn-northwest-1"},
+		Glacier:     true,
+		GlacierFIPS: []string(nil),
+	},
+	{
+		ID:          "aws-us-gov",
+		RegionRegex: regexp.MustCompile("^us\\-gov\\-\\w+\\-\\d+$"),
+		Regions:     []string{"us-gov-east-1", "us-gov-west-1"},
+		Glacier:     true,
+		GlacierFIPS: []string{"us-gov-east-1", "us-gov-west-1"},
+	},
+	{
+		ID:          "aws-iso",
+		RegionRegex: regexp.MustCompile("^us\\-iso\\-\\w+\\-\\d+$"),
+		Regions:     []string{"us-iso-east-1", "us-iso-west-1"},
+		Glacier:     true,
+		GlacierFIPS: []string(nil),
+	},
+	{
+		ID:          "aws-iso-b",
+		RegionRegex: regexp.MustCompile("^us\\-isob\\-\\w+\\-\\d+$"),
+		Regions:     []string{"us-isob-east-1"},
+		Glacier:     true,
+		GlacierFIPS: []string(nil),
+	},
+	{
+		ID:          "aws-iso-e",
+		RegionRegex: regexp.MustCompile("^eu\\-isoe\\-\\w+\\-\\d+$"),
+		Regions:     []string(nil),
+		Glacier:     false,
+		GlacierFIPS: []string(nil),
+	},
+	{
+		ID:          "aws-iso-f",
+		RegionRegex: regexp.MustCompile("^us\\-isof\\-\\w+\\-\\d+$"),
+		Regions:     []string(nil),
+		Glacier:     false,
+		GlacierFIPS: []string(nil),
+	},
+}
@@ -0,0 +1,77 @@
+package glacierpurge
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegions(t *testing.T) {
+	aws := Regions(PartitionAWS)
+	// Regions the hand-kept list this replaced was missing.
+	for _, region := range []string{"us-east-1", "eu-central-2", "ap-south-2", "il-central-1", "me-central-1", "ca-west-1"} {
+		if !slices.Contains(aws, region) {
+			t.Errorf("%s isn't among the aws regions %v", region, aws)
+		}
+	}
+	for _, region := range aws {
+		if strings.HasSuffix(region, "-global") || strings.HasPrefix(region, "us-gov-") || strings.HasPrefix(region, "cn-") {
+			t.Errorf("%s is among the aws regions", region)
+		}
+	}
+	if !slices.IsSorted(aws) {
+		t.Errorf("the aws regions aren't sorted: %v", aws)
+	}
+	if got := Regions(PartitionGov); !slices.Equal(got, []string{"us-gov-east-1", "us-gov-west-1"}) {
+		t.Errorf("got GovCloud regions %v", got)
+	}
+	if got := Regions(PartitionChina); !slices.Equal(got, []string{"cn-north-1", "cn-northwest-1"}) {
+		t.Errorf("got China regions %v", got)
+	}
+	if got := Regions("aws-iso-f"); got != nil {
+		t.Errorf("got regions %v of a partition without Glacier", got)
+	}
+}
+
+func TestPartitionOf(t *testing.T) {
+	for region, want := range map[string]string{
+		"us-east-1":      "aws",
+		"il-central-1":   "aws",
+		"eu-west-9":      "aws", // newer than the metadata, but named as its regions are
+		"us-gov-west-1":  "aws-us-gov",
+		"cn-northwest-1": "aws-cn",
+		"us-isob-east-1": "aws-iso-b",
+		"moon-base-1":    "unknown",
+	} {
+		if got := PartitionOf(region); got != want {
+			t.Errorf("PartitionOf(%q) = %q, want %q", region, got, want)
+		}
+	}
+}
+
+func TestHasFIPSEndpoint(t *testing.T) {
+	for region, want := range map[string]bool{
+		"us-east-1":     true,
+		"ca-central-1":  true,
+		"us-gov-east-1": true,
+		"eu-west-1":     false,
+		"cn-north-1":    false,
+		"moon-base-1":   false,
+	} {
+		if got := HasFIPSEndpoint(region); got != want {
+			t.Errorf("HasFIPSEndpoint(%q) = %t, want %t", region, got, want)
+		}
+	}
+}
+
+func TestCheckRegion(t *testing.T) {
+	if err := CheckRegion("me-central-1"); err != nil {
+		t.Errorf("me-central-1: %v", err)
+	}
+	if err := CheckRegion("us-esat-1"); err == nil || !strings.Contains(err.Error(), "did you mean us-east-1?") {
+		t.Errorf("us-esat-1: got %v, want a suggestion of us-east-1", err)
+	}
+	if err := CheckRegion("moon-base-1"); err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("moon-base-1: got %v, want it unknown without a suggestion", err)
+	}
+}
@@ -0,0 +1,38 @@
+package glacierpurge
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Registry owns a single Glacier client per region. Vaults discovered in a
+// region keep a reference to the client they were found with, so the same
+// client is used for every later operation against them. It's safe for
+// concurrent use.
+type Registry struct {
+	Options []Option // applied to every client the registry creates
+	clients map[string]*Glacier
+	mu      sync.Mutex
+}
+
+// Get returns the Glacier client for region, creating it on first use.
+func (r *Registry) Get(ctx context.Context, region string) (*Glacier, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if g, ok := r.clients[region]; ok {
+		return g, nil
+	}
+
+	g, err := New(ctx, region, r.Options...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Glacier client for region %s: %w", region, err)
+	}
+
+	if r.clients == nil {
+		r.clients = make(map[string]*Glacier)
+	}
+	r.clients[region] = g
+	return g, nil
+}
@@ -0,0 +1,286 @@
+package glacierpurge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// ErrNotRecorded is returned during a replay for a call the recording has
+// no answer to, because the replayed run has strayed from the recorded one.
+var ErrNotRecorded = errors.New("no recorded response to replay")
+
+// Replay answers the clients it's given to from a recording, so a recorded
+// run can be made again offline, failing where it failed. Each call gets the
+// response recorded for the same call with the same parameters in the same
+// region, in the order they were recorded, and its waits are over at once.
+// It's safe for concurrent use.
+type Replay struct {
+	Recording Recording
+
+	dir   string
+	clock *replayClock
+
+	mu    sync.Mutex
+	calls map[string][]*recordedCall // by region, operation and parameters
+}
+
+// OpenReplay loads the recording in dir.
+func OpenReplay(dir string) (*Replay, error) {
+	data, err := os.ReadFile(filepath.Join(dir, recordingFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	r := &Replay{dir: dir, calls: map[string][]*recordedCall{}}
+	if err := json.Unmarshal(data, &r.Recording); err != nil {
+		return nil, fmt.Errorf("failed to parse recording %s: %w", dir, err)
+	}
+	if r.Recording.Version > RecordingVersion {
+		return nil, fmt.Errorf("recording %s has format version %d; this ice-breaker reads up to version %d", dir, r.Recording.Version, RecordingVersion)
+	}
+
+	file, err := os.Open(filepath.Join(dir, callsFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	defer file.Close()
+	var calls []*recordedCall
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		call := &recordedCall{}
+		if err := json.Unmarshal(scanner.Bytes(), call); err != nil {
+			return nil, fmt.Errorf("failed to parse recording %s, line %d: %w", dir, line, err)
+		}
+		calls = append(calls, call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	// Job outputs are written once their bodies are closed, out of turn.
+	sort.SliceStable(calls, func(i, j int) bool { return calls[i].Seq < calls[j].Seq })
+	for _, call := range calls {
+		key := replayKey(call.Region, call.Op, call.Params)
+		r.calls[key] = append(r.calls[key], call)
+	}
+	r.clock = &replayClock{now: r.Recording.Started}
+	return r, nil
+}
+
+// WithReplay answers the client's calls from replay instead of AWS, and has
+// it wait by the replay's clock. It's meant to be shared by every client.
+func WithReplay(replay *Replay) Option {
+	return func(o *options) {
+		o.replay = replay
+	}
+}
+
+// Clock returns the replay's clock, which starts when the recording did and
+// moves forward by however long each wait is, at once, so a replay runs the
+// same whatever the time.
+func (r *Replay) Clock() Clock {
+	return r.clock
+}
+
+// Unused returns how many recorded calls weren't replayed.
+func (r *Replay) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, calls := range r.calls {
+		n += len(calls)
+	}
+	return n
+}
+
+func replayKey(region, op string, params []byte) string {
+	return region + "\x00" + op + "\x00" + string(params)
+}
+
+// take returns the next recorded call matching this one.
+func (r *Replay) take(region string, params any) (*recordedCall, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	op := operationName(params)
+	key := replayKey(region, op, data)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.calls[key]
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("%w for %s in region %s with %s", ErrNotRecorded, op, region, data)
+	}
+	call := calls[0]
+	r.calls[key] = calls[1:]
+	r.clock.reach(call.Time)
+	return call, nil
+}
+
+// err returns an error handled the same way as the recorded one.
+func (e *recordedError) err(op string, status int) error {
+	switch e.Kind {
+	case "api":
+		fault := smithy.FaultUnknown
+		switch e.Fault {
+		case smithy.FaultClient.String():
+			fault = smithy.FaultClient
+		case smithy.FaultServer.String():
+			fault = smithy.FaultServer
+		}
+		apiErr := &smithy.GenericAPIError{Code: e.Code, Message: e.Message, Fault: fault}
+		response := &smithyhttp.Response{Response: &http.Response{StatusCode: status, Header: http.Header{}}}
+		return &smithy.OperationError{ServiceID: "Glacier", OperationName: op, Err: &smithyhttp.ResponseError{Response: response, Err: apiErr}}
+	case "send":
+		return &smithy.OperationError{ServiceID: "Glacier", OperationName: op, Err: &smithyhttp.RequestSendError{Err: errors.New(e.Text)}}
+	case "read-only":
+		return &GuardError{Op: op}
+	case "canceled":
+		return context.Canceled
+	case "deadline":
+		return context.DeadlineExceeded
+	}
+	return errors.New(e.Text)
+}
+
+// replayAPI answers from a Replay for one region.
+type replayAPI struct {
+	replay *Replay
+	region string
+}
+
+func replayed[Out any](a replayAPI, params any) (*Out, error) {
+	out, _, err := replayedCall[Out](a, params)
+	return out, err
+}
+
+// replayedCall also returns the call replayed.
+func replayedCall[Out any](a replayAPI, params any) (*Out, *recordedCall, error) {
+	call, err := a.replay.take(a.region, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if call.Err != nil && call.Body == "" {
+		return nil, nil, call.Err.err(call.Op, call.Status)
+	}
+	out := new(Out)
+	if err := json.Unmarshal(call.Output, out); err != nil {
+		return nil, nil, fmt.Errorf("failed to replay %s: %w", call.Op, err)
+	}
+	return out, call, nil
+}
+
+func (a replayAPI) ListVaults(ctx context.Context, params *glacier.ListVaultsInput, optFns ...func(*glacier.Options)) (*glacier.ListVaultsOutput, error) {
+	return replayed[glacier.ListVaultsOutput](a, params)
+}
+
+func (a replayAPI) DescribeVault(ctx context.Context, params *glacier.DescribeVaultInput, optFns ...func(*glacier.Options)) (*glacier.DescribeVaultOutput, error) {
+	return replayed[glacier.DescribeVaultOutput](a, params)
+}
+
+func (a replayAPI) ListTagsForVault(ctx context.Context, params *glacier.ListTagsForVaultInput, optFns ...func(*glacier.Options)) (*glacier.ListTagsForVaultOutput, error) {
+	return replayed[glacier.ListTagsForVaultOutput](a, params)
+}
+
+func (a replayAPI) AddTagsToVault(ctx context.Context, params *glacier.AddTagsToVaultInput, optFns ...func(*glacier.Options)) (*glacier.AddTagsToVaultOutput, error) {
+	return replayed[glacier.AddTagsToVaultOutput](a, params)
+}
+
+func (a replayAPI) RemoveTagsFromVault(ctx context.Context, params *glacier.RemoveTagsFromVaultInput, optFns ...func(*glacier.Options)) (*glacier.RemoveTagsFromVaultOutput, error) {
+	return replayed[glacier.RemoveTagsFromVaultOutput](a, params)
+}
+
+func (a replayAPI) InitiateJob(ctx context.Context, params *glacier.InitiateJobInput, optFns ...func(*glacier.Options)) (*glacier.InitiateJobOutput, error) {
+	return replayed[glacier.InitiateJobOutput](a, params)
+}
+
+func (a replayAPI) DescribeJob(ctx context.Context, params *glacier.DescribeJobInput, optFns ...func(*glacier.Options)) (*glacier.DescribeJobOutput, error) {
+	return replayed[glacier.DescribeJobOutput](a, params)
+}
+
+// GetJobOutput replays the recorded body, cut short where it was.
+func (a replayAPI) GetJobOutput(ctx context.Context, params *glacier.GetJobOutputInput, optFns ...func(*glacier.Options)) (*glacier.GetJobOutputOutput, error) {
+	out, call, err := replayedCall[glacier.GetJobOutputOutput](a, params)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(a.replay.dir, call.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay job output: %w", err)
+	}
+	out.Body = file
+	if call.Err != nil {
+		out.Body = &replayedBody{file, call.Err.err(call.Op, call.Status)}
+	}
+	return out, nil
+}
+
+func (a replayAPI) ListJobs(ctx context.Context, params *glacier.ListJobsInput, optFns ...func(*glacier.Options)) (*glacier.ListJobsOutput, error) {
+	return replayed[glacier.ListJobsOutput](a, params)
+}
+
+func (a replayAPI) DeleteArchive(ctx context.Context, params *glacier.DeleteArchiveInput, optFns ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error) {
+	return replayed[glacier.DeleteArchiveOutput](a, params)
+}
+
+func (a replayAPI) DeleteVault(ctx context.Context, params *glacier.DeleteVaultInput, optFns ...func(*glacier.Options)) (*glacier.DeleteVaultOutput, error) {
+	return replayed[glacier.DeleteVaultOutput](a, params)
+}
+
+// replayedBody ends a body the way the recorded one ended.
+type replayedBody struct {
+	*os.File
+	err error
+}
+
+func (b *replayedBody) Read(p []byte) (int, error) {
+	n, err := b.File.Read(p)
+	if err == io.EOF {
+		err = b.err
+	}
+	return n, err
+}
+
+// replayClock is a Clock whose waits are over at once, moving it forward.
+type replayClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *replayClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *replayClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	ch <- now
+	return ch
+}
+
+// reach moves the clock forward to t, if it's behind.
+func (c *replayClock) reach(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
@@ -0,0 +1,170 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/rdegges/ice-breaker/internal/s3object"
+)
+
+// s3PartSize is the size of the parts files bigger than it are uploaded in.
+const s3PartSize = 64 << 20
+
+// S3Location is a bucket, and a prefix for the keys written to it.
+type S3Location struct {
+	Bucket string
+	Prefix string // ends in / unless it's empty
+}
+
+// ParseS3URI parses a URI such as s3://bucket/prefix/.
+func ParseS3URI(uri string) (S3Location, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return S3Location{}, fmt.Errorf("invalid S3 URI %q: must look like s3://bucket/prefix/", uri)
+	}
+	prefix := strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return S3Location{Bucket: u.Host, Prefix: prefix}, nil
+}
+
+// URI returns the s3:// URI of key in the location.
+func (l S3Location) URI(key string) string {
+	return "s3://" + l.Bucket + "/" + l.Prefix + key
+}
+
+// S3UploadOptions say how uploaded objects are encrypted at rest. Left
+// empty, the bucket's default encryption applies.
+type S3UploadOptions struct {
+	ServerSideEncryption string // AES256, aws:kms, or aws:kms:dsse
+	KMSKeyID             string // for aws:kms, the key to encrypt with
+}
+
+// Uploader writes files to S3, and reads and writes single objects, with an
+// S3 client configured like every other client. It's safe for concurrent
+// use.
+type Uploader struct {
+	Location S3Location
+	Options  S3UploadOptions
+	// Limiter paces the uploader's calls, and its retries come out of the
+	// limiter's budget, if set. Set it before the uploader is first used.
+	Limiter *Limiter
+
+	settings *ClientSettings
+
+	mu       sync.Mutex
+	region   string // the bucket's; found by asking S3 if empty
+	client   *s3.Client
+	endpoint string // where requests are sent instead of AWS, for tests
+}
+
+// NewUploader returns an uploader to location, in the bucket's region if it's
+// given.
+func NewUploader(settings *ClientSettings, location S3Location, region string, opts S3UploadOptions) *Uploader {
+	return &Uploader{Location: location, Options: opts, region: region, settings: settings}
+}
+
+// Upload writes the file at path to key under the location's prefix, in
+// parts if it's big, and returns its s3:// URI. A multipart upload that
+// fails is aborted, so S3 doesn't keep charging for the parts already up.
+func (u *Uploader) Upload(ctx context.Context, key, path string) (string, error) {
+	client, err := u.s3Client(ctx)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	input := &s3.PutObjectInput{Bucket: aws.String(u.Location.Bucket), Key: aws.String(u.Location.Prefix + key), Body: file}
+	u.encrypt(input)
+	uploader := manager.NewUploader(client, func(m *manager.Uploader) {
+		m.PartSize = s3PartSize
+	})
+	if _, err := uploader.Upload(ctx, input); err != nil {
+		return "", &S3Error{Err: err}
+	}
+	return u.Location.URI(key), nil
+}
+
+// encrypt gives an object the encryption options.
+func (u *Uploader) encrypt(input *s3.PutObjectInput) {
+	if u.Options.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(u.Options.ServerSideEncryption)
+		if u.Options.KMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(u.Options.KMSKeyID)
+		}
+	}
+}
+
+// s3Client returns the client for the bucket's region, constructing it on
+// first use, once it has asked S3 for the region if it wasn't given.
+func (u *Uploader) s3Client(ctx context.Context) (*s3.Client, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.client != nil {
+		return u.client, nil
+	}
+
+	if u.region == "" {
+		lookup, err := u.newClient(ctx, "us-east-1")
+		if err != nil {
+			return nil, err
+		}
+		// S3 says which region the bucket is in even as it turns the
+		// request down.
+		region, err := manager.GetBucketRegion(ctx, lookup, u.Location.Bucket)
+		var notFound manager.BucketNotFound
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("bucket %s doesn't exist", u.Location.Bucket)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find bucket %s's region: %w; give it with the bucket's region", u.Location.Bucket, err)
+		}
+		u.region = region
+	}
+
+	client, err := u.newClient(ctx, u.region)
+	if err != nil {
+		return nil, err
+	}
+	u.client = client
+	return client, nil
+}
+
+func (u *Uploader) newClient(ctx context.Context, region string) (*s3.Client, error) {
+	cfg, err := LoadConfig(ctx, region, u.settings)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg, func(opts *s3.Options) {
+		if u.endpoint != "" {
+			opts.BaseEndpoint = aws.String(u.endpoint)
+			opts.UsePathStyle = true
+		}
+		if u.Limiter != nil {
+			opts.APIOptions = append(opts.APIOptions, u.Limiter.middleware)
+			opts.Retryer = retry.NewStandard(func(so *retry.StandardOptions) {
+				so.RateLimiter = u.Limiter.retries
+			})
+		}
+	}), nil
+}
+
+// S3Error is an error from S3. One for a missing object wraps
+// fs.ErrNotExist, and one for a write whose condition failed ErrS3Conflict.
+type S3Error = s3object.Error
@@ -0,0 +1,122 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is just enough of S3 for the uploader: objects by path, with ETags,
+// and conditional writes.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+	etags   map[string]string
+	headers []http.Header // of each PUT
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[r.URL.Path]
+	switch r.Method {
+	case http.MethodGet:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		w.Header().Set("ETag", f.etags[r.URL.Path])
+		io.WriteString(w, data)
+	case http.MethodPut:
+		f.headers = append(f.headers, r.Header.Clone())
+		if match := r.Header.Get("If-Match"); (match != "" && match != f.etags[r.URL.Path]) || (r.Header.Get("If-None-Match") == "*" && ok) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			io.WriteString(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(body)
+		f.etags[r.URL.Path] = `"` + string(rune('a'+len(f.headers))) + `"`
+		w.Header().Set("ETag", f.etags[r.URL.Path])
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		delete(f.etags, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestUploader(t *testing.T, opts S3UploadOptions) (*Uploader, *fakeS3) {
+	fake := &fakeS3{objects: map[string]string{}, etags: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	u := NewUploader(&ClientSettings{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, S3Location{Bucket: "bucket", Prefix: "runs/"}, "us-east-1", opts)
+	u.endpoint = server.URL
+	return u, fake
+}
+
+func TestUploaderObjects(t *testing.T) {
+	ctx := context.Background()
+	u, _ := newTestUploader(t, S3UploadOptions{})
+	u.Limiter = NewLimiter(1000)
+
+	if _, _, err := u.GetObject(ctx, "state.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("reading a missing object: got %v, want fs.ErrNotExist", err)
+	}
+	etag, err := u.PutObject(ctx, "state.json", []byte("one"), S3Condition{IfNoneExisted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.PutObject(ctx, "state.json", []byte("two"), S3Condition{IfNoneExisted: true}); !errors.Is(err, ErrS3Conflict) {
+		t.Errorf("creating an existing object: got %v, want ErrS3Conflict", err)
+	}
+	if _, err := u.PutObject(ctx, "state.json", []byte("two"), S3Condition{IfMatch: `"stale"`}); !errors.Is(err, ErrS3Conflict) {
+		t.Errorf("replacing a changed object: got %v, want ErrS3Conflict", err)
+	}
+	if _, err := u.PutObject(ctx, "state.json", []byte("two"), S3Condition{IfMatch: etag}); err != nil {
+		t.Errorf("replacing an unchanged object: %v", err)
+	}
+	data, _, err := u.GetObject(ctx, "state.json")
+	if err != nil || string(data) != "two" {
+		t.Errorf("read %q, %v; want two", data, err)
+	}
+	if err := u.DeleteObject(ctx, "state.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := u.GetObject(ctx, "state.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("reading a deleted object: got %v, want fs.ErrNotExist", err)
+	}
+	if u.Limiter.calls != 8 {
+		t.Errorf("the limiter saw %d calls, want 8", u.Limiter.calls)
+	}
+}
+
+func TestUploaderUpload(t *testing.T) {
+	u, fake := newTestUploader(t, S3UploadOptions{ServerSideEncryption: "aws:kms", KMSKeyID: "key-1"})
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	if err := os.WriteFile(path, []byte(`{"ArchiveList":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := u.Upload(context.Background(), "purge-1/inventory.json", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri != "s3://bucket/runs/purge-1/inventory.json" {
+		t.Errorf("uploaded to %s", uri)
+	}
+	if got := fake.objects["/bucket/runs/purge-1/inventory.json"]; got != `{"ArchiveList":[]}` {
+		t.Errorf("uploaded %q", got)
+	}
+	header := fake.headers[0]
+	if header.Get("X-Amz-Server-Side-Encryption") != "aws:kms" || header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != "key-1" {
+		t.Errorf("uploaded without the encryption options: %v", header)
+	}
+}
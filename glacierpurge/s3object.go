@@ -0,0 +1,57 @@
+package glacierpurge
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/rdegges/ice-breaker/internal/s3object"
+)
+
+// ErrS3Conflict is wrapped by the error for a conditional write to S3 whose
+// condition failed, because another writer got to the object first.
+var ErrS3Conflict = s3object.ErrConflict
+
+// S3Condition makes a write to S3 conditional on the object it replaces, so
+// two writers can't overwrite each other unawares. The zero value writes
+// regardless.
+type S3Condition = s3object.Condition
+
+// GetObject reads the object at key under the location's prefix, returning
+// its contents and ETag. A missing object's error wraps fs.ErrNotExist.
+func (u *Uploader) GetObject(ctx context.Context, key string) ([]byte, string, error) {
+	client, err := u.s3Client(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return s3object.Get(ctx, client, u.Location.Bucket, u.Location.Prefix+key)
+}
+
+// PutObject writes data to key under the location's prefix, if cond holds,
+// and returns the new object's ETag. A write whose condition failed returns
+// an error wrapping ErrS3Conflict.
+func (u *Uploader) PutObject(ctx context.Context, key string, data []byte, cond S3Condition) (string, error) {
+	client, err := u.s3Client(ctx)
+	if err != nil {
+		return "", err
+	}
+	input := &s3.PutObjectInput{Bucket: aws.String(u.Location.Bucket), Key: aws.String(u.Location.Prefix + key)}
+	u.encrypt(input)
+	return s3object.Put(ctx, client, input, data, cond)
+}
+
+// DeleteObject deletes the object at key under the location's prefix. S3
+// doesn't mind if it's already gone.
+func (u *Uploader) DeleteObject(ctx context.Context, key string) error {
+	client, err := u.s3Client(ctx)
+	if err != nil {
+		return err
+	}
+	return s3object.Delete(ctx, client, u.Location.Bucket, u.Location.Prefix+key)
+}
+
+// URI returns the s3:// URI of key under the location's prefix.
+func (u *Uploader) URI(key string) string {
+	return u.Location.URI(key)
+}
@@ -0,0 +1,311 @@
+package glacierpurge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const manifestName = "manifest.json"
+
+// SalvageOptions controls Salvage.
+type SalvageOptions struct {
+	Dir  string // where the archives and the manifest are written
+	Tier string // retrieval tier; defaults to Bulk, the cheapest
+	// TierFallback retries Expedited retrievals on the Standard tier when
+	// Glacier has no Expedited capacity.
+	TierFallback bool
+	Concurrency  int // archives retrieved at once; defaults to 4
+	Wait         WaitOptions
+}
+
+// ManifestEntry records the salvage of one archive.
+type ManifestEntry struct {
+	ArchiveId   string `json:"archiveId"`
+	Description string `json:"description,omitempty"`
+	File        string `json:"file"` // relative to the salvage directory
+	Size        int64  `json:"size"`
+	TreeHash    string `json:"treeHash,omitempty"`
+	JobId       string `json:"jobId,omitempty"`
+	Verified    bool   `json:"verified"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ManifestVersion is the salvage manifest format written by this build.
+const ManifestVersion = 2
+
+// Manifest lists what Salvage has downloaded into a directory. It's saved
+// after every change, so an interrupted salvage picks up where it stopped.
+type Manifest struct {
+	Version  int                       `json:"version"`
+	Archives map[string]*ManifestEntry `json:"archives"`
+
+	path string
+	mu   sync.Mutex
+}
+
+func loadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{Archives: make(map[string]*ManifestEntry), path: filepath.Join(dir, manifestName)}
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read salvage manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse salvage manifest %s: %w", m.path, err)
+	}
+	// Version 1 differs only in not recording its version.
+	if m.Version > ManifestVersion {
+		return nil, fmt.Errorf("salvage manifest %s has format version %d, from a newer ice-breaker; this one reads up to version %d, so upgrade it to carry on salvaging", m.path, m.Version, ManifestVersion)
+	}
+	m.Version = ManifestVersion
+	if m.Archives == nil {
+		m.Archives = make(map[string]*ManifestEntry)
+	}
+	return m, nil
+}
+
+// update applies fn to the manifest and saves it.
+func (m *Manifest) update(fn func()) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fn()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write salvage manifest: %w", err)
+	}
+	return nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// salvageFileName names an archive's file after its description, which is
+// usually the original file name, made unique with the start of its ID.
+func salvageFileName(archive *Archive) string {
+	sum := sha256.Sum256([]byte(archive.Id))
+	id := hex.EncodeToString(sum[:6])
+
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(filepath.Base(archive.Description), "_"), "._")
+	if name == "" {
+		return id
+	}
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return name + "." + id
+}
+
+// Salvage downloads every archive into opts.Dir and verifies each against its
+// tree hash, recording the results in a manifest there. Archives the manifest
+// already shows as verified are skipped, and partial downloads are resumed. It
+// returns an error unless every archive was salvaged, in which case it's safe
+// to delete them.
+func Salvage(ctx context.Context, archives []*Archive, opts SalvageOptions) (*Manifest, error) {
+	return salvage(ctx, func(emit func(*Archive) error) error {
+		for _, archive := range archives {
+			if err := emit(archive); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, opts)
+}
+
+// Salvage saves the completed job's inventory into opts.Dir, then salvages
+// every archive in it as Salvage does, reading the saved copy as it goes
+// rather than all of it first, so a vault of millions of archives takes no
+// more memory than one of a few. Filter, if set, leaves out the archives it
+// returns false for. It returns the saved inventory's path, for the archives
+// to be deleted from once they're salvaged: Glacier may have let go of the
+// job's output by the time the retrievals are through.
+func (j *InventoryJob) Salvage(ctx context.Context, filter func(*Archive) bool, opts SalvageOptions) (string, error) {
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create salvage directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(j.Id))
+	path := filepath.Join(opts.Dir, "inventory."+hex.EncodeToString(sum[:6]))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := j.Download(ctx, f, DownloadOptions{}); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	// An archive listed twice mustn't be downloaded by two goroutines at
+	// once, so one whose ID's fingerprint was seen waits for a second pass,
+	// once the first is through. By then the manifest, which goes by the
+	// whole ID, has it if it was listed again, and it's passed over; if it
+	// only shared a fingerprint, it's salvaged then.
+	seen := NewArchiveSet()
+	var again []*Archive
+	_, err = salvage(ctx, func(emit func(*Archive) error) error {
+		return StreamInventory(f, j.Vault, func(archive *Archive) error {
+			if filter != nil && !filter(archive) {
+				return nil
+			}
+			if !seen.Add(archive.Id) {
+				again = append(again, archive)
+				return nil
+			}
+			return emit(archive)
+		})
+	}, opts)
+	if err == nil && len(again) > 0 {
+		_, err = Salvage(ctx, again, opts)
+	}
+	return path, err
+}
+
+// salvage salvages the archives produce emits, a few at a time.
+func salvage(ctx context.Context, produce func(emit func(*Archive) error) error, opts SalvageOptions) (*Manifest, error) {
+	if opts.Tier == "" {
+		opts.Tier = TierBulk
+	}
+	if err := ValidateTier(opts.Tier); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create salvage directory: %w", err)
+	}
+	manifest, err := loadManifest(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	var (
+		wg            sync.WaitGroup
+		mu            sync.Mutex
+		total, failed int
+		slots         = make(chan struct{}, concurrency)
+	)
+	err = produce(func(archive *Archive) error {
+		total++
+		manifest.mu.Lock()
+		entry := manifest.Archives[archive.Id]
+		manifest.mu.Unlock()
+		if entry != nil && entry.Verified {
+			return nil
+		}
+		if entry == nil {
+			entry = &ManifestEntry{ArchiveId: archive.Id, Description: archive.Description, File: salvageFileName(archive), Size: archive.Size}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := salvageArchive(ctx, archive, entry, manifest, opts); err != nil {
+				archive.Vault.Glacier.Logger.Printf("Failed to salvage archive %s: %v", archive.Id, err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}()
+		return nil
+	})
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return manifest, ctx.Err()
+	}
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	if failed > 0 {
+		return manifest, fmt.Errorf("failed to salvage %d of %d archives", failed, total)
+	}
+	return manifest, nil
+}
+
+func salvageArchive(ctx context.Context, archive *Archive, entry *ManifestEntry, manifest *Manifest, opts SalvageOptions) error {
+	path := filepath.Join(opts.Dir, entry.File)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	download := func(jobId string, offset int64) (*DownloadResult, error) {
+		return archive.Download(ctx, f, DownloadOptions{
+			Tier:         opts.Tier,
+			TierFallback: opts.TierFallback,
+			JobId:        jobId,
+			Offset:       offset,
+			Partial:      io.NewSectionReader(f, 0, offset),
+			Wait:         opts.Wait,
+			Initiated: func(jobId string) {
+				manifest.update(func() {
+					entry.JobId = jobId
+					manifest.Archives[entry.ArchiveId] = entry
+				})
+			},
+		})
+	}
+
+	result, err := download(entry.JobId, offset)
+	if err != nil && entry.JobId != "" && isNotFound(err) {
+		// The recorded job has expired; start over with a new one.
+		archive.Vault.Glacier.Logger.Printf("Archive retrieval job %s for archive %s has expired; starting a new one", entry.JobId, archive.Id)
+		result, err = download("", result.Written)
+	}
+	if err == nil && archive.TreeHash != "" && result.TreeHash != archive.TreeHash {
+		err = fmt.Errorf("%w: the inventory lists tree hash %s, got %s", ErrChecksumMismatch, archive.TreeHash, result.TreeHash)
+	}
+
+	if errors.Is(err, ErrChecksumMismatch) {
+		// Resuming would only extend the bad bytes; start the file over.
+		f.Truncate(0)
+	}
+
+	return errors.Join(err, manifest.update(func() {
+		entry.JobId = result.JobId
+		if result.Size > 0 {
+			entry.Size = result.Size
+		}
+		entry.TreeHash = result.TreeHash
+		entry.Verified = err == nil
+		entry.Error = ""
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		manifest.Archives[entry.ArchiveId] = entry
+	}))
+}
@@ -0,0 +1,166 @@
+package glacierpurge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// soakArchives is how many archives the soak test's inventory lists, and
+// soakPeakRSS the most memory the process may hold at once while it deletes
+// them: a fraction of what holding the inventory's archives alone would take.
+const (
+	soakArchives = 2_000_000
+	soakPeakRSS  = 160 << 20
+)
+
+// soakInventory is a JSON inventory of n archives, generated as it's read so
+// it takes no memory of its own.
+type soakInventory struct {
+	n, next int
+	buf     bytes.Buffer
+}
+
+func newSoakInventory(n int) *soakInventory {
+	r := &soakInventory{n: n}
+	fmt.Fprintf(&r.buf, `{"VaultARN":"arn:aws:glacier:us-east-1:123456789012:vaults/soak","InventoryDate":%q,"ArchiveList":[`, testStart.Format(time.RFC3339))
+	return r
+}
+
+func (r *soakInventory) Read(p []byte) (int, error) {
+	for r.buf.Len() < len(p) && r.next <= r.n {
+		if r.next == r.n {
+			r.buf.WriteString("]}")
+		} else {
+			if r.next > 0 {
+				r.buf.WriteByte(',')
+			}
+			entry, _ := json.Marshal(inventoryEntry{
+				ArchiveId:          soakArchiveId(r.next),
+				ArchiveDescription: fmt.Sprintf(`{"path":"backups/2019/%07d.tar.gz","type":"file"}`, r.next),
+				CreationDate:       testStart.Add(-time.Duration(r.next) * time.Second).Format(time.RFC3339),
+				Size:               4096,
+				SHA256TreeHash:     fmt.Sprintf("%064x", r.next),
+			})
+			r.buf.Write(entry)
+		}
+		r.next++
+	}
+	if r.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return r.buf.Read(p)
+}
+
+// soakArchiveId is the ID of the inventory's i'th archive, as long as
+// Glacier's are.
+func soakArchiveId(i int) string {
+	return fmt.Sprintf("%0138d", i)
+}
+
+// soakAPI serves the soak inventory, and deletes every archive but one in a
+// thousand, which it fails to.
+type soakAPI struct {
+	API
+	n int
+}
+
+func (a soakAPI) DescribeVault(ctx context.Context, params *glacier.DescribeVaultInput, optFns ...func(*glacier.Options)) (*glacier.DescribeVaultOutput, error) {
+	return &glacier.DescribeVaultOutput{VaultName: params.VaultName, NumberOfArchives: int64(a.n), CreationDate: aws.String(testStart.Format(time.RFC3339))}, nil
+}
+
+func (a soakAPI) GetJobOutput(ctx context.Context, params *glacier.GetJobOutputInput, optFns ...func(*glacier.Options)) (*glacier.GetJobOutputOutput, error) {
+	return &glacier.GetJobOutputOutput{Body: io.NopCloser(newSoakInventory(a.n))}, nil
+}
+
+func (soakAPI) DeleteArchive(ctx context.Context, params *glacier.DeleteArchiveInput, optFns ...func(*glacier.Options)) (*glacier.DeleteArchiveOutput, error) {
+	if i, _ := strconv.Atoi(aws.ToString(params.ArchiveId)); i%1000 == 999 {
+		return nil, glaciertest.Unavailable()
+	}
+	return &glacier.DeleteArchiveOutput{}, nil
+}
+
+// peakRSS returns the most memory the process has held at once since it last
+// reset it, or skips the test where Linux doesn't say.
+func peakRSS(t *testing.T) int64 {
+	t.Helper()
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		t.Skipf("can't read the peak RSS: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "VmHWM:"); ok {
+			kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+			if err != nil {
+				t.Fatalf("can't parse the peak RSS %q: %v", value, err)
+			}
+			return kb << 10
+		}
+	}
+	t.Skip("can't find the peak RSS")
+	return 0
+}
+
+// resetPeakRSS starts the peak RSS over from the memory now held, so what
+// earlier tests held doesn't count.
+func resetPeakRSS(t *testing.T) {
+	t.Helper()
+	if err := os.WriteFile("/proc/self/clear_refs", []byte("5"), 0); err != nil {
+		t.Skipf("can't reset the peak RSS: %v", err)
+	}
+}
+
+// TestSoakDeleteAll deletes a synthetic inventory of millions of archives,
+// deduplicating, checkpointing and failing some of them as a real run does,
+// and checks the memory it takes stays well under what the archives would.
+func TestSoakDeleteAll(t *testing.T) {
+	if testing.Short() {
+		t.Skip("deletes millions of archives")
+	}
+	peakRSS(t)
+	g, err := New(context.Background(), "us-east-1", WithClient(soakAPI{n: soakArchives}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &InventoryJob{Vault: &Vault{Glacier: g, Name: "soak"}, Id: "soak"}
+	var done atomic.Int64
+	resetPeakRSS(t)
+
+	start := time.Now()
+	result, err := job.DeleteAll(context.Background(), DeleteOptions{
+		Workers: 32,
+		Seen:    NewArchiveSet(),
+		Done:    func(n int64) { done.Store(n) },
+		Failed:  func(*Archive, error) {},
+	})
+	peak := peakRSS(t)
+	t.Logf("deleted %d archives in %v, with a peak RSS of %d MiB", result.Deleted, time.Since(start).Round(time.Millisecond), peak>>20)
+
+	failures := soakArchives / 1000
+	if err == nil || result.Deleted != soakArchives-failures || result.Failed != failures {
+		t.Errorf("deleted %d and failed %d archives (%v); want %d and %d", result.Deleted, result.Failed, err, soakArchives-failures, failures)
+	}
+	// The first failure holds the checkpoint back.
+	if n := done.Load(); n != 999 {
+		t.Errorf("checkpointed %d archives, want the 999 before the first failure", n)
+	}
+	if peak > soakPeakRSS {
+		t.Errorf("the peak RSS was %d MiB, want at most %d MiB", peak>>20, soakPeakRSS>>20)
+	}
+}
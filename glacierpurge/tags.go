@@ -0,0 +1,90 @@
+package glacierpurge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+)
+
+// Tags returns the vault's tags, calling ListTagsForVault the first time and
+// returning the cached result after that. A *PermissionError is returned, and
+// cached too, if the credentials aren't allowed to list them.
+func (v *Vault) Tags(ctx context.Context) (map[string]string, error) {
+	if v.tags != nil || v.tagsErr != nil {
+		return v.tags, v.tagsErr
+	}
+
+	output, err := v.Glacier.Client.ListTagsForVault(ctx, &glacier.ListTagsForVaultInput{
+		AccountId: aws.String("-"),
+		VaultName: aws.String(v.Name),
+	})
+	if isAccessDenied(err) {
+		v.tagsErr = &PermissionError{Op: "ListTagsForVault", Vault: v.Name, Err: err}
+		return nil, v.tagsErr
+	}
+	if isNotFound(err) {
+		return nil, fmt.Errorf("%w: %s in region %s", ErrVaultNotFound, v.Name, v.Glacier.Region)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the tags of vault %s: %w", v.Name, err)
+	}
+
+	v.tags = output.Tags
+	if v.tags == nil {
+		v.tags = map[string]string{}
+	}
+	return v.tags, nil
+}
+
+// AddTags adds tags to the vault, replacing any it already has with the same
+// keys. A *PermissionError is returned if the credentials aren't allowed to
+// tag it.
+func (v *Vault) AddTags(ctx context.Context, tags map[string]string) error {
+	_, err := v.Glacier.Client.AddTagsToVault(ctx, &glacier.AddTagsToVaultInput{
+		AccountId: aws.String("-"),
+		VaultName: aws.String(v.Name),
+		Tags:      tags,
+	})
+	if isAccessDenied(err) {
+		return &PermissionError{Op: "AddTagsToVault", Vault: v.Name, Err: err}
+	}
+	if isNotFound(err) {
+		return fmt.Errorf("%w: %s in region %s", ErrVaultNotFound, v.Name, v.Glacier.Region)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to tag vault %s: %w", v.Name, err)
+	}
+
+	if v.tags != nil {
+		for key, value := range tags {
+			v.tags[key] = value
+		}
+	}
+	return nil
+}
+
+// RemoveTags removes the tags with the given keys from the vault. A
+// *PermissionError is returned if the credentials aren't allowed to untag it.
+func (v *Vault) RemoveTags(ctx context.Context, keys ...string) error {
+	_, err := v.Glacier.Client.RemoveTagsFromVault(ctx, &glacier.RemoveTagsFromVaultInput{
+		AccountId: aws.String("-"),
+		VaultName: aws.String(v.Name),
+		TagKeys:   keys,
+	})
+	if isAccessDenied(err) {
+		return &PermissionError{Op: "RemoveTagsFromVault", Vault: v.Name, Err: err}
+	}
+	if isNotFound(err) {
+		return fmt.Errorf("%w: %s in region %s", ErrVaultNotFound, v.Name, v.Glacier.Region)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to untag vault %s: %w", v.Name, err)
+	}
+
+	for _, key := range keys {
+		delete(v.tags, key)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package glacierpurge
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// DefaultConnections is how many connections to each endpoint a Transport
+// keeps open between requests unless told otherwise.
+const DefaultConnections = 16
+
+// Transport is an HTTP client tuned for the many small requests a run makes:
+// it keeps enough connections to each endpoint open between requests for
+// every deletion in flight to reuse one, sticks to HTTP/1.1 keep-alives, and
+// gives up on connections that stall. It's meant to be shared by every
+// client, and counts how often connections are reused. It's safe for
+// concurrent use.
+type Transport struct {
+	client *awshttp.BuildableClient
+
+	requests, opened, reused atomic.Int64
+}
+
+// TransportStats counts a Transport's requests, and the connections they
+// went over.
+type TransportStats struct {
+	Requests int64
+	Opened   int64 // new connections
+	Reused   int64 // requests sent over a connection kept from an earlier one
+}
+
+// NewTransport returns a Transport keeping up to conns connections to each
+// endpoint open between requests, or DefaultConnections if conns isn't
+// positive.
+func NewTransport(conns int) *Transport {
+	if conns <= 0 {
+		conns = DefaultConnections
+	}
+	client := awshttp.NewBuildableClient().
+		WithDialerOptions(func(d *net.Dialer) {
+			d.Timeout = 10 * time.Second
+			d.KeepAlive = 30 * time.Second
+		}).
+		WithTransportOptions(func(tr *http.Transport) {
+			tr.MaxIdleConnsPerHost = conns
+			// Every region's endpoint is a host of its own.
+			tr.MaxIdleConns = 4 * conns
+			tr.IdleConnTimeout = 90 * time.Second
+			tr.TLSHandshakeTimeout = 10 * time.Second
+			tr.ResponseHeaderTimeout = time.Minute
+			tr.ExpectContinueTimeout = time.Second
+			tr.ForceAttemptHTTP2 = false
+			tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		})
+	return &Transport{client: client}
+}
+
+// defaultTransport is shared by the clients given no Transport.
+var defaultTransport = sync.OnceValue(func() *Transport {
+	return NewTransport(DefaultConnections)
+})
+
+// WithTransport sends the client's requests through transport, which is meant
+// to be shared by every client. Clients are otherwise given one shared
+// Transport with DefaultConnections.
+func WithTransport(transport *Transport) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// Do sends a request, noting whether it reused a connection.
+func (t *Transport) Do(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.reused.Add(1)
+			} else {
+				t.opened.Add(1)
+			}
+		},
+	}
+	return t.client.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// Stats returns the requests sent so far and the connections they went over.
+func (t *Transport) Stats() TransportStats {
+	return TransportStats{Requests: t.requests.Load(), Opened: t.opened.Load(), Reused: t.reused.Load()}
+}
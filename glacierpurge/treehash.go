@@ -0,0 +1,62 @@
+package glacierpurge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const treeHashChunkSize = 1 << 20
+
+// treeHash computes the SHA-256 tree hash Glacier uses to checksum archives:
+// the hashes of each 1 MiB chunk are combined pairwise until one remains.
+type treeHash struct {
+	chunk  []byte
+	hashes [][]byte
+}
+
+func (t *treeHash) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		take := treeHashChunkSize - len(t.chunk)
+		if take > len(p) {
+			take = len(p)
+		}
+		t.chunk = append(t.chunk, p[:take]...)
+		p = p[take:]
+
+		if len(t.chunk) == treeHashChunkSize {
+			t.flush()
+		}
+	}
+	return n, nil
+}
+
+func (t *treeHash) flush() {
+	sum := sha256.Sum256(t.chunk)
+	t.hashes = append(t.hashes, sum[:])
+	t.chunk = t.chunk[:0]
+}
+
+// Sum returns the hex-encoded tree hash of everything written so far.
+func (t *treeHash) Sum() string {
+	hashes := append([][]byte(nil), t.hashes...)
+	if len(t.chunk) > 0 || len(hashes) == 0 {
+		sum := sha256.Sum256(t.chunk)
+		hashes = append(hashes, sum[:])
+	}
+
+	for len(hashes) > 1 {
+		var next [][]byte
+		for i := 0; i < len(hashes); i += 2 {
+			if i+1 == len(hashes) {
+				next = append(next, hashes[i])
+				continue
+			}
+			sum := sha256.Sum256(append(append([]byte(nil), hashes[i]...), hashes[i+1]...))
+			next = append(next, sum[:])
+		}
+		hashes = next
+	}
+
+	return hex.EncodeToString(hashes[0])
+}
@@ -0,0 +1,314 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+type Vault struct {
+	Glacier *Glacier
+	Name    string
+	// ARN and CreationDate are filled in by GetVaults and Describe. The ARN
+	// tells apart identically named vaults in different accounts.
+	ARN          string
+	CreationDate time.Time
+
+	description *VaultDescription // cached by Describe
+	tags        map[string]string // cached by Tags
+	tagsErr     error             // a *PermissionError cached by Tags
+	arnErr      error             // why journalARN couldn't find the ARN
+}
+
+// VaultDescription is the vault metadata Glacier reports. Its archive count
+// and size are only as fresh as the vault's last inventory, which Glacier
+// takes about once a day.
+type VaultDescription struct {
+	ARN               string
+	SizeInBytes       int64
+	NumberOfArchives  int64
+	CreationDate      time.Time
+	LastInventoryDate time.Time // zero if Glacier has never inventoried the vault
+}
+
+// Describe returns the vault's metadata, calling DescribeVault the first time
+// and returning the cached result after that. A *PermissionError is returned
+// if the credentials aren't allowed to describe the vault.
+func (v *Vault) Describe(ctx context.Context) (*VaultDescription, error) {
+	if v.description != nil {
+		return v.description, nil
+	}
+	return v.Refresh(ctx)
+}
+
+// Refresh calls DescribeVault again, replacing the cached description.
+func (v *Vault) Refresh(ctx context.Context) (*VaultDescription, error) {
+	output, err := v.Glacier.Client.DescribeVault(ctx, &glacier.DescribeVaultInput{
+		AccountId: aws.String("-"),
+		VaultName: aws.String(v.Name),
+	})
+	if isAccessDenied(err) {
+		return nil, &PermissionError{Op: "DescribeVault", Vault: v.Name, Err: err}
+	}
+	if isNotFound(err) {
+		return nil, fmt.Errorf("%w: %s in region %s", ErrVaultNotFound, v.Name, v.Glacier.Region)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe vault %s: %w", v.Name, err)
+	}
+
+	description, err := newVaultDescription(output.VaultARN, output.SizeInBytes, output.NumberOfArchives, output.CreationDate, output.LastInventoryDate)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: %w", v.Name, err)
+	}
+
+	v.description = description
+	v.ARN, v.CreationDate = description.ARN, description.CreationDate
+	return description, nil
+}
+
+func newVaultDescription(arn *string, size, archives int64, created, lastInventory *string) (*VaultDescription, error) {
+	description := &VaultDescription{
+		ARN:              aws.ToString(arn),
+		SizeInBytes:      size,
+		NumberOfArchives: archives,
+	}
+
+	var err error
+	if description.CreationDate, err = parseDate(created); err != nil {
+		return nil, fmt.Errorf("invalid creation date: %w", err)
+	}
+	if description.LastInventoryDate, err = parseDate(lastInventory); err != nil {
+		return nil, fmt.Errorf("invalid last inventory date: %w", err)
+	}
+	return description, nil
+}
+
+// StaleInventoryAge is how old a vault's last inventory can get before it may
+// be missing archives uploaded since.
+const StaleInventoryAge = 48 * time.Hour
+
+// InventoryStale reports whether the vault's last inventory, which every
+// inventory retrieval job returns, may be missing archives as of now: the
+// vault has never been inventoried, the inventory is older than
+// StaleInventoryAge, or the vault itself is new enough to still be receiving
+// its first uploads.
+func (d *VaultDescription) InventoryStale(now time.Time) bool {
+	return d.LastInventoryDate.IsZero() ||
+		now.Sub(d.LastInventoryDate) > StaleInventoryAge ||
+		now.Sub(d.CreationDate) < StaleInventoryAge
+}
+
+// parseDate parses one of Glacier's ISO 8601 timestamps, treating a missing
+// one as the zero time.
+func parseDate(value *string) (time.Time, error) {
+	if aws.ToString(value) == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, *value)
+}
+
+// PurgeResult reports what Purge did to a vault.
+type PurgeResult struct {
+	JobId    string // the inventory retrieval job the archive list came from
+	Archives int    // archives listed in the inventory
+	Deleted  int
+	Failed   int
+	// FailedBy counts the failed archives by the class of their error.
+	FailedBy ClassCounts
+	// Absent counts the archives that were already gone, which are neither
+	// deleted nor failed, and free nothing.
+	Absent int
+	// DeletedBytes is the size of the archives deleted, as the inventory
+	// gave it; the absent ones aren't counted.
+	DeletedBytes int64
+	Pages        int // inventory jobs the archive list took, when it was paginated
+	Skipped      int // archives left alone by DeleteOptions.Filter
+	Passed       int // archives passed over by DeleteOptions.Skip, an earlier run having dealt with them
+	// Duplicates counts the archives the inventory listed again after their
+	// first time, which aren't counted again. ArchiveSet only suspects them,
+	// so each is deleted again, harmlessly, and only counted here once that
+	// finds it gone; one that fails to is counted as failed instead.
+	Duplicates int
+	// Unattempted counts the archives never tried because the circuit
+	// breaker tripped; Breaker is why it did.
+	Unattempted int
+	Breaker     string
+}
+
+// Add accumulates another page's result into r.
+func (r *PurgeResult) Add(page *PurgeResult) {
+	r.Archives += page.Archives
+	r.Deleted += page.Deleted
+	r.Absent += page.Absent
+	r.DeletedBytes += page.DeletedBytes
+	r.Failed += page.Failed
+	if len(page.FailedBy) > 0 {
+		if r.FailedBy == nil {
+			r.FailedBy = ClassCounts{}
+		}
+		r.FailedBy.Add(page.FailedBy)
+	}
+	r.Skipped += page.Skipped
+	r.Passed += page.Passed
+	r.Duplicates += page.Duplicates
+	r.Unattempted += page.Unattempted
+	if page.Breaker != "" {
+		r.Breaker = page.Breaker
+	}
+	r.Pages++
+}
+
+// InventoryOptions narrows an inventory retrieval job. The zero value
+// inventories the whole vault in one job.
+type InventoryOptions struct {
+	// Limit caps the archives in the job's output, paginating the inventory;
+	// the job's Next continues from where it stopped. Zero means no limit.
+	Limit int
+	// Marker starts the inventory where an earlier page ended.
+	Marker string
+	// StartDate and EndDate have Glacier only list archives created in that
+	// window. Zero leaves that end open.
+	StartDate time.Time
+	EndDate   time.Time
+	// Format is the inventory's format: JSON (the default), CSV, or auto,
+	// which asks for CSV, much smaller for big vaults, once the vault holds
+	// more than CSVInventoryThreshold archives.
+	Format string
+}
+
+// CSVInventoryThreshold is the archive count above which an auto-format
+// inventory is requested as CSV.
+const CSVInventoryThreshold = 100000
+
+// Validate checks that the options can be sent to Glacier.
+func (o InventoryOptions) Validate() error {
+	if o.Limit < 0 {
+		return fmt.Errorf("invalid inventory limit %d", o.Limit)
+	}
+	switch o.Format {
+	case "", "JSON", "CSV", "auto":
+	default:
+		return fmt.Errorf("invalid inventory format %q: must be JSON, CSV, or auto", o.Format)
+	}
+	if !o.StartDate.IsZero() && !o.EndDate.IsZero() && !o.EndDate.After(o.StartDate) {
+		return fmt.Errorf("inventory end date %s isn't after its start date %s", o.EndDate.Format(time.RFC3339), o.StartDate.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (v *Vault) InitiateInventoryRetrievalJob(ctx context.Context) (*InventoryJob, error) {
+	return v.InitiateInventoryJob(ctx, InventoryOptions{})
+}
+
+// InitiateInventoryJob starts an inventory retrieval job narrowed by opts.
+func (v *Vault) InitiateInventoryJob(ctx context.Context, opts InventoryOptions) (*InventoryJob, error) {
+	if err := opts.Validate(); err != nil {
+		return &InventoryJob{}, err
+	}
+	if opts.Format == "auto" {
+		opts.Format = "JSON"
+		if description, err := v.Describe(ctx); err == nil && description.NumberOfArchives > CSVInventoryThreshold {
+			opts.Format = "CSV"
+		}
+	}
+
+	params := &glacier.InitiateJobInput{
+		AccountId: aws.String("-"), // Use "-" for the current account
+		VaultName: aws.String(v.Name),
+		JobParameters: &types.JobParameters{
+			Type: aws.String("inventory-retrieval"),
+		},
+	}
+	if opts.Format != "" {
+		params.JobParameters.Format = aws.String(opts.Format)
+	}
+	if opts.Limit > 0 || opts.Marker != "" || !opts.StartDate.IsZero() || !opts.EndDate.IsZero() {
+		retrieval := &types.InventoryRetrievalJobInput{}
+		if opts.Limit > 0 {
+			retrieval.Limit = aws.String(strconv.Itoa(opts.Limit))
+		}
+		if opts.Marker != "" {
+			retrieval.Marker = aws.String(opts.Marker)
+		}
+		if !opts.StartDate.IsZero() {
+			retrieval.StartDate = aws.String(opts.StartDate.UTC().Format(time.RFC3339))
+		}
+		if !opts.EndDate.IsZero() {
+			retrieval.EndDate = aws.String(opts.EndDate.UTC().Format(time.RFC3339))
+		}
+		params.JobParameters.InventoryRetrievalParameters = retrieval
+	}
+
+	result, err := v.Glacier.Client.InitiateJob(ctx, params)
+	if err != nil {
+		return &InventoryJob{}, fmt.Errorf("failed to initiate inventory retrieval job: %w", err)
+	}
+	return &InventoryJob{Vault: v, Id: *result.JobId, Options: opts}, nil
+}
+
+// ErrVaultNotFound is returned when the vault doesn't exist.
+var ErrVaultNotFound = errors.New("vault not found")
+
+// ErrVaultNotEmpty is returned by Delete while Glacier still counts archives
+// in the vault. Its count only catches up with deletions at the vault's next
+// inventory, which Glacier takes about once a day.
+var ErrVaultNotEmpty = errors.New("vault isn't empty as of its last inventory")
+
+// String names the vault by its ARN when that's known, and otherwise by its
+// name and region.
+func (v *Vault) String() string {
+	if v.ARN != "" {
+		return v.ARN
+	}
+	return fmt.Sprintf("%s in region %s", v.Name, v.Glacier.Region)
+}
+
+// Delete deletes the vault itself, which Glacier only allows once its last
+// inventory shows no archives.
+func (v *Vault) Delete(ctx context.Context) error {
+	err := v.journaled(v.journalARN(ctx), JournalEntry{Op: "DeleteVault"}, func() (middleware.Metadata, error) {
+		output, err := v.Glacier.Client.DeleteVault(ctx, &glacier.DeleteVaultInput{
+			AccountId: aws.String("-"),
+			VaultName: aws.String(v.Name),
+		})
+		if err != nil {
+			return middleware.Metadata{}, err
+		}
+		return output.ResultMetadata, nil
+	})
+	switch {
+	case err == nil:
+		return nil
+	case isNotFound(err):
+		return fmt.Errorf("%w: %s in region %s", ErrVaultNotFound, v.Name, v.Glacier.Region)
+	case isInvalidParameter(err):
+		return fmt.Errorf("failed to delete vault %s: %w", v.Name, ErrVaultNotEmpty)
+	case isAccessDenied(err):
+		return &PermissionError{Op: "DeleteVault", Vault: v.Name, Err: err}
+	}
+	return fmt.Errorf("failed to delete vault %s: %w", v.Name, err)
+}
+
+// Purge retrieves the vault's inventory and deletes every archive in it. The
+// inventory job typically takes several hours, during which Purge blocks. An
+// error is returned if the inventory can't be retrieved or any archive fails
+// to delete, or ctx ends first; the result is filled in as far as Purge got.
+//
+// Archives are only deleted once across all of a paginated inventory's pages.
+func (v *Vault) Purge(ctx context.Context) (*PurgeResult, error) {
+	job, err := v.InitiateInventoryRetrievalJob(ctx)
+	if err != nil {
+		return &PurgeResult{}, err
+	}
+
+	v.Glacier.Logger.Printf("Inventory retrieval job initiated for vault %s, job ID: %s. This operation will likely take a number of hours to complete.", v.Name, job.Id)
+	return job.Purge(ctx)
+}
@@ -0,0 +1,117 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// TestDescribeFailures scripts DescribeVault to fail each way it can, and
+// checks Describe and Refresh report it as what it is, caching nothing, and
+// that a failed Refresh leaves the description it would have replaced.
+func TestDescribeFailures(t *testing.T) {
+	badDate := func(created, inventoried string) glaciertest.Response {
+		return glaciertest.Response{Output: &glacier.DescribeVaultOutput{
+			VaultARN:          aws.String("arn:aws:glacier:us-east-1:123456789012:vaults/photos"),
+			VaultName:         aws.String("photos"),
+			CreationDate:      aws.String(created),
+			LastInventoryDate: aws.String(inventoried),
+		}}
+	}
+	for _, c := range []struct {
+		name     string
+		response glaciertest.Response
+		check    func(t *testing.T, err error)
+	}{
+		{
+			name:     "access denied",
+			response: glaciertest.Response{Err: glaciertest.AccessDenied()},
+			check: func(t *testing.T, err error) {
+				var permission *PermissionError
+				if !errors.As(err, &permission) || permission.Op != "DescribeVault" || permission.Vault != "photos" {
+					t.Errorf("got %v, want a PermissionError for DescribeVault on photos", err)
+				}
+				if !isAccessDenied(err) {
+					t.Errorf("%v doesn't wrap the access denied", err)
+				}
+			},
+		},
+		{
+			name:     "not found",
+			response: glaciertest.Response{Err: glaciertest.NotFound("Vault not found")},
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, ErrVaultNotFound) || !strings.Contains(err.Error(), "photos in region us-east-1") {
+					t.Errorf("got %v, want ErrVaultNotFound naming the vault and region", err)
+				}
+			},
+		},
+		{
+			name:     "bad creation date",
+			response: badDate("last tuesday", "2024-03-01T12:00:00Z"),
+			check: func(t *testing.T, err error) {
+				if err == nil || err.Error() != `vault photos: invalid creation date: parsing time "last tuesday" as "2006-01-02T15:04:05Z07:00": cannot parse "last tuesday" as "2006"` {
+					t.Errorf("got %v, want the creation date turned down", err)
+				}
+			},
+		},
+		{
+			name:     "bad inventory date",
+			response: badDate("2024-03-01T12:00:00Z", "2024-13-01T12:00:00Z"),
+			check: func(t *testing.T, err error) {
+				if err == nil || !strings.HasPrefix(err.Error(), "vault photos: invalid last inventory date: ") {
+					t.Errorf("got %v, want the last inventory date turned down", err)
+				}
+			},
+		},
+		{
+			name:     "anything else",
+			response: glaciertest.Response{Err: glaciertest.Error(400, "InvalidParameterValueException", "bad vault name")},
+			check: func(t *testing.T, err error) {
+				if err == nil || !strings.HasPrefix(err.Error(), "failed to describe vault photos: ") || isAccessDenied(err) || errors.Is(err, ErrVaultNotFound) {
+					t.Errorf("got %v, want it passed on as it was", err)
+				}
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			fake := glaciertest.New()
+			fake.AddVault(glaciertest.Vault{Name: "photos", Archives: make([]glaciertest.Archive, 2)})
+			g, _ := newTestGlacier(t, fake)
+			ctx := context.Background()
+
+			vault := &Vault{Glacier: g, Name: "photos"}
+			fake.Script(glaciertest.OpDescribeVault, c.response)
+			description, err := vault.Describe(ctx)
+			if description != nil {
+				t.Errorf("Describe returned %+v with its error", description)
+			}
+			c.check(t, err)
+			if vault.ARN != "" || !vault.CreationDate.IsZero() {
+				t.Errorf("the failed Describe set ARN %q, created %s", vault.ARN, vault.CreationDate)
+			}
+			// Nothing was cached, so the next asks again.
+			if description, err := vault.Describe(ctx); err != nil || description.NumberOfArchives != 2 {
+				t.Fatalf("Describe again = %+v, %v", description, err)
+			}
+
+			fake.Script(glaciertest.OpDescribeVault, c.response)
+			description, err = vault.Refresh(ctx)
+			if description != nil {
+				t.Errorf("Refresh returned %+v with its error", description)
+			}
+			c.check(t, err)
+			if description, err := vault.Describe(ctx); err != nil || description.NumberOfArchives != 2 || vault.ARN != fake.ARN("photos") {
+				t.Errorf("after the failed Refresh, Describe = %+v, %v with ARN %q; want the earlier description", description, err, vault.ARN)
+			}
+			if n := fake.Count(glaciertest.OpDescribeVault); n != 3 {
+				t.Errorf("DescribeVault was called %d times, want 3", n)
+			}
+		})
+	}
+}
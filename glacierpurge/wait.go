@@ -0,0 +1,183 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+)
+
+// ErrWaitTimeout is returned by Wait when WaitOptions.MaxWait passes before
+// the job completes.
+var ErrWaitTimeout = errors.New("gave up waiting for job to complete")
+
+// WaitOptions controls how Wait polls. The zero value polls once a minute for
+// as long as it takes.
+type WaitOptions struct {
+	PollInterval time.Duration // defaults to one minute
+	// Backoff, if set, returns the interval to use after each poll given the
+	// last one. Without it the interval stays fixed.
+	Backoff func(interval time.Duration) time.Duration
+	MaxWait time.Duration // zero means no limit
+	// Progress, if set, is called after every poll with the time spent so far
+	// and Glacier's latest description of the job.
+	Progress func(elapsed time.Duration, description *glacier.DescribeJobOutput)
+	Clock    Clock // defaults to the client's
+	// MaxPollFailures is how many polls in a row may fail for a passing
+	// reason, such as a 5xx or a dropped connection, before Wait gives up;
+	// defaults to 10. A poll failing for any other reason ends Wait at once.
+	MaxPollFailures int
+}
+
+// defaultMaxPollFailures is WaitOptions.MaxPollFailures unless set.
+const defaultMaxPollFailures = 10
+
+// ExponentialBackoff returns a Backoff that multiplies the interval by factor
+// after each poll, up to max.
+func ExponentialBackoff(factor float64, max time.Duration) func(time.Duration) time.Duration {
+	return func(interval time.Duration) time.Duration {
+		next := time.Duration(float64(interval) * factor)
+		if next > max {
+			return max
+		}
+		return next
+	}
+}
+
+// WaitOutcome is how a Wait ended.
+type WaitOutcome int
+
+const (
+	WaitSucceeded WaitOutcome = iota
+	WaitJobFailed             // Glacier reported the job as failed
+	WaitTimedOut              // MaxWait passed
+	WaitCanceled              // the context ended
+)
+
+func (o WaitOutcome) String() string {
+	switch o {
+	case WaitSucceeded:
+		return "succeeded"
+	case WaitJobFailed:
+		return "job failed"
+	case WaitTimedOut:
+		return "timed out"
+	case WaitCanceled:
+		return "canceled"
+	}
+	return fmt.Sprintf("WaitOutcome(%d)", int(o))
+}
+
+// WaitResult reports how a Wait ended.
+type WaitResult struct {
+	Outcome       WaitOutcome
+	Elapsed       time.Duration
+	StatusMessage string                     // Glacier's explanation when the job failed
+	Description   *glacier.DescribeJobOutput // the last description seen, if any
+}
+
+// Wait blocks until the job completes, fails, or MaxWait or ctx ends. The
+// error is nil only when the job succeeded; otherwise it describes the
+// outcome, which the result also records. If DescribeJob itself fails, or the
+// credentials expire and can't be renewed, the result is nil.
+func (j *InventoryJob) Wait(ctx context.Context, opts WaitOptions) (*WaitResult, error) {
+	return waitForJob(ctx, j.Vault, j.Id, opts)
+}
+
+func waitForJob(ctx context.Context, vault *Vault, jobId string, opts WaitOptions) (*WaitResult, error) {
+	clock := opts.Clock
+	if clock == nil {
+		clock = vault.Glacier.clock()
+	}
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = pollingInterval
+	}
+
+	maxFailures := opts.MaxPollFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxPollFailures
+	}
+
+	start := clock.Now()
+	result := &WaitResult{}
+	failures := 0
+	for {
+		if ctx.Err() != nil {
+			result.Outcome = WaitCanceled
+			return result, ctx.Err()
+		}
+
+		description, err := vault.Glacier.Client.DescribeJob(ctx, &glacier.DescribeJobInput{
+			JobId:     aws.String(jobId),
+			VaultName: aws.String(vault.Name),
+		})
+		switch {
+		case err == nil:
+			failures = 0
+		case ctx.Err() == nil && isTransient(err) && failures+1 < maxFailures:
+			// Hours of waiting aren't thrown away over a blip; the next poll
+			// tries again.
+			failures++
+			vault.Glacier.debugf("Polling job %s failed (%d in a row); trying again at the next poll: %v", jobId, failures, err)
+		case failures > 0 && isTransient(err):
+			return nil, fmt.Errorf("failed to describe job %d times in a row: %w", failures+1, err)
+		default:
+			return nil, fmt.Errorf("failed to describe job: %w", jobExpired(ctx, vault, err))
+		}
+		result.Elapsed = clock.Now().Sub(start)
+
+		if description != nil {
+			result.Description = description
+			if opts.Progress != nil {
+				opts.Progress(result.Elapsed, description)
+			}
+
+			if description.StatusCode == types.StatusCodeFailed {
+				result.Outcome = WaitJobFailed
+				result.StatusMessage = aws.ToString(description.StatusMessage)
+				return result, fmt.Errorf("job %s failed: %s", jobId, result.StatusMessage)
+			}
+			if description.Completed {
+				result.Outcome = WaitSucceeded
+				return result, nil
+			}
+		}
+
+		// Sleep no further than MaxWait, so the last poll happens at the
+		// deadline rather than after it.
+		sleep := interval
+		if opts.MaxWait > 0 {
+			remaining := opts.MaxWait - result.Elapsed
+			if remaining <= 0 {
+				result.Outcome = WaitTimedOut
+				return result, ErrWaitTimeout
+			}
+			if remaining < sleep {
+				sleep = remaining
+			}
+		}
+
+		// Credentials that would expire before the next poll are refreshed,
+		// or renewed, now, rather than found expired once the sleep is over.
+		if credentials := vault.Glacier.Credentials; credentials != nil {
+			if err := credentials.refreshBefore(ctx, time.Now().Add(sleep)); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := sleepFor(ctx, clock, sleep); err != nil {
+			result.Outcome = WaitCanceled
+			result.Elapsed = clock.Now().Sub(start)
+			return result, err
+		}
+
+		if opts.Backoff != nil {
+			interval = opts.Backoff(interval)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package glacierpurge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// startJob creates a vault in fake and initiates an inventory job of it.
+func startJob(t *testing.T, g *Glacier, fake *glaciertest.Fake) *InventoryJob {
+	t.Helper()
+	fake.AddVault(glaciertest.Vault{Name: "vault", Archives: make([]glaciertest.Archive, 3)})
+	job, err := (&Vault{Glacier: g, Name: "vault"}).InitiateInventoryRetrievalJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestWaitPollSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		polls   int // times the job is reported in progress
+		opts    WaitOptions
+		outcome WaitOutcome
+		waits   []time.Duration
+	}{
+		{
+			name:    "done at once",
+			outcome: WaitSucceeded,
+		},
+		{
+			name:    "every minute by default",
+			polls:   3,
+			outcome: WaitSucceeded,
+			waits:   []time.Duration{time.Minute, time.Minute, time.Minute},
+		},
+		{
+			name:    "backing off up to a limit",
+			polls:   5,
+			opts:    WaitOptions{PollInterval: time.Minute, Backoff: ExponentialBackoff(2, 5*time.Minute)},
+			outcome: WaitSucceeded,
+			waits:   []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute},
+		},
+		{
+			name:    "the last poll at the deadline",
+			polls:   10,
+			opts:    WaitOptions{PollInterval: time.Minute, MaxWait: 150 * time.Second},
+			outcome: WaitTimedOut,
+			waits:   []time.Duration{time.Minute, time.Minute, 30 * time.Second},
+		},
+		{
+			name:    "hours of polling",
+			polls:   4 * 60,
+			opts:    WaitOptions{PollInterval: 15 * time.Minute},
+			outcome: WaitSucceeded,
+			waits:   repeat(15*time.Minute, 4*60),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := glaciertest.New()
+			fake.JobPolls = test.polls
+			g, clock := newTestGlacier(t, fake)
+			job := startJob(t, g, fake)
+
+			result, err := job.Wait(context.Background(), test.opts)
+			if result == nil {
+				t.Fatalf("got no result, error %v", err)
+			}
+			if result.Outcome != test.outcome {
+				t.Errorf("got outcome %s (%v), want %s", result.Outcome, err, test.outcome)
+			}
+			if (err == nil) != (test.outcome == WaitSucceeded) {
+				t.Errorf("got error %v for outcome %s", err, result.Outcome)
+			}
+			if got := clock.Waits(); fmt.Sprint(got) != fmt.Sprint(test.waits) {
+				t.Errorf("waited %v, want %v", got, test.waits)
+			}
+			var total time.Duration
+			for _, wait := range test.waits {
+				total += wait
+			}
+			if result.Elapsed != total {
+				t.Errorf("got elapsed %s, want %s", result.Elapsed, total)
+			}
+			if n := fake.Count(glaciertest.OpDescribeJob); n != len(test.waits)+1 {
+				t.Errorf("polled %d times, want %d", n, len(test.waits)+1)
+			}
+		})
+	}
+}
+
+func repeat(d time.Duration, n int) []time.Duration {
+	waits := make([]time.Duration, n)
+	for i := range waits {
+		waits[i] = d
+	}
+	return waits
+}
+
+func TestWaitReportsAFailedJob(t *testing.T) {
+	fake := glaciertest.New()
+	fake.JobPolls = 5
+	g, _ := newTestGlacier(t, fake)
+	job := startJob(t, g, fake)
+	fake.FailJob(job.Id, "the vault is locked")
+
+	result, err := job.Wait(context.Background(), WaitOptions{})
+	if err == nil || result.Outcome != WaitJobFailed || result.StatusMessage != "the vault is locked" {
+		t.Errorf("got result %+v and error %v, want the job failed", result, err)
+	}
+}
+
+func TestWaitRidesOutPassingFailures(t *testing.T) {
+	fake := glaciertest.New()
+	fake.JobPolls = 1
+	g, clock := newTestGlacier(t, fake)
+	job := startJob(t, g, fake)
+	fake.Script(glaciertest.OpDescribeJob,
+		glaciertest.Response{Err: glaciertest.Unavailable()},
+		glaciertest.Response{Err: glaciertest.Throttled()},
+	)
+
+	result, err := job.Wait(context.Background(), WaitOptions{MaxPollFailures: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Outcome != WaitSucceeded {
+		t.Errorf("got outcome %s, want succeeded", result.Outcome)
+	}
+	// Two failed polls, one finding the job in progress, one finding it done.
+	if got := clock.Waits(); len(got) != 3 {
+		t.Errorf("waited %v, want three minutes", got)
+	}
+}
+
+func TestWaitGivesUpAfterMaxPollFailures(t *testing.T) {
+	fake := glaciertest.New()
+	fake.JobPolls = 1
+	g, _ := newTestGlacier(t, fake)
+	job := startJob(t, g, fake)
+	fake.Script(glaciertest.OpDescribeJob,
+		glaciertest.Response{Err: glaciertest.Unavailable()},
+		glaciertest.Response{Err: glaciertest.Unavailable()},
+	)
+
+	if result, err := job.Wait(context.Background(), WaitOptions{MaxPollFailures: 2}); err == nil {
+		t.Errorf("got result %+v, want an error", result)
+	}
+	if n := fake.Count(glaciertest.OpDescribeJob); n != 2 {
+		t.Errorf("polled %d times, want 2", n)
+	}
+}
+
+func TestWaitStopsAtAPermanentFailure(t *testing.T) {
+	fake := glaciertest.New()
+	g, _ := newTestGlacier(t, fake)
+	job := startJob(t, g, fake)
+	fake.ExpireJob(job.Id)
+
+	_, err := job.Wait(context.Background(), WaitOptions{})
+	if !errors.Is(err, ErrJobExpired) {
+		t.Errorf("got %v, want ErrJobExpired", err)
+	}
+	if n := fake.Count(glaciertest.OpDescribeJob); n != 1 {
+		t.Errorf("polled %d times, want 1", n)
+	}
+}
+
+func TestWaitCanceledWhileSleeping(t *testing.T) {
+	fake := glaciertest.New()
+	fake.JobPolls = 100
+	clock := glaciertest.NewClock(testStart)
+	g, err := New(context.Background(), "us-east-1", WithClient(fake), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := startJob(t, g, fake)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	type waited struct {
+		result *WaitResult
+		err    error
+	}
+	done := make(chan waited)
+	go func() {
+		result, err := job.Wait(ctx, WaitOptions{})
+		done <- waited{result, err}
+	}()
+
+	for i := 0; i < 3; i++ {
+		if !clock.BlockUntil(1, 5*time.Second) {
+			t.Fatal("Wait never slept")
+		}
+		clock.Advance(time.Minute)
+	}
+	if !clock.BlockUntil(1, 5*time.Second) {
+		t.Fatal("Wait never slept")
+	}
+	cancel()
+
+	w := <-done
+	if !errors.Is(w.err, context.Canceled) || w.result.Outcome != WaitCanceled {
+		t.Errorf("got result %+v and error %v, want canceled", w.result, w.err)
+	}
+	if w.result.Elapsed != 3*time.Minute {
+		t.Errorf("got elapsed %s, want 3m0s", w.result.Elapsed)
+	}
+}
@@ -0,0 +1,59 @@
+package glacierpurge
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrWrappedUp is returned by the deletions, and the work around them, that
+// were stopped early because a wrap-up was requested.
+var ErrWrappedUp = errors.New("stopped early, as asked")
+
+// WrapUp asks the clients it's given to to finish early without cancelling
+// anything: no more archives are handed out for deletion, while those in
+// flight finish. It's safe for concurrent use, and a nil WrapUp is never
+// requested.
+type WrapUp struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+// NewWrapUp returns a wrap-up not yet requested.
+func NewWrapUp() *WrapUp {
+	return &WrapUp{ch: make(chan struct{})}
+}
+
+// WithWrapUp stops the client's deletions early once wrapUp is requested. It's
+// meant to be shared by every client.
+func WithWrapUp(wrapUp *WrapUp) Option {
+	return func(o *options) {
+		o.wrapUp = wrapUp
+	}
+}
+
+// Request asks for the wrap-up. Later calls do nothing.
+func (w *WrapUp) Request() {
+	w.once.Do(func() { close(w.ch) })
+}
+
+// Requested reports whether the wrap-up has been requested.
+func (w *WrapUp) Requested() bool {
+	if w == nil {
+		return false
+	}
+	select {
+	case <-w.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Done returns a channel closed once the wrap-up is requested, or nil, which
+// is never ready, for a nil WrapUp.
+func (w *WrapUp) Done() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	return w.ch
+}
@@ -0,0 +1,165 @@
+// Package audit keeps a local, append-only journal of every deletion. Each
+// line is a JSON record carrying the hash of the line before it, so a line
+// that's edited, inserted, or removed breaks the chain from there on.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/redact"
+)
+
+// Record is one line of the journal.
+type Record struct {
+	Time      time.Time `json:"time"`
+	AccountId string    `json:"accountId,omitempty"`
+	Region    string    `json:"region"`
+	Vault     string    `json:"vault"`
+	VaultARN  string    `json:"vaultArn,omitempty"`
+	Op        string    `json:"op"`
+	ArchiveId string    `json:"archiveId,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	RequestId string    `json:"requestId,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	Version   string    `json:"version,omitempty"` // of the tool that made the record
+
+	Prev string `json:"prev"` // the previous record's hash; empty for the first
+	Hash string `json:"hash"` // SHA-256 of this record with Hash empty
+}
+
+func (r *Record) sum() (string, error) {
+	unsealed := *r
+	unsealed.Hash = ""
+	data, err := json.Marshal(&unsealed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log is a journal file open for appending. It satisfies
+// glacierpurge.Journal and is safe for concurrent use.
+type Log struct {
+	// Version is the tool's version, recorded in every record.
+	Version string
+
+	f    *os.File
+	head string // the last record's hash
+	mu   sync.Mutex
+}
+
+// Open opens the journal at path, creating it if need be. An existing
+// journal is verified first, so records are never chained onto a broken one.
+func Open(path string) (*Log, error) {
+	_, head, err := Verify(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Log{f: f, head: head}, nil
+}
+
+// Record appends entry to the journal and syncs it to disk before returning.
+func (l *Log) Record(entry glacierpurge.JournalEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record := &Record{
+		Time:      time.Now().UTC(),
+		Region:    entry.Region,
+		Vault:     entry.Vault,
+		VaultARN:  entry.VaultARN,
+		Op:        entry.Op,
+		ArchiveId: entry.ArchiveId,
+		Size:      entry.Size,
+		RequestId: entry.RequestId,
+		Outcome:   entry.Outcome,
+		Error:     redact.String(entry.Error),
+		Version:   l.Version,
+		Prev:      l.head,
+	}
+	if parsed, err := arn.Parse(entry.VaultARN); err == nil {
+		record.AccountId = parsed.AccountID
+	}
+
+	var err error
+	if record.Hash, err = record.sum(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := l.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	l.head = record.Hash
+	return nil
+}
+
+// Head returns the hash of the last record, which pins down everything before
+// it: note it elsewhere and a later truncation of the journal shows too.
+func (l *Log) Head() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// Verify checks the chain of every record in the journal at path, returning
+// the number of records and the last one's hash.
+func Verify(path string) (int, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	records, head := 0, ""
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		records++
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return records, head, fmt.Errorf("audit log %s, line %d: %w", path, records, err)
+		}
+		if record.Prev != head {
+			return records, head, fmt.Errorf("audit log %s, line %d: doesn't follow the line before it; the log has been edited", path, records)
+		}
+		sum, err := record.sum()
+		if err != nil {
+			return records, head, err
+		}
+		if sum != record.Hash {
+			return records, head, fmt.Errorf("audit log %s, line %d: doesn't match its hash; the log has been edited", path, records)
+		}
+		head = record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return records, head, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return records, head, nil
+}
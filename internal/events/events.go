@@ -0,0 +1,146 @@
+// Package events carries what happens during a run to whatever wants to
+// follow it, such as a file of JSON lines, without any of them being able to
+// hold the run up.
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind is what an Event reports.
+type Kind string
+
+const (
+	VaultSelected  Kind = "vaultSelected"  // the run has taken the vault on
+	JobInitiated   Kind = "jobInitiated"   // an inventory job was initiated for the vault
+	JobCompleted   Kind = "jobCompleted"   // the vault's inventory job completed
+	ArchiveDeleted Kind = "archiveDeleted" // an archive was deleted
+	ArchiveFailed  Kind = "archiveFailed"  // an archive failed to delete
+	ArchiveAbsent  Kind = "archiveAbsent"  // an archive was already gone
+	VaultFinished  Kind = "vaultFinished"  // the run is done with the vault, with its counts
+	VaultDeleted   Kind = "vaultDeleted"   // the vault itself was deleted
+	RunFinished    Kind = "runFinished"    // every vault is done with
+)
+
+// Event is something that happened during a run. Only the fields that apply
+// to its Kind are set.
+type Event struct {
+	Kind      Kind      `json:"kind"`
+	Time      time.Time `json:"time"`
+	Region    string    `json:"region,omitempty"`
+	Vault     string    `json:"vault,omitempty"`
+	JobId     string    `json:"jobId,omitempty"`
+	ArchiveId string    `json:"archiveId,omitempty"`
+	Size      int64     `json:"size,omitempty"` // VaultFinished: of the archives deleted
+	Error     string    `json:"error,omitempty"`
+	Vaults    int       `json:"vaults,omitempty"`  // RunFinished: the vaults taken on
+	Failed    int       `json:"failed,omitempty"`  // RunFinished: the vaults that failed; VaultFinished: its archives that did
+	Deleted   int       `json:"deleted,omitempty"` // VaultFinished: the archives deleted
+	Absent    int       `json:"absent,omitempty"`  // VaultFinished: the archives already gone
+	Order     string    `json:"order,omitempty"`   // RunFinished: the order the vaults were worked through in
+	// FailedBy counts, for VaultFinished, the archives that failed by the
+	// class of their error, such as AccessDenied or Throttling.
+	FailedBy map[string]int `json:"failedBy,omitempty"`
+}
+
+// Bus hands every event published on it to each of its subscriptions, in the
+// order they were published. Publishing never waits for a subscriber: an
+// event a subscription has no room left for is dropped, and counted. A nil
+// Bus drops everything. It's safe for concurrent use.
+type Bus struct {
+	mu     sync.Mutex
+	subs   []*Subscription
+	closed bool
+}
+
+// NewBus returns a Bus with no subscriptions.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscription receives a Bus's events on C, which is closed once the Bus
+// is.
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	dropped atomic.Int64
+}
+
+// Dropped returns how many events the subscription had no room for.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Subscribe returns a subscription holding up to buffer events its reader
+// hasn't got to yet.
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	c := make(chan Event, buffer)
+	sub := &Subscription{C: c, c: c}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+	} else {
+		b.subs = append(b.subs, sub)
+	}
+	return sub
+}
+
+// Publish hands e to every subscription with room for it, timestamping it
+// now if it isn't already.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	for _, sub := range b.subs {
+		select {
+		case sub.c <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Close closes every subscription once it's been handed the events
+// published so far. Events published after it are dropped.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, sub := range b.subs {
+		close(sub.c)
+	}
+}
+
+// WriteJSON writes each of the subscription's events to w as a line of
+// JSON until the subscription is closed. It keeps reading after a write
+// fails, so the run's events aren't backed up, and returns the first error.
+func WriteJSON(sub *Subscription, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	var first error
+	for e := range sub.C {
+		if err := encoder.Encode(e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
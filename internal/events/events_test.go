@@ -0,0 +1,157 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// drain reads a closed subscription's events.
+func drain(sub *Subscription) []Event {
+	var events []Event
+	for e := range sub.C {
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestEachSubscriberGetsEventsInOrder(t *testing.T) {
+	bus := NewBus()
+	subs := []*Subscription{bus.Subscribe(1000), bus.Subscribe(1000)}
+
+	// From several publishers at once: each subscriber sees the same order,
+	// and each publisher's events in the order it published them.
+	const publishers, each = 4, 100
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				bus.Publish(Event{Kind: ArchiveDeleted, Vault: fmt.Sprint(p), Deleted: i})
+			}
+		}(p)
+	}
+	wg.Wait()
+	bus.Close()
+
+	var orders [][]Event
+	for _, sub := range subs {
+		events := drain(sub)
+		if len(events) != publishers*each || sub.Dropped() != 0 {
+			t.Fatalf("got %d events with %d dropped, want %d", len(events), sub.Dropped(), publishers*each)
+		}
+		next := make(map[string]int)
+		for _, e := range events {
+			if e.Deleted != next[e.Vault] {
+				t.Fatalf("publisher %s's event %d came when %d was next", e.Vault, e.Deleted, next[e.Vault])
+			}
+			next[e.Vault]++
+			if e.Time.IsZero() {
+				t.Errorf("event %+v wasn't timestamped", e)
+			}
+		}
+		orders = append(orders, events)
+	}
+	for i := range orders[0] {
+		if a, b := orders[0][i], orders[1][i]; a.Vault != b.Vault || a.Deleted != b.Deleted {
+			t.Fatalf("event %d was %+v for one subscriber and %+v for the other", i, a, b)
+		}
+	}
+}
+
+func TestPublishDropsWhatAFullSubscriberHasNoRoomFor(t *testing.T) {
+	bus := NewBus()
+	slow, roomy := bus.Subscribe(2), bus.Subscribe(10)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			bus.Publish(Event{Kind: ArchiveDeleted, Deleted: i})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publishing waited for a subscriber nobody reads")
+	}
+	bus.Close()
+
+	if got := drain(slow); len(got) != 2 || got[0].Deleted != 0 || got[1].Deleted != 1 || slow.Dropped() != 3 {
+		t.Errorf("the full subscriber got %+v with %d dropped, want the first 2 with 3 dropped", got, slow.Dropped())
+	}
+	if got := drain(roomy); len(got) != 5 || roomy.Dropped() != 0 {
+		t.Errorf("the subscriber with room got %d events with %d dropped, want all 5", len(got), roomy.Dropped())
+	}
+}
+
+func TestClose(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(10)
+	bus.Publish(Event{Kind: VaultSelected, Vault: "photos"})
+	bus.Close()
+	bus.Close()
+	// Dropped once closed, without counting against the subscription.
+	bus.Publish(Event{Kind: VaultSelected, Vault: "later"})
+
+	if got := drain(sub); len(got) != 1 || got[0].Vault != "photos" {
+		t.Errorf("got %+v, want only the event published before Close", got)
+	}
+	if sub.Dropped() != 0 {
+		t.Errorf("counted %d dropped", sub.Dropped())
+	}
+	if got := drain(bus.Subscribe(10)); len(got) != 0 {
+		t.Errorf("subscribing after Close got %+v", got)
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var bus *Bus
+	bus.Publish(Event{Kind: RunFinished})
+	bus.Close()
+}
+
+// failingWriter fails every write after the first.
+type failingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errors.New("the disk is full")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWriteJSON(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(10)
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	bus.Publish(Event{Kind: ArchiveDeleted, Time: at, Region: "us-east-1", Vault: "photos", ArchiveId: "a"})
+	bus.Publish(Event{Kind: ArchiveFailed, Time: at, Region: "us-east-1", Vault: "photos", ArchiveId: "b", Error: "throttled"})
+	bus.Publish(Event{Kind: RunFinished, Time: at, Vaults: 1})
+	bus.Close()
+
+	// It reads on past the failure, to the subscription's close, and
+	// returns the failure.
+	w := &failingWriter{}
+	err := WriteJSON(sub, w)
+	if err == nil || !strings.Contains(err.Error(), "the disk is full") {
+		t.Errorf("got %v, want the failed write's error", err)
+	}
+	if len(sub.C) != 0 {
+		t.Errorf("%d event(s) were left unread", len(sub.C))
+	}
+	var e Event
+	if err := json.Unmarshal(w.Bytes(), &e); err != nil || e.ArchiveId != "a" || !e.Time.Equal(at) {
+		t.Errorf("wrote %q, want the first event", w.String())
+	}
+}
@@ -0,0 +1,120 @@
+// Package plan holds a cleanup decided ahead of time: the vaults to destroy,
+// written to a file that can be reviewed and attached to a change ticket, and
+// later applied exactly as written.
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+)
+
+// Version is the plan file format written by this build.
+const Version = 1
+
+// ErrModified is returned by Read when a plan's contents don't match its hash.
+var ErrModified = errors.New("the plan has been modified since it was written")
+
+// Plan is every action a cleanup will take.
+type Plan struct {
+	Version   int       `json:"version"`
+	AccountId string    `json:"accountId"`
+	CreatedAt time.Time `json:"createdAt"`
+	// DeleteVaults deletes each vault once its archives are gone.
+	DeleteVaults bool     `json:"deleteVaults"`
+	Vaults       []*Vault `json:"vaults"`
+
+	// Hash is the SHA-256 of the rest of the plan, so an edited plan is
+	// refused.
+	Hash string `json:"hash"`
+}
+
+// Vault is one vault the plan destroys. Its archive count and size are as of
+// the vault's last inventory when the plan was made.
+type Vault struct {
+	Region            string     `json:"region"`
+	Name              string     `json:"name"`
+	ARN               string     `json:"arn"`
+	Archives          int64      `json:"archives"`
+	SizeInBytes       int64      `json:"sizeInBytes"`
+	LastInventoryDate *time.Time `json:"lastInventoryDate,omitempty"`
+	InventoryJobId    string     `json:"inventoryJobId,omitempty"` // initiated by plan --inventory
+}
+
+func (p *Plan) sum() (string, error) {
+	unsealed := *p
+	unsealed.Hash = ""
+	data, err := json.Marshal(&unsealed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Write seals the plan with its hash and saves it to path.
+func Write(path string, p *Plan) error {
+	p.Version = Version
+	p.CreatedAt = p.CreatedAt.UTC()
+
+	var err error
+	if p.Hash, err = p.sum(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+// Read loads the plan at path, returning ErrModified if it isn't exactly as
+// Write left it.
+func Read(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	p := &Plan{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	if p.Version > Version {
+		return nil, fmt.Errorf("plan %s has format version %d, from a newer ice-breaker; this one applies up to version %d, so upgrade it to apply the plan", path, p.Version, Version)
+	}
+	if p.Version != Version {
+		return nil, fmt.Errorf("plan %s has format version %d; this build only applies version %d", path, p.Version, Version)
+	}
+
+	sum, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if sum != p.Hash {
+		return nil, fmt.Errorf("plan %s: %w", path, ErrModified)
+	}
+	for _, v := range p.Vaults {
+		if err := glacierpurge.CheckRegion(v.Region); err != nil {
+			return nil, fmt.Errorf("plan %s, vault %s: %w", path, v.Name, err)
+		}
+	}
+	return p, nil
+}
+
+// Check refuses to apply the plan with credentials for a different account.
+func (p *Plan) Check(accountId string) error {
+	if accountId != p.AccountId {
+		return fmt.Errorf("the plan is for account %s, but the credentials are for account %s", p.AccountId, accountId)
+	}
+	return nil
+}
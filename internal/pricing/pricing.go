@@ -0,0 +1,265 @@
+// Package pricing estimates what Glacier storage costs, from a small table of
+// per-region prices embedded in the binary, which a file can override.
+package pricing
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+//go:embed prices.json
+var embedded []byte
+
+// StaleAfter is how old a table's prices can get before they're flagged as
+// possibly out of date.
+const StaleAfter = 365 * 24 * time.Hour
+
+// Table is Glacier's storage prices, per region, as of a date.
+type Table struct {
+	AsOf     Date   `json:"asOf"`
+	Currency string `json:"currency"`
+	// MinimumStorageDays is how long Glacier charges for an archive's
+	// storage, however soon it's deleted.
+	MinimumStorageDays int `json:"minimumStorageDays"`
+	// StoragePerGBMonth is what a GB stored for a month costs, per region.
+	StoragePerGBMonth map[string]float64 `json:"storagePerGBMonth"`
+}
+
+// Date is a day, written as 2006-01-02.
+type Date struct {
+	time.Time
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+// Default returns the embedded table.
+func Default() *Table {
+	t := &Table{}
+	if err := json.Unmarshal(embedded, t); err != nil {
+		panic(fmt.Sprintf("invalid embedded price table: %v", err))
+	}
+	return t
+}
+
+// Load returns the embedded table with the prices in the file at path laid
+// over it: the file's regions replace the embedded ones, and its other fields
+// any it sets. An empty path gives the embedded table alone.
+func Load(path string) (*Table, error) {
+	t := Default()
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price file: %w", err)
+	}
+	override := &Table{}
+	if err := json.Unmarshal(data, override); err != nil {
+		return nil, fmt.Errorf("failed to parse price file %s: %w", path, err)
+	}
+
+	if !override.AsOf.IsZero() {
+		t.AsOf = override.AsOf
+	}
+	if override.Currency != "" {
+		t.Currency = override.Currency
+	}
+	if override.MinimumStorageDays > 0 {
+		t.MinimumStorageDays = override.MinimumStorageDays
+	}
+	for region, price := range override.StoragePerGBMonth {
+		t.StoragePerGBMonth[region] = price
+	}
+	return t, nil
+}
+
+// bytesPerGB is the GB prices are quoted per, which AWS bills as a GiB.
+const bytesPerGB = 1 << 30
+
+// Monthly returns what storing size bytes in region costs a month, and
+// whether the table has a price for the region.
+func (t *Table) Monthly(region string, size int64) (float64, bool) {
+	price, ok := t.StoragePerGBMonth[region]
+	if !ok {
+		return 0, false
+	}
+	return float64(size) / bytesPerGB * price, true
+}
+
+// Stale reports whether the table's prices are older than StaleAfter as of
+// now.
+func (t *Table) Stale(now time.Time) bool {
+	return now.Sub(t.AsOf.Time) > StaleAfter
+}
+
+// Format formats an amount in the table's currency, e.g. "$12.34" or
+// "12.34 EUR".
+func (t *Table) Format(amount float64) string {
+	if t.Currency == "USD" {
+		return fmt.Sprintf("$%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, t.Currency)
+}
+
+// Savings adds up the storage a deletion frees, and what it saves.
+type Savings struct {
+	table *Table
+
+	Bytes   int64
+	Monthly float64 // for the bytes in regions the table has a price for
+	// Unpriced is the bytes in regions the table has no price for, which
+	// are listed in UnpricedRegions.
+	Unpriced        int64
+	UnpricedRegions []string
+}
+
+// Savings returns an empty Savings priced with t.
+func (t *Table) Savings() *Savings {
+	return &Savings{table: t}
+}
+
+// Add counts size bytes stored in region.
+func (s *Savings) Add(region string, size int64) {
+	s.Bytes += size
+	monthly, ok := s.table.Monthly(region, size)
+	if ok {
+		s.Monthly += monthly
+		return
+	}
+	s.Unpriced += size
+	if !slices.Contains(s.UnpricedRegions, region) {
+		s.UnpricedRegions = append(s.UnpricedRegions, region)
+	}
+}
+
+// String describes the savings, e.g. "1.5 TiB: an estimated $5.53 a month,
+// $66.36 a year of storage (Glacier prices as of 2024-06-01)".
+func (s *Savings) String() string {
+	text := ui.Bytes(s.Bytes)
+	if s.Unpriced < s.Bytes {
+		stale := ""
+		if s.table.Stale(time.Now()) {
+			stale = ", which may be out of date"
+		}
+		text += fmt.Sprintf(": an estimated %s a month, %s a year of storage (Glacier prices as of %s%s)",
+			s.table.Format(s.Monthly), s.table.Format(s.Monthly*12), s.table.AsOf.Format("2006-01-02"), stale)
+	}
+	switch {
+	case s.Unpriced == s.Bytes && s.Bytes > 0:
+		text += fmt.Sprintf(" (the price table has no price for %s)", strings.Join(s.UnpricedRegions, ", "))
+	case s.Unpriced > 0:
+		text += fmt.Sprintf("; %s in %s, which the price table has no price for", ui.Bytes(s.Unpriced), strings.Join(s.UnpricedRegions, ", "))
+	}
+	return text
+}
+
+// MinimumStorage is how long Glacier charges for an archive's storage,
+// however soon it's deleted.
+func (t *Table) MinimumStorage() time.Duration {
+	return time.Duration(t.MinimumStorageDays) * 24 * time.Hour
+}
+
+// EarlyDeletionFee estimates what deleting size bytes stored in region since
+// created costs at deleted, before the minimum storage duration is up: the
+// storage charge for the rest of it, prorated to the fraction of a day. It's
+// 0 once the duration is up. It returns false if the table has no price for
+// the region.
+func (t *Table) EarlyDeletionFee(region string, size int64, created, deleted time.Time) (float64, bool) {
+	monthly, ok := t.Monthly(region, size)
+	if !ok {
+		return 0, false
+	}
+	// An archive created "after" deleted only means the clocks disagree.
+	stored := max(deleted.Sub(created), 0)
+	remaining := t.MinimumStorage() - stored
+	if remaining <= 0 {
+		return 0, true
+	}
+	// AWS prorates the monthly price over a 30-day month.
+	return monthly * remaining.Hours() / (30 * 24), true
+}
+
+// EarlyFees adds up the estimated early-deletion fees of the archives deleted
+// before their minimum storage duration is up. It's safe for concurrent use.
+type EarlyFees struct {
+	table *Table
+
+	mu       sync.Mutex
+	fee      float64
+	archives int   // younger than the minimum storage duration
+	bytes    int64 // of those archives
+	unpriced bool  // some of them were in regions the table has no price for
+}
+
+// EarlyFees returns an empty EarlyFees priced with t.
+func (t *Table) EarlyFees() *EarlyFees {
+	return &EarlyFees{table: t}
+}
+
+// Add counts size bytes stored in region since created, deleted at deleted.
+func (f *EarlyFees) Add(region string, size int64, created, deleted time.Time) {
+	if deleted.Sub(created) >= f.table.MinimumStorage() {
+		return
+	}
+	fee, ok := f.table.EarlyDeletionFee(region, size, created, deleted)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fee += fee
+	f.archives++
+	f.bytes += size
+	f.unpriced = f.unpriced || !ok
+}
+
+// Merge adds other's counts to f's.
+func (f *EarlyFees) Merge(other *EarlyFees) {
+	other.mu.Lock()
+	fee, archives, bytes, unpriced := other.fee, other.archives, other.bytes, other.unpriced
+	other.mu.Unlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fee += fee
+	f.archives += archives
+	f.bytes += bytes
+	f.unpriced = f.unpriced || unpriced
+}
+
+// Total returns the fees so far, and how many archives younger than the
+// minimum storage duration they're for, totalling how many bytes.
+func (f *EarlyFees) Total() (fee float64, archives int, bytes int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fee, f.archives, f.bytes
+}
+
+// String describes the fees, e.g. "an estimated $0.42 in early-deletion fees
+// for 12 archive(s), 1.5 GiB, stored for less than 90 days".
+func (f *EarlyFees) String() string {
+	f.mu.Lock()
+	fee, archives, bytes, unpriced := f.fee, f.archives, f.bytes, f.unpriced
+	f.mu.Unlock()
+	text := fmt.Sprintf("an estimated %s in early-deletion fees for %d archive(s), %s, stored for less than %d days", f.table.Format(fee), archives, ui.Bytes(bytes), f.table.MinimumStorageDays)
+	if unpriced {
+		text += ", though the fee leaves out those in regions the price table has no price for"
+	}
+	return text
+}
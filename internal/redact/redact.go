@@ -0,0 +1,120 @@
+// Package redact keeps the secrets ice-breaker is given out of everything it
+// writes: messages, logs, machine-readable output, recordings, events, the
+// audit log, and panics. Secrets are registered as soon as they're known;
+// everything written afterwards has them masked.
+package redact
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Mask is what a secret is replaced with.
+const Mask = "[redacted]"
+
+// minLength is the shortest secret masked; anything shorter would mask
+// innocent text, and is no real secret anyway.
+const minLength = 8
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+	masker  *strings.Replacer
+)
+
+// Add registers secrets to be masked from now on, along with their URL
+// escaped forms, in which they'd appear in a dumped request. Empty and very
+// short ones are ignored.
+func Add(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, value := range values {
+		if len(value) < minLength {
+			continue
+		}
+		for _, form := range []string{value, url.QueryEscape(value), url.PathEscape(value)} {
+			if !slices.Contains(secrets, form) {
+				secrets = append(secrets, form)
+			}
+		}
+	}
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, Mask)
+	}
+	masker = strings.NewReplacer(pairs...)
+}
+
+// String returns s with every registered secret masked.
+func String(s string) string {
+	mu.RLock()
+	m := masker
+	mu.RUnlock()
+	if m == nil {
+		return s
+	}
+	return m.Replace(s)
+}
+
+// Bytes returns p with every registered secret masked. It's p itself when
+// there's nothing to mask.
+func Bytes(p []byte) []byte {
+	mu.RLock()
+	m, registered := masker, secrets
+	mu.RUnlock()
+	if m == nil {
+		return p
+	}
+	for _, secret := range registered {
+		if bytes.Contains(p, []byte(secret)) {
+			return []byte(m.Replace(string(p)))
+		}
+	}
+	return p
+}
+
+// Writer masks the registered secrets in everything written to it before
+// passing it on. Each write is masked on its own, so it's meant for writers
+// given whole messages or lines, as loggers and encoders give them.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a Writer passing what's written on to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes p, masked, and reports all of p written if it all was.
+func (w *Writer) Write(p []byte) (int, error) {
+	masked := Bytes(p)
+	n, err := w.w.Write(masked)
+	if err != nil {
+		return min(n, len(p)), err
+	}
+	return len(p), nil
+}
+
+// Target returns the writer w passes what's written on to.
+func (w *Writer) Target() io.Writer {
+	return w.w
+}
+
+// Panics, deferred first thing in main, reports a panic the way the runtime
+// would, and with the same exit status, but with the registered secrets
+// masked in its value and stack. Panics in other goroutines get past it.
+func Panics() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "panic: %s\n\n%s", String(fmt.Sprint(r)), Bytes(debug.Stack()))
+	os.Exit(2)
+}
@@ -0,0 +1,68 @@
+package redact
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// sentinel is a secret no output may repeat.
+const sentinel = "SENTINEL/SECRET+4f1d9c2b"
+
+// panicEnv has the test binary panic with the sentinel, registered, instead
+// of running the tests.
+const panicEnv = "REDACT_TEST_PANIC"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(panicEnv) == "1" {
+		defer Panics()
+		Add(sentinel)
+		panic("couldn't use the key " + sentinel)
+	}
+	os.Exit(m.Run())
+}
+
+func TestMasked(t *testing.T) {
+	Add(sentinel, "short")
+	for _, form := range []string{sentinel, url.QueryEscape(sentinel), url.PathEscape(sentinel)} {
+		text := "the key is " + form + ", as given"
+		if got := String(text); got != "the key is "+Mask+", as given" {
+			t.Errorf("String(%q) = %q", text, got)
+		}
+		if got := string(Bytes([]byte(text))); got != "the key is "+Mask+", as given" {
+			t.Errorf("Bytes(%q) = %q", text, got)
+		}
+	}
+	// Too short to be told from innocent text.
+	if got := String("short and sweet"); got != "short and sweet" {
+		t.Errorf("masked a short secret: %q", got)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	line := "error: " + sentinel + "\n"
+	if n, err := w.Write([]byte(line)); n != len(line) || err != nil {
+		t.Errorf("Write = %d, %v; want %d, nil", n, err, len(line))
+	}
+	if buf.String() != "error: "+Mask+"\n" {
+		t.Errorf("wrote %q", buf.String())
+	}
+}
+
+func TestPanics(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), panicEnv+"=1")
+	output, err := cmd.CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 2 {
+		t.Errorf("got %v, want exit status 2 as for a panic", err)
+	}
+	if strings.Contains(string(output), "SENTINEL") {
+		t.Errorf("the panic has the secret:\n%s", output)
+	}
+	if !strings.Contains(string(output), "panic: couldn't use the key "+Mask) || !strings.Contains(string(output), "goroutine") {
+		t.Errorf("got %s, want the panic with its stack, the secret masked", output)
+	}
+}
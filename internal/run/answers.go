@@ -0,0 +1,116 @@
+package run
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// Answers are scripted replies to Select's prompts, read from a file where
+// each line is a pattern and y or n:
+//
+//	# destroy the old backups, keep everything else in us-east-1
+//	us-east-1/backup-2019-* y
+//	us-east-1/*             n
+//	scratch                 y
+//
+// A pattern is a glob matched against region/vault, or against the vault name
+// alone in any region if it has no slash. The first matching line wins.
+type Answers struct {
+	path  string
+	lines []*answer
+}
+
+type answer struct {
+	line    int
+	pattern string
+	yes     bool
+	used    bool
+}
+
+// LoadAnswers reads an answers file.
+func LoadAnswers(name string) (*Answers, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open answers file: %w", err)
+	}
+	defer f.Close()
+
+	answers := &Answers{path: name}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("answers file %s, line %d: want a pattern and y or n", name, n)
+		}
+		a := &answer{line: n, pattern: fields[0]}
+		switch strings.ToLower(fields[1]) {
+		case "y", "yes":
+			a.yes = true
+		case "n", "no":
+		default:
+			return nil, fmt.Errorf("answers file %s, line %d: answer %q must be y or n", name, n, fields[1])
+		}
+		if _, err := path.Match(a.pattern, ""); err != nil {
+			return nil, fmt.Errorf("answers file %s, line %d: invalid pattern %q: %w", name, n, a.pattern, err)
+		}
+		// A region spelled out rather than matched is checked, since a typo
+		// would otherwise just never match.
+		if region, _, ok := strings.Cut(a.pattern, "/"); ok && !strings.ContainsAny(region, `*?[\`) {
+			if err := glacierpurge.CheckRegion(region); err != nil {
+				return nil, fmt.Errorf("answers file %s, line %d: %w", name, n, err)
+			}
+		}
+		answers.lines = append(answers.lines, a)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read answers file: %w", err)
+	}
+
+	return answers, nil
+}
+
+// lookup returns the line answering for vault, or nil if there isn't one.
+func (a *Answers) lookup(vault *glacierpurge.Vault) *answer {
+	if a == nil {
+		return nil
+	}
+	var first *answer
+	for _, line := range a.lines {
+		name := vault.Glacier.Region + "/" + vault.Name
+		if !strings.Contains(line.pattern, "/") {
+			name = vault.Name
+		}
+		if matched, _ := path.Match(line.pattern, name); matched {
+			// Every matching line counts as used, so one shadowed by an
+			// earlier line isn't reported as a typo.
+			line.used = true
+			if first == nil {
+				first = line
+			}
+		}
+	}
+	return first
+}
+
+// warnUnused warns about lines that answered no vault, most likely typos.
+func (a *Answers) warnUnused() {
+	if a == nil {
+		return
+	}
+	for _, line := range a.lines {
+		if !line.used {
+			ui.Printf("%sAnswers file %s, line %d: no such vault %q%s\n", ui.Yellow, a.path, line.line, line.pattern, ui.Reset)
+		}
+	}
+}
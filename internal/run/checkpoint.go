@@ -0,0 +1,77 @@
+package run
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// Checkpoint is how often a vault's progress through its inventory is
+// recorded in the state file: once Every archives have been dealt with since
+// it last was, or once Interval has passed, whichever comes first. With both
+// zero it's only recorded as the vault stops.
+type Checkpoint struct {
+	Every    int
+	Interval time.Duration
+}
+
+// checkpointer records how far a job's deletions have got through its
+// inventory as often as the options' Checkpoint asks.
+type checkpointer struct {
+	job  *glacierpurge.InventoryJob
+	opts Options
+	skip int64 // where the recorded checkpoint has the deletions start
+
+	mu      sync.Mutex
+	n       int64 // archives at the head of the inventory dealt with
+	saved   int64 // as last recorded
+	savedAt time.Time
+}
+
+// checkpointer returns the checkpointer of the job, starting from the
+// checkpoint recorded for it, if any.
+func (o Options) checkpointer(job *glacierpurge.InventoryJob) *checkpointer {
+	c := &checkpointer{job: job, opts: o, savedAt: o.clock().Now()}
+	if o.store == nil {
+		return c
+	}
+	if recorded, ok := o.store.Job(job.Vault.Glacier.Region, job.Vault.Name); ok && recorded.JobId == job.Id {
+		c.skip = recorded.Done
+	}
+	c.n, c.saved = c.skip, c.skip
+	return c
+}
+
+// done notes that the first n archives have all been dealt with, recording
+// it if a checkpoint is due.
+func (c *checkpointer) done(n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n = n
+	policy := c.opts.Checkpoint
+	if policy.Every > 0 && n-c.saved >= int64(policy.Every) ||
+		policy.Interval > 0 && c.opts.clock().Now().Sub(c.savedAt) >= policy.Interval {
+		c.save()
+	}
+}
+
+// flush records how far the deletions got, once they've stopped.
+func (c *checkpointer) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.n != c.saved {
+		c.save()
+	}
+}
+
+func (c *checkpointer) save() {
+	c.saved, c.savedAt = c.n, c.opts.clock().Now()
+	if c.opts.store == nil {
+		return
+	}
+	if err := c.opts.store.PutDone(c.job.Vault.Glacier.Region, c.job.Vault.Name, c.job.Id, c.n); err != nil {
+		ui.Printf("%sCouldn't checkpoint vault %s in the state file: %v%s\n", ui.Yellow, c.job.Vault.Name, err, ui.Reset)
+	}
+}
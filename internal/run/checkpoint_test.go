@@ -0,0 +1,109 @@
+package run
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"go.uber.org/goleak"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+	"github.com/rdegges/ice-breaker/internal/state"
+)
+
+func TestCheckpointPolicy(t *testing.T) {
+	type step struct {
+		advance time.Duration // how long goes by first
+		done    int64         // the archives dealt with by then
+		want    int64         // the checkpoint recorded after it
+	}
+	for _, c := range []struct {
+		name   string
+		policy Checkpoint
+		steps  []step
+	}{
+		{"every 10 archives", Checkpoint{Every: 10}, []step{{0, 5, 0}, {0, 9, 0}, {0, 10, 10}, {time.Hour, 19, 10}, {0, 25, 25}}},
+		{"every minute", Checkpoint{Interval: time.Minute}, []step{{0, 500, 0}, {30 * time.Second, 600, 0}, {30 * time.Second, 700, 700}, {0, 800, 700}, {time.Minute, 801, 801}}},
+		{"whichever comes first", Checkpoint{Every: 100, Interval: time.Minute}, []step{{0, 100, 100}, {time.Minute, 101, 101}, {0, 150, 101}, {0, 201, 201}}},
+		{"only as the vault stops", Checkpoint{}, []step{{0, 100, 0}, {time.Hour, 10_000, 0}}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			clock := glaciertest.NewClock(testStart)
+			store := newTestStore(t)
+			job := &glacierpurge.InventoryJob{Vault: &glacierpurge.Vault{Glacier: newTestGlacier(t, glaciertest.New(), clock), Name: "photos"}, Id: "job-1"}
+			record(store, job, 0, nil)
+			checkpoint := Options{Checkpoint: c.policy, Clock: clock, store: store}.checkpointer(job)
+
+			for _, s := range c.steps {
+				clock.Advance(s.advance)
+				checkpoint.done(s.done)
+				if recorded, _ := store.Job(job.Vault.Glacier.Region, job.Vault.Name); recorded.Done != s.want {
+					t.Errorf("with %d done after %v, recorded %d; want %d", s.done, s.advance, recorded.Done, s.want)
+				}
+			}
+			checkpoint.flush()
+			last := c.steps[len(c.steps)-1].done
+			if recorded, _ := store.Job(job.Vault.Glacier.Region, job.Vault.Name); recorded.Done != last {
+				t.Errorf("flushed %d, want %d", recorded.Done, last)
+			}
+		})
+	}
+}
+
+// TestResumePassesOverTheCheckpoint stops a run part way through a vault,
+// then checks a resume only asks Glacier to delete the archives after its
+// checkpoint.
+func TestResumePassesOverTheCheckpoint(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	w := newWorld(t, map[string]int{"us-east-1/photos": 200})
+	store := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var deletions atomic.Int64
+	w.intercept(func(*glacier.DeleteArchiveInput) error {
+		if deletions.Add(1) == 120 {
+			cancel()
+		}
+		return nil
+	})
+	opts := Options{WorkersPerVault: 8, Checkpoint: Checkpoint{Every: 25}}
+
+	w.destroy(ctx, t, store, opts)
+	recorded, ok := store.Job("us-east-1", "photos")
+	deleted := 200 - w.left()["us-east-1/photos"]
+	if !ok || recorded.Done == 0 || recorded.Done > int64(deleted) {
+		t.Fatalf("recorded %+v, want the job checkpointed at up to the %d archives deleted", recorded, deleted)
+	}
+
+	w.intercept(func(*glacier.DeleteArchiveInput) error { return nil })
+	fake := w.fakes["us-east-1"]
+	calls := fake.Count(glaciertest.OpDeleteArchive)
+	var output lockedBuffer
+	r := &Runner{Clients: w.clients, Store: store, Clock: w.clock, Output: &output, Options: opts}
+	report := r.Resume(context.Background())
+	if err := r.Summarize(report); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if n, want := fake.Count(glaciertest.OpDeleteArchive)-calls, 200-int(recorded.Done); n != want {
+		t.Errorf("the resume made %d deletions, want the %d after the checkpoint", n, want)
+	}
+	if passed := report.Results[0].Purge.Passed; passed != int(recorded.Done) {
+		t.Errorf("the resume passed over %d archive(s), want %d", passed, recorded.Done)
+	}
+	if !strings.Contains(output.String(), "Passing over the first") {
+		t.Errorf("the resume didn't say it passed over any archives:\n%s", output.String())
+	}
+	if left := w.left()["us-east-1/photos"]; left != 0 {
+		t.Errorf("%d archives are left", left)
+	}
+	if _, ok := store.Job("us-east-1", "photos"); ok {
+		t.Error("the job is still recorded")
+	}
+	if outcome, _ := store.Outcome("us-east-1", "photos"); outcome.Status != state.StatusArchivesDone {
+		t.Errorf("the vault was recorded %q", outcome.Status)
+	}
+}
@@ -0,0 +1,84 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/events"
+	"github.com/rdegges/ice-breaker/internal/state"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// DeleteWhenInventoried deletes each of the emptied vaults as soon as Glacier
+// lets it, which is only once its next inventory, about a day after the last
+// archive went, shows it empty. A vault is tried every poll until it's gone,
+// failing if it has archives again, until maxWait passes, ctx ends, or a
+// wrap-up is requested. The error counts the vaults left. It waits by
+// opts.Clock.
+func DeleteWhenInventoried(ctx context.Context, vaults []*glacierpurge.Vault, store *state.Store, opts Options, poll, maxWait time.Duration) error {
+	clock := opts.clock()
+	deadline := clock.Now().Add(maxWait)
+	pending := vaults
+	failed := 0
+	for {
+		var left []*glacierpurge.Vault
+		for _, vault := range pending {
+			description, err := vault.Refresh(ctx)
+			if errors.Is(err, glacierpurge.ErrVaultNotFound) {
+				continue // deleted by someone else
+			}
+			if err == nil && description.NumberOfArchives > 0 {
+				if emptied, ok := store.EmptiedVault(vault.Glacier.Region, vault.Name); !ok || description.LastInventoryDate.After(emptied.EmptiedAt) {
+					failed++
+					ui.Printf("%sNot deleting vault %s in region %s: Glacier's inventory of %s counts %d archive(s) in it again.%s\n", ui.Red, vault.Name, vault.Glacier.Region, description.LastInventoryDate.Local().Format("2006-01-02 15:04"), description.NumberOfArchives, ui.Reset)
+					continue
+				}
+			}
+
+			err = vault.Delete(ctx)
+			switch {
+			case err == nil:
+				ui.Printf("%sVault %s deleted from region %s%s\n", ui.Green, vault.Name, vault.Glacier.Region, ui.Reset)
+				opts.Events.Publish(vaultEvent(events.VaultDeleted, vault))
+				mark(store, vault, state.Outcome{Status: state.StatusVaultDeleted})
+				if err := store.RemoveEmptied(vault.Glacier.Region, vault.Name); err != nil {
+					ui.Printf("%sCouldn't update the completed-work file: %v%s\n", ui.Yellow, err, ui.Reset)
+				}
+			case errors.Is(err, glacierpurge.ErrVaultNotFound):
+			case errors.Is(err, glacierpurge.ErrVaultNotEmpty):
+				left = append(left, vault)
+			case errors.As(err, new(*glacierpurge.PermissionError)) || ctx.Err() != nil:
+				failed++
+				ui.Printf("%sFailed to delete vault %s in region %s: %v%s\n", ui.Red, vault.Name, vault.Glacier.Region, err, ui.Reset)
+			default:
+				// Most likely passing; the next try may well get through.
+				ui.Printf("%sFailed to delete vault %s in region %s, trying again later: %v%s\n", ui.Yellow, vault.Name, vault.Glacier.Region, err, ui.Reset)
+				left = append(left, vault)
+			}
+		}
+
+		pending = left
+		if len(pending) == 0 {
+			break
+		}
+		if !clock.Now().Add(poll).Before(deadline) {
+			return fmt.Errorf("%d vault(s) still waiting for Glacier's next inventory after %s, and %d failed; run this again later to delete them", len(pending), maxWait, failed)
+		}
+		ui.Printf("Waiting for Glacier's next inventory of %d emptied vault(s) before it lets them be deleted; trying again in %s.\n", len(pending), poll)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d emptied vault(s) left undeleted: %w", len(pending), ctx.Err())
+		case <-pending[0].Glacier.WrapUp.Done():
+			return fmt.Errorf("%d emptied vault(s) left undeleted: %w", len(pending), glacierpurge.ErrWrappedUp)
+		case <-clock.After(poll):
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d vault(s) couldn't be deleted", failed)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+	"github.com/rdegges/ice-breaker/internal/state"
+)
+
+// emptiedVault creates a vault whose archives have all been deleted since
+// Glacier's last inventory of it, and records it as emptied now.
+func emptiedVault(t *testing.T, g *glacierpurge.Glacier, fake *glaciertest.Fake, store *state.Store, clock *glaciertest.Clock, name string) *glacierpurge.Vault {
+	t.Helper()
+	fake.AddVault(glaciertest.Vault{Name: name, Archives: make([]glaciertest.Archive, 3)})
+	for _, archive := range fake.Archives(name) {
+		if _, err := fake.DeleteArchive(context.Background(), &glacier.DeleteArchiveInput{VaultName: aws.String(name), ArchiveId: aws.String(archive.Id)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.PutEmptied(state.Emptied{Region: g.Region, Vault: name, EmptiedAt: clock.Now(), Deleted: 3}); err != nil {
+		t.Fatal(err)
+	}
+	return &glacierpurge.Vault{Glacier: g, Name: name}
+}
+
+// inventoryAt has Glacier take each vault's next inventory once the clock
+// reaches the time given for it, as the vault is next described.
+func inventoryAt(fake *glaciertest.Fake, clock *glaciertest.Clock, at map[string]time.Duration) {
+	fake.Intercept(glaciertest.OpDescribeVault, func(input any) error {
+		name := aws.ToString(input.(*glacier.DescribeVaultInput).VaultName)
+		if after, ok := at[name]; ok && !clock.Now().Before(testStart.Add(after)) {
+			fake.TakeInventory(name)
+			delete(at, name)
+		}
+		return nil
+	})
+}
+
+// deleteAttempts counts the DeleteVault calls made of each vault.
+func deleteAttempts(fake *glaciertest.Fake) map[string]int {
+	attempts := make(map[string]int)
+	for _, call := range fake.Calls(glaciertest.OpDeleteVault) {
+		attempts[aws.ToString(call.Input.(*glacier.DeleteVaultInput).VaultName)]++
+	}
+	return attempts
+}
+
+func TestDeleteWhenInventoriedPollSchedule(t *testing.T) {
+	tests := []struct {
+		name        string
+		inventoried map[string]time.Duration // when each vault's next inventory is taken
+		poll, wait  time.Duration
+		attempts    map[string]int
+		waits       int // polls waited through
+		err         string
+	}{
+		{
+			name:        "deleted at the next inventory",
+			inventoried: map[string]time.Duration{"a": 20 * time.Hour},
+			poll:        time.Hour,
+			wait:        48 * time.Hour,
+			attempts:    map[string]int{"a": 21},
+			waits:       20,
+		},
+		{
+			name:        "each vault at its own inventory",
+			inventoried: map[string]time.Duration{"a": 5 * time.Hour, "b": 10 * time.Hour},
+			poll:        time.Hour,
+			wait:        48 * time.Hour,
+			attempts:    map[string]int{"a": 6, "b": 11},
+			waits:       10,
+		},
+		{
+			name:        "inventoried between polls",
+			inventoried: map[string]time.Duration{"a": 90 * time.Minute},
+			poll:        time.Hour,
+			wait:        48 * time.Hour,
+			attempts:    map[string]int{"a": 3},
+			waits:       2,
+		},
+		{
+			name:        "giving up before a poll past the wait",
+			inventoried: map[string]time.Duration{"a": 100 * time.Hour},
+			poll:        2 * time.Hour,
+			wait:        5 * time.Hour,
+			attempts:    map[string]int{"a": 3},
+			waits:       2,
+			err:         "1 vault(s) still waiting for Glacier's next inventory after 5h0m0s",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := glaciertest.New()
+			clock := glaciertest.NewClock(testStart)
+			clock.Auto = true
+			g := newTestGlacier(t, fake, clock)
+			store := newTestStore(t)
+			var vaults []*glacierpurge.Vault
+			for name := range test.attempts {
+				vaults = append(vaults, emptiedVault(t, g, fake, store, clock, name))
+			}
+			inventoryAt(fake, clock, test.inventoried)
+
+			err := DeleteWhenInventoried(context.Background(), vaults, store, Options{Clock: clock}, test.poll, test.wait)
+			if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("got error %v, want %q", err, test.err)
+			}
+
+			if got := deleteAttempts(fake); fmt.Sprint(got) != fmt.Sprint(test.attempts) {
+				t.Errorf("got attempts %v, want %v", got, test.attempts)
+			}
+			if got, want := clock.Waits(), repeat(test.poll, test.waits); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("waited %v, want %v", got, want)
+			}
+			for name := range test.attempts {
+				deleted := !fake.HasVault(name)
+				if deleted != (test.err == "") {
+					t.Errorf("vault %s deleted: %t", name, deleted)
+				}
+				if _, emptied := store.EmptiedVault("us-east-1", name); emptied == deleted {
+					t.Errorf("vault %s still recorded as emptied: %t", name, emptied)
+				}
+				if outcome, _ := store.Outcome("us-east-1", name); deleted != (outcome.Status == state.StatusVaultDeleted) {
+					t.Errorf("vault %s recorded as %q", name, outcome.Status)
+				}
+			}
+		})
+	}
+}
+
+func repeat(d time.Duration, n int) []time.Duration {
+	waits := make([]time.Duration, n)
+	for i := range waits {
+		waits[i] = d
+	}
+	return waits
+}
+
+func TestDeleteWhenInventoriedLeavesAVaultWithArchivesAgain(t *testing.T) {
+	fake := glaciertest.New()
+	clock := glaciertest.NewClock(testStart)
+	clock.Auto = true
+	g := newTestGlacier(t, fake, clock)
+	store := newTestStore(t)
+	vault := emptiedVault(t, g, fake, store, clock, "a")
+	fake.Intercept(glaciertest.OpDescribeVault, func(any) error {
+		if clock.Now().Sub(testStart) == 2*time.Hour {
+			fake.Upload("a", glaciertest.Archive{Content: []byte("uploaded since")})
+			fake.TakeInventory("a")
+		}
+		return nil
+	})
+
+	err := DeleteWhenInventoried(context.Background(), []*glacierpurge.Vault{vault}, store, Options{Clock: clock}, time.Hour, 48*time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "1 vault(s) couldn't be deleted") {
+		t.Errorf("got %v, want the vault failed", err)
+	}
+	if n := fake.Count(glaciertest.OpDeleteVault); n != 2 {
+		t.Errorf("tried to delete the vault %d times, want 2", n)
+	}
+	if !fake.HasVault("a") {
+		t.Error("the vault was deleted")
+	}
+}
+
+func TestDeleteWhenInventoriedRetriesPassingFailures(t *testing.T) {
+	fake := glaciertest.New()
+	clock := glaciertest.NewClock(testStart)
+	clock.Auto = true
+	g := newTestGlacier(t, fake, clock)
+	store := newTestStore(t)
+	vault := emptiedVault(t, g, fake, store, clock, "a")
+	fake.TakeInventory("a")
+	fake.Script(glaciertest.OpDeleteVault,
+		glaciertest.Response{Err: glaciertest.Unavailable()},
+		glaciertest.Response{Err: glaciertest.Throttled()},
+	)
+
+	if err := DeleteWhenInventoried(context.Background(), []*glacierpurge.Vault{vault}, store, Options{Clock: clock}, time.Hour, 48*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := clock.Waits(); fmt.Sprint(got) != "[1h0m0s 1h0m0s]" {
+		t.Errorf("waited %v, want two polls", got)
+	}
+	if fake.HasVault("a") {
+		t.Error("the vault is still there")
+	}
+}
+
+func TestDeleteWhenInventoriedCanceled(t *testing.T) {
+	fake := glaciertest.New()
+	clock := glaciertest.NewClock(testStart)
+	g := newTestGlacier(t, fake, clock)
+	store := newTestStore(t)
+	vault := emptiedVault(t, g, fake, store, clock, "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- DeleteWhenInventoried(ctx, []*glacierpurge.Vault{vault}, store, Options{Clock: clock}, time.Hour, 48*time.Hour)
+	}()
+	for i := 0; i < 3; i++ {
+		if !clock.BlockUntil(1, 5*time.Second) {
+			t.Fatal("never waited for the next poll")
+		}
+		clock.Advance(time.Hour)
+	}
+	if !clock.BlockUntil(1, 5*time.Second) {
+		t.Fatal("never waited for the next poll")
+	}
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if n := fake.Count(glaciertest.OpDeleteVault); n != 4 {
+		t.Errorf("tried to delete the vault %d times, want 4", n)
+	}
+}
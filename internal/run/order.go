@@ -0,0 +1,60 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+)
+
+// The orders a run can work through its vaults in, for Options.Order.
+const (
+	// OrderAsSelected takes the vaults in the order they were selected, and
+	// with MaxConcurrentVaults above one lets them delete in the order their
+	// inventories complete.
+	OrderAsSelected = "as-selected"
+	// OrderSmallestFirst gets the quick vaults done first, and so finds out
+	// early whether the permissions are right.
+	OrderSmallestFirst = "smallest-first"
+	// OrderLargestFirst starts on the vaults that will take longest straight
+	// away.
+	OrderLargestFirst = "largest-first"
+)
+
+// ValidateOrder checks that order is one the run knows.
+func ValidateOrder(order string) error {
+	switch order {
+	case "", OrderAsSelected, OrderSmallestFirst, OrderLargestFirst:
+		return nil
+	}
+	return fmt.Errorf("invalid order %q: must be as-selected, smallest-first, or largest-first", order)
+}
+
+// rankVaults ranks vaults by order from 0, the first to be worked on, going
+// by the size Glacier's last inventory gives each. Vaults that can't be
+// described rank last. It returns nil for the order they were selected in,
+// which ranks them all alike.
+func rankVaults(ctx context.Context, vaults []*glacierpurge.Vault, order string) map[*glacierpurge.Vault]int {
+	if order == "" || order == OrderAsSelected {
+		return nil
+	}
+	sorted := slices.Clone(vaults)
+	SortVaults(ctx, sorted, SortOptions{By: "size", Desc: order == OrderLargestFirst})
+	ranks := make(map[*glacierpurge.Vault]int, len(sorted))
+	for i, vault := range sorted {
+		ranks[vault] = i
+	}
+	return ranks
+}
+
+// inOrder puts tasks in the order of their vaults' ranks.
+func inOrder(tasks []task, ranks map[*glacierpurge.Vault]int) {
+	if ranks == nil {
+		return
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return ranks[tasks[i].vault] < ranks[tasks[j].vault]
+	})
+}
@@ -0,0 +1,121 @@
+package run
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+)
+
+// The phases a vault goes through in a run.
+const (
+	PhaseQueued     = "queued"
+	PhaseInitiating = "waiting to initiate its inventory job"
+	PhaseInventory  = "waiting for its inventory job"
+	PhaseTurn       = "waiting for its turn to delete"
+	PhaseSalvaging  = "salvaging"
+	PhaseDeleting   = "deleting"
+	PhaseDone       = "done"
+	PhaseFailed     = "failed"
+	PhaseStopped    = "stopped early"
+)
+
+// Progress tracks what each vault of a run is doing, so a snapshot of the run
+// can be taken at any time while it goes. It's safe for concurrent use, and
+// a nil Progress tracks nothing.
+type Progress struct {
+	mu     sync.Mutex
+	vaults []*VaultProgress // in the order they were added
+}
+
+// VaultProgress is what a vault is doing.
+type VaultProgress struct {
+	Region, Vault string
+	Phase         string
+	Since         time.Time // when the vault entered Phase
+
+	JobId    string
+	JobSince time.Time // when the job was initiated, if known
+	Archives int64     // how many archives there are to delete, roughly, once deleting
+	Err      error     // why the vault failed
+}
+
+// NewProgress returns a Progress with no vaults.
+func NewProgress() *Progress {
+	return &Progress{}
+}
+
+// Vaults returns what every vault is doing, in the order they were added.
+func (p *Progress) Vaults() []VaultProgress {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vaults := make([]VaultProgress, len(p.vaults))
+	for i, v := range p.vaults {
+		vaults[i] = *v
+	}
+	return vaults
+}
+
+func (p *Progress) add(vault *glacierpurge.Vault) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.vaults = append(p.vaults, &VaultProgress{Region: vault.Glacier.Region, Vault: vault.Name, Phase: PhaseQueued, Since: time.Now()})
+}
+
+// update changes the vault's progress with f.
+func (p *Progress) update(vault *glacierpurge.Vault, f func(*VaultProgress)) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, v := range p.vaults {
+		if v.Region == vault.Glacier.Region && v.Vault == vault.Name {
+			f(v)
+			return
+		}
+	}
+}
+
+func (p *Progress) phase(vault *glacierpurge.Vault, phase string) {
+	p.update(vault, func(v *VaultProgress) {
+		if v.Phase != phase {
+			v.Phase, v.Since = phase, time.Now()
+		}
+	})
+}
+
+// job notes the inventory job the vault is waiting for, initiated at since
+// or, when that's zero, at some unknown time before.
+func (p *Progress) job(vault *glacierpurge.Vault, id string, since time.Time) {
+	p.update(vault, func(v *VaultProgress) {
+		v.JobId, v.JobSince = id, since
+	})
+}
+
+// archives notes roughly how many archives the vault has to delete.
+func (p *Progress) archives(vault *glacierpurge.Vault, n int64) {
+	p.update(vault, func(v *VaultProgress) {
+		v.Archives = n
+	})
+}
+
+// finished notes the outcome of the vault's task.
+func (p *Progress) finished(vault *glacierpurge.Vault, err error) {
+	p.update(vault, func(v *VaultProgress) {
+		v.Phase, v.Since, v.Err = PhaseDone, time.Now(), err
+		switch {
+		case errors.Is(err, glacierpurge.ErrWrappedUp):
+			v.Phase = PhaseStopped
+		case err != nil:
+			v.Phase = PhaseFailed
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package run
+
+import (
+	"context"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// What a rescan found of a vault, compared to before the run.
+const (
+	RescanGone      = "gone"      // there before, not any more
+	RescanRemaining = "remaining" // there before and still there
+	RescanNew       = "new"       // not there before the run
+	RescanUnknown   = "unknown"   // its region couldn't be scanned again
+)
+
+// RescanEntry is what a rescan found of a vault.
+type RescanEntry struct {
+	Region   string `json:"region"`
+	Vault    string `json:"vault"`
+	ARN      string `json:"arn,omitempty"`
+	Status   string `json:"status"`
+	Selected bool   `json:"selected"` // chosen for destruction in the run
+	// Archives is Glacier's count for a vault still there, as of its last
+	// inventory, LastInventory.
+	Archives      int64     `json:"archives"`
+	LastInventory time.Time `json:"lastInventory,omitempty"`
+}
+
+// Rescan scans the regions again, read-only, once the run is over, and
+// compares the vaults found with those found before it.
+func Rescan(ctx context.Context, registry Clients, regions []string, before, selected []*glacierpurge.Vault) ([]*RescanEntry, []*RegionResult) {
+	ui.Printf("\n%sRescanning %d region(s) to confirm what's left%s\n", ui.Bold, len(regions), ui.Reset)
+	after, scanned := Scan(ctx, registry, regions)
+
+	type key struct{ region, name string }
+	rescanned := map[string]bool{}
+	for _, result := range scanned {
+		rescanned[result.Region] = result.Status != RegionSkipped
+	}
+	chosen := map[key]bool{}
+	for _, v := range selected {
+		chosen[key{v.Glacier.Region, v.Name}] = true
+	}
+	found := map[key]*glacierpurge.Vault{}
+	for _, v := range after {
+		found[key{v.Glacier.Region, v.Name}] = v
+	}
+
+	var entries []*RescanEntry
+	seen := map[key]bool{}
+	add := func(v *glacierpurge.Vault, status string) {
+		k := key{v.Glacier.Region, v.Name}
+		entry := &RescanEntry{Region: k.region, Vault: k.name, ARN: v.ARN, Status: status, Selected: chosen[k]}
+		if now := found[k]; now != nil {
+			if description, err := now.Describe(ctx); err == nil {
+				entry.Archives, entry.LastInventory = description.NumberOfArchives, description.LastInventoryDate
+			}
+		}
+		entries = append(entries, entry)
+		seen[k] = true
+	}
+	for _, v := range before {
+		k := key{v.Glacier.Region, v.Name}
+		switch {
+		case !rescanned[k.region]:
+			add(v, RescanUnknown)
+		case found[k] != nil:
+			add(found[k], RescanRemaining)
+		default:
+			add(v, RescanGone)
+		}
+	}
+	for _, v := range after {
+		if !seen[key{v.Glacier.Region, v.Name}] {
+			add(v, RescanNew)
+		}
+	}
+	return entries, scanned
+}
+
+// SummarizeRescan prints what a rescan found.
+func SummarizeRescan(entries []*RescanEntry) {
+	rows := make([]ui.RescanRow, 0, len(entries))
+	for _, entry := range entries {
+		rows = append(rows, ui.RescanRow{
+			Region:        entry.Region,
+			Vault:         entry.Vault,
+			Status:        entry.Status,
+			Selected:      entry.Selected,
+			Archives:      entry.Archives,
+			LastInventory: entry.LastInventory,
+		})
+	}
+	ui.PrintRescan(rows)
+}
@@ -0,0 +1,142 @@
+package run
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/goleak"
+
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+	"github.com/rdegges/ice-breaker/internal/state"
+)
+
+// TestResumeFromEachStatus resumes a run that left one vault with each of the
+// outcomes a run records, and checks the resume only asks Glacier for what
+// that outcome leaves to do.
+func TestResumeFromEachStatus(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		outcome     state.Outcome
+		archives    int  // in the vault
+		job         bool // recorded for the vault too
+		retryFailed bool
+
+		ops    []string // the distinct operations doing work Glacier is asked for, in order
+		left   int      // archives left in the vault
+		status state.Status
+		says   string
+	}{
+		{
+			name:     "archives done",
+			outcome:  state.Outcome{Status: state.StatusArchivesDone},
+			archives: 3, // left alone by a filter
+			left:     3,
+			status:   state.StatusArchivesDone,
+			says:     "Skipping 1 vault(s) an earlier run finished with or left alone.",
+		},
+		{
+			name:    "vault deleted",
+			outcome: state.Outcome{Status: state.StatusVaultDeleted},
+			status:  state.StatusVaultDeleted,
+			says:    "Skipping 1 vault(s) an earlier run finished with or left alone.",
+		},
+		{
+			name:     "skipped",
+			outcome:  state.Outcome{Status: state.StatusSkipped, Reason: "its prompt was never answered"},
+			archives: 3,
+			left:     3,
+			status:   state.StatusSkipped,
+			says:     "Skipping 1 vault(s) an earlier run finished with or left alone.",
+		},
+		{
+			name:     "skipped with its job",
+			outcome:  state.Outcome{Status: state.StatusSkipped, Reason: "its prompt was never answered"},
+			archives: 3,
+			job:      true,
+			left:     3,
+			status:   state.StatusSkipped,
+			says:     "Leaving vault photos in region us-east-1 alone, as the run that recorded its job did: its prompt was never answered.",
+		},
+		{
+			name:    "vault delete pending",
+			outcome: state.Outcome{Status: state.StatusDeletePending},
+			ops:     []string{glaciertest.OpDeleteVault},
+			status:  state.StatusVaultDeleted,
+			says:    "Deleting vault photos in region us-east-1, which an earlier run emptied",
+		},
+		{
+			name:     "failed",
+			outcome:  state.Outcome{Status: state.StatusFailed, Reason: "AccessDeniedException"},
+			archives: 3,
+			left:     3,
+			status:   state.StatusFailed,
+		},
+		{
+			name:        "failed, retried",
+			outcome:     state.Outcome{Status: state.StatusFailed, Reason: "AccessDeniedException"},
+			archives:    3,
+			retryFailed: true,
+			ops:         []string{glaciertest.OpInitiateJob, glaciertest.OpGetJobOutput, glaciertest.OpDeleteArchive},
+			status:      state.StatusArchivesDone,
+			says:        "Retrying vault photos in region us-east-1, which failed at",
+		},
+		{
+			name:        "failed, retried on to deleting the vault",
+			outcome:     state.Outcome{Status: state.StatusFailed, Reason: "AccessDeniedException", DeleteVault: true},
+			archives:    3,
+			retryFailed: true,
+			ops:         []string{glaciertest.OpInitiateJob, glaciertest.OpGetJobOutput, glaciertest.OpDeleteArchive, glaciertest.OpDeleteVault},
+			// Glacier won't until its next inventory shows it empty.
+			status: state.StatusDeletePending,
+			says:   "Glacier won't delete vault photos until its next inventory",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			w := newWorld(t, map[string]int{"us-east-1/photos": c.archives})
+			fake := w.fakes["us-east-1"]
+			store := newTestStore(t)
+			// Recording a job forgets the vault's outcome, so it comes first.
+			if c.job {
+				if err := store.PutJob(state.Job{Region: "us-east-1", Vault: "photos", JobId: "job-1", InitiatedAt: testStart}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			outcome := c.outcome
+			outcome.Region, outcome.Vault, outcome.At = "us-east-1", "photos", testStart
+			if err := store.PutOutcome(outcome); err != nil {
+				t.Fatal(err)
+			}
+
+			var output lockedBuffer
+			r := &Runner{Clients: w.clients, Store: store, Clock: w.clock, Output: &output, Options: Options{WorkersPerVault: 4, RetryFailed: c.retryFailed}}
+			report := r.Resume(context.Background())
+			r.Summarize(report)
+
+			var ops []string
+			for _, call := range fake.Calls(glaciertest.OpInitiateJob, glaciertest.OpGetJobOutput, glaciertest.OpDeleteArchive, glaciertest.OpDeleteVault) {
+				if !slices.Contains(ops, call.Op) {
+					ops = append(ops, call.Op)
+				}
+			}
+			if !slices.Equal(ops, c.ops) {
+				t.Errorf("asked Glacier for %v, want %v", ops, c.ops)
+			}
+			if fake.HasVault("photos") {
+				if left := len(fake.Archives("photos")); left != c.left {
+					t.Errorf("%d archives are left, want %d", left, c.left)
+				}
+			} else if c.status != state.StatusVaultDeleted {
+				t.Error("the vault was deleted")
+			}
+			if recorded, _ := store.Outcome("us-east-1", "photos"); recorded.Status != c.status {
+				t.Errorf("the vault was recorded %q, want %q", recorded.Status, c.status)
+			}
+			if !strings.Contains(output.String(), c.says) {
+				t.Errorf("the resume didn't say %q:\n%s", c.says, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,1253 @@
+// Package run orchestrates a cleanup: scanning regions for vaults, asking
+// which ones to destroy, and emptying the vaults that were selected.
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/events"
+	"github.com/rdegges/ice-breaker/internal/pricing"
+	"github.com/rdegges/ice-breaker/internal/state"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// VaultResult records the outcome of destroying a single vault.
+type VaultResult struct {
+	Vault   *glacierpurge.Vault
+	Purge   *glacierpurge.PurgeResult
+	Err     error
+	Skipped bool // the user never answered the prompt for this vault
+	// Filtered is why the vault was left out of the run by a filter, such as
+	// a tag it carries; it's never prompted for or deleted.
+	Filtered string
+	// PossiblyIncomplete is set when the vault's inventory may have been
+	// missing recent uploads, so some archives may not have been deleted.
+	PossiblyIncomplete bool
+	// EarlyFees estimates the early-deletion fees of the archives deleted,
+	// when Options.Prices is set.
+	EarlyFees *pricing.EarlyFees
+}
+
+// scanConcurrency is how many regions Scan lists at once.
+const scanConcurrency = 8
+
+// Region dispositions recorded by Scan.
+const (
+	RegionScanned = "scanned" // listed, with vaults
+	RegionEmpty   = "empty"   // listed, without vaults
+	RegionSkipped = "skipped" // couldn't be listed
+)
+
+// Classes of error a region can be skipped for. They call for different
+// fixes.
+const (
+	ErrorCredentials  = "credentials"   // not valid in the region or its partition
+	ErrorAccessDenied = "access denied" // valid, but not allowed to list vaults
+	ErrorNetwork      = "network"       // the endpoint couldn't be reached
+	ErrorOther        = "other"
+)
+
+// RegionResult records what Scan made of a region.
+type RegionResult struct {
+	Region     string `json:"region"`
+	Status     string `json:"status"`
+	Vaults     int    `json:"vaults"`
+	ErrorClass string `json:"errorClass,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// Scan lists the vaults in every region, skipping regions whose client can't
+// be created or whose vaults can't be listed, and records what became of
+// each region. Regions are listed concurrently, but reported and returned in
+// the order given.
+func Scan(ctx context.Context, registry Clients, regions []string) ([]*glacierpurge.Vault, []*RegionResult) {
+	scanned := make([][]*glacierpurge.Vault, len(regions))
+	results := make([]*RegionResult, len(regions))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, scanConcurrency)
+	for i, region := range regions {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			scanned[i], results[i] = scanRegion(ctx, registry, region)
+		}(i, region)
+	}
+	wg.Wait()
+
+	var vaults []*glacierpurge.Vault
+	for i, region := range regions {
+		if results[i].Status == RegionSkipped {
+			ui.Printf("%sSkipping region %s: %s%s\n", ui.Yellow, region, results[i].Reason, ui.Reset)
+			continue
+		}
+		ui.Printf("Found %d Glacier Vault(s) in region %s%s%s%s\n", len(scanned[i]), ui.Green, ui.Bold, region, ui.Reset)
+		vaults = append(vaults, scanned[i]...)
+	}
+
+	return vaults, results
+}
+
+func scanRegion(ctx context.Context, registry Clients, region string) ([]*glacierpurge.Vault, *RegionResult) {
+	result := &RegionResult{Region: region, Status: RegionSkipped}
+
+	g, err := registry.Get(ctx, region)
+	if err != nil {
+		result.ErrorClass, result.Reason = ErrorOther, err.Error()
+		return nil, result
+	}
+	if g.Endpoint != "" {
+		ui.Debugf("Using Glacier endpoint %s for region %s", g.Endpoint, region)
+	}
+
+	vaults, err := g.GetVaults(ctx)
+	if err != nil {
+		result.ErrorClass, result.Reason = classifyError(err), err.Error()
+		if result.ErrorClass == ErrorCredentials {
+			result.Reason = fmt.Sprintf("the credentials aren't recognized in the %s partition; they most likely belong to a different AWS partition, or the region isn't enabled for the account", glacierpurge.PartitionOf(region))
+		}
+		return nil, result
+	}
+
+	result.Status, result.Vaults = RegionScanned, len(vaults)
+	if len(vaults) == 0 {
+		result.Status = RegionEmpty
+	}
+	return vaults, result
+}
+
+// classifyError sorts an error listing a region's vaults into one of the
+// Error classes.
+func classifyError(err error) string {
+	var netErr net.Error
+	var sendErr *smithyhttp.RequestSendError
+	switch {
+	case glacierpurge.IsUnrecognizedCredentials(err):
+		return ErrorCredentials
+	case glacierpurge.IsAccessDenied(err):
+		return ErrorAccessDenied
+	case errors.As(err, &sendErr), errors.As(err, &netErr):
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
+
+// SummarizeRegions prints what Scan made of every region.
+func SummarizeRegions(results []*RegionResult) {
+	rows := make([]ui.RegionRow, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, ui.RegionRow{
+			Region:     result.Region,
+			Status:     result.Status,
+			Vaults:     result.Vaults,
+			ErrorClass: result.ErrorClass,
+			Reason:     result.Reason,
+		})
+	}
+	ui.PrintRegions(rows)
+}
+
+// Confirmer asks the user yes/no questions, as ui.Prompter does.
+type Confirmer interface {
+	Confirm(ctx context.Context, question string) (bool, error)
+}
+
+// Select asks the user about each vault in turn and returns the ones they
+// confirmed for destruction. Vaults the answers file, which may be nil, has a
+// line for aren't asked about. If the input runs out before every vault has
+// been answered, the remaining vaults are returned as skipped rather than
+// treated as declined. Any other read error, or ctx ending, is returned.
+func Select(ctx context.Context, prompter Confirmer, answers *Answers, vaults []*glacierpurge.Vault) (selected []*glacierpurge.Vault, skipped []*glacierpurge.Vault, err error) {
+	defer answers.warnUnused()
+
+	for i, vault := range vaults {
+		warnStale(ctx, vault)
+
+		label := vault.Name
+		if tags := TagLabel(ctx, vault); tags != "" {
+			label += " (" + tags + ")"
+		}
+
+		var confirmed bool
+		if answer := answers.lookup(vault); answer != nil {
+			confirmed = answer.yes
+			reply := "n"
+			if confirmed {
+				reply = "y"
+			}
+			ui.Printf("[%s] %s: %s (answers file line %d)\n", vault.Glacier.Region, label, reply, answer.line)
+		} else {
+			confirmed, err = prompter.Confirm(ctx, fmt.Sprintf("[%s] %s: Would you like to destroy this vault?", vault.Glacier.Region, label))
+			if err == io.EOF {
+				ui.Printf("%sReached end of input; skipping the remaining %d vault(s).%s\n", ui.Yellow, len(vaults)-i, ui.Reset)
+				return selected, vaults[i:], nil
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if confirmed {
+			ui.Printf("%sVault %s in region %s marked for deletion.%s\n", ui.Green, vault.Name, vault.Glacier.Region, ui.Reset)
+			selected = append(selected, vault)
+		}
+	}
+
+	return selected, nil, nil
+}
+
+// Options controls how Destroy and Resume empty vaults.
+type Options struct {
+	// FailFast stops the run at the first vault that fails: the run's context
+	// is canceled and the vaults not yet started are reported as aborted.
+	// Without it every vault is attempted and the failures reported at the end.
+	FailFast bool
+
+	// Salvage, if set, downloads every vault's archives into a directory per
+	// vault beneath Salvage.Dir, and only deletes them once all of them have
+	// been verified.
+	Salvage *glacierpurge.SalvageOptions
+
+	// ReuseInventory uses an inventory job the vault already has, finished or
+	// still running, instead of always initiating a new one.
+	ReuseInventory bool
+
+	// DeleteVault deletes each vault once its archives are gone.
+	DeleteVault bool
+
+	// Inventory narrows the inventory jobs initiated, e.g. paginating them.
+	Inventory glacierpurge.InventoryOptions
+
+	// NoReinitiate fails a vault whose inventory job, or its output, has
+	// expired, as a job resumed a day or more after it completed has, instead
+	// of initiating a fresh one and waiting for that.
+	NoReinitiate bool
+
+	// CreatedBefore, if set, leaves any archive created after it alone, even
+	// if an inventory lists it. Applying a plan uses it so nothing uploaded
+	// since the plan was made is deleted.
+	CreatedBefore time.Time
+
+	// WorkersPerVault is how many of a vault's archives are deleted at once;
+	// defaults to 4. With AdaptiveWorkers it's the most at once instead.
+	WorkersPerVault int
+
+	// AdaptiveWorkers varies the deletions in flight with how much AWS
+	// throttles them.
+	AdaptiveWorkers bool
+
+	// MaxConcurrentVaults is how many vaults delete archives at once. Above
+	// one, every vault's inventory is waited for side by side, and vaults
+	// take a free slot in the order their inventories complete. Defaults to
+	// one vault at a time, start to finish.
+	MaxConcurrentVaults int
+
+	// MaxPendingJobs, if set, is how many vaults may have an inventory job
+	// initiated and not yet complete at once, when vaults are waited for side
+	// by side. Further jobs are initiated as earlier vaults move on to
+	// deleting. Jobs already initiated, by an earlier run or found for reuse,
+	// count towards it but are never held back.
+	MaxPendingJobs int
+
+	// Progress, if set, tracks what each vault is doing.
+	Progress *Progress
+
+	// Schedule, if set, tags each vault as scheduled for deletion until it's
+	// done.
+	Schedule *Schedule
+
+	// Prices, if set, estimates the early-deletion fees of the archives
+	// deleted from each vault.
+	Prices *pricing.Table
+
+	// Events, if set, is told what becomes of each vault and archive.
+	Events *events.Bus
+
+	// Order is the order the vaults are worked through in: OrderAsSelected,
+	// the default, OrderSmallestFirst, or OrderLargestFirst. By size, one
+	// vault at a time takes them in turn; above one at a time, the inventory
+	// jobs MaxPendingJobs holds back are initiated, and the vaults waiting for
+	// a free slot let in, in that order too.
+	Order string
+
+	// RetryFailed has Resume retry the vaults an earlier run failed, which
+	// it otherwise leaves alone.
+	RetryFailed bool
+
+	// Checkpoint is how often each vault's progress through its inventory is
+	// recorded, so a resume passes over the archives already dealt with.
+	Checkpoint Checkpoint
+
+	// Clock is what the run tells the time and waits by; the wall clock if
+	// nil.
+	Clock glacierpurge.Clock
+
+	deleting *gate                                      // set by Destroy and Resume from MaxConcurrentVaults
+	pending  *gate                                      // set by Destroy and Resume from MaxPendingJobs
+	ranks    map[*glacierpurge.Vault]int                // set by Destroy and Resume from Order
+	fees     map[*glacierpurge.Vault]*pricing.EarlyFees // set by Destroy and Resume from Prices
+	store    *state.Store                               // set by Destroy and Resume, to record each vault's outcome
+	// alsoDelete is set by Resume for the vaults an earlier run was to
+	// delete as well as empty, and windows for those whose work it narrowed.
+	alsoDelete map[*glacierpurge.Vault]bool
+	windows    map[*glacierpurge.Vault]*state.Window
+}
+
+// forVault returns the options for the vault's own work, deleting it too if
+// an earlier run was to, and keeping to the window that run narrowed it to.
+func (o Options) forVault(vault *glacierpurge.Vault) Options {
+	if o.alsoDelete[vault] {
+		o.DeleteVault = true
+	}
+	if window := o.windows[vault]; window != nil {
+		o.Inventory.StartDate, o.Inventory.EndDate = window.StartDate, window.EndDate
+		o.CreatedBefore = window.CreatedBefore
+	}
+	return o
+}
+
+// clock returns what the run tells the time by.
+func (o Options) clock() glacierpurge.Clock {
+	if o.Clock == nil {
+		return glacierpurge.WallClock
+	}
+	return o.Clock
+}
+
+// window returns what the options narrow a vault's work to, or nil if it's
+// all of the vault.
+func (o Options) window() *state.Window {
+	return newWindow(o.Inventory, o.CreatedBefore)
+}
+
+func newWindow(inventory glacierpurge.InventoryOptions, createdBefore time.Time) *state.Window {
+	if inventory.StartDate.IsZero() && inventory.EndDate.IsZero() && createdBefore.IsZero() {
+		return nil
+	}
+	return &state.Window{StartDate: inventory.StartDate, EndDate: inventory.EndDate, CreatedBefore: createdBefore}
+}
+
+// addFees starts counting the vault's early-deletion fees, if they're
+// estimated. Every vault must be added before any is processed.
+func (o *Options) addFees(vault *glacierpurge.Vault) {
+	if o.Prices == nil {
+		return
+	}
+	if o.fees == nil {
+		o.fees = make(map[*glacierpurge.Vault]*pricing.EarlyFees)
+	}
+	o.fees[vault] = o.Prices.EarlyFees()
+}
+
+// deleted returns what counts the early-deletion fees of the vault's archives
+// as they're deleted, if they're estimated, and reports each deletion.
+func (o Options) deleted(vault *glacierpurge.Vault) func(*glacierpurge.Archive) {
+	fees := o.fees[vault]
+	if fees == nil && o.Events == nil {
+		return nil
+	}
+	return func(archive *glacierpurge.Archive) {
+		if fees != nil {
+			fees.Add(vault.Glacier.Region, archive.Size, archive.CreationDate, o.clock().Now())
+		}
+		event := vaultEvent(events.ArchiveDeleted, vault)
+		event.ArchiveId, event.Size = archive.Id, archive.Size
+		o.Events.Publish(event)
+	}
+}
+
+// absent returns what reports each of the vault's archives found already
+// gone, or nil if nothing is told.
+func (o Options) absent(vault *glacierpurge.Vault) func(*glacierpurge.Archive) {
+	if o.Events == nil {
+		return nil
+	}
+	return func(archive *glacierpurge.Archive) {
+		event := vaultEvent(events.ArchiveAbsent, vault)
+		event.ArchiveId, event.Size = archive.Id, archive.Size
+		o.Events.Publish(event)
+	}
+}
+
+// finished notes that the run is done with a vault.
+func (o Options) finished(vault *glacierpurge.Vault, result *glacierpurge.PurgeResult, err error) {
+	o.Progress.finished(vault, err)
+	event := vaultEvent(events.VaultFinished, vault)
+	if result != nil {
+		event.Deleted, event.Absent, event.Failed, event.Size = result.Deleted, result.Absent, result.Failed, result.DeletedBytes
+		for class, n := range result.FailedBy {
+			if event.FailedBy == nil {
+				event.FailedBy = make(map[string]int)
+			}
+			event.FailedBy[string(class)] = n
+		}
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	o.Events.Publish(event)
+
+	// A vault wrapped up or stopped part way is left to resume as it was.
+	if o.store != nil && err != nil && !errors.Is(err, glacierpurge.ErrWrappedUp) && !errors.Is(err, ErrStopped) &&
+		!errors.Is(err, ErrAborted) && !errors.Is(err, context.Canceled) {
+		vaultOpts := o.forVault(vault)
+		mark(o.store, vault, state.Outcome{Status: state.StatusFailed, Reason: err.Error(), DeleteVault: vaultOpts.DeleteVault, Window: vaultOpts.window()})
+	}
+}
+
+// MarkSkipped records the vaults among results the run left alone, and why,
+// if they have an inventory job recorded, so a resume leaves them alone too.
+func MarkSkipped(store *state.Store, results []*VaultResult) {
+	for _, result := range results {
+		if _, ok := store.Job(result.Vault.Glacier.Region, result.Vault.Name); !ok {
+			continue
+		}
+		switch {
+		case result.Filtered != "":
+			mark(store, result.Vault, state.Outcome{Status: state.StatusSkipped, Reason: result.Filtered})
+		case result.Skipped:
+			mark(store, result.Vault, state.Outcome{Status: state.StatusSkipped, Reason: "its prompt was never answered"})
+		}
+	}
+}
+
+// mark records how far the run got with a vault, for a resume. Failing to is
+// only worth a warning.
+func mark(store *state.Store, vault *glacierpurge.Vault, outcome state.Outcome) {
+	outcome.Region, outcome.Vault, outcome.At = vault.Glacier.Region, vault.Name, time.Now()
+	if err := store.PutOutcome(outcome); err != nil {
+		ui.Printf("%sCouldn't record vault %s as %s in the state file: %v%s\n", ui.Yellow, vault.Name, outcome.Status, err, ui.Reset)
+	}
+}
+
+// failed returns what reports each of the vault's archives that fails to
+// delete, or nil if nothing is told.
+func (o Options) failed(vault *glacierpurge.Vault) func(*glacierpurge.Archive, error) {
+	if o.Events == nil {
+		return nil
+	}
+	return func(archive *glacierpurge.Archive, err error) {
+		event := vaultEvent(events.ArchiveFailed, vault)
+		event.ArchiveId, event.Size, event.Error = archive.Id, archive.Size, err.Error()
+		o.Events.Publish(event)
+	}
+}
+
+func vaultEvent(kind events.Kind, vault *glacierpurge.Vault) events.Event {
+	return events.Event{Kind: kind, Region: vault.Glacier.Region, Vault: vault.Name}
+}
+
+func jobEvent(kind events.Kind, job *glacierpurge.InventoryJob) events.Event {
+	event := vaultEvent(kind, job.Vault)
+	event.JobId = job.Id
+	return event
+}
+
+// Destroy empties each vault in turn and records the outcome. Each vault's
+// inventory job is recorded in store as soon as it's initiated so an
+// interrupted run can be resumed, and forgotten once the vault is done. Once
+// ctx ends, vaults that haven't been started are recorded as failed without
+// any further calls being made.
+func Destroy(ctx context.Context, vaults []*glacierpurge.Vault, store *state.Store, opts Options) []*VaultResult {
+	opts.deleting = newSlots(opts.MaxConcurrentVaults)
+	if opts.MaxConcurrentVaults > 1 {
+		opts.pending = newPendingJobs(opts.MaxPendingJobs)
+	}
+	opts.store = store
+	opts.ranks = rankVaults(ctx, vaults, opts.Order)
+	opts.Schedule.tag(ctx, vaults)
+	tasks := make([]task, 0, len(vaults))
+	for _, vault := range vaults {
+		vault := vault
+		opts.Progress.add(vault)
+		opts.addFees(vault)
+		opts.Events.Publish(vaultEvent(events.VaultSelected, vault))
+		tasks = append(tasks, task{vault, func(ctx context.Context) (*glacierpurge.PurgeResult, error) {
+			return purge(ctx, vault, store, opts)
+		}})
+	}
+
+	return process(ctx, tasks, opts)
+}
+
+// purge deletes the vault's archives, and with opts.DeleteVault the vault,
+// from a fresh inventory, or one reused with opts.ReuseInventory. A vault an
+// earlier run emptied, or Glacier's inventory found empty, goes without.
+func purge(ctx context.Context, vault *glacierpurge.Vault, store *state.Store, opts Options) (*glacierpurge.PurgeResult, error) {
+	if vault.Glacier.WrapUp.Requested() {
+		return &glacierpurge.PurgeResult{}, fmt.Errorf("not started: %w", glacierpurge.ErrWrappedUp)
+	}
+	if reason := alreadyEmptied(ctx, vault, store); reason != "" {
+		if !opts.DeleteVault {
+			ui.Printf("Nothing to delete from vault %s in region %s: %s.\n", vault.Name, vault.Glacier.Region, reason)
+			mark(store, vault, state.Outcome{Status: state.StatusArchivesDone})
+			return &glacierpurge.PurgeResult{}, nil
+		}
+		ui.Printf("Going straight to deleting vault %s in region %s, without an inventory: %s.\n", vault.Name, vault.Glacier.Region, reason)
+		mark(store, vault, state.Outcome{Status: state.StatusDeletePending})
+		return &glacierpurge.PurgeResult{}, deleteVault(ctx, vault, store, opts)
+	}
+	var job *glacierpurge.InventoryJob
+	if opts.ReuseInventory {
+		job = reuse(ctx, vault, store, opts.window())
+	}
+	if job != nil {
+		opts.pending.hold(opts.ranks[vault])
+		opts.Progress.job(vault, job.Id, time.Time{})
+	} else {
+		if opts.pending != nil {
+			opts.Progress.phase(vault, PhaseInitiating)
+		}
+		waitCtx, stopWaiting := wrappingUp(ctx, vault)
+		err := opts.pending.acquire(waitCtx, opts.ranks[vault])
+		stopWaiting()
+		if err != nil {
+			return &glacierpurge.PurgeResult{}, wrappedUp(ctx, vault, err)
+		}
+		if job, err = initiate(ctx, vault, store, opts.Inventory, opts.window()); err != nil {
+			opts.pending.release()
+			return &glacierpurge.PurgeResult{}, err
+		}
+		opts.Progress.job(vault, job.Id, opts.clock().Now())
+		opts.Events.Publish(jobEvent(events.JobInitiated, job))
+	}
+	return finish(ctx, job, store, opts)
+}
+
+// Resume carries on with the work an earlier run recorded in store. Each
+// vault with an inventory job recorded has its archives deleted once the job
+// completes. Vaults it records as done or skipped are left alone, and ones
+// emptied with their deletion still to come only have that tried again. Those
+// that failed are only taken up again with opts.RetryFailed, from their job if
+// they still have one and from a fresh inventory otherwise. Whatever an
+// earlier run narrowed a vault's work to, such as the archives older than the
+// plan it applied, the work carried on keeps to.
+func Resume(ctx context.Context, registry Clients, store *state.Store, opts Options) []*VaultResult {
+	opts.deleting = newSlots(opts.MaxConcurrentVaults)
+	if opts.MaxConcurrentVaults > 1 {
+		opts.pending = newPendingJobs(opts.MaxPendingJobs)
+	}
+	opts.store = store
+	opts.alsoDelete = make(map[*glacierpurge.Vault]bool)
+	opts.windows = make(map[*glacierpurge.Vault]*state.Window)
+	var tasks []task
+	add := func(vault *glacierpurge.Vault, run func(context.Context) (*glacierpurge.PurgeResult, error)) {
+		opts.Progress.add(vault)
+		opts.addFees(vault)
+		opts.Events.Publish(vaultEvent(events.VaultSelected, vault))
+		tasks = append(tasks, task{vault, run})
+	}
+
+	for _, recorded := range store.State.Jobs {
+		outcome, _ := store.Outcome(recorded.Region, recorded.Vault)
+		if outcome.Status == state.StatusSkipped {
+			ui.Printf("Leaving vault %s in region %s alone, as the run that recorded its job did: %s.\n", recorded.Vault, recorded.Region, outcome.Reason)
+			continue
+		}
+		if outcome.Status == state.StatusFailed && !opts.RetryFailed {
+			continue
+		}
+		g, err := registry.Get(ctx, recorded.Region)
+		if err != nil {
+			ui.Printf("%sCan't resume vault %s in region %s: %v%s\n", ui.Red, recorded.Vault, recorded.Region, err, ui.Reset)
+			continue
+		}
+
+		job := &glacierpurge.InventoryJob{
+			Vault:   &glacierpurge.Vault{Glacier: g, Name: recorded.Vault},
+			Id:      recorded.JobId,
+			Options: glacierpurge.InventoryOptions{Limit: recorded.PageSize, Marker: recorded.Marker},
+		}
+		if window := recorded.Window; window != nil {
+			job.Options.StartDate, job.Options.EndDate = window.StartDate, window.EndDate
+		}
+		ui.Printf("Resuming vault %s in region %s with inventory retrieval job %s\n", job.Vault.Name, g.Region, job.Id)
+		opts.alsoDelete[job.Vault] = outcome.DeleteVault
+		opts.windows[job.Vault] = recorded.Window
+		add(job.Vault, func(ctx context.Context) (*glacierpurge.PurgeResult, error) {
+			if g.WrapUp.Requested() {
+				return &glacierpurge.PurgeResult{JobId: job.Id}, fmt.Errorf("not resumed: %w", glacierpurge.ErrWrappedUp)
+			}
+			opts.pending.hold(opts.ranks[job.Vault])
+			return finish(ctx, job, store, opts.forVault(job.Vault))
+		})
+		opts.Progress.job(job.Vault, job.Id, recorded.InitiatedAt)
+	}
+
+	done := 0
+	for _, outcome := range store.Outcomes() {
+		if _, ok := store.Job(outcome.Region, outcome.Vault); ok {
+			continue // taken up, or not, with its job
+		}
+		switch {
+		case outcome.Status.Done(), outcome.Status == state.StatusSkipped:
+			done++
+			continue
+		case outcome.Status == state.StatusFailed && !opts.RetryFailed:
+			continue
+		}
+		g, err := registry.Get(ctx, outcome.Region)
+		if err != nil {
+			ui.Printf("%sCan't resume vault %s in region %s: %v%s\n", ui.Red, outcome.Vault, outcome.Region, err, ui.Reset)
+			continue
+		}
+
+		vault := &glacierpurge.Vault{Glacier: g, Name: outcome.Vault}
+		if outcome.Status == state.StatusDeletePending {
+			// Its archives are gone, as far as the run that emptied it was to
+			// delete them, so there's nothing to inventory: a fresh inventory
+			// would list whatever that run left alone.
+			ui.Printf("Deleting vault %s in region %s, which an earlier run emptied\n", vault.Name, g.Region)
+			add(vault, func(ctx context.Context) (*glacierpurge.PurgeResult, error) {
+				if g.WrapUp.Requested() {
+					return &glacierpurge.PurgeResult{}, fmt.Errorf("not resumed: %w", glacierpurge.ErrWrappedUp)
+				}
+				return &glacierpurge.PurgeResult{}, deleteVault(ctx, vault, store, opts)
+			})
+			continue
+		}
+		ui.Printf("Retrying vault %s in region %s, which failed at %s: %s\n", vault.Name, g.Region, outcome.At.Local().Format("2006-01-02 15:04"), outcome.Reason)
+		opts.alsoDelete[vault] = outcome.DeleteVault
+		opts.windows[vault] = outcome.Window
+		add(vault, func(ctx context.Context) (*glacierpurge.PurgeResult, error) {
+			return purge(ctx, vault, store, opts.forVault(vault))
+		})
+	}
+	if done > 0 {
+		ui.Printf("Skipping %d vault(s) an earlier run finished with or left alone.\n", done)
+	}
+
+	vaults := make([]*glacierpurge.Vault, len(tasks))
+	for i, t := range tasks {
+		vaults[i] = t.vault
+	}
+	opts.ranks = rankVaults(ctx, vaults, opts.Order)
+	opts.Schedule.tag(ctx, vaults)
+	return process(ctx, tasks, opts)
+}
+
+// Inventory records an inventory retrieval job for each vault in store
+// without waiting for it, so a later resume can finish the work. With
+// reuseJobs, a vault's existing job is recorded in place of initiating one,
+// unless opts only inventory part of the vault. Vaults needing no inventory
+// are skipped. It returns how many jobs were initiated and how many reused.
+func Inventory(ctx context.Context, vaults []*glacierpurge.Vault, store *state.Store, opts glacierpurge.InventoryOptions, reuseJobs bool) (initiated, reused int, err error) {
+	whole := opts.Limit == 0 && opts.StartDate.IsZero() && opts.EndDate.IsZero()
+	for _, vault := range vaults {
+		if reason := alreadyEmptied(ctx, vault, store); reason != "" {
+			ui.Printf("Nothing to inventory in vault %s in region %s: %s.\n", vault.Name, vault.Glacier.Region, reason)
+			continue
+		}
+		var job *glacierpurge.InventoryJob
+		if reuseJobs && whole {
+			job = reuse(ctx, vault, store, nil)
+		}
+		if job != nil {
+			reused++
+		} else {
+			if job, err = initiate(ctx, vault, store, opts, newWindow(opts, time.Time{})); err != nil {
+				return initiated, reused, fmt.Errorf("vault %s in region %s: %w", vault.Name, vault.Glacier.Region, err)
+			}
+			initiated++
+		}
+		ui.Printf("[%s] %s: %s\n", vault.Glacier.Region, vault.Name, job.Id)
+	}
+
+	return initiated, reused, nil
+}
+
+// JobStatus is Glacier's current view of an inventory job recorded in the
+// state file.
+type JobStatus struct {
+	state.Job
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+	Err     error  `json:"-"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status looks up every inventory job recorded in store. Jobs are listed once
+// per vault, so a recorded job that Glacier no longer has, typically because
+// it completed more than a day ago, is reported as expired.
+func Status(ctx context.Context, registry Clients, store *state.Store) []*JobStatus {
+	listed := make(map[string]map[string]*glacierpurge.Job)
+
+	var statuses []*JobStatus
+	for _, recorded := range store.State.Jobs {
+		status := &JobStatus{Job: recorded}
+		statuses = append(statuses, status)
+
+		key := recorded.Region + "/" + recorded.Vault
+		jobs, ok := listed[key]
+		if !ok {
+			var err error
+			if jobs, err = listInventoryJobs(ctx, registry, recorded); err != nil {
+				status.Err, status.Error = err, err.Error()
+				continue
+			}
+			listed[key] = jobs
+		}
+
+		job, ok := jobs[recorded.JobId]
+		if !ok {
+			status.Status = "Expired"
+			status.Message = "Glacier no longer has this job; start a new inventory"
+			continue
+		}
+		status.Status = string(job.StatusCode)
+		status.Message = job.StatusMessage
+	}
+
+	return statuses
+}
+
+func listInventoryJobs(ctx context.Context, registry Clients, recorded state.Job) (map[string]*glacierpurge.Job, error) {
+	g, err := registry.Get(ctx, recorded.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	vault := &glacierpurge.Vault{Glacier: g, Name: recorded.Vault}
+	jobs, err := vault.ListJobs(ctx, glacierpurge.ListJobsOptions{InventoryOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[string]*glacierpurge.Job, len(jobs))
+	for _, job := range jobs {
+		byId[job.Id] = job
+	}
+	return byId, nil
+}
+
+// FilterVaults keeps only the vaults with one of the given names, which may
+// be path.Match patterns such as logs-*: vault names can't hold the
+// characters patterns use. No names means no filtering.
+func FilterVaults(vaults []*glacierpurge.Vault, names []string) []*glacierpurge.Vault {
+	if len(names) == 0 {
+		return vaults
+	}
+
+	wanted := make(map[string]bool)
+	var patterns []string
+	for _, name := range names {
+		if strings.ContainsAny(name, "*?[\\") {
+			patterns = append(patterns, name)
+		} else {
+			wanted[name] = true
+		}
+	}
+
+	var filtered []*glacierpurge.Vault
+	for _, vault := range vaults {
+		if wanted[vault.Name] || slices.ContainsFunc(patterns, func(pattern string) bool {
+			matched, _ := path.Match(pattern, vault.Name)
+			return matched
+		}) {
+			filtered = append(filtered, vault)
+		}
+	}
+
+	return filtered
+}
+
+// task is one vault's share of the deletion phase.
+type task struct {
+	vault *glacierpurge.Vault
+	run   func(ctx context.Context) (*glacierpurge.PurgeResult, error)
+}
+
+// ErrAborted is the error of the vaults --fail-fast kept from being started.
+var ErrAborted = errors.New("not started because an earlier vault failed")
+
+// ErrStopped is the error of the vaults --fail-fast stopped part way, when
+// vaults are worked on side by side.
+var ErrStopped = errors.New("stopped because another vault failed")
+
+// process works through the tasks, one vault at a time unless
+// MaxConcurrentVaults says otherwise.
+func process(ctx context.Context, tasks []task, opts Options) []*VaultResult {
+	order := opts.Order
+	if order == "" {
+		order = OrderAsSelected
+	}
+	if opts.ranks != nil {
+		inOrder(tasks, opts.ranks)
+		ui.Printf("Working through the %d vault(s) %s.\n", len(tasks), strings.ReplaceAll(order, "-", " "))
+	}
+
+	var results []*VaultResult
+	if opts.MaxConcurrentVaults > 1 {
+		results = processConcurrently(ctx, tasks, opts)
+	} else {
+		results = processInTurn(ctx, tasks, opts)
+	}
+
+	finished := events.Event{Kind: events.RunFinished, Vaults: len(results), Order: order}
+	for _, result := range results {
+		if result.Err != nil {
+			finished.Failed++
+		}
+	}
+	opts.Events.Publish(finished)
+	return results
+}
+
+func processInTurn(ctx context.Context, tasks []task, opts Options) []*VaultResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var results []*VaultResult
+	aborted := false
+	for i, t := range tasks {
+		if ctx.Err() != nil {
+			err := fmt.Errorf("not started: %w", ctx.Err())
+			if aborted {
+				err = ErrAborted
+			}
+			for _, t := range tasks[i:] {
+				results = append(results, &VaultResult{Vault: t.vault, Err: err})
+			}
+			break
+		}
+
+		result, err := t.run(ctx)
+		opts.finished(t.vault, result, err)
+		if err == nil {
+			opts.Schedule.done(ctx, t.vault)
+		}
+		if err != nil && !errors.Is(err, glacierpurge.ErrWrappedUp) {
+			ui.Printf("%sError destroying vault %s in region %s: %v%s\n", ui.Red, t.vault.Name, t.vault.Glacier.Region, err, ui.Reset)
+		}
+		_, stale := staleInventory(ctx, t.vault)
+		results = append(results, &VaultResult{Vault: t.vault, Purge: result, Err: err, PossiblyIncomplete: stale, EarlyFees: opts.fees[t.vault]})
+
+		// Every vault after one whose credentials ran out would fail the same way.
+		expired := errors.Is(err, glacierpurge.ErrCredentialsExpired)
+		if err != nil && (opts.FailFast || expired) && i < len(tasks)-1 {
+			reason := "--fail-fast is set"
+			if expired {
+				reason = "the AWS credentials have expired"
+			}
+			ui.Printf("%sAborting the remaining %d vault(s) because %s.%s\n", ui.Yellow, len(tasks)-i-1, reason, ui.Reset)
+			aborted = true
+			cancel()
+		}
+	}
+
+	return results
+}
+
+// staleInventory returns the vault's description and whether its last
+// inventory may be missing recent uploads. A vault that can't be described
+// isn't reported as stale.
+func staleInventory(ctx context.Context, vault *glacierpurge.Vault) (*glacierpurge.VaultDescription, bool) {
+	description, err := vault.Describe(ctx)
+	if err != nil {
+		return nil, false
+	}
+	return description, description.InventoryStale(time.Now())
+}
+
+// warnStale warns that a vault's inventory may be missing recent uploads,
+// which would leave archives behind and the vault undeletable.
+func warnStale(ctx context.Context, vault *glacierpurge.Vault) {
+	description, stale := staleInventory(ctx, vault)
+	if !stale {
+		return
+	}
+	last := "has never been inventoried"
+	if !description.LastInventoryDate.IsZero() {
+		last = "was last inventoried " + description.LastInventoryDate.Local().Format("2006-01-02 15:04")
+	}
+	ui.Printf("%s%sWarning: vault %s %s. Glacier's inventory lags about a day behind uploads, so archives added since may not be deleted this run; a follow-up run once Glacier has inventoried the vault again may be needed.%s\n", ui.Yellow, ui.Bold, vault.Name, last, ui.Reset)
+}
+
+// reuse returns an inventory job the vault already has, recording it in store
+// with the window the run is narrowed to, or nil if there isn't one to reuse.
+func reuse(ctx context.Context, vault *glacierpurge.Vault, store *state.Store, window *state.Window) *glacierpurge.InventoryJob {
+	job, err := vault.ReusableInventory(ctx)
+	if err != nil {
+		ui.Printf("%sCouldn't look for an existing inventory job for vault %s: %v%s\n", ui.Yellow, vault.Name, err, ui.Reset)
+		return nil
+	}
+	if job == nil {
+		return nil
+	}
+
+	ui.Printf("Reusing inventory retrieval job %s for vault %s\n", job.Id, vault.Name)
+	record(store, job, 0, window)
+	return job
+}
+
+func initiate(ctx context.Context, vault *glacierpurge.Vault, store *state.Store, opts glacierpurge.InventoryOptions, window *state.Window) (*glacierpurge.InventoryJob, error) {
+	job, err := vault.InitiateInventoryJob(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Inventory retrieval job initiated for vault %s, job ID: %s\n%s%sThis operation will likely take a number of hours to complete. Please wait while AWS generates a list of archives for this vault.%s", vault.Name, job.Id, ui.Yellow, ui.Bold, ui.Reset)
+
+	page := 0
+	if opts.Limit > 0 {
+		page = 1
+	}
+	record(store, job, page, window)
+	return job, nil
+}
+
+// record notes job in store, with the window the run is narrowed to, so an
+// interrupted run can be resumed. Failing to is only worth a warning.
+func record(store *state.Store, job *glacierpurge.InventoryJob, page int, window *state.Window) {
+	err := store.PutJob(state.Job{
+		Region:      job.Vault.Glacier.Region,
+		Vault:       job.Vault.Name,
+		JobId:       job.Id,
+		InitiatedAt: time.Now(),
+		PageSize:    job.Options.Limit,
+		Page:        page,
+		Marker:      job.Options.Marker,
+		Window:      window,
+	})
+	if err != nil {
+		ui.Printf("%sCouldn't record job %s for resuming later: %v%s\n", ui.Yellow, job.Id, err, ui.Reset)
+	}
+}
+
+// finish waits for the vault's inventory and deletes the archives in it. A
+// paginated inventory is worked through page by page, recording each page's
+// job as it's initiated, so the deletions start with the first page.
+func finish(ctx context.Context, job *glacierpurge.InventoryJob, store *state.Store, opts Options) (*glacierpurge.PurgeResult, error) {
+	result := &glacierpurge.PurgeResult{JobId: job.Id}
+	page := 1
+	if recorded, ok := store.Job(job.Vault.Glacier.Region, job.Vault.Name); ok && recorded.Page > 0 {
+		page = recorded.Page
+	}
+
+	// Shared by the pages, so an archive two of them list is deleted once.
+	seen := glacierpurge.NewArchiveSet()
+	reinitiated := 0
+	for {
+		pageResult, err := finishPage(ctx, job, seen, opts)
+		result.Add(pageResult)
+		if errors.Is(err, glacierpurge.ErrJobExpired) && !opts.NoReinitiate && reinitiated < maxReinitiations && !job.Vault.Glacier.WrapUp.Requested() {
+			ui.Printf("%sThe output of inventory job %s for vault %s has expired; Glacier only keeps it for about a day.%s\n", ui.Yellow, job.Id, job.Vault.Name, ui.Reset)
+			if err := opts.pending.acquire(ctx, opts.ranks[job.Vault]); err != nil {
+				return result, err
+			}
+			if job, err = reinitiate(ctx, job, store, page, opts); err != nil {
+				opts.pending.release()
+				return result, err
+			}
+			opts.Progress.job(job.Vault, job.Id, opts.clock().Now())
+			opts.Events.Publish(jobEvent(events.JobInitiated, job))
+			reinitiated++
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		// Only a job still to be initiated waits its turn among the pending
+		// ones, so a vault done with its inventory isn't held up by them.
+		more, err := job.More(ctx)
+		if err != nil {
+			return result, err
+		}
+		if !more {
+			break
+		}
+		if err := opts.pending.acquire(ctx, opts.ranks[job.Vault]); err != nil {
+			return result, err
+		}
+		next, err := job.Next(ctx)
+		if err != nil || next == nil {
+			opts.pending.release()
+		}
+		if err != nil {
+			return result, err
+		}
+		if next == nil {
+			break
+		}
+		page++
+		ui.Printf("Vault %s: page %d of the inventory, job ID %s\n", job.Vault.Name, page, next.Id)
+		record(store, next, page, opts.window())
+		opts.Progress.job(next.Vault, next.Id, opts.clock().Now())
+		opts.Events.Publish(jobEvent(events.JobInitiated, next))
+		job = next
+		if job.Vault.Glacier.WrapUp.Requested() {
+			// The next page's job is recorded for the resume, which waits for
+			// it; this run gives up its place among the pending jobs.
+			opts.pending.release()
+			return result, fmt.Errorf("%w, after page %d of the inventory", glacierpurge.ErrWrappedUp, page-1)
+		}
+	}
+
+	done := state.Outcome{Region: job.Vault.Glacier.Region, Vault: job.Vault.Name, Status: state.StatusArchivesDone, At: opts.clock().Now()}
+	if opts.DeleteVault {
+		done.Status = state.StatusDeletePending
+	}
+	if err := store.CompleteJob(done); err != nil {
+		ui.Printf("%sCouldn't update the state file: %v%s\n", ui.Yellow, err, ui.Reset)
+	}
+	if result.Skipped == 0 && opts.window() == nil {
+		// Every archive is gone, so a later run needn't inventory the vault
+		// again before Glacier notices.
+		err := store.PutEmptied(state.Emptied{
+			Region:    job.Vault.Glacier.Region,
+			Vault:     job.Vault.Name,
+			ARN:       job.Vault.ARN,
+			EmptiedAt: opts.clock().Now(),
+			Deleted:   result.Deleted,
+		})
+		if err != nil {
+			ui.Printf("%sCouldn't record vault %s as emptied: %v%s\n", ui.Yellow, job.Vault.Name, err, ui.Reset)
+		}
+	}
+
+	if opts.DeleteVault {
+		warnStale(ctx, job.Vault)
+		return result, deleteVault(ctx, job.Vault, store, opts)
+	}
+	return result, nil
+}
+
+// deleteVault deletes a vault emptied of archives, leaving it for a later run
+// when Glacier hasn't noticed yet.
+func deleteVault(ctx context.Context, vault *glacierpurge.Vault, store *state.Store, opts Options) error {
+	err := vault.Delete(ctx)
+	if errors.Is(err, glacierpurge.ErrVaultNotEmpty) {
+		ui.Printf("%sGlacier won't delete vault %s until its next inventory, about a day from now, shows it empty. Run this again then to delete it.%s\n", ui.Yellow, vault.Name, ui.Reset)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ui.Printf("%sVault %s deleted from region %s%s\n", ui.Green, vault.Name, vault.Glacier.Region, ui.Reset)
+	opts.Events.Publish(vaultEvent(events.VaultDeleted, vault))
+	mark(store, vault, state.Outcome{Status: state.StatusVaultDeleted})
+	if err := store.RemoveEmptied(vault.Glacier.Region, vault.Name); err != nil {
+		ui.Printf("%sCouldn't update the completed-work file: %v%s\n", ui.Yellow, err, ui.Reset)
+	}
+	return nil
+}
+
+// alreadyEmptied returns why the vault needs no inventory, or "" if it does:
+// an earlier run deleted every archive and Glacier hasn't inventoried it
+// since, or Glacier's last inventory found it empty. An inventory job would
+// only return that same inventory.
+func alreadyEmptied(ctx context.Context, vault *glacierpurge.Vault, store *state.Store) string {
+	description, err := vault.Describe(ctx)
+	if err != nil {
+		return ""
+	}
+	const when = "2006-01-02 15:04"
+
+	if emptied, ok := store.EmptiedVault(vault.Glacier.Region, vault.Name); ok {
+		switch {
+		case emptied.ARN != "" && description.ARN != "" && emptied.ARN != description.ARN,
+			description.CreationDate.After(emptied.EmptiedAt):
+			ui.Printf("Vault %s was created again since an earlier run emptied it; taking a fresh inventory.\n", vault.Name)
+		case !description.LastInventoryDate.After(emptied.EmptiedAt):
+			return fmt.Sprintf("an earlier run deleted its %d archive(s) at %s, and Glacier hasn't inventoried it since", emptied.Deleted, emptied.EmptiedAt.Local().Format(when))
+		case description.NumberOfArchives > 0:
+			ui.Printf("Vault %s has %d archive(s) again in Glacier's inventory of %s, since an earlier run emptied it; taking a fresh inventory.\n", vault.Name, description.NumberOfArchives, description.LastInventoryDate.Local().Format(when))
+		}
+		if description.NumberOfArchives > 0 || description.CreationDate.After(emptied.EmptiedAt) {
+			if err := store.RemoveEmptied(vault.Glacier.Region, vault.Name); err != nil {
+				ui.Printf("%sCouldn't update the completed-work file: %v%s\n", ui.Yellow, err, ui.Reset)
+			}
+		}
+	}
+
+	if description.NumberOfArchives == 0 && !description.LastInventoryDate.IsZero() {
+		return fmt.Sprintf("Glacier's inventory of %s lists no archives in it", description.LastInventoryDate.Local().Format(when))
+	}
+	return ""
+}
+
+// maxReinitiations is how many times in one vault an expired inventory job is
+// replaced before the vault is given up on.
+const maxReinitiations = 3
+
+// reinitiate replaces an expired inventory job with a fresh one for the same
+// page, and no wider than the run is narrowed to, recording it in store in its
+// place.
+func reinitiate(ctx context.Context, job *glacierpurge.InventoryJob, store *state.Store, page int, opts Options) (*glacierpurge.InventoryJob, error) {
+	options := job.Options
+	if options.StartDate.IsZero() {
+		options.StartDate = opts.Inventory.StartDate
+	}
+	if options.EndDate.IsZero() {
+		options.EndDate = opts.Inventory.EndDate
+	}
+	next, err := job.Vault.InitiateInventoryJob(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace expired inventory job %s: %w", job.Id, err)
+	}
+	ui.Printf("Inventory retrieval job %s initiated for vault %s in its place; waiting for it instead.\n", next.Id, job.Vault.Name)
+	if job.Options.Limit == 0 {
+		page = 0 // not paginated
+	}
+	record(store, next, page, opts.window())
+	return next, nil
+}
+
+// finishPage waits for one page's inventory, releasing its place among the
+// pending jobs once it's through, then deletes the archives in it that seen
+// doesn't already hold.
+func finishPage(ctx context.Context, job *glacierpurge.InventoryJob, seen *glacierpurge.ArchiveSet, opts Options) (*glacierpurge.PurgeResult, error) {
+	var keep func(*glacierpurge.Archive) bool
+	if !opts.CreatedBefore.IsZero() {
+		keep = func(archive *glacierpurge.Archive) bool {
+			return archive.CreationDate.Before(opts.CreatedBefore)
+		}
+	}
+
+	opts.Progress.phase(job.Vault, PhaseInventory)
+	// The waits before deleting end early on a wrap-up; the deleting doesn't.
+	waitCtx, stopWaiting := wrappingUp(ctx, job.Vault)
+	defer stopWaiting()
+	// turn waits for the vault's turn to delete, once its inventory is in.
+	turn := func() error {
+		if opts.deleting != nil {
+			opts.Progress.phase(job.Vault, PhaseTurn)
+		}
+		return wrappedUp(ctx, job.Vault, opts.deleting.acquire(waitCtx, opts.ranks[job.Vault]))
+	}
+
+	// Nothing needs the whole inventory at once, so it's streamed straight
+	// into the deletions, or with a salvage, into the retrievals and then
+	// the deletions from the copy of it the salvage saved.
+	err := wrappedUp(ctx, job.Vault, job.WaitLogged(waitCtx))
+	opts.pending.release()
+	if err != nil {
+		return &glacierpurge.PurgeResult{JobId: job.Id}, err
+	}
+	opts.Events.Publish(jobEvent(events.JobCompleted, job))
+	if err := turn(); err != nil {
+		return &glacierpurge.PurgeResult{JobId: job.Id}, err
+	}
+	defer opts.deleting.release()
+
+	var inventory io.Reader
+	if opts.Salvage != nil {
+		salvage := *opts.Salvage
+		salvage.Dir = filepath.Join(salvage.Dir, job.Vault.Glacier.Region, glacierpurge.VaultFileName(job.Vault.Name))
+		ui.Printf("Salvaging the archives in vault %s into %s before deleting them\n", job.Vault.Name, salvage.Dir)
+		opts.Progress.phase(job.Vault, PhaseSalvaging)
+		path, err := job.Salvage(ctx, keep, salvage)
+		if err != nil {
+			return &glacierpurge.PurgeResult{JobId: job.Id}, fmt.Errorf("not deleting anything until the salvage succeeds: %w", err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return &glacierpurge.PurgeResult{JobId: job.Id}, err
+		}
+		defer f.Close()
+		inventory = f
+	}
+
+	opts.Progress.phase(job.Vault, PhaseDeleting)
+	// Going by Glacier's count, as the deletions do, unless the job lists
+	// only some of the vault.
+	if o := job.Options; o.Limit == 0 && o.StartDate.IsZero() && o.EndDate.IsZero() {
+		if description, err := job.Vault.Describe(ctx); err == nil {
+			opts.Progress.archives(job.Vault, description.NumberOfArchives)
+		}
+	}
+	checkpoint := opts.checkpointer(job)
+	if checkpoint.skip > 0 {
+		ui.Printf("Passing over the first %d archive(s) in the inventory of vault %s, which an earlier run dealt with\n", checkpoint.skip, job.Vault.Name)
+	}
+	deleteOpts := glacierpurge.DeleteOptions{Workers: opts.WorkersPerVault, Adaptive: opts.AdaptiveWorkers, Filter: keep, Deleted: opts.deleted(job.Vault), Failed: opts.failed(job.Vault), Absent: opts.absent(job.Vault), Seen: seen, Skip: checkpoint.skip, Done: checkpoint.done}
+	var result *glacierpurge.PurgeResult
+	if inventory == nil {
+		result, err = job.DeleteAll(ctx, deleteOpts)
+	} else {
+		result, err = job.DeleteFrom(ctx, inventory, deleteOpts)
+	}
+	checkpoint.flush()
+	noteDuplicates(job, result.Duplicates)
+	noteLeftAlone(job, result.Skipped, opts)
+	return result, err
+}
+
+func noteDuplicates(job *glacierpurge.InventoryJob, duplicates int) {
+	if duplicates > 0 {
+		ui.Printf("%sFound %d duplicate archive ID(s) in the inventory of vault %s: each archive is only counted once.%s\n", ui.Yellow, duplicates, job.Vault.Name, ui.Reset)
+	}
+}
+
+func noteLeftAlone(job *glacierpurge.InventoryJob, left int, opts Options) {
+	if left > 0 {
+		ui.Printf("%sLeaving %d archive(s) in vault %s alone: they were created after %s.%s\n", ui.Yellow, left, job.Vault.Name, opts.CreatedBefore.Local().Format("2006-01-02 15:04"), ui.Reset)
+	}
+}
+
+// Summarize prints the outcome of every vault. It returns an error if any
+// vault failed: the first failure when the run stopped at it, otherwise a
+// count of the failures.
+func Summarize(results []*VaultResult) error {
+	rows := make([]ui.SummaryRow, 0, len(results))
+	var first *VaultResult
+	aborted := false
+	for _, result := range results {
+		row := ui.SummaryRow{
+			Region:   result.Vault.Glacier.Region,
+			Vault:    result.Vault.Name,
+			ARN:      result.Vault.ARN,
+			Err:      result.Err,
+			Skipped:  result.Skipped,
+			Filtered: result.Filtered,
+			Aborted:  errors.Is(result.Err, ErrAborted) || errors.Is(result.Err, ErrStopped),
+			Stopped:  errors.Is(result.Err, glacierpurge.ErrWrappedUp),
+
+			PossiblyIncomplete: result.PossiblyIncomplete,
+		}
+		if row.Aborted {
+			aborted = true
+		} else if result.Err != nil && !row.Stopped && first == nil {
+			first = result
+		}
+		if result.Purge != nil {
+			row.Deleted = result.Purge.Deleted
+			row.Unattempted = result.Purge.Unattempted
+			row.Duplicates = result.Purge.Duplicates
+			row.Absent = result.Purge.Absent
+			if result.Purge.Failed > 0 {
+				row.FailedBy = result.Purge.FailedBy.String()
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	failed := ui.PrintSummary(rows)
+	if aborted && first != nil {
+		return fmt.Errorf("stopped after vault %s in region %s failed: %w", first.Vault.Name, first.Vault.Glacier.Region, first.Err)
+	}
+	if failed > 0 {
+		if first != nil && errors.Is(first.Err, glacierpurge.ErrCredentialsExpired) {
+			return fmt.Errorf("%d vault(s) failed: %w", failed, first.Err)
+		}
+		return fmt.Errorf("%d vault(s) failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package run
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+	"github.com/rdegges/ice-breaker/internal/state"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+func TestMain(m *testing.M) {
+	ui.Messages = io.Discard
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// testStart is when the tests' fake clocks start.
+var testStart = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestGlacier returns a client of fake, in the fake's region, which reads
+// the time from clock.
+func newTestGlacier(t testing.TB, fake *glaciertest.Fake, clock *glaciertest.Clock, opts ...glacierpurge.Option) *glacierpurge.Glacier {
+	t.Helper()
+	fake.Now = clock.Now
+	region := fake.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	g, err := glacierpurge.New(context.Background(), region, append([]glacierpurge.Option{glacierpurge.WithClient(fake), glacierpurge.WithClock(clock)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// newTestStore returns an empty store in a temporary directory.
+func newTestStore(t testing.TB) *state.Store {
+	t.Helper()
+	store, err := state.Open(state.NewFileBackend(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
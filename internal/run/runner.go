@@ -0,0 +1,170 @@
+package run
+
+import (
+	"context"
+	"io"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/state"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// Clients gives the Glacier client of each region, as a
+// glacierpurge.Registry does.
+type Clients interface {
+	Get(ctx context.Context, region string) (*glacierpurge.Glacier, error)
+}
+
+// Runner carries a purge through: scanning the regions for vaults, asking
+// which of them to destroy, emptying the ones selected, and summarizing what
+// became of each. Everything the run reaches outside the process through is
+// one of its fields, so a run can be driven entirely through fakes.
+type Runner struct {
+	// Clients are the Glacier clients the vaults are found and destroyed
+	// through.
+	Clients Clients
+	// Prompter is asked about each vault in turn.
+	Prompter Confirmer
+	// Answers, if set, answers for the vaults it has a line for.
+	Answers *Answers
+	// Store records the run's progress, for a resume.
+	Store *state.Store
+	// Clock is what the run tells the time and waits by; the wall clock if
+	// nil.
+	Clock glacierpurge.Clock
+	// Output, if set, is where the run's messages go instead of ui.Messages.
+	// Vaults emptied side by side write to it at once.
+	Output io.Writer
+	// Options controls how the vaults are destroyed or resumed.
+	Options Options
+
+	// Names, if set, narrows the vaults offered to those with one of the
+	// names, or names matching one of the patterns.
+	Names []string
+	// Narrow, if set, narrows the vaults further once their tags are known,
+	// before Tags is applied.
+	Narrow func(ctx context.Context, vaults []*glacierpurge.Vault) []*glacierpurge.Vault
+	// Tags, if set, leaves out the vaults its tags don't pick.
+	Tags TagFilter
+	// Sort is the order the vaults are offered in.
+	Sort SortOptions
+}
+
+// Report is what became of a run: every region scanned, and every vault
+// found, whether it was destroyed, declined or left out.
+type Report struct {
+	Regions  []*RegionResult
+	Found    []*glacierpurge.Vault // every vault found in the regions
+	Selected []*glacierpurge.Vault // the vaults confirmed for destruction
+	Declined []*glacierpurge.Vault // the vaults answered no
+	Results  []*VaultResult        // the selected vaults, and those skipped or filtered out
+}
+
+// Run scans the regions, asks about each vault found, destroys the ones
+// selected and prints the summary, returning the summary's error. The report
+// is returned even then; only a prompt failing, or sorting the vaults, ends
+// the run before anything's destroyed.
+func (r *Runner) Run(ctx context.Context, regions []string) (*Report, error) {
+	report := &Report{}
+	vaults, err := r.Offer(ctx, regions, report)
+	if err != nil {
+		return report, err
+	}
+	if err := r.Select(ctx, vaults, report); err != nil {
+		return report, err
+	}
+	r.Destroy(ctx, report)
+	return report, r.Summarize(report)
+}
+
+// Offer scans the regions and returns the vaults to ask about, narrowed by
+// Names, Narrow and Tags and in the order Sort gives. The report takes what became of
+// the regions, and the vaults filtered out.
+func (r *Runner) Offer(ctx context.Context, regions []string, report *Report) ([]*glacierpurge.Vault, error) {
+	defer r.output()()
+	vaults, scanned := Scan(ctx, r.Clients, regions)
+	report.Regions, report.Found = scanned, vaults
+	vaults = FilterVaults(vaults, r.Names)
+	Enrich(ctx, vaults)
+	if r.Narrow != nil {
+		vaults = r.Narrow(ctx, vaults)
+	}
+	vaults, filtered := FilterTags(ctx, vaults, r.Tags)
+	report.Results = append(report.Results, filtered...)
+	return vaults, SortVaults(ctx, vaults, r.Sort)
+}
+
+// Select asks about each of the vaults, noting in the report which were
+// selected, declined and never answered.
+func (r *Runner) Select(ctx context.Context, vaults []*glacierpurge.Vault, report *Report) error {
+	defer r.output()()
+	selected, skipped, err := Select(ctx, r.Prompter, r.Answers, vaults)
+	if err != nil {
+		return err
+	}
+	report.Selected = selected
+	for _, vault := range vaults {
+		switch {
+		case contains(selected, vault):
+		case contains(skipped, vault):
+			report.Results = append(report.Results, &VaultResult{Vault: vault, Skipped: true})
+		default:
+			report.Declined = append(report.Declined, vault)
+		}
+	}
+	return nil
+}
+
+// Destroy empties the selected vaults, putting their results ahead of those
+// of the vaults left alone, and records the vaults skipped for a resume.
+func (r *Runner) Destroy(ctx context.Context, report *Report) {
+	defer r.output()()
+	results := Destroy(ctx, report.Selected, r.Store, r.options())
+	report.Results = append(results, report.Results...)
+	MarkSkipped(r.Store, report.Results)
+}
+
+// Resume carries on with the work an earlier run recorded in Store, as
+// Resume does.
+func (r *Runner) Resume(ctx context.Context) *Report {
+	defer r.output()()
+	return &Report{Results: Resume(ctx, r.Clients, r.Store, r.options())}
+}
+
+// Summarize prints what became of the regions, if any were scanned, and of
+// every vault, returning an error if any vault failed.
+func (r *Runner) Summarize(report *Report) error {
+	defer r.output()()
+	if report.Regions != nil {
+		SummarizeRegions(report.Regions)
+	}
+	return Summarize(report.Results)
+}
+
+func (r *Runner) options() Options {
+	opts := r.Options
+	if r.Clock != nil {
+		opts.Clock = r.Clock
+	}
+	return opts
+}
+
+// output points ui.Messages at Output, if it's set, returning what points it
+// back.
+func (r *Runner) output() (restore func()) {
+	if r.Output == nil {
+		return func() {}
+	}
+	messages := ui.Messages
+	ui.Messages = r.Output
+	return func() { ui.Messages = messages }
+}
+
+func contains(vaults []*glacierpurge.Vault, vault *glacierpurge.Vault) bool {
+	for _, v := range vaults {
+		if v == vault {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,365 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"go.uber.org/goleak"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+	"github.com/rdegges/ice-breaker/internal/state"
+)
+
+// clientMap gives each region's client from the map, so each region can have
+// its own fake.
+type clientMap map[string]*glacierpurge.Glacier
+
+func (m clientMap) Get(_ context.Context, region string) (*glacierpurge.Glacier, error) {
+	if g, ok := m[region]; ok {
+		return g, nil
+	}
+	return nil, fmt.Errorf("no client for region %s", region)
+}
+
+// scenarioRegions are the regions of scenarioVaults, in the order they're
+// scanned.
+var scenarioRegions = []string{"us-east-1", "eu-west-1", "ap-southeast-2"}
+
+// scriptedPrompter answers the question about each vault from its answers,
+// by "region/vault", and runs out of input at the first vault it has no
+// answer for.
+type scriptedPrompter struct {
+	answers map[string]bool
+}
+
+func (p *scriptedPrompter) Confirm(ctx context.Context, question string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	region, rest, _ := strings.Cut(strings.TrimPrefix(question, "["), "] ")
+	vault, _, _ := strings.Cut(rest, ":")
+	answer, ok := p.answers[region+"/"+vault]
+	if !ok {
+		return false, io.EOF
+	}
+	return answer, nil
+}
+
+// lockedBuffer is a buffer that's safe for the vaults emptied side by side
+// to write their messages to at once.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// runner returns a Runner over the world's regions, writing its messages to
+// output.
+func (w *world) runner(t *testing.T, prompter Confirmer, output io.Writer, opts Options) *Runner {
+	t.Helper()
+	return &Runner{
+		Clients:  w.clients,
+		Prompter: prompter,
+		Store:    newTestStore(t),
+		Clock:    w.clock,
+		Output:   output,
+		Options:  opts,
+	}
+}
+
+// vaultNames returns the vaults as "region/vault".
+func vaultNames(vaults []*glacierpurge.Vault) []string {
+	keys := make([]string, len(vaults))
+	for i, vault := range vaults {
+		keys[i] = vault.Glacier.Region + "/" + vault.Name
+	}
+	return keys
+}
+
+func TestRunnerSelection(t *testing.T) {
+	every := func(answer bool) map[string]bool {
+		answers := make(map[string]bool)
+		for name := range scenarioVaults {
+			answers[name] = answer
+		}
+		return answers
+	}
+	for _, c := range []struct {
+		name    string
+		answers map[string]bool
+		// want is what becomes of each vault: destroyed, declined or
+		// skipped.
+		want map[string]string
+	}{
+		{
+			name:    "every vault accepted",
+			answers: every(true),
+			want: map[string]string{
+				"us-east-1/a": "destroyed", "us-east-1/b": "destroyed", "us-east-1/c": "destroyed", "us-east-1/d": "destroyed",
+				"eu-west-1/e": "destroyed", "eu-west-1/f": "destroyed", "ap-southeast-2/g": "destroyed",
+			},
+		},
+		{
+			name:    "every vault declined",
+			answers: every(false),
+			want: map[string]string{
+				"us-east-1/a": "declined", "us-east-1/b": "declined", "us-east-1/c": "declined", "us-east-1/d": "declined",
+				"eu-west-1/e": "declined", "eu-west-1/f": "declined", "ap-southeast-2/g": "declined",
+			},
+		},
+		{
+			name: "some accepted",
+			answers: map[string]bool{
+				"us-east-1/a": true, "us-east-1/b": false, "us-east-1/c": false, "us-east-1/d": true,
+				"eu-west-1/e": false, "eu-west-1/f": true, "ap-southeast-2/g": false,
+			},
+			want: map[string]string{
+				"us-east-1/a": "destroyed", "us-east-1/b": "declined", "us-east-1/c": "declined", "us-east-1/d": "destroyed",
+				"eu-west-1/e": "declined", "eu-west-1/f": "destroyed", "ap-southeast-2/g": "declined",
+			},
+		},
+		{
+			name:    "input runs out",
+			answers: map[string]bool{"us-east-1/a": true, "us-east-1/b": false, "us-east-1/c": true},
+			want: map[string]string{
+				"us-east-1/a": "destroyed", "us-east-1/b": "declined", "us-east-1/c": "destroyed", "us-east-1/d": "skipped",
+				"eu-west-1/e": "skipped", "eu-west-1/f": "skipped", "ap-southeast-2/g": "skipped",
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			w := newWorld(t, scenarioVaults)
+			var output lockedBuffer
+			r := w.runner(t, &scriptedPrompter{answers: c.answers}, &output, Options{MaxConcurrentVaults: 2})
+
+			report, err := r.Run(context.Background(), scenarioRegions)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if len(report.Found) != len(scenarioVaults) {
+				t.Errorf("found %v, want every vault", vaultNames(report.Found))
+			}
+			selected, declined := vaultNames(report.Selected), vaultNames(report.Declined)
+			results := resultsByVault(report.Results)
+			left := w.left()
+			for name, want := range c.want {
+				result, isSelected, isDeclined := results[name], false, false
+				for _, key := range selected {
+					isSelected = isSelected || key == name
+				}
+				for _, key := range declined {
+					isDeclined = isDeclined || key == name
+				}
+				region, vault, _ := strings.Cut(name, "/")
+
+				switch want {
+				case "destroyed":
+					if !isSelected || result == nil || result.Err != nil || left[name] != 0 {
+						t.Errorf("vault %s: selected %t, result %+v, %d archives left; want it destroyed", name, isSelected, result, left[name])
+					}
+					if !strings.Contains(output.String(), fmt.Sprintf("Vault %s in region %s marked for deletion.", vault, region)) {
+						t.Errorf("vault %s wasn't reported marked for deletion", name)
+					}
+				case "declined":
+					if !isDeclined || result != nil || left[name] != scenarioVaults[name] {
+						t.Errorf("vault %s: declined %t, result %+v, %d archives left; want it declined and untouched", name, isDeclined, result, left[name])
+					}
+				case "skipped":
+					_, recorded := r.Store.Job(region, vault)
+					if result == nil || !result.Skipped || left[name] != scenarioVaults[name] || recorded {
+						t.Errorf("vault %s: result %+v, %d archives left, job recorded %t; want it skipped", name, result, left[name], recorded)
+					}
+				}
+			}
+			if !strings.Contains(output.String(), "Found 4 Glacier Vault(s) in region") {
+				t.Errorf("the scan wasn't reported to the output:\n%s", output.String())
+			}
+			w.checkDeletedOnce(t)
+		})
+	}
+}
+
+func TestRunnerFailureThenResume(t *testing.T) {
+	for _, c := range concurrency {
+		t.Run(c.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			w := newWorld(t, scenarioVaults)
+			var failing atomic.Bool
+			failing.Store(true)
+			w.intercept(func(input *glacier.DeleteArchiveInput) error {
+				if failing.Load() && aws.ToString(input.VaultName) == "b" {
+					return glaciertest.AccessDenied()
+				}
+				return nil
+			})
+			answers := make(map[string]bool)
+			for name := range scenarioVaults {
+				answers[name] = true
+			}
+			r := w.runner(t, &scriptedPrompter{answers: answers}, io.Discard, c.opts)
+
+			report, err := r.Run(context.Background(), scenarioRegions)
+			if err == nil || !strings.Contains(err.Error(), "1 vault(s) failed") {
+				t.Fatalf("Run returned %v, want vault b's failure", err)
+			}
+			for name, result := range resultsByVault(report.Results) {
+				if (result.Err != nil) != (name == "us-east-1/b") {
+					t.Errorf("vault %s got %v", name, result.Err)
+				}
+			}
+			if outcome, _ := r.Store.Outcome("us-east-1", "b"); outcome.Status != state.StatusFailed {
+				t.Errorf("vault b was recorded %q, want failed", outcome.Status)
+			}
+
+			// Resumed, the failed vault isn't retried unless asked.
+			failing.Store(false)
+			if report := r.Resume(context.Background()); len(report.Results) != 0 {
+				t.Errorf("resuming without retrying took up %v", vaultNames(resultVaults(report.Results)))
+			}
+			r.Options.RetryFailed = true
+			report = r.Resume(context.Background())
+			if err := r.Summarize(report); err != nil {
+				t.Errorf("resume: %v", err)
+			}
+			if got := vaultNames(resultVaults(report.Results)); len(got) != 1 || got[0] != "us-east-1/b" {
+				t.Errorf("resume took up %v, want vault b alone", got)
+			}
+			for name, n := range w.left() {
+				if n != 0 {
+					t.Errorf("vault %s has %d archives left", name, n)
+				}
+			}
+			w.checkDeletedOnce(t)
+		})
+	}
+}
+
+func TestRunnerCanceledThenResume(t *testing.T) {
+	for _, c := range concurrency {
+		t.Run(c.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			w := newWorld(t, scenarioVaults)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var deletions atomic.Int64
+			w.intercept(func(*glacier.DeleteArchiveInput) error {
+				if deletions.Add(1) == 50 {
+					cancel()
+				}
+				return nil
+			})
+			answers := make(map[string]bool)
+			for name := range scenarioVaults {
+				answers[name] = true
+			}
+			r := w.runner(t, &scriptedPrompter{answers: answers}, io.Discard, c.opts)
+
+			if _, err := r.Run(ctx, scenarioRegions); err == nil {
+				t.Fatal("the canceled run succeeded")
+			}
+			left := 0
+			for _, n := range w.left() {
+				left += n
+			}
+			if left == 0 {
+				t.Fatal("every archive was deleted")
+			}
+
+			// The vaults with a job recorded are taken up from it; those the
+			// run never started are left for a fresh one.
+			var resumable []string
+			for _, job := range r.Store.State.Jobs {
+				resumable = append(resumable, job.Region+"/"+job.Vault)
+			}
+			report := r.Resume(context.Background())
+			if err := r.Summarize(report); err != nil {
+				t.Errorf("resume: %v", err)
+			}
+			if got := vaultNames(resultVaults(report.Results)); len(got) != len(resumable) {
+				t.Errorf("resume took up %v, want %v", got, resumable)
+			}
+			left = 0
+			for name, n := range w.left() {
+				if n != 0 && n != scenarioVaults[name] {
+					t.Errorf("vault %s has %d of its %d archives left after the resume", name, n, scenarioVaults[name])
+				}
+				left += n
+			}
+			for _, name := range resumable {
+				if n := w.left()[name]; n != 0 {
+					t.Errorf("vault %s has %d archives left after the resume", name, n)
+				}
+			}
+			t.Logf("%d vault(s) resumed, %d archives left for a fresh run", len(resumable), left)
+			w.checkDeletedOnce(t)
+		})
+	}
+}
+
+func TestRunnerCanceledWhileAsking(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	w := newWorld(t, scenarioVaults)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prompter := &cancelingPrompter{after: 3, cancel: cancel}
+	r := w.runner(t, prompter, io.Discard, Options{})
+
+	report, err := r.Run(ctx, scenarioRegions)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v, want it canceled", err)
+	}
+	if len(report.Selected) != 0 || len(report.Results) != 0 {
+		t.Errorf("selected %v with %d result(s), want nothing destroyed", vaultNames(report.Selected), len(report.Results))
+	}
+	for region, fake := range w.fakes {
+		if n := fake.Count(glaciertest.OpInitiateJob) + fake.Count(glaciertest.OpDeleteArchive); n != 0 {
+			t.Errorf("%d job(s) or deletion(s) in %s", n, region)
+		}
+	}
+}
+
+// cancelingPrompter accepts every vault until it has been asked after times,
+// then cancels the run.
+type cancelingPrompter struct {
+	after  int
+	cancel context.CancelFunc
+}
+
+func (p *cancelingPrompter) Confirm(ctx context.Context, question string) (bool, error) {
+	if p.after--; p.after < 0 {
+		p.cancel()
+		return false, ctx.Err()
+	}
+	return true, nil
+}
+
+// resultVaults returns the vault of each result.
+func resultVaults(results []*VaultResult) []*glacierpurge.Vault {
+	vaults := make([]*glacierpurge.Vault, len(results))
+	for i, result := range results {
+		vaults[i] = result.Vault
+	}
+	return vaults
+}
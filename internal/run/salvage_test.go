@@ -0,0 +1,64 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/goleak"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+)
+
+// TestSalvageThenDelete salvages a vault before purging it, and checks every
+// archive was downloaded and verified, and that the deletions were read from
+// the copy of the inventory the salvage saved rather than fetched again.
+func TestSalvageThenDelete(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	w := newWorld(t, map[string]int{"us-east-1/photos": 0})
+	fake := w.fakes["us-east-1"]
+	for i := 0; i < 30; i++ {
+		fake.Upload("photos", glaciertest.Archive{Description: fmt.Sprintf("photo-%02d.jpg", i), Content: []byte(fmt.Sprintf("the bytes of photo %d", i))})
+	}
+	fake.TakeInventory("photos")
+	dir := t.TempDir()
+	opts := Options{WorkersPerVault: 4, Salvage: &glacierpurge.SalvageOptions{Dir: dir, Concurrency: 4}}
+
+	results := w.destroy(context.Background(), t, newTestStore(t), opts)
+	if err := results[0].Err; err != nil {
+		t.Fatal(err)
+	}
+	if left := w.left()["us-east-1/photos"]; left != 0 {
+		t.Errorf("%d archives are left", left)
+	}
+
+	vaultDir := filepath.Join(dir, "us-east-1", "photos")
+	data, err := os.ReadFile(filepath.Join(vaultDir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest glacierpurge.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Archives) != 30 {
+		t.Errorf("the manifest has %d archives, want 30", len(manifest.Archives))
+	}
+	for id, entry := range manifest.Archives {
+		if !entry.Verified {
+			t.Errorf("archive %s wasn't verified: %s", id, entry.Error)
+		}
+	}
+	inventories, _ := filepath.Glob(filepath.Join(vaultDir, "inventory.*"))
+	if len(inventories) != 1 {
+		t.Errorf("saved inventories %v, want one", inventories)
+	}
+	// One for the inventory, and one for each archive.
+	if n := fake.Count(glaciertest.OpGetJobOutput); n != 31 {
+		t.Errorf("got %d job outputs, want the inventory's once and each archive's", n)
+	}
+}
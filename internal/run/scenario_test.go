@@ -0,0 +1,547 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"go.uber.org/goleak"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/glacierpurge/glaciertest"
+	"github.com/rdegges/ice-breaker/internal/events"
+	"github.com/rdegges/ice-breaker/internal/state"
+)
+
+// The scenarios run the whole pipeline, concurrent parts and all, against
+// fake regions, and are meant to be run with -race too. Each checks that no
+// goroutine outlives the run.
+
+// world is a fake Glacier in each of a few regions.
+type world struct {
+	clock   *glaciertest.Clock
+	fakes   map[string]*glaciertest.Fake
+	clients clientMap
+	vaults  []*glacierpurge.Vault // in the order they were given
+}
+
+// newWorld creates the vaults given as "region/vault", each with the number
+// of archives given for it, every inventory job taking two polls.
+func newWorld(t *testing.T, vaults map[string]int, opts ...glacierpurge.Option) *world {
+	t.Helper()
+	w := &world{clock: glaciertest.NewClock(testStart), fakes: make(map[string]*glaciertest.Fake), clients: make(clientMap)}
+	w.clock.Auto = true
+	clients := w.clients
+
+	names := make([]string, 0, len(vaults))
+	for name := range vaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		region, vault, _ := strings.Cut(name, "/")
+		fake := w.fakes[region]
+		if fake == nil {
+			fake = glaciertest.New()
+			fake.Region, fake.JobPolls, fake.InventoryOnRefusal = region, 2, true
+			w.fakes[region] = fake
+			clients[region] = newTestGlacier(t, fake, w.clock, opts...)
+		}
+		fake.AddVault(glaciertest.Vault{Name: vault, Archives: make([]glaciertest.Archive, vaults[name])})
+		w.vaults = append(w.vaults, &glacierpurge.Vault{Glacier: clients[region], Name: vault})
+	}
+	return w
+}
+
+// intercept runs fn on every archive deletion in every region.
+func (w *world) intercept(fn func(input *glacier.DeleteArchiveInput) error) {
+	for _, fake := range w.fakes {
+		fake.Intercept(glaciertest.OpDeleteArchive, func(input any) error {
+			return fn(input.(*glacier.DeleteArchiveInput))
+		})
+	}
+}
+
+// left returns how many archives each vault still has, by "region/vault".
+func (w *world) left() map[string]int {
+	left := make(map[string]int)
+	for _, vault := range w.vaults {
+		left[vault.Glacier.Region+"/"+vault.Name] = len(w.fakes[vault.Glacier.Region].Archives(vault.Name))
+	}
+	return left
+}
+
+// checkDeletedOnce fails if any archive was deleted more than once.
+func (w *world) checkDeletedOnce(t *testing.T) {
+	t.Helper()
+	for region, fake := range w.fakes {
+		deleted := make(map[string]int)
+		for _, call := range fake.Calls(glaciertest.OpDeleteArchive) {
+			if call.Err == nil {
+				input := call.Input.(*glacier.DeleteArchiveInput)
+				deleted[aws.ToString(input.VaultName)+"/"+aws.ToString(input.ArchiveId)]++
+			}
+		}
+		for id, n := range deleted {
+			if n > 1 {
+				t.Errorf("archive %s in %s was deleted %d times", id, region, n)
+			}
+		}
+	}
+}
+
+// checkJobsKept fails if a vault the run didn't finish had its inventory job
+// initiated and no longer recorded, for a resume to carry on with.
+func (w *world) checkJobsKept(t *testing.T, store *state.Store, results []*VaultResult) {
+	t.Helper()
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		initiated := false
+		for _, call := range w.fakes[result.Vault.Glacier.Region].Calls(glaciertest.OpInitiateJob) {
+			initiated = initiated || call.Err == nil && aws.ToString(call.Input.(*glacier.InitiateJobInput).VaultName) == result.Vault.Name
+		}
+		if _, ok := store.Job(result.Vault.Glacier.Region, result.Vault.Name); initiated && !ok {
+			t.Errorf("vault %s was left unfinished without its job recorded", result.Vault.Name)
+		}
+	}
+}
+
+// destroy runs Destroy over every vault, reading its progress and events
+// all the while as the status display and --events-file would.
+func (w *world) destroy(ctx context.Context, t *testing.T, store *state.Store, opts Options) []*VaultResult {
+	t.Helper()
+	opts.Clock = w.clock
+	opts.Progress = NewProgress()
+	opts.Events = events.NewBus()
+	sub := opts.Events.Subscribe(16)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range sub.C {
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				opts.Progress.Vaults()
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	results := Destroy(ctx, w.vaults, store, opts)
+	close(done)
+	opts.Events.Close()
+	wg.Wait()
+	if len(results) != len(w.vaults) {
+		t.Fatalf("got %d results for %d vaults", len(results), len(w.vaults))
+	}
+	return results
+}
+
+// resultsByVault returns the results by "region/vault".
+func resultsByVault(results []*VaultResult) map[string]*VaultResult {
+	byVault := make(map[string]*VaultResult)
+	for _, result := range results {
+		byVault[result.Vault.Glacier.Region+"/"+result.Vault.Name] = result
+	}
+	return byVault
+}
+
+// scenarioVaults are the vaults of the scenarios: several regions, one with
+// a vault too many to delete side by side.
+var scenarioVaults = map[string]int{
+	"us-east-1/a":      40,
+	"us-east-1/b":      25,
+	"us-east-1/c":      0,
+	"us-east-1/d":      60,
+	"eu-west-1/e":      30,
+	"eu-west-1/f":      15,
+	"ap-southeast-2/g": 50,
+}
+
+// concurrency are the ways the scenarios work through the vaults.
+var concurrency = []struct {
+	name string
+	opts Options
+}{
+	{"in turn", Options{}},
+	{"side by side", Options{MaxConcurrentVaults: 3, MaxPendingJobs: 2, WorkersPerVault: 8}},
+	{"side by side, adaptive", Options{MaxConcurrentVaults: 3, AdaptiveWorkers: true, WorkersPerVault: 16}},
+	{"side by side, paginated", Options{MaxConcurrentVaults: 2, MaxPendingJobs: 3, Inventory: glacierpurge.InventoryOptions{Limit: 7}}},
+}
+
+func TestScenarioEveryVaultDestroyed(t *testing.T) {
+	for _, c := range concurrency {
+		t.Run(c.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			w := newWorld(t, scenarioVaults)
+			store := newTestStore(t)
+			opts := c.opts
+			opts.DeleteVault = true
+
+			results := w.destroy(context.Background(), t, store, opts)
+			for name, result := range resultsByVault(results) {
+				if result.Err != nil {
+					t.Errorf("vault %s failed: %v", name, result.Err)
+				}
+			}
+			for name, n := range w.left() {
+				if n != 0 {
+					t.Errorf("vault %s has %d archives left", name, n)
+				}
+			}
+			w.checkDeletedOnce(t)
+			if len(store.State.Jobs) != 0 {
+				t.Errorf("jobs are still recorded: %v", store.State.Jobs)
+			}
+			// Glacier turns the vaults down until its next inventory, but for
+			// the one it already found empty.
+			if pending := store.Outcomes(state.StatusDeletePending); len(pending) != len(scenarioVaults)-1 {
+				t.Errorf("%d vault(s) are waiting to be deleted, want %d", len(pending), len(scenarioVaults)-1)
+			}
+			if w.fakes["us-east-1"].HasVault("c") {
+				t.Error("the empty vault c is still there")
+			}
+		})
+	}
+}
+
+// TestScenarioDuplicates purges a vault whose inventory lists an archive
+// twice, and checks it's deleted, counted once, and the vault recorded as
+// emptied all the same.
+func TestScenarioDuplicates(t *testing.T) {
+	w := newWorld(t, map[string]int{"us-east-1/photos": 5})
+	fake := w.fakes["us-east-1"]
+	fake.Upload("photos", glaciertest.Archive{Id: fake.Archives("photos")[2].Id})
+	fake.TakeInventory("photos")
+	store := newTestStore(t)
+
+	results := w.destroy(context.Background(), t, store, Options{WorkersPerVault: 4})
+	result := results[0]
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if result.Purge.Deleted != 5 || result.Purge.Duplicates != 1 {
+		t.Errorf("got %+v, want 5 deleted and 1 duplicate", result.Purge)
+	}
+	if left := w.left()["us-east-1/photos"]; left != 0 {
+		t.Errorf("%d archives are left", left)
+	}
+	if _, emptied := store.EmptiedVault("us-east-1", "photos"); !emptied {
+		t.Error("the vault wasn't recorded as emptied")
+	}
+}
+
+func TestScenarioFailFast(t *testing.T) {
+	for _, c := range concurrency {
+		t.Run(c.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			w := newWorld(t, scenarioVaults)
+			store := newTestStore(t)
+			w.intercept(func(input *glacier.DeleteArchiveInput) error {
+				if aws.ToString(input.VaultName) == "b" {
+					return glaciertest.AccessDenied()
+				}
+				return nil
+			})
+			opts := c.opts
+			opts.FailFast = true
+
+			results := resultsByVault(w.destroy(context.Background(), t, store, opts))
+			if err := results["us-east-1/b"].Err; err == nil || errors.Is(err, ErrStopped) || errors.Is(err, ErrAborted) {
+				t.Errorf("vault b got %v, want its own failure", err)
+			}
+			left := w.left()
+			for name, result := range results {
+				switch {
+				case name == "us-east-1/b":
+				case result.Err == nil:
+					if left[name] != 0 {
+						t.Errorf("vault %s succeeded with %d archives left", name, left[name])
+					}
+				case !errors.Is(result.Err, ErrStopped) && !errors.Is(result.Err, ErrAborted):
+					t.Errorf("vault %s got %v, want it stopped or aborted", name, result.Err)
+				}
+			}
+			if c.opts.MaxConcurrentVaults <= 1 {
+				// Taken in order, every vault after b is aborted unstarted.
+				for _, name := range []string{"us-east-1/c", "us-east-1/d"} {
+					if !errors.Is(results[name].Err, ErrAborted) || left[name] != scenarioVaults[name] {
+						t.Errorf("vault %s got %v with %d archives left, want it aborted", name, results[name].Err, left[name])
+					}
+				}
+			}
+			// Side by side, the others may all be done before b fails.
+			if err := Summarize(w.destroyed(results)); err == nil || c.opts.MaxConcurrentVaults <= 1 && !strings.Contains(err.Error(), "vault b") {
+				t.Errorf("got %v, want the run stopped at vault b", err)
+			}
+			w.checkDeletedOnce(t)
+		})
+	}
+}
+
+// TestScenarioJobFailures has one vault's inventory job fail to start and
+// another's output fail to decode, and checks each is recorded against its
+// vault and fails the run, while the other vaults are still destroyed.
+func TestScenarioJobFailures(t *testing.T) {
+	for _, c := range concurrency {
+		t.Run(c.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			vaults := map[string]int{"us-east-1/a": 20, "us-east-1/b": 15, "us-east-1/c": 10, "eu-west-1/e": 12}
+			w := newWorld(t, vaults)
+			store := newTestStore(t)
+			w.fakes["us-east-1"].Intercept(glaciertest.OpInitiateJob, func(input any) error {
+				if aws.ToString(input.(*glacier.InitiateJobInput).VaultName) == "b" {
+					return glaciertest.Error(400, "InvalidParameterValueException", "no jobs today")
+				}
+				return nil
+			})
+			w.fakes["eu-west-1"].Script(glaciertest.OpGetJobOutput, glaciertest.Response{
+				Output: &glacier.GetJobOutputOutput{Body: io.NopCloser(strings.NewReader(`{"ArchiveList":[{"ArchiveId":`))},
+			})
+
+			results := resultsByVault(w.destroy(context.Background(), t, store, c.opts))
+			if err := results["us-east-1/b"].Err; err == nil || !strings.Contains(err.Error(), "failed to initiate inventory retrieval job") || !strings.Contains(err.Error(), "no jobs today") {
+				t.Errorf("vault b got %v, want the failure to initiate its job", err)
+			}
+			if err := results["eu-west-1/e"].Err; err == nil || !strings.Contains(err.Error(), "failed to decode job output") {
+				t.Errorf("vault e got %v, want the failure to decode its inventory", err)
+			}
+			left := w.left()
+			for _, name := range []string{"us-east-1/b", "eu-west-1/e"} {
+				if left[name] != vaults[name] {
+					t.Errorf("vault %s has %d archives left, want all %d", name, left[name], vaults[name])
+				}
+			}
+			for _, name := range []string{"us-east-1/a", "us-east-1/c"} {
+				if err := results[name].Err; err != nil || left[name] != 0 {
+					t.Errorf("vault %s got %v with %d archives left, want it destroyed", name, err, left[name])
+				}
+			}
+
+			if err := Summarize(w.destroyed(results)); err == nil || err.Error() != "2 vault(s) failed" {
+				t.Errorf("got %v, want the run failed for both vaults", err)
+			}
+			w.checkJobsKept(t, store, w.destroyed(results))
+		})
+	}
+}
+
+// destroyed returns the results in the order of the vaults.
+func (w *world) destroyed(results map[string]*VaultResult) []*VaultResult {
+	ordered := make([]*VaultResult, len(w.vaults))
+	for i, vault := range w.vaults {
+		ordered[i] = results[vault.Glacier.Region+"/"+vault.Name]
+	}
+	return ordered
+}
+
+func TestScenarioCanceled(t *testing.T) {
+	for _, c := range concurrency {
+		t.Run(c.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			w := newWorld(t, scenarioVaults)
+			store := newTestStore(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var deletions atomic.Int64
+			w.intercept(func(*glacier.DeleteArchiveInput) error {
+				if deletions.Add(1) == 50 {
+					cancel()
+				}
+				return nil
+			})
+
+			results := w.destroy(ctx, t, store, c.opts)
+			total, left := 0, 0
+			for name, n := range w.left() {
+				total += scenarioVaults[name]
+				left += n
+			}
+			if left == 0 {
+				t.Fatal("every archive was deleted")
+			}
+			canceled := 0
+			for _, result := range results {
+				if errors.Is(result.Err, context.Canceled) {
+					canceled++
+				} else if result.Err != nil {
+					t.Errorf("vault %s got %v", result.Vault.Name, result.Err)
+				}
+			}
+			if canceled == 0 {
+				t.Error("no vault was canceled")
+			}
+			w.checkDeletedOnce(t)
+			w.checkJobsKept(t, store, results)
+			t.Logf("%d of %d archives deleted before the run ended", total-left, total)
+		})
+	}
+}
+
+func TestScenarioWrappedUp(t *testing.T) {
+	for _, c := range concurrency {
+		t.Run(c.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			wrapUp := glacierpurge.NewWrapUp()
+			w := newWorld(t, scenarioVaults, glacierpurge.WithWrapUp(wrapUp))
+			store := newTestStore(t)
+			var deletions atomic.Int64
+			w.intercept(func(*glacier.DeleteArchiveInput) error {
+				if deletions.Add(1) == 50 {
+					wrapUp.Request()
+				}
+				return nil
+			})
+
+			results := w.destroy(context.Background(), t, store, c.opts)
+			stopped := 0
+			for _, result := range results {
+				switch {
+				case errors.Is(result.Err, glacierpurge.ErrWrappedUp):
+					stopped++
+				case result.Err != nil:
+					t.Errorf("vault %s got %v", result.Vault.Name, result.Err)
+				}
+			}
+			if stopped == 0 {
+				t.Error("no vault was stopped early")
+			}
+			w.checkDeletedOnce(t)
+			w.checkJobsKept(t, store, results)
+		})
+	}
+}
+
+// awkwardVaults are vaults with names at the edges of what Glacier allows:
+// ones differing only in case, ones of nothing but dots, a Windows device
+// name, every punctuation mark Glacier takes, and the longest name it gives.
+var awkwardVaults = []string{
+	"Photos", "photos", "PHOTOS",
+	".", "...", "photos.",
+	"CON", "nul",
+	"a.b-c_d",
+	strings.Repeat("x", glacierpurge.MaxVaultNameLength),
+}
+
+// TestScenarioAwkwardNames salvages and empties vaults with awkwardVaults'
+// names, and checks each kept a salvage directory of its own and had its
+// outcome recorded under its exact name.
+func TestScenarioAwkwardNames(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	vaults := make(map[string]int)
+	for _, name := range awkwardVaults {
+		vaults["us-east-1/"+name] = 0
+	}
+	w := newWorld(t, vaults)
+	fake := w.fakes["us-east-1"]
+	for _, name := range awkwardVaults {
+		for i := 0; i < 2; i++ {
+			fake.Upload(name, glaciertest.Archive{Description: name, Content: []byte(name + " " + strconv.Itoa(i))})
+		}
+		fake.TakeInventory(name)
+	}
+	store := newTestStore(t)
+	dir := t.TempDir()
+	opts := Options{MaxConcurrentVaults: 3, Salvage: &glacierpurge.SalvageOptions{Dir: dir}}
+
+	for name, result := range resultsByVault(w.destroy(context.Background(), t, store, opts)) {
+		if result.Err != nil {
+			t.Errorf("vault %s failed: %v", name, result.Err)
+		}
+	}
+	for name, n := range w.left() {
+		if n != 0 {
+			t.Errorf("vault %s has %d archives left", name, n)
+		}
+	}
+	for _, name := range awkwardVaults {
+		if outcome, ok := store.Outcome("us-east-1", name); !ok || outcome.Status != state.StatusArchivesDone {
+			t.Errorf("vault %q has outcome %+v, recorded %t", name, outcome, ok)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "us-east-1", glacierpurge.VaultFileName(name), "manifest.json"))
+		if err != nil {
+			t.Errorf("vault %q: %v", name, err)
+			continue
+		}
+		var manifest glacierpurge.Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		// Each manifest has only its own vault's archives, described by the
+		// vault's name.
+		if len(manifest.Archives) != 2 {
+			t.Errorf("vault %q's manifest has %d archives, want 2", name, len(manifest.Archives))
+		}
+		for _, entry := range manifest.Archives {
+			if entry.Description != name || !entry.Verified {
+				t.Errorf("vault %q's manifest has %+v", name, entry)
+			}
+		}
+	}
+	if dirs, _ := os.ReadDir(filepath.Join(dir, "us-east-1")); len(dirs) != len(awkwardVaults) {
+		t.Errorf("got %d salvage directories for %d vaults", len(dirs), len(awkwardVaults))
+	}
+}
+
+// TestScenarioAwkwardNamesPicked checks --vault picks the awkward names byte
+// for byte, never folding case.
+func TestScenarioAwkwardNamesPicked(t *testing.T) {
+	vaults := make(map[string]int)
+	for _, name := range awkwardVaults {
+		vaults["us-east-1/"+name] = 1
+	}
+	for _, c := range []struct {
+		names []string
+		want  []string
+	}{
+		{[]string{"Photos"}, []string{"Photos"}},
+		{[]string{"photos"}, []string{"photos"}},
+		{[]string{"photo"}, nil},
+		{[]string{"P*"}, []string{"PHOTOS", "Photos"}},
+		{[]string{"photos?"}, []string{"photos."}},
+		{[]string{"."}, []string{"."}},
+		{[]string{"con", "NUL"}, nil},
+		{[]string{"a.b-c_d"}, []string{"a.b-c_d"}},
+		{[]string{"x*"}, []string{strings.Repeat("x", glacierpurge.MaxVaultNameLength)}},
+	} {
+		w := newWorld(t, vaults)
+		r := w.runner(t, nil, io.Discard, Options{})
+		r.Names = c.names
+		offered, err := r.Offer(context.Background(), []string{"us-east-1"}, &Report{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, vault := range offered {
+			got = append(got, vault.Name)
+		}
+		sort.Strings(got)
+		if !slices.Equal(got, c.want) {
+			t.Errorf("--vault %q offered %q, want %q", c.names, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// gate admits up to max at once. Those waiting for room are let in by
+// rank, lowest first, and among equal ranks in the order they started
+// waiting. A nil gate admits everyone at once.
+type gate struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	n       int
+	max     int
+	tickets int         // handed out to waiters, in the order they come
+	waiting map[int]int // each waiter's rank, by ticket
+	// expected holds the tickets of the ranks expect was told of, by rank,
+	// until they come.
+	expected map[int]int
+}
+
+func newGate(max int) *gate {
+	g := &gate{max: max, waiting: make(map[int]int), expected: make(map[int]int)}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// newSlots returns the gate limiting how many vaults delete archives at
+// once, or nil for one at a time, which needs none. Vaults of equal rank are
+// let in in the order their inventories completed.
+func newSlots(n int) *gate {
+	if n <= 1 {
+		return nil
+	}
+	return newGate(n)
+}
+
+// acquire waits for room, and for every waiter ahead of rank to be let in,
+// or for ctx to end.
+func (g *gate) acquire(ctx context.Context, rank int) error {
+	if g == nil {
+		return nil
+	}
+	stop := context.AfterFunc(ctx, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.cond.Broadcast()
+	})
+	defer stop()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ticket, ok := g.expected[rank]
+	if ok {
+		delete(g.expected, rank)
+	} else {
+		ticket = g.tickets
+		g.tickets++
+		g.waiting[ticket] = rank
+	}
+	defer func() {
+		delete(g.waiting, ticket)
+		// Whoever's next may fit in too, or may have been waiting on this one.
+		g.cond.Broadcast()
+	}()
+	for g.n >= g.max || !g.first(ticket) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		g.cond.Wait()
+	}
+	g.n++
+	return nil
+}
+
+// first reports whether the waiter with ticket is the next to be let in.
+func (g *gate) first(ticket int) bool {
+	rank := g.waiting[ticket]
+	for other, otherRank := range g.waiting {
+		if otherRank < rank || otherRank == rank && other < ticket {
+			return false
+		}
+	}
+	return true
+}
+
+// expect has each of ranks wait its turn from now on, as though it had
+// started waiting, so ranks that all arrive at once are let in by rank
+// rather than by which happens to come first. Each expected rank must come,
+// or be withdrawn. Ranks must be unique.
+func (g *gate) expect(ranks map[*glacierpurge.Vault]int) {
+	if g == nil || ranks == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sorted := make([]int, 0, len(ranks))
+	for _, rank := range ranks {
+		sorted = append(sorted, rank)
+	}
+	sort.Ints(sorted)
+	for _, rank := range sorted {
+		g.expected[rank] = g.tickets
+		g.waiting[g.tickets] = rank
+		g.tickets++
+	}
+}
+
+// withdraw stops expecting rank, if it still is, once it won't be coming.
+func (g *gate) withdraw(rank int) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.withdrawLocked(rank)
+}
+
+func (g *gate) withdrawLocked(rank int) {
+	if ticket, ok := g.expected[rank]; ok {
+		delete(g.expected, rank)
+		delete(g.waiting, ticket)
+		g.cond.Broadcast()
+	}
+}
+
+// hold counts rank in without waiting, whether or not there's room.
+func (g *gate) hold(rank int) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.withdrawLocked(rank)
+	g.n++
+}
+
+// release is called once one acquired or held is done.
+func (g *gate) release() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n--
+	g.cond.Broadcast()
+}
+
+// processConcurrently starts every task at once, so the vaults' inventories
+// are waited for side by side; opts.deleting keeps all but
+// MaxConcurrentVaults of them from deleting at the same time.
+func processConcurrently(ctx context.Context, tasks []task, opts Options) []*VaultResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Every job is initiated in the vaults' order, rather than that of
+	// whichever gets there first.
+	opts.pending.expect(opts.ranks)
+
+	results := make([]*VaultResult, len(tasks))
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		aborted bool
+	)
+	for i, t := range tasks {
+		wg.Add(1)
+		go func(i int, t task) {
+			defer wg.Done()
+
+			result, err := t.run(ctx)
+			opts.pending.withdraw(opts.ranks[t.vault])
+			opts.finished(t.vault, result, err)
+			if err == nil {
+				opts.Schedule.done(ctx, t.vault)
+			}
+			mu.Lock()
+			if aborted && errors.Is(err, context.Canceled) {
+				err = ErrStopped
+			}
+			if err != nil && !errors.Is(err, ErrStopped) && !errors.Is(err, glacierpurge.ErrWrappedUp) {
+				ui.Printf("%sError destroying vault %s in region %s: %v%s\n", ui.Red, t.vault.Name, t.vault.Glacier.Region, err, ui.Reset)
+
+				expired := errors.Is(err, glacierpurge.ErrCredentialsExpired)
+				if (opts.FailFast || expired) && !aborted {
+					reason := "--fail-fast is set"
+					if expired {
+						reason = "the AWS credentials have expired"
+					}
+					ui.Printf("%sStopping the other vaults because %s.%s\n", ui.Yellow, reason, ui.Reset)
+					aborted = true
+					cancel()
+				}
+			}
+			mu.Unlock()
+
+			_, stale := staleInventory(ctx, t.vault)
+			results[i] = &VaultResult{Vault: t.vault, Purge: result, Err: err, PossiblyIncomplete: stale, EarlyFees: opts.fees[t.vault]}
+		}(i, t)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// newPendingJobs returns the gate limiting how many vaults have an
+// inventory job initiated but not yet through its wait, so a long list of
+// vaults doesn't have every job complete at once, and expire unread, or nil
+// for no limit. A job already initiated, by an earlier run or outside the
+// tool, is held, counting whether or not there's room.
+func newPendingJobs(max int) *gate {
+	if max <= 0 {
+		return nil
+	}
+	return newGate(max)
+}
+
+// wrappingUp returns a context that also ends once a wrap-up of the vault's
+// deletions is requested, for the waits that come before deleting.
+func wrappingUp(ctx context.Context, vault *glacierpurge.Vault) (context.Context, context.CancelFunc) {
+	waitCtx, cancel := context.WithCancel(ctx)
+	if done := vault.Glacier.WrapUp.Done(); done != nil {
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-waitCtx.Done():
+			}
+		}()
+	}
+	return waitCtx, cancel
+}
+
+// wrappedUp turns the error of a wait that wrappingUp ended, rather than
+// ctx, into glacierpurge.ErrWrappedUp.
+func wrappedUp(ctx context.Context, vault *glacierpurge.Vault, err error) error {
+	if err != nil && ctx.Err() == nil && vault.Glacier.WrapUp.Requested() && errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w, before deleting anything more", glacierpurge.ErrWrappedUp)
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package run
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// DefaultScheduledTag is the key of the tag a Schedule puts on vaults.
+const DefaultScheduledTag = "icebreaker:scheduled"
+
+// Schedule tags each vault of a run as scheduled for deletion when the run
+// starts, and removes the tag once the vault is done, so a run interrupted
+// part way leaves a trace in AWS itself of the vaults it didn't finish. The
+// tag's value is the run's ID and when it started. Failing to tag or untag a
+// vault is only warned about. A nil Schedule tags nothing.
+type Schedule struct {
+	Key     string
+	RunId   string
+	Started time.Time
+}
+
+// NewSchedule returns a Schedule tagging vaults with key for a run starting
+// now, under a new random run ID.
+func NewSchedule(key string) *Schedule {
+	id := make([]byte, 4)
+	rand.Read(id)
+	return &Schedule{Key: key, RunId: hex.EncodeToString(id), Started: time.Now()}
+}
+
+func (s *Schedule) value() string {
+	return s.RunId + " " + s.Started.UTC().Format(time.RFC3339)
+}
+
+// ParseScheduled returns the run ID and start time a scheduled tag's value
+// records.
+func ParseScheduled(value string) (runId string, started time.Time, ok bool) {
+	runId, at, ok := strings.Cut(value, " ")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	started, err := time.Parse(time.RFC3339, at)
+	return runId, started, err == nil
+}
+
+// tag tags every vault as scheduled for deletion.
+func (s *Schedule) tag(ctx context.Context, vaults []*glacierpurge.Vault) {
+	if s == nil || len(vaults) == 0 {
+		return
+	}
+	var tagged atomic.Int32
+	forEachVault(vaults, func(vault *glacierpurge.Vault) {
+		if err := vault.AddTags(ctx, map[string]string{s.Key: s.value()}); err != nil {
+			ui.Printf("%sCouldn't tag vault %s in region %s as scheduled for deletion: %v%s\n", ui.Yellow, vault.Name, vault.Glacier.Region, err, ui.Reset)
+			return
+		}
+		tagged.Add(1)
+	})
+	if tagged.Load() > 0 {
+		ui.Printf("Tagged %d vault(s) %s=%s until they're done.\n", tagged.Load(), s.Key, s.value())
+	}
+}
+
+// done removes the tag from a vault the run is done with.
+func (s *Schedule) done(ctx context.Context, vault *glacierpurge.Vault) {
+	if s == nil {
+		return
+	}
+	untag(ctx, vault, s.Key)
+}
+
+func untag(ctx context.Context, vault *glacierpurge.Vault, key string) bool {
+	err := vault.RemoveTags(ctx, key)
+	if errors.Is(err, glacierpurge.ErrVaultNotFound) {
+		// A deleted vault takes its tags with it.
+		return false
+	}
+	if err != nil {
+		ui.Printf("%sCouldn't remove the %s tag from vault %s in region %s: %v%s\n", ui.Yellow, key, vault.Name, vault.Glacier.Region, err, ui.Reset)
+		return false
+	}
+	return true
+}
+
+// Unschedule removes the scheduled tag from those of the vaults that carry
+// it, as tagged by an earlier run, for vaults that weren't selected this
+// time.
+func Unschedule(ctx context.Context, vaults []*glacierpurge.Vault, key string) {
+	for _, vault := range vaults {
+		tags, err := vault.Tags(ctx)
+		if _, ok := tags[key]; err != nil || !ok {
+			continue
+		}
+		if untag(ctx, vault, key) {
+			ui.Printf("Removed the %s tag from vault %s in region %s, which wasn't selected.\n", key, vault.Name, vault.Glacier.Region)
+		}
+	}
+}
+
+// FindScheduled keeps the vaults tagged as scheduled for deletion with key,
+// reporting which run tagged each.
+func FindScheduled(ctx context.Context, vaults []*glacierpurge.Vault, key string) []*glacierpurge.Vault {
+	var scheduled []*glacierpurge.Vault
+	for _, vault := range vaults {
+		tags, err := vault.Tags(ctx)
+		if err != nil {
+			ui.Printf("%sCan't tell whether vault %s in region %s is scheduled for deletion: %v%s\n", ui.Yellow, vault.Name, vault.Glacier.Region, err, ui.Reset)
+			continue
+		}
+		value, ok := tags[key]
+		if !ok {
+			continue
+		}
+		if runId, started, ok := ParseScheduled(value); ok {
+			ui.Printf("Vault %s in region %s was scheduled for deletion by run %s, started %s.\n", vault.Name, vault.Glacier.Region, runId, started.Local().Format("2006-01-02 15:04"))
+		} else {
+			ui.Printf("Vault %s in region %s is tagged %s=%s.\n", vault.Name, vault.Glacier.Region, key, value)
+		}
+		scheduled = append(scheduled, vault)
+	}
+	return scheduled
+}
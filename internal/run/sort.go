@@ -0,0 +1,90 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+)
+
+// SortOptions controls the order SortVaults puts vaults in.
+type SortOptions struct {
+	// By is name, size, archives, creation-date, or age (newest first). Empty
+	// keeps the order the vaults were found in.
+	By   string
+	Desc bool // reverse the order
+	// ByRegion keeps vaults grouped by region, in the order the regions were
+	// scanned, sorting within each region.
+	ByRegion bool
+}
+
+// vaultKeys compares two described vaults.
+var vaultKeys = map[string]func(a, b *glacierpurge.VaultDescription) bool{
+	"size":          func(a, b *glacierpurge.VaultDescription) bool { return a.SizeInBytes < b.SizeInBytes },
+	"archives":      func(a, b *glacierpurge.VaultDescription) bool { return a.NumberOfArchives < b.NumberOfArchives },
+	"creation-date": func(a, b *glacierpurge.VaultDescription) bool { return a.CreationDate.Before(b.CreationDate) },
+	"age":           func(a, b *glacierpurge.VaultDescription) bool { return a.CreationDate.After(b.CreationDate) },
+}
+
+// Validate checks that the sort key is one SortVaults knows.
+func (o SortOptions) Validate() error {
+	if _, ok := vaultKeys[o.By]; ok || o.By == "" || o.By == "name" {
+		return nil
+	}
+	return fmt.Errorf("invalid sort %q: must be name, size, archives, or creation-date", o.By)
+}
+
+// SortVaults orders vaults in place. Sorting by anything but name describes
+// each vault first; vaults that couldn't be described sort last, whatever the
+// direction.
+func SortVaults(ctx context.Context, vaults []*glacierpurge.Vault, opts SortOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	regionOrder := make(map[string]int)
+	for _, vault := range vaults {
+		if _, ok := regionOrder[vault.Glacier.Region]; !ok {
+			regionOrder[vault.Glacier.Region] = len(regionOrder)
+		}
+	}
+
+	key := vaultKeys[opts.By]
+	descriptions := make(map[*glacierpurge.Vault]*glacierpurge.VaultDescription)
+	if key != nil {
+		for _, vault := range vaults {
+			if description, err := vault.Describe(ctx); err == nil {
+				descriptions[vault] = description
+			}
+		}
+	}
+
+	less := func(a, b *glacierpurge.Vault) bool {
+		switch opts.By {
+		case "":
+			return false
+		case "name":
+			return a.Glacier.Region+"/"+a.Name < b.Glacier.Region+"/"+b.Name
+		}
+		return key(descriptions[a], descriptions[b])
+	}
+
+	sort.SliceStable(vaults, func(i, j int) bool {
+		a, b := vaults[i], vaults[j]
+		if opts.ByRegion && a.Glacier.Region != b.Glacier.Region {
+			return regionOrder[a.Glacier.Region] < regionOrder[b.Glacier.Region]
+		}
+		if key != nil {
+			da, db := descriptions[a], descriptions[b]
+			if da == nil || db == nil {
+				return da != nil && db == nil
+			}
+		}
+		if opts.Desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+	return nil
+}
@@ -0,0 +1,145 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rdegges/ice-breaker/glacierpurge"
+	"github.com/rdegges/ice-breaker/internal/ui"
+)
+
+// enrichConcurrency is how many vaults Enrich and FetchTags look up at once.
+const enrichConcurrency = 8
+
+// Enrich describes every vault and lists its tags, several vaults at once,
+// so prompting and sorting use the cached results rather than calling AWS
+// one vault at a time. Failures are left for whatever uses the results to
+// report.
+func Enrich(ctx context.Context, vaults []*glacierpurge.Vault) {
+	forEachVault(vaults, func(vault *glacierpurge.Vault) {
+		vault.Describe(ctx)
+		vault.Tags(ctx)
+	})
+}
+
+// FetchTags lists every vault's tags, several vaults at once, like Enrich
+// but without describing them.
+func FetchTags(ctx context.Context, vaults []*glacierpurge.Vault) {
+	forEachVault(vaults, func(vault *glacierpurge.Vault) {
+		vault.Tags(ctx)
+	})
+}
+
+func forEachVault(vaults []*glacierpurge.Vault, f func(*glacierpurge.Vault)) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, enrichConcurrency)
+	for _, vault := range vaults {
+		wg.Add(1)
+		go func(vault *glacierpurge.Vault) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			f(vault)
+		}(vault)
+	}
+	wg.Wait()
+}
+
+// TagLabel describes the vault's tags for showing alongside it: "" when it
+// has none, otherwise its tags in order of key, or that they're unavailable.
+func TagLabel(ctx context.Context, vault *glacierpurge.Vault) string {
+	tags, err := vault.Tags(ctx)
+	if err != nil {
+		return "tags unavailable"
+	}
+	return FormatTags(tags)
+}
+
+// FormatTags lists tags as key=value pairs in order of key.
+func FormatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// Tag is a tag, or a tag a vault is required to have or not to have.
+type Tag struct {
+	Key, Value string
+}
+
+func (t Tag) String() string {
+	return t.Key + "=" + t.Value
+}
+
+// ParseTag parses key=value.
+func ParseTag(s string) (Tag, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return Tag{}, fmt.Errorf("invalid tag %q: must be key=value", s)
+	}
+	return Tag{key, value}, nil
+}
+
+// TagFilter picks vaults by their tags.
+type TagFilter struct {
+	Match   []Tag // a vault must have every one of these
+	Exclude []Tag // a vault with any one of these is filtered out
+}
+
+// match returns why the vault is filtered out, or "" if it isn't. A vault
+// whose tags can't be listed is filtered out whenever there's anything to
+// check, since it can't be shown not to carry an excluded tag.
+func (f TagFilter) match(ctx context.Context, vault *glacierpurge.Vault) string {
+	if len(f.Match) == 0 && len(f.Exclude) == 0 {
+		return ""
+	}
+	tags, err := vault.Tags(ctx)
+	if err != nil {
+		return fmt.Sprintf("its tags couldn't be checked: %v", err)
+	}
+	for _, tag := range f.Exclude {
+		if value, ok := tags[tag.Key]; ok && value == tag.Value {
+			return "tagged " + tag.String()
+		}
+	}
+	for _, tag := range f.Match {
+		if value, ok := tags[tag.Key]; !ok || value != tag.Value {
+			return "not tagged " + tag.String()
+		}
+	}
+	return ""
+}
+
+// FilterTags keeps the vaults filter picks, reporting each of the others and
+// returning them as filtered results, which are never prompted for or
+// deleted.
+func FilterTags(ctx context.Context, vaults []*glacierpurge.Vault, filter TagFilter) (kept []*glacierpurge.Vault, filtered []*VaultResult) {
+	for _, vault := range vaults {
+		reason := filter.match(ctx, vault)
+		if reason == "" {
+			kept = append(kept, vault)
+			continue
+		}
+		ui.Printf("%sLeaving vault %s in region %s alone: %s.%s\n", ui.Yellow, vault.Name, vault.Glacier.Region, reason, ui.Reset)
+		filtered = append(filtered, &VaultResult{Vault: vault, Filtered: reason})
+	}
+	return kept, filtered
+}
+
+// FilterTagsQuietly keeps the vaults filter picks, for listings, which leave
+// the others out without remark.
+func FilterTagsQuietly(ctx context.Context, vaults []*glacierpurge.Vault, filter TagFilter) []*glacierpurge.Vault {
+	var kept []*glacierpurge.Vault
+	for _, vault := range vaults {
+		if filter.match(ctx, vault) == "" {
+			kept = append(kept, vault)
+		}
+	}
+	return kept
+}
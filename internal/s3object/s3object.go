@@ -0,0 +1,128 @@
+// Package s3object reads, writes and deletes single S3 objects, a write made
+// conditional on the object it replaces when two writers mustn't overwrite
+// each other unawares. glacierpurge's Uploader reads and writes objects with
+// it, and the state package's S3 backend keeps the state in them through a
+// Store, without depending on glacierpurge for either.
+package s3object
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// ErrConflict is wrapped by the error for a conditional write whose
+// condition failed, because another writer got to the object first.
+var ErrConflict = errors.New("the S3 object was changed by another writer")
+
+// Condition makes a write conditional on the object it replaces. The zero
+// value writes regardless.
+type Condition struct {
+	IfMatch       string // the ETag the object must still have
+	IfNoneExisted bool   // the object mustn't exist yet
+}
+
+// options returns the client options sending the condition. The SDK's
+// PutObjectInput predates conditional writes, so they're set as headers.
+func (c Condition) options() []func(*s3.Options) {
+	var header func(*middleware.Stack) error
+	switch {
+	case c.IfMatch != "":
+		header = smithyhttp.SetHeaderValue("If-Match", c.IfMatch)
+	case c.IfNoneExisted:
+		header = smithyhttp.SetHeaderValue("If-None-Match", "*")
+	default:
+		return nil
+	}
+	return []func(*s3.Options){s3.WithAPIOptions(header)}
+}
+
+// Store is somewhere objects are kept by key, such as under a prefix in a
+// bucket.
+type Store interface {
+	// GetObject returns the object's contents and ETag. A missing object's
+	// error wraps fs.ErrNotExist.
+	GetObject(ctx context.Context, key string) ([]byte, string, error)
+	// PutObject writes the object, if cond holds, and returns its new ETag.
+	// A write whose condition failed returns an error wrapping ErrConflict.
+	PutObject(ctx context.Context, key string, data []byte, cond Condition) (string, error)
+	// DeleteObject deletes the object, whether or not it exists.
+	DeleteObject(ctx context.Context, key string) error
+	// URI returns the s3:// URI of the object.
+	URI(key string) string
+}
+
+// Client is the part of the S3 client the objects are read and written
+// with.
+type Client interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// Get reads the object at key in bucket, returning its contents and ETag. A
+// missing object's error wraps fs.ErrNotExist.
+func Get(ctx context.Context, client Client, bucket, key string) ([]byte, string, error) {
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, "", &Error{err}
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, aws.ToString(out.ETag), nil
+}
+
+// Put writes data as input says, if cond holds, and returns the new
+// object's ETag. A write whose condition failed returns an error wrapping
+// ErrConflict.
+func Put(ctx context.Context, client Client, input *s3.PutObjectInput, data []byte, cond Condition) (string, error) {
+	input.Body = bytes.NewReader(data)
+	out, err := client.PutObject(ctx, input, cond.options()...)
+	if err != nil {
+		return "", &Error{err}
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+// Delete deletes the object at key in bucket. S3 doesn't mind if it's
+// already gone.
+func Delete(ctx context.Context, client Client, bucket, key string) error {
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}); err != nil {
+		return &Error{err}
+	}
+	return nil
+}
+
+// Error is an error from S3. One for a missing object wraps fs.ErrNotExist,
+// and one for a write whose condition failed ErrConflict.
+type Error struct {
+	Err error // the SDK's
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	var resp interface{ HTTPStatusCode() int }
+	if errors.As(e.Err, &resp) {
+		switch resp.HTTPStatusCode() {
+		case http.StatusNotFound:
+			return []error{e.Err, fs.ErrNotExist}
+		case http.StatusPreconditionFailed, http.StatusConflict:
+			return []error{e.Err, ErrConflict}
+		}
+	}
+	return []error{e.Err}
+}
@@ -0,0 +1,23 @@
+package state
+
+// Backend is where a state directory's files are kept, by name: on disk, or
+// in S3. Either way the Store reads and writes them the same, checking their
+// format versions and checksums and falling back on their previous
+// generations.
+type Backend interface {
+	// Load returns the named file's contents. A missing file's error wraps
+	// os.ErrNotExist.
+	Load(name string) ([]byte, error)
+	// Save replaces the named file with data so that a crash leaves either
+	// the old contents or the new, and keeps the old as the file's previous
+	// generation, named with the .prev suffix.
+	Save(name string, data []byte) error
+	// Lock takes the exclusive hold of the state, failing with a
+	// *LockedError if another run has it. With force an existing lock is
+	// broken first, which is only safe if the run holding it is really gone.
+	Lock(force bool) (*Lock, error)
+	// Where describes where the named file is, for messages.
+	Where(name string) string
+	// String describes where the state is, for messages.
+	String() string
+}
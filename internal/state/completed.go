@@ -0,0 +1,126 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const completedName = "completed.json"
+
+// Emptied is a vault a run deleted every archive from. Glacier only notices
+// about a day later, at the vault's next inventory, so until then the vault
+// still reports archives and can't be deleted.
+type Emptied struct {
+	Region    string    `json:"region"`
+	Vault     string    `json:"vault"`
+	ARN       string    `json:"arn,omitempty"`
+	EmptiedAt time.Time `json:"emptiedAt"`
+	Deleted   int       `json:"deleted"`
+}
+
+// completedFile is the completed-work file. Version 1 was a bare list of
+// emptied vaults.
+type completedFile struct {
+	Version int       `json:"version"`
+	Emptied []Emptied `json:"emptied"`
+}
+
+// parseCompleted parses a completed-work file of any version up to the
+// current one.
+func parseCompleted(path string, data []byte) ([]Emptied, error) {
+	var emptied []Emptied
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(data, &emptied)
+		return emptied, err
+	}
+	var file completedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if err := checkVersion(path, file.Version); err != nil {
+		return nil, err
+	}
+	return file.Emptied, nil
+}
+
+// loadEmptied reads the emptied vaults recorded in the backend, if any.
+func (s *Store) loadEmptied() error {
+	path := s.backend.Where(completedName)
+	warning, err := readFile(s.backend, completedName, func(data []byte) error {
+		emptied, err := parseCompleted(path, data)
+		if err == nil {
+			s.Emptied = emptied
+		}
+		return err
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read completed-work file %s: %w", path, err)
+	}
+	s.warn(warning)
+	return nil
+}
+
+func (s *Store) saveEmptied() error {
+	data, err := json.MarshalIndent(completedFile{Version: Version, Emptied: s.Emptied}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := s.backend.Save(completedName, data); err != nil {
+		return fmt.Errorf("failed to write completed-work file: %w", err)
+	}
+	return nil
+}
+
+// EmptiedVault returns the record of a run emptying a vault, if any.
+func (s *Store) EmptiedVault(region, vault string) (Emptied, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.emptiedVault(region, vault)
+}
+
+func (s *Store) emptiedVault(region, vault string) (Emptied, bool) {
+	for _, emptied := range s.Emptied {
+		if emptied.Region == region && emptied.Vault == vault {
+			return emptied, true
+		}
+	}
+	return Emptied{}, false
+}
+
+// PutEmptied records a vault as emptied, replacing any earlier record of it.
+func (s *Store) PutEmptied(emptied Emptied) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeEmptied(emptied.Region, emptied.Vault)
+	s.Emptied = append(s.Emptied, emptied)
+	return s.saveEmptied()
+}
+
+// RemoveEmptied forgets that a vault was emptied, once it's deleted or has
+// archives again.
+func (s *Store) RemoveEmptied(region, vault string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.emptiedVault(region, vault); !ok {
+		return nil
+	}
+	s.removeEmptied(region, vault)
+	return s.saveEmptied()
+}
+
+func (s *Store) removeEmptied(region, vault string) {
+	kept := s.Emptied[:0]
+	for _, emptied := range s.Emptied {
+		if emptied.Region != region || emptied.Vault != vault {
+			kept = append(kept, emptied)
+		}
+	}
+	s.Emptied = kept
+}
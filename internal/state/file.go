@@ -0,0 +1,129 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// previousSuffix names the generation of a file before its last write, kept
+// to fall back on should the file be found damaged.
+const previousSuffix = ".prev"
+
+// writeFile replaces the file at path with data so that a crash, power loss,
+// or full disk leaves either the old file or the new one, never part of
+// either: data is written to a temporary file beside it and synced to disk
+// before taking its place. The old file is kept as the previous generation.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return err
+	}
+
+	if err := os.Rename(path, path+previousSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes the renames in dir durable. Not every platform can sync a
+// directory, so failing to is ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// FileBackend keeps the state in a directory on disk.
+type FileBackend struct {
+	Dir string
+}
+
+// NewFileBackend returns the backend keeping the state in dir, which is
+// created once there's something to write to it.
+func NewFileBackend(dir string) *FileBackend {
+	return &FileBackend{Dir: dir}
+}
+
+func (b *FileBackend) Load(name string) ([]byte, error) {
+	return os.ReadFile(b.Where(name))
+}
+
+func (b *FileBackend) Save(name string, data []byte) error {
+	if err := os.MkdirAll(b.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return writeFile(b.Where(name), data)
+}
+
+func (b *FileBackend) Where(name string) string {
+	return filepath.Join(b.Dir, name)
+}
+
+func (b *FileBackend) String() string {
+	return b.Dir
+}
+
+// readFile reads the named file from backend with parse, which reports
+// whether its contents are intact. A file that's missing or damaged is read
+// from its previous generation instead, if that's intact, and the returned
+// warning says so. A file that's missing with no previous generation reads
+// as os.ErrNotExist. A file from a newer build isn't damaged, just unreadable
+// by this one, and nor is one that couldn't be read at all, as when S3 can't
+// be reached, so there's no falling back from either.
+func readFile(backend Backend, name string, parse func([]byte) error) (warning string, err error) {
+	path := backend.Where(name)
+	data, err := backend.Load(name)
+	if err == nil {
+		if err = parse(data); err == nil {
+			return "", nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	var newer *NewerVersionError
+	if errors.As(err, &newer) {
+		return "", err
+	}
+	current := err
+
+	previous := backend.Where(name + previousSuffix)
+	data, err = backend.Load(name + previousSuffix)
+	if err != nil {
+		// Without an earlier generation to fall back on there's just the
+		// file's own error to report.
+		return "", current
+	}
+	if err := parse(data); err != nil {
+		// Not wrapping current, which would pass a missing file off as a
+		// fresh start.
+		return "", fmt.Errorf("%v, and its previous generation %s is damaged too: %w", current, previous, err)
+	}
+	if errors.Is(current, os.ErrNotExist) {
+		return fmt.Sprintf("%s is missing, most likely from a crash while it was being written; using its previous generation %s, which may be missing the last change", path, previous), nil
+	}
+	return fmt.Sprintf("%s is damaged (%v); using its previous generation %s, which may be missing the last change", path, current, previous), nil
+}
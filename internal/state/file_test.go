@@ -0,0 +1,166 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// generations writes two generations of the state file in dir: job-1
+// recorded, then job-2 recorded over it, which leaves job-1's as the
+// previous generation.
+func generations(t *testing.T, dir string) {
+	t.Helper()
+	store, err := Open(NewFileBackend(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"job-1", "job-2"} {
+		if err := store.PutJob(Job{Region: "us-east-1", Vault: "photos", JobId: id, InitiatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// The damage a crash, power loss, full disk or bad sector does to a file.
+var (
+	truncate = func(data []byte) []byte { return data[:len(data)/2] }
+	empty    = func([]byte) []byte { return nil }
+	// flipValue flips a bit in the current job's ID, leaving valid JSON
+	// that only its checksum gives away.
+	flipValue = func(data []byte) []byte {
+		i := bytes.Index(data, []byte("job-2")) + len("job-")
+		data[i] ^= 1
+		return data
+	}
+	// flipSyntax flips a bit in the opening brace, leaving no JSON at all.
+	flipSyntax = func(data []byte) []byte {
+		data[bytes.IndexByte(data, '{')] ^= 0x10
+		return data
+	}
+	newer = func(data []byte) []byte {
+		return bytes.Replace(data, []byte(fmt.Sprintf(`"version": %d`, Version)), []byte(fmt.Sprintf(`"version": %d`, Version+1)), 1)
+	}
+)
+
+func TestOpenTornWrites(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		// current and previous damage the state file and its previous
+		// generation; nil leaves one intact, and remove deletes it.
+		current, previous func([]byte) []byte
+		remove            string
+		wantJob           string // the job Open loads, or "" if it fails
+		wantWarning       string
+		wantErr           string
+	}{
+		{name: "intact", wantJob: "job-2"},
+		{name: "truncated", current: truncate, wantJob: "job-1", wantWarning: "damaged"},
+		{name: "emptied", current: empty, wantJob: "job-1", wantWarning: "damaged"},
+		{name: "bit flipped in a value", current: flipValue, wantJob: "job-1", wantWarning: "checksum doesn't match"},
+		{name: "bit flipped in the syntax", current: flipSyntax, wantJob: "job-1", wantWarning: "damaged"},
+		{name: "missing", remove: fileName, wantJob: "job-1", wantWarning: "is missing"},
+		{name: "truncated without a previous generation", current: truncate, remove: fileName + previousSuffix, wantErr: "failed to read state file"},
+		{name: "bit flipped without a previous generation", current: flipValue, remove: fileName + previousSuffix, wantErr: "checksum doesn't match"},
+		{name: "truncated, and the previous generation too", current: truncate, previous: truncate, wantErr: "is damaged too"},
+		{name: "bit flipped, and the previous generation too", current: flipValue, previous: flipSyntax, wantErr: "is damaged too"},
+		{name: "previous generation damaged", previous: truncate, wantJob: "job-2"},
+		{name: "from a newer build", current: newer, wantErr: "from a newer ice-breaker"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			generations(t, dir)
+			damage := func(name string, fn func([]byte) []byte) {
+				if fn == nil {
+					return
+				}
+				path := filepath.Join(dir, name)
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, fn(data), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			damage(fileName, c.current)
+			damage(fileName+previousSuffix, c.previous)
+			if c.remove != "" {
+				if err := os.Remove(filepath.Join(dir, c.remove)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			store, err := Open(NewFileBackend(dir))
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("Open returned %v, want an error saying %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			job, ok := store.Job("us-east-1", "photos")
+			if !ok || job.JobId != c.wantJob {
+				t.Errorf("loaded job %q, want %q", job.JobId, c.wantJob)
+			}
+			warnings := strings.Join(store.Warnings, "\n")
+			if c.wantWarning == "" && warnings != "" || !strings.Contains(warnings, c.wantWarning) {
+				t.Errorf("got warnings %q, want one saying %q", warnings, c.wantWarning)
+			}
+		})
+	}
+}
+
+// TestWriteFileLeavesNoTemporaries checks that the temporary file each
+// write goes through is gone once it's taken the file's place.
+func TestWriteFileLeavesNoTemporaries(t *testing.T) {
+	dir := t.TempDir()
+	generations(t, dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got := strings.Join(names, " "); got != "state.json state.json.prev" {
+		t.Errorf("the state directory holds %s", got)
+	}
+}
+
+func TestPutDone(t *testing.T) {
+	dir := t.TempDir()
+	generations(t, dir)
+	store, err := Open(NewFileBackend(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutDone("us-east-1", "photos", "job-2", 5000); err != nil {
+		t.Fatal(err)
+	}
+	// A job since replaced isn't checkpointed over its replacement.
+	if err := store.PutDone("us-east-1", "photos", "job-1", 7000); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(NewFileBackend(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := reopened.Job("us-east-1", "photos"); job.JobId != "job-2" || job.Done != 5000 {
+		t.Errorf("reopened job %s with %d done, want job-2 with 5000", job.JobId, job.Done)
+	}
+	if err := reopened.PutJob(Job{Region: "us-east-1", Vault: "photos", JobId: "job-3"}); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := reopened.Job("us-east-1", "photos"); job.Done != 0 {
+		t.Errorf("a fresh job starts with %d done", job.Done)
+	}
+}
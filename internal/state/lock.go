@@ -0,0 +1,117 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rdegges/ice-breaker/internal/redact"
+)
+
+const lockName = "lock"
+
+// Holder describes the process holding a state directory's lock.
+type Holder struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// LockedError is returned by Lock when another process holds the lock.
+type LockedError struct {
+	Path   string
+	Holder Holder // zero if the lock file couldn't be read
+	// Stale is set when the holder ran on this machine and is no longer
+	// running, so the lock was left behind.
+	Stale bool
+}
+
+func newLockedError(path string, data []byte) *LockedError {
+	locked := &LockedError{Path: path}
+	if json.Unmarshal(data, &locked.Holder) == nil {
+		locked.Stale = locked.Holder.gone()
+	}
+	return locked
+}
+
+func (e *LockedError) Error() string {
+	if e.Holder.PID == 0 {
+		return fmt.Sprintf("the state directory is locked by another run (%s)", e.Path)
+	}
+	if e.Stale {
+		return fmt.Sprintf("the state directory's lock is stale: process %d on %s, which ran %q from %s, is no longer running (%s)",
+			e.Holder.PID, e.Holder.Host, e.Holder.Command, e.Holder.StartedAt.Local().Format("2006-01-02 15:04:05"), e.Path)
+	}
+	return fmt.Sprintf("the state directory is locked by process %d on %s, running %q since %s (%s)",
+		e.Holder.PID, e.Holder.Host, e.Holder.Command, e.Holder.StartedAt.Local().Format("2006-01-02 15:04:05"), e.Path)
+}
+
+// gone reports whether the holder is known to have stopped: it ran on this
+// machine, and no process with its PID is running. One on another machine
+// can't be checked.
+func (h Holder) gone() bool {
+	host, err := os.Hostname()
+	if err != nil || h.PID <= 0 || h.Host != host {
+		return false
+	}
+	return !running(h.PID)
+}
+
+// Lock is the exclusive hold of a state directory, so two runs don't both
+// work from, and overwrite, the same state.
+type Lock struct {
+	release func() error
+}
+
+// newHolder describes this process.
+func newHolder() Holder {
+	holder := Holder{PID: os.Getpid(), Command: redact.String(strings.Join(os.Args, " ")), StartedAt: time.Now()}
+	holder.Host, _ = os.Hostname()
+	return holder
+}
+
+// Lock locks the directory by creating its lock file, failing with a
+// *LockedError if it already exists. With force an existing lock is broken
+// first, which is only safe if the process holding it is really gone.
+func (b *FileBackend) Lock(force bool) (*Lock, error) {
+	if err := os.MkdirAll(b.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	path := b.Where(lockName)
+	if force {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove lock file: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		data, _ := os.ReadFile(path)
+		return nil, newLockedError(path, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock file: %w", err)
+	}
+	defer f.Close()
+
+	holder := newHolder()
+	if err := json.NewEncoder(f).Encode(&holder); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return &Lock{release: func() error {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove lock file: %w", err)
+		}
+		return nil
+	}}, nil
+}
+
+// Release gives up the lock.
+func (l *Lock) Release() error {
+	return l.release()
+}
@@ -0,0 +1,112 @@
+package state
+
+import "time"
+
+// Status is how far a run got with a vault.
+type Status string
+
+const (
+	// StatusArchivesDone is a vault whose archives are deleted, and which
+	// the run wasn't asked to delete.
+	StatusArchivesDone Status = "archives-done"
+	// StatusDeletePending is a vault whose archives are deleted, and which
+	// is to be deleted once Glacier lets it.
+	StatusDeletePending Status = "vault-delete-pending"
+	StatusVaultDeleted  Status = "vault-deleted"
+	StatusFailed        Status = "failed"
+	// StatusSkipped is a vault the run left alone, such as one a filter left
+	// out or whose prompt was never answered.
+	StatusSkipped Status = "skipped"
+)
+
+// Done reports whether a vault with the status needs nothing more.
+func (s Status) Done() bool {
+	return s == StatusArchivesDone || s == StatusVaultDeleted
+}
+
+// Outcome is the last milestone a run reached with a vault, or why it
+// stopped short of the next, so a resume knows what's left without working
+// it out again.
+type Outcome struct {
+	Region string    `json:"region"`
+	Vault  string    `json:"vault"`
+	Status Status    `json:"status"`
+	Reason string    `json:"reason,omitempty"` // why it failed or was skipped
+	At     time.Time `json:"at"`
+	// DeleteVault is set when the vault was to be deleted too, so a retry
+	// of a failed one carries on to that.
+	DeleteVault bool `json:"deleteVault,omitempty"`
+	// Window is what the vault's work was narrowed to, nil for all of it,
+	// so a retry keeps to it.
+	Window *Window `json:"window,omitempty"`
+}
+
+// Outcome returns the outcome recorded for a vault, if any.
+func (s *Store) Outcome(region, vault string) (Outcome, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, outcome := range s.State.Vaults {
+		if outcome.Region == region && outcome.Vault == vault {
+			return outcome, true
+		}
+	}
+	return Outcome{}, false
+}
+
+// Outcomes returns the outcomes recorded with any of statuses, or every
+// outcome without them.
+func (s *Store) Outcomes(statuses ...Status) []Outcome {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var outcomes []Outcome
+	for _, outcome := range s.State.Vaults {
+		if len(statuses) == 0 || hasStatus(statuses, outcome.Status) {
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	return outcomes
+}
+
+func hasStatus(statuses []Status, status Status) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// PutOutcome records outcome, replacing any earlier outcome for the same
+// vault.
+func (s *Store) PutOutcome(outcome Outcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putOutcome(outcome)
+	return s.save()
+}
+
+// CompleteJob forgets the job recorded for outcome's vault and records
+// outcome in its place, in the one write, so the state file never has the
+// vault's work both done and forgotten or neither.
+func (s *Store) CompleteJob(outcome Outcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeJob(outcome.Region, outcome.Vault)
+	s.putOutcome(outcome)
+	return s.save()
+}
+
+func (s *Store) putOutcome(outcome Outcome) {
+	s.removeOutcome(outcome.Region, outcome.Vault)
+	s.State.Vaults = append(s.State.Vaults, outcome)
+}
+
+func (s *Store) removeOutcome(region, vault string) {
+	kept := s.State.Vaults[:0]
+	for _, outcome := range s.State.Vaults {
+		if outcome.Region != region || outcome.Vault != vault {
+			kept = append(kept, outcome)
+		}
+	}
+	s.State.Vaults = kept
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package state
+
+import "os"
+
+// running reports whether a process with pid is running, which finding it
+// tells here.
+func running(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package state
+
+import (
+	"errors"
+	"syscall"
+)
+
+// running reports whether a process with pid is running. Signal 0 is only
+// checked for, never sent; a process that's there but not ours to signal is
+// still running.
+func running(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
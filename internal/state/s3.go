@@ -0,0 +1,129 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rdegges/ice-breaker/internal/s3object"
+)
+
+// s3Timeout is how long each of the S3 backend's requests gets. They're made
+// whatever became of the run's context, so a run that's interrupted still
+// saves what it did.
+const s3Timeout = 2 * time.Minute
+
+// S3Backend keeps the state under a prefix in an S3 bucket, so a run can be
+// resumed from any machine with access to it. An object is never half
+// written, and each is only replaced if it's still what this run last read
+// or wrote, so even a run that broke the lock of one still going can't
+// overwrite the other's state unawares. The lock is an object only created
+// if it doesn't exist.
+type S3Backend struct {
+	objects s3object.Store
+
+	mu   sync.Mutex
+	seen map[string]s3Object // by name, for each object read or written
+}
+
+// s3Object is an object as last read or written, with no ETag if it didn't
+// exist.
+type s3Object struct {
+	data []byte
+	etag string
+}
+
+// NewS3Backend returns the backend keeping the state in the objects under
+// the uploader's location.
+func NewS3Backend(objects s3object.Store) *S3Backend {
+	return &S3Backend{objects: objects, seen: map[string]s3Object{}}
+}
+
+func (b *S3Backend) Load(name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+	data, etag, err := b.objects.GetObject(ctx, name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", b.Where(name), err)
+	}
+	b.mu.Lock()
+	b.seen[name] = s3Object{data, etag}
+	b.mu.Unlock()
+	return data, err
+}
+
+// Save keeps the old contents as the previous generation only once the new
+// are written, so a run whose write is refused changes nothing. An object
+// can't be left half written, so the previous generation is only there for
+// whatever else damages it.
+func (b *S3Backend) Save(name string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var cond s3object.Condition
+	old, ok := b.seen[name]
+	if ok {
+		cond.IfMatch = old.etag
+		cond.IfNoneExisted = old.etag == ""
+	}
+	etag, err := b.objects.PutObject(ctx, name, data, cond)
+	if errors.Is(err, s3object.ErrConflict) {
+		return fmt.Errorf("%s was changed by another run since this one read it; is one running without holding the lock? %w", b.Where(name), err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", b.Where(name), err)
+	}
+	b.seen[name] = s3Object{data, etag}
+	if old.etag != "" {
+		if _, err := b.objects.PutObject(ctx, name+previousSuffix, old.data, s3object.Condition{}); err != nil {
+			return fmt.Errorf("failed to write %s: %w", b.Where(name+previousSuffix), err)
+		}
+	}
+	return nil
+}
+
+// Lock creates the lock object, if it doesn't exist yet.
+func (b *S3Backend) Lock(force bool) (*Lock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+	if force {
+		if err := b.objects.DeleteObject(ctx, lockName); err != nil {
+			return nil, fmt.Errorf("failed to remove lock object %s: %w", b.Where(lockName), err)
+		}
+	}
+
+	data, err := json.Marshal(newHolder())
+	if err != nil {
+		return nil, err
+	}
+	_, err = b.objects.PutObject(ctx, lockName, data, s3object.Condition{IfNoneExisted: true})
+	if errors.Is(err, s3object.ErrConflict) {
+		data, _, _ := b.objects.GetObject(ctx, lockName)
+		return nil, newLockedError(b.Where(lockName), data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock object %s: %w", b.Where(lockName), err)
+	}
+	return &Lock{release: func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+		defer cancel()
+		if err := b.objects.DeleteObject(ctx, lockName); err != nil {
+			return fmt.Errorf("failed to remove lock object %s: %w", b.Where(lockName), err)
+		}
+		return nil
+	}}, nil
+}
+
+func (b *S3Backend) Where(name string) string {
+	return b.objects.URI(name)
+}
+
+func (b *S3Backend) String() string {
+	return b.objects.URI("")
+}
@@ -0,0 +1,142 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rdegges/ice-breaker/internal/s3object"
+)
+
+// memObjects is a bucket in memory, with ETags and conditional writes as
+// S3 has them.
+type memObjects struct {
+	mu      sync.Mutex
+	objects map[string]string
+	etags   map[string]string
+	writes  int
+}
+
+func newMemObjects() *memObjects {
+	return &memObjects{objects: map[string]string{}, etags: map[string]string{}}
+}
+
+func (m *memObjects) GetObject(ctx context.Context, key string) ([]byte, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, "", fmt.Errorf("no object %s: %w", key, fs.ErrNotExist)
+	}
+	return []byte(data), m.etags[key], nil
+}
+
+func (m *memObjects) PutObject(ctx context.Context, key string, data []byte, cond s3object.Condition) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.objects[key]
+	if cond.IfMatch != "" && cond.IfMatch != m.etags[key] || cond.IfNoneExisted && ok {
+		return "", fmt.Errorf("precondition failed for %s: %w", key, s3object.ErrConflict)
+	}
+	m.writes++
+	m.objects[key], m.etags[key] = string(data), fmt.Sprintf(`"%d"`, m.writes)
+	return m.etags[key], nil
+}
+
+func (m *memObjects) DeleteObject(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	delete(m.etags, key)
+	return nil
+}
+
+func (m *memObjects) URI(key string) string {
+	return "s3://bucket/runs/" + key
+}
+
+func TestS3BackendKeepsGenerations(t *testing.T) {
+	objects := newMemObjects()
+	store, err := Open(NewS3Backend(objects))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, id := range []string{"job-1", "job-2"} {
+		if err := store.PutJob(Job{Region: "us-east-1", Vault: "photos", JobId: id, InitiatedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.Contains(objects.objects[fileName], "job-2") || !strings.Contains(objects.objects[fileName+previousSuffix], "job-1") {
+		t.Errorf("got %q, want job-2 with job-1 as the previous generation", objects.objects)
+	}
+
+	reopened, err := Open(NewS3Backend(objects))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, ok := reopened.Job("us-east-1", "photos"); !ok || job.JobId != "job-2" {
+		t.Errorf("reopened with %+v", reopened.State.Jobs)
+	}
+}
+
+func TestS3BackendRefusesToOverwriteAnotherRun(t *testing.T) {
+	objects := newMemObjects()
+	backend := NewS3Backend(objects)
+	if _, err := backend.Load(fileName); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v, want the object missing", err)
+	}
+	// Another run writes it first.
+	objects.PutObject(context.Background(), fileName, []byte("theirs"), s3object.Condition{})
+
+	err := backend.Save(fileName, []byte("ours"))
+	if !errors.Is(err, s3object.ErrConflict) || !strings.Contains(err.Error(), "s3://bucket/runs/"+fileName+" was changed by another run") {
+		t.Errorf("got %v, want the conflict", err)
+	}
+	if objects.objects[fileName] != "theirs" {
+		t.Errorf("overwrote the other run's state with %q", objects.objects[fileName])
+	}
+
+	// Once read again, it's ours to replace.
+	if _, err := backend.Load(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Save(fileName, []byte("ours")); err != nil {
+		t.Fatal(err)
+	}
+	if objects.objects[fileName] != "ours" || objects.objects[fileName+previousSuffix] != "theirs" {
+		t.Errorf("got %q", objects.objects)
+	}
+}
+
+func TestS3BackendLock(t *testing.T) {
+	objects := newMemObjects()
+	lock, err := NewS3Backend(objects).Lock(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var locked *LockedError
+	if _, err := NewS3Backend(objects).Lock(false); !errors.As(err, &locked) {
+		t.Fatalf("got %v, want a LockedError", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := objects.objects[lockName]; ok {
+		t.Error("the lock object is still there once released")
+	}
+
+	// Left behind, the lock is only broken by force.
+	if _, err := NewS3Backend(objects).Lock(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewS3Backend(objects).Lock(true); err != nil {
+		t.Errorf("forcing the lock: %v", err)
+	}
+}
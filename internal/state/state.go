@@ -0,0 +1,320 @@
+// Package state persists what a run has started so a later run can pick up
+// where it left off: inventory jobs take hours, and the process rarely lives
+// that long.
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const fileName = "state.json"
+
+// Version is the format of the state directory's files written by this
+// build. Files from before the format was versioned read as version 1, and
+// are migrated as they're read; files from a newer build are refused.
+//
+//   - 1: the state file without a version; completed.json a bare list
+//   - 2: both files record their version; completed.json an object
+//   - 3: the state file records each vault's outcome
+//   - 4: jobs and outcomes record the window their vault's work was
+//     narrowed to
+const Version = 4
+
+// NewerVersionError is returned for a file written by a newer build, in a
+// format this one doesn't know.
+type NewerVersionError struct {
+	Path    string
+	Version int
+}
+
+func (e *NewerVersionError) Error() string {
+	return fmt.Sprintf("it has format version %d, from a newer ice-breaker; this one reads up to version %d, so upgrade it to carry on from there", e.Version, Version)
+}
+
+// checkVersion refuses a file from a newer build.
+func checkVersion(path string, version int) error {
+	if version > Version {
+		return &NewerVersionError{Path: path, Version: version}
+	}
+	return nil
+}
+
+// Job is an inventory retrieval job initiated for a vault.
+type Job struct {
+	Region      string    `json:"region"`
+	Vault       string    `json:"vault"`
+	JobId       string    `json:"jobId"`
+	InitiatedAt time.Time `json:"initiatedAt"`
+
+	// For paginated inventories: the page size, the page this job fetches
+	// (counting from 1), and the marker it started from.
+	PageSize int    `json:"pageSize,omitempty"`
+	Page     int    `json:"page,omitempty"`
+	Marker   string `json:"marker,omitempty"`
+
+	// Window is what the vault's work was narrowed to, nil for all of it.
+	Window *Window `json:"window,omitempty"`
+
+	// Done is how many archives at the head of the job's inventory, in the
+	// order it lists them, had all been dealt with when last checkpointed.
+	Done int64 `json:"done,omitempty"`
+}
+
+// Window narrows a vault's work to the archives created in it, as applying a
+// plan does so nothing uploaded since the plan was made is deleted. Whatever
+// carries on with the work, a resume, a retry, or a job replacing one that
+// expired, must keep to it.
+type Window struct {
+	// StartDate and EndDate are the creation dates the inventory jobs list
+	// archives between. Zero leaves that end open.
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate"`
+	// CreatedBefore, if set, leaves alone any archive created after it,
+	// even one an inventory lists.
+	CreatedBefore time.Time `json:"createdBefore"`
+}
+
+type State struct {
+	Version int   `json:"version"`
+	Jobs    []Job `json:"jobs"`
+	// Vaults is how far runs got with each vault, once its work is done or
+	// has stopped short.
+	Vaults []Outcome `json:"vaults,omitempty"`
+	// Checksum is the SHA-256 of the state with Checksum empty, so a file
+	// damaged on disk is noticed. Files written before it was added have
+	// none.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+func (s State) sum() (string, error) {
+	s.Checksum = ""
+	return checksum(&s)
+}
+
+// checksum returns the SHA-256 of v as JSON.
+func checksum(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// stateV1 is the state file as version 1 wrote it, without its version,
+// which its checksum doesn't cover either.
+type stateV1 struct {
+	Jobs     []Job  `json:"jobs"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+func (s stateV1) sum() (string, error) {
+	s.Checksum = ""
+	return checksum(&s)
+}
+
+// migrations take a state file from the version each is keyed by to the
+// next. Each version so far has only added what an older file can go
+// without, so none of them changes anything yet; a version that renames or
+// reshapes what's there says how to here.
+var migrations = map[int]func(*State) error{
+	// 2 records its version, which parseState sets once the steps are run.
+	1: func(*State) error { return nil },
+	// 3 records each vault's outcome. A version 2 file has none, so a
+	// resume works from its jobs alone, as version 2 did.
+	2: func(*State) error { return nil },
+	// 4 records the window each job's and outcome's work was narrowed to.
+	// A version 3 file has none, so its work is all of the vault's, as
+	// version 3 took it.
+	3: func(*State) error { return nil },
+}
+
+// parseState parses a state file, checking it against its checksum and
+// migrating it to the current version.
+func parseState(path string, data []byte, state *State) error {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return err
+	}
+	if err := checkVersion(path, header.Version); err != nil {
+		return err
+	}
+
+	var (
+		parsed State
+		sum    string
+		err    error
+	)
+	if header.Version == 0 {
+		var v1 stateV1
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return err
+		}
+		sum, err = v1.sum()
+		parsed = State{Version: 1, Jobs: v1.Jobs, Checksum: v1.Checksum}
+	} else {
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return err
+		}
+		sum, err = parsed.sum()
+	}
+	if err != nil {
+		return err
+	}
+	// Files written before the checksum was added have none.
+	if parsed.Checksum != "" && sum != parsed.Checksum {
+		return errors.New("its checksum doesn't match its contents")
+	}
+
+	for parsed.Version < Version {
+		if err := migrations[parsed.Version](&parsed); err != nil {
+			return fmt.Errorf("failed to migrate it from version %d: %w", parsed.Version, err)
+		}
+		parsed.Version++
+	}
+	*state = parsed
+	return nil
+}
+
+// Store is the state file in a state directory, loaded into memory, along
+// with the record of the vaults emptied so far. Every change is written back
+// immediately, and atomically. Its methods are safe for concurrent use.
+type Store struct {
+	Path    string // where the state file is
+	State   State
+	Emptied []Emptied
+	// Warnings says which files were found damaged when the store was
+	// opened, and read from their previous generation instead.
+	Warnings []string
+
+	mu      sync.Mutex
+	backend Backend
+}
+
+// DefaultDir returns $XDG_STATE_HOME/ice-breaker, falling back to
+// ~/.local/state/ice-breaker.
+func DefaultDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "ice-breaker")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "ice-breaker")
+	}
+	return "."
+}
+
+// Open loads the state file from backend, starting empty if there isn't one
+// yet.
+func Open(backend Backend) (*Store, error) {
+	store := &Store{Path: backend.Where(fileName), backend: backend}
+	if err := store.loadEmptied(); err != nil {
+		return nil, err
+	}
+	warning, err := readFile(backend, fileName, func(data []byte) error {
+		return parseState(store.Path, data, &store.State)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", store.Path, err)
+	}
+	store.warn(warning)
+	return store, nil
+}
+
+func (s *Store) warn(warning string) {
+	if warning != "" {
+		s.Warnings = append(s.Warnings, warning)
+	}
+}
+
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+func (s *Store) save() error {
+	s.State.Version = Version
+	sum, err := s.State.sum()
+	if err != nil {
+		return err
+	}
+	s.State.Checksum = sum
+	data, err := json.MarshalIndent(&s.State, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := s.backend.Save(fileName, data); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// Job returns the job recorded for a vault, if any.
+func (s *Store) Job(region, vault string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.State.Jobs {
+		if job.Region == region && job.Vault == vault {
+			return job, true
+		}
+	}
+	return Job{}, false
+}
+
+// PutJob records job, replacing any earlier job for the same vault, and
+// forgets the vault's outcome, its work being under way again.
+func (s *Store) PutJob(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeJob(job.Region, job.Vault)
+	s.removeOutcome(job.Region, job.Vault)
+	s.State.Jobs = append(s.State.Jobs, job)
+	return s.save()
+}
+
+// PutDone checkpoints how many archives at the head of the job's inventory
+// have all been dealt with. It does nothing if the vault's recorded job is no
+// longer that one.
+func (s *Store) PutDone(region, vault, jobId string, done int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, job := range s.State.Jobs {
+		if job.Region == region && job.Vault == vault && job.JobId == jobId {
+			s.State.Jobs[i].Done = done
+			return s.save()
+		}
+	}
+	return nil
+}
+
+// RemoveJob forgets the job recorded for a vault.
+func (s *Store) RemoveJob(region, vault string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeJob(region, vault)
+	return s.save()
+}
+
+func (s *Store) removeJob(region, vault string) {
+	jobs := s.State.Jobs[:0]
+	for _, job := range s.State.Jobs {
+		if job.Region != region || job.Vault != vault {
+			jobs = append(jobs, job)
+		}
+	}
+	s.State.Jobs = jobs
+}
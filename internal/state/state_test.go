@@ -0,0 +1,87 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOpenOlderVersions opens a state file of each older version in
+// testdata, as that version wrote it, checking it reads as it was meant,
+// and that once saved it's the current version and reads back the same.
+func TestOpenOlderVersions(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	photos := Job{Region: "us-east-1", Vault: "photos", JobId: "job-photos", InitiatedAt: at}
+	backups := Job{Region: "eu-west-1", Vault: "backups", JobId: "job-backups-2", InitiatedAt: at.Add(time.Hour), PageSize: 1000, Page: 2, Marker: "marker-1"}
+	for _, c := range []struct {
+		version int
+		jobs    []Job
+		vaults  []Outcome
+	}{
+		{version: 1, jobs: []Job{photos, backups}},
+		{version: 2, jobs: []Job{photos, backups}},
+		{
+			version: 3,
+			jobs:    []Job{backups},
+			vaults: []Outcome{
+				{Region: "us-east-1", Vault: "photos", Status: StatusDeletePending, At: at.Add(2 * time.Hour), DeleteVault: true},
+				{Region: "us-west-2", Vault: "logs", Status: StatusFailed, Reason: "failed to delete 3 of 40 archives", At: at.Add(3 * time.Hour), DeleteVault: true},
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("version %d", c.version), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", fmt.Sprintf("state.v%d.json", c.version)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, fileName), data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := Open(NewFileBackend(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(store.Warnings) > 0 {
+				t.Errorf("got warnings %q", store.Warnings)
+			}
+			if store.State.Version != Version || !reflect.DeepEqual(store.State.Jobs, c.jobs) || !reflect.DeepEqual(store.State.Vaults, c.vaults) {
+				t.Fatalf("read version %d as %+v", c.version, store.State)
+			}
+
+			if err := store.Save(); err != nil {
+				t.Fatal(err)
+			}
+			saved, err := os.ReadFile(filepath.Join(dir, fileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(saved), fmt.Sprintf(`"version": %d`, Version)) {
+				t.Errorf("saved as:\n%s\nwant version %d", saved, Version)
+			}
+			reopened, err := Open(NewFileBackend(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reopened.Warnings) > 0 {
+				t.Errorf("got warnings %q reopening it", reopened.Warnings)
+			}
+			if !reflect.DeepEqual(reopened.State, store.State) {
+				t.Errorf("reopened as %+v, want %+v", reopened.State, store.State)
+			}
+		})
+	}
+}
+
+func TestMigrationsCoverEveryVersion(t *testing.T) {
+	for version := 1; version < Version; version++ {
+		if migrations[version] == nil {
+			t.Errorf("no migration from version %d", version)
+		}
+	}
+}
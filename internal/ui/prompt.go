@@ -0,0 +1,312 @@
+package ui
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Prompter asks yes/no questions on a reader, typically stdin. Lines are read
+// on a background goroutine so a pending question can be abandoned when the
+// context ends.
+type Prompter struct {
+	// Timeout, if set, gives up on a question left unanswered this long.
+	// Confirm then takes the answer as no.
+	Timeout time.Duration
+	// NoInput makes every question fail with ErrNoInput instead of waiting
+	// for an answer, for runs nobody is watching.
+	NoInput bool
+
+	input    io.Reader
+	reader   *bufio.Reader
+	lines    chan line
+	once     sync.Once
+	err      error // sticky once the reader fails or runs out
+	timedOut bool  // the last question timed out; its late answer is dropped
+
+	mu       sync.Mutex
+	started  bool              // the background reader is running
+	asking   bool              // a question is waiting for its answer
+	commands func(text string) // set by Listen
+}
+
+// ErrNoInput is returned by Ask when Prompter.NoInput is set.
+var ErrNoInput = errors.New("an answer is needed, but --no-input is set")
+
+// ErrPromptTimeout is returned by Ask when Prompter.Timeout passes without an
+// answer.
+var ErrPromptTimeout = errors.New("no answer before the prompt timed out")
+
+type line struct {
+	text string
+	err  error
+}
+
+func NewPrompter(r io.Reader) *Prompter {
+	return &Prompter{input: r, reader: bufio.NewReader(r), lines: make(chan line)}
+}
+
+// start starts the background reader, unless it's running already.
+func (p *Prompter) start() {
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.started = true
+		p.mu.Unlock()
+		go p.read()
+	})
+}
+
+func (p *Prompter) read() {
+	for {
+		text, err := p.reader.ReadString('\n')
+		if err == nil {
+			p.mu.Lock()
+			commands := p.commands
+			if p.asking {
+				commands = nil
+			}
+			p.mu.Unlock()
+			if commands != nil {
+				commands(strings.TrimSpace(text))
+				continue
+			}
+		}
+		p.lines <- line{text, err}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Confirm prints question and reports whether the answer was "y". It returns
+// io.EOF once the input is exhausted without an answer; a final answer that
+// isn't followed by a newline still counts, and the next call returns io.EOF.
+// If ctx ends while waiting, its error is returned.
+func (p *Prompter) Confirm(ctx context.Context, question string) (bool, error) {
+	answer, err := p.Ask(ctx, question+" (y/N)")
+	if errors.Is(err, ErrPromptTimeout) {
+		Printf("%sNo answer within %s; taking that as no.%s\n", Yellow, p.Timeout, Reset)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return strings.ToLower(answer) == "y", nil
+}
+
+// Ask prints question and returns the answer with surrounding space trimmed.
+// It fails the same way Confirm does.
+func (p *Prompter) Ask(ctx context.Context, question string) (string, error) {
+	if p.NoInput {
+		return "", fmt.Errorf("%s: %w", question, ErrNoInput)
+	}
+	if p.err != nil {
+		return "", p.err
+	}
+	p.start()
+
+	if p.timedOut {
+		// Whatever was typed after the last question gave up on it must not
+		// answer this one.
+		p.timedOut = false
+		select {
+		case response := <-p.lines:
+			if response.err == io.EOF {
+				p.err = io.EOF
+			} else if response.err != nil {
+				p.err = fmt.Errorf("failed to read response: %w", response.err)
+			}
+		default:
+		}
+		if p.err != nil {
+			return "", p.err
+		}
+	}
+
+	var timeout, tick <-chan time.Time
+	countdown := ""
+	if p.Timeout > 0 {
+		timer := time.NewTimer(p.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+		if isTerminal(Messages) {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			tick = ticker.C
+			countdown = fmt.Sprintf("[%4ds] ", int(p.Timeout.Seconds()))
+		}
+	}
+	deadline := time.Now().Add(p.Timeout)
+
+	p.mu.Lock()
+	p.asking = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.asking = false
+		p.mu.Unlock()
+	}()
+
+	Printf("%s%s%s%s %s", countdown, Bold, Red, question, Reset)
+
+	var response line
+wait:
+	for {
+		select {
+		case <-ctx.Done():
+			Println()
+			return "", ctx.Err()
+		case <-timeout:
+			Println()
+			p.timedOut = true
+			return "", ErrPromptTimeout
+		case <-tick:
+			// Rewrite the countdown at the start of the line, leaving the
+			// cursor, and anything typed so far, where it was.
+			Printf("\0337\r[%4ds] \0338", int(time.Until(deadline).Round(time.Second).Seconds()))
+		case response = <-p.lines:
+			break wait
+		}
+	}
+
+	if response.err != nil && response.err != io.EOF {
+		Println()
+		p.err = fmt.Errorf("failed to read response: %w", response.err)
+		return "", p.err
+	}
+
+	if response.err == io.EOF {
+		Println()
+		p.err = io.EOF
+		// Only what was typed before it ran out: the input ending is no
+		// answer, even after stray space.
+		if strings.TrimSpace(response.text) == "" {
+			return "", io.EOF
+		}
+	}
+
+	return strings.TrimSpace(response.text), nil
+}
+
+// Question is one of the questions AskAll asks.
+type Question struct {
+	Text   string
+	Secret bool // the answer isn't to be echoed, as for a secret key
+}
+
+// AskAll asks each question in turn and returns the answers, with surrounding
+// space trimmed. On a terminal nothing else has read from yet, they're asked
+// with it in raw mode, so secret answers aren't echoed, and it's put back as
+// it was afterwards; Ctrl-C or Ctrl-D there gives up with io.EOF. Otherwise,
+// as from a pipe, each is asked the way Ask asks, and fails the same way; a
+// secret asked on a terminal the background reader already reads is asked
+// with the terminal's echo off, and fails if it can't be turned off.
+func (p *Prompter) AskAll(ctx context.Context, questions ...Question) ([]string, error) {
+	answers := make([]string, len(questions))
+	f, isFile := p.input.(*os.File)
+	terminal := isFile && term.IsTerminal(int(f.Fd()))
+	p.mu.Lock()
+	started := p.started
+	p.mu.Unlock()
+	if p.NoInput || !terminal || started {
+		for i, q := range questions {
+			ask := p.Ask
+			if q.Secret && terminal {
+				ask = func(ctx context.Context, question string) (string, error) {
+					return p.askSecret(ctx, f, question)
+				}
+			}
+			answer, err := ask(ctx, q.Text)
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = answer
+		}
+		return answers, nil
+	}
+
+	state, err := term.MakeRaw(int(f.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	defer term.Restore(int(f.Fd()), state)
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{f, Messages}, "")
+	for i, q := range questions {
+		prompt := Bold + Red + q.Text + " " + Reset
+		var answer string
+		if q.Secret {
+			answer, err = t.ReadPassword(prompt)
+		} else {
+			t.SetPrompt(prompt)
+			answer, err = t.ReadLine()
+		}
+		if err != nil {
+			return nil, err
+		}
+		answers[i] = strings.TrimSpace(answer)
+	}
+	return answers, nil
+}
+
+// askSecret asks question the way Ask does with the terminal f's echo off.
+// The background reader is already waiting on f for the answer, so reading it
+// as term.ReadPassword does would race that reader for what's typed.
+func (p *Prompter) askSecret(ctx context.Context, f *os.File, question string) (string, error) {
+	if p.NoInput {
+		return p.Ask(ctx, question)
+	}
+	restore, err := echoOff(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to hide the answer to %q: %w", question, err)
+	}
+	defer restore()
+	answer, err := p.Ask(ctx, question)
+	if err == nil {
+		// The newline typed wasn't echoed either.
+		Println()
+	}
+	return answer, err
+}
+
+// Listen hands commands every line typed on a terminal while no question is
+// waiting for its answer, until the returned function is called. It reports
+// false, and does nothing, if the input isn't a terminal or --no-input is set.
+func (p *Prompter) Listen(commands func(text string)) (stop func(), ok bool) {
+	f, isFile := p.input.(*os.File)
+	if p.NoInput || !isFile || !isTerminal(f) {
+		return func() {}, false
+	}
+	p.mu.Lock()
+	p.commands = commands
+	p.mu.Unlock()
+	p.start()
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.commands = nil
+	}, true
+}
+
+// isTerminal reports whether w is a terminal rather than a file or pipe.
+func isTerminal(w io.Writer) bool {
+	if s, ok := w.(*statusWriter); ok {
+		w = s.out
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a pseudo-terminal, returning the terminal end the prompter
+// reads and the end a user types at, which gets back whatever is echoed.
+func openPTY(t *testing.T) (tty, user *os.File) {
+	t.Helper()
+	user, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals here: %v", err)
+	}
+	t.Cleanup(func() { user.Close() })
+	if err := unix.IoctlSetPointerInt(int(user.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatal(err)
+	}
+	n, err := unix.IoctlGetUint32(int(user.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tty, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tty.Close() })
+	return tty, user
+}
+
+// echoing reports whether the terminal echoes what's typed.
+func echoing(t *testing.T, tty *os.File) bool {
+	t.Helper()
+	termios, err := unix.IoctlGetTermios(int(tty.Fd()), ioctlReadTermios)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return termios.Lflag&unix.ECHO != 0
+}
+
+// TestAskAllSecretAfterStart asks for a secret on a terminal the background
+// reader has already taken over, as credentials renewed mid-run are, and
+// checks it's never echoed while the answer that isn't secret is.
+func TestAskAllSecretAfterStart(t *testing.T) {
+	messages := Messages
+	Messages = io.Discard
+	t.Cleanup(func() { Messages = messages })
+
+	tty, user := openPTY(t)
+	var (
+		mu     sync.Mutex
+		echoed bytes.Buffer
+	)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := user.Read(buf)
+			mu.Lock()
+			echoed.Write(buf[:n])
+			mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	ctx := context.Background()
+	p := NewPrompter(tty)
+	user.WriteString("y\n")
+	if ok, err := p.Confirm(ctx, "Enter new credentials now?"); !ok || err != nil {
+		t.Fatalf("Confirm = %t, %v", ok, err)
+	}
+
+	done := make(chan struct{})
+	var (
+		answers []string
+		err     error
+	)
+	go func() {
+		defer close(done)
+		answers, err = p.AskAll(ctx, Question{Text: "Key:"}, Question{Text: "Secret:", Secret: true})
+	}()
+	user.WriteString("AKIDVISIBLE\n")
+	// Type the secret only once the terminal has stopped echoing.
+	for deadline := time.Now().Add(5 * time.Second); echoing(t, tty); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the terminal never stopped echoing for the secret")
+		}
+	}
+	user.WriteString("SENTINEL-SECRET\n")
+	<-done
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answers[0] != "AKIDVISIBLE" || answers[1] != "SENTINEL-SECRET" {
+		t.Errorf("got answers %q", answers)
+	}
+	if !echoing(t, tty) {
+		t.Error("the terminal's echo wasn't turned back on")
+	}
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(echoed.String(), "AKIDVISIBLE") {
+		t.Errorf("the key ID wasn't echoed: %q", echoed.String())
+	}
+	if strings.Contains(echoed.String(), "SENTINEL") {
+		t.Errorf("the secret was echoed: %q", echoed.String())
+	}
+}
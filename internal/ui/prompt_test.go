@@ -0,0 +1,133 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// quietly has Messages discarded for the rest of the test.
+func quietly(t *testing.T) {
+	messages := Messages
+	Messages = io.Discard
+	t.Cleanup(func() { Messages = messages })
+}
+
+// answer runs ask, failing the test if it hasn't returned within a few
+// seconds rather than let it hang.
+func answer[T any](t *testing.T, ask func() (T, error)) (T, error) {
+	t.Helper()
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := ask()
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting for an answer the input can't give")
+		panic("unreachable")
+	}
+}
+
+var errUnplugged = errors.New("the keyboard was unplugged")
+
+func TestAskShortInput(t *testing.T) {
+	quietly(t)
+	ctx := context.Background()
+	for _, c := range []struct {
+		name    string
+		input   io.Reader
+		answers []string // before the input runs out
+		err     error    // each question gets once it has
+	}{
+		{name: "empty", input: strings.NewReader(""), err: io.EOF},
+		{name: "no final newline", input: strings.NewReader("y"), answers: []string{"y"}, err: io.EOF},
+		{name: "blank line", input: strings.NewReader("\n"), answers: []string{""}, err: io.EOF},
+		{name: "spaces before the end", input: strings.NewReader("first\n   "), answers: []string{"first"}, err: io.EOF},
+		{name: "read error", input: io.MultiReader(strings.NewReader("first\n"), iotest.ErrReader(errUnplugged)), answers: []string{"first"}, err: errUnplugged},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			p := NewPrompter(c.input)
+			for _, want := range c.answers {
+				got, err := answer(t, func() (string, error) { return p.Ask(ctx, "Vault?") })
+				if got != want || err != nil {
+					t.Fatalf("Ask = %q, %v; want %q", got, err, want)
+				}
+			}
+			// And again: the failure sticks rather than the next question
+			// waiting on input that's gone.
+			for i := 0; i < 2; i++ {
+				got, err := answer(t, func() (string, error) { return p.Ask(ctx, "Vault?") })
+				if got != "" || !errors.Is(err, c.err) {
+					t.Fatalf("Ask = %q, %v; want %v", got, err, c.err)
+				}
+			}
+			if ok, err := answer(t, func() (bool, error) { return p.Confirm(ctx, "Delete?") }); ok || !errors.Is(err, c.err) {
+				t.Errorf("Confirm = %t, %v; want false, %v", ok, err, c.err)
+			}
+		})
+	}
+}
+
+func TestAskClosedPipe(t *testing.T) {
+	quietly(t)
+	r, w := io.Pipe()
+	p := NewPrompter(r)
+	time.AfterFunc(10*time.Millisecond, func() { w.CloseWithError(errUnplugged) })
+	if _, err := answer(t, func() (string, error) { return p.Ask(context.Background(), "Vault?") }); !errors.Is(err, errUnplugged) {
+		t.Errorf("got %v, want the pipe's error", err)
+	}
+}
+
+func TestAskAllShortInput(t *testing.T) {
+	quietly(t)
+	ctx := context.Background()
+	questions := []Question{{Text: "Key:"}, {Text: "Secret:", Secret: true}, {Text: "Token:", Secret: true}}
+	for _, c := range []struct {
+		name  string
+		input io.Reader
+		err   error
+	}{
+		{name: "empty", input: strings.NewReader(""), err: io.EOF},
+		{name: "one answer of three", input: strings.NewReader("AKIDEXAMPLE\n"), err: io.EOF},
+		{name: "two answers of three", input: strings.NewReader("AKIDEXAMPLE\nsecret\n"), err: io.EOF},
+		{name: "read error", input: io.MultiReader(strings.NewReader("AKIDEXAMPLE\n"), iotest.ErrReader(errUnplugged)), err: errUnplugged},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			p := NewPrompter(c.input)
+			answers, err := answer(t, func() ([]string, error) { return p.AskAll(ctx, questions...) })
+			if answers != nil || !errors.Is(err, c.err) {
+				t.Errorf("AskAll = %q, %v; want no answers and %v", answers, err, c.err)
+			}
+		})
+	}
+
+	// The last answer counts without its newline, as for Ask.
+	p := NewPrompter(strings.NewReader("AKIDEXAMPLE\nsecret\ntoken"))
+	answers, err := answer(t, func() ([]string, error) { return p.AskAll(ctx, questions...) })
+	if err != nil || strings.Join(answers, ",") != "AKIDEXAMPLE,secret,token" {
+		t.Errorf("AskAll = %q, %v", answers, err)
+	}
+}
+
+func TestAskNoInput(t *testing.T) {
+	quietly(t)
+	p := NewPrompter(strings.NewReader("y\n"))
+	p.NoInput = true
+	if _, err := p.Ask(context.Background(), "Vault?"); !errors.Is(err, ErrNoInput) {
+		t.Errorf("Ask got %v, want ErrNoInput", err)
+	}
+	if _, err := p.AskAll(context.Background(), Question{Text: "Secret:", Secret: true}); !errors.Is(err, ErrNoInput) {
+		t.Errorf("AskAll got %v, want ErrNoInput", err)
+	}
+}
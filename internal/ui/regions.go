@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"fmt"
+	"text/tabwriter"
+)
+
+// RegionRow is one region's line in the end-of-run region table.
+type RegionRow struct {
+	Region     string
+	Status     string // scanned, empty, or skipped
+	Vaults     int
+	ErrorClass string // why a skipped region was skipped
+	Reason     string
+}
+
+// PrintRegions prints a table of every region scanned and what became of it,
+// so a region that was skipped doesn't go unnoticed.
+func PrintRegions(rows []RegionRow) {
+	skipped := 0
+	Printf("\n%sRegions%s\n", Bold, Reset)
+	w := tabwriter.NewWriter(MessageWriter, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  REGION\tSTATUS\tVAULTS\tERROR")
+	for _, row := range rows {
+		if row.ErrorClass != "" {
+			skipped++
+			fmt.Fprintf(w, "  %s\t%s\t-\t%s: %s\n", row.Region, row.Status, row.ErrorClass, row.Reason)
+			continue
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%d\t\n", row.Region, row.Status, row.Vaults)
+	}
+	w.Flush()
+
+	if skipped > 0 {
+		Printf("%s%d of %d region(s) couldn't be scanned; any vaults in them were missed.%s\n", Yellow, skipped, len(rows), Reset)
+	}
+}
@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+// RescanRow is one vault's line in the table of what a rescan found.
+type RescanRow struct {
+	Region, Vault string
+	Status        string // gone, remaining, new, or unknown
+	Selected      bool   // chosen for destruction in the run
+	Archives      int64
+	LastInventory time.Time
+}
+
+// PrintRescan prints a table of the vaults a rescan found, or didn't, with
+// the archives Glacier still counts in those left.
+func PrintRescan(rows []RescanRow) {
+	counts := map[string]int{}
+	leftBehind := 0
+	Printf("\n%sAfter the run%s\n", Bold, Reset)
+	w := tabwriter.NewWriter(MessageWriter, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  REGION\tVAULT\tSTATUS\tSELECTED\tARCHIVES\tAS OF")
+	for _, row := range rows {
+		counts[row.Status]++
+		selected := "no"
+		if row.Selected {
+			selected = "yes"
+			if row.Status == "remaining" {
+				leftBehind++
+			}
+		}
+		archives, asOf := "-", "-"
+		if row.Status == "remaining" || row.Status == "new" {
+			archives = fmt.Sprint(row.Archives)
+			asOf = "never inventoried"
+			if !row.LastInventory.IsZero() {
+				asOf = row.LastInventory.Local().Format("2006-01-02 15:04")
+			}
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", row.Region, row.Vault, row.Status, selected, archives, asOf)
+	}
+	w.Flush()
+
+	Printf("%d vault(s) gone, %d remaining, %d new", counts["gone"], counts["remaining"], counts["new"])
+	if counts["unknown"] > 0 {
+		Printf(", %d unknown: their regions couldn't be scanned again", counts["unknown"])
+	}
+	Println()
+	if leftBehind > 0 {
+		Printf("Archive counts are Glacier's as of each vault's last inventory, which lags about a day behind deletions; %d selected vault(s) are still there.\n", leftBehind)
+	}
+}
@@ -0,0 +1,285 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rdegges/ice-breaker/internal/redact"
+)
+
+// MessageWriter writes to whatever Messages is at the time, for loggers
+// created before a status display replaces it.
+var MessageWriter io.Writer = messageWriter{}
+
+type messageWriter struct{}
+
+func (messageWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(Messages, plain(string(redact.Bytes(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// statusWriter keeps a few status lines below the messages written to a
+// terminal, clearing them before each message and drawing them again after
+// it. While a message is left unfinished, such as a prompt waiting for an
+// answer, they're left off. Lines are cut to the terminal's width, so each
+// takes one row.
+type statusWriter struct {
+	out io.Writer
+
+	mu      sync.Mutex
+	lines   []string
+	drawn   []int // the width of each status line on the screen below the cursor
+	midLine bool  // the last message didn't end its line
+}
+
+func (s *statusWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clear()
+	n, err := s.out.Write(p)
+	if len(p) > 0 {
+		s.midLine = p[len(p)-1] != '\n'
+	}
+	s.draw()
+	return n, err
+}
+
+// set replaces the status lines.
+func (s *statusWriter) set(lines []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clear()
+	s.lines = lines
+	s.draw()
+}
+
+// size returns the terminal's columns and rows, or zeros if they can't be
+// told.
+func (s *statusWriter) size() (width, height int) {
+	if f, ok := s.out.(*os.File); ok {
+		if width, height, ok := terminalSize(f); ok {
+			return width, height
+		}
+	}
+	return 0, 0
+}
+
+// clear erases the status lines from the screen. A terminal made narrower
+// since they were drawn may have wrapped them onto more rows, which are
+// erased too. The caller must hold s.mu.
+func (s *statusWriter) clear() {
+	if len(s.drawn) == 0 {
+		return
+	}
+	width, _ := s.size()
+	rows := 0
+	for _, w := range s.drawn {
+		rows++
+		if width > 0 && w > width {
+			rows += (w - 1) / width
+		}
+	}
+	fmt.Fprintf(s.out, "\033[%dA\r\033[J", rows)
+	s.drawn = s.drawn[:0]
+}
+
+// draw writes the status lines below the messages, leaving the last column
+// free so none wraps. The caller must hold s.mu.
+func (s *statusWriter) draw() {
+	if s.midLine || len(s.lines) == 0 {
+		return
+	}
+	width, _ := s.size()
+	var b strings.Builder
+	for _, line := range s.lines {
+		line = plain(line)
+		if width > 1 {
+			line = fit(line, width-1)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		s.drawn = append(s.drawn, columns(line))
+	}
+	io.WriteString(s.out, b.String())
+}
+
+// fit cuts line down to width columns, not counting its colors, ending it
+// with Reset if that cuts one off.
+func fit(line string, width int) string {
+	cols := 0
+	for i := 0; i < len(line); {
+		if n := escapeLen(line[i:]); n > 0 {
+			i += n
+			continue
+		}
+		if cols == width {
+			return line[:i] + Reset
+		}
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+		cols++
+	}
+	return line
+}
+
+// columns returns how many columns line takes, not counting its colors.
+func columns(line string) int {
+	cols := 0
+	for i := 0; i < len(line); {
+		if n := escapeLen(line[i:]); n > 0 {
+			i += n
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+		cols++
+	}
+	return cols
+}
+
+// escapeLen returns the length of the color code s starts with, or 0 if it
+// doesn't start with one.
+func escapeLen(s string) int {
+	if !strings.HasPrefix(s, "\033[") {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// Status is what ShowStatus keeps on display: lines about the command as a
+// whole, and a line for each of the things it's busy with.
+type Status struct {
+	Summary []string
+	Items   []string
+}
+
+func (s Status) lines() []string {
+	return append(slices.Clip(s.Summary), s.Items...)
+}
+
+// ShowStatus keeps the status returns up to date while a command runs. On a
+// terminal its lines are redrawn in place below the messages every second
+// and whenever the terminal is resized; should the items not fit on the
+// screen, only the summary is, and the items are listed among the messages
+// every interval. Otherwise the lines are logged as messages every interval.
+// While the lines are on a terminal, the standard logger writes above them
+// rather than across them. The returned function stops all this, leaving the
+// final status in the messages.
+func ShowStatus(interval time.Duration, status func() Status) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	var update func()
+	var finish func()
+	resized := make(chan os.Signal, 1)
+	tick := interval
+	if isTerminal(Messages) {
+		s := &statusWriter{out: Messages}
+		Messages = s
+		logOutput := log.Writer()
+		target := logOutput
+		if w, ok := logOutput.(*redact.Writer); ok {
+			target = w.Target()
+		}
+		if target == io.Writer(os.Stderr) && isTerminal(os.Stderr) {
+			log.SetOutput(redact.NewWriter(s))
+		}
+		if len(resizeSignals) > 0 {
+			signal.Notify(resized, resizeSignals...)
+		}
+		tick = time.Second
+		var listed time.Time
+		update = func() {
+			current := status()
+			lines := current.lines()
+			if _, height := s.size(); height > 0 && len(lines) >= height && len(current.Items) > 0 {
+				lines = append(slices.Clip(current.Summary), fmt.Sprintf("(%d more, too many to show here, listed above every %s)", len(current.Items), interval))
+				if time.Since(listed) >= interval {
+					listed = time.Now()
+					fmt.Fprint(s, strings.Join(current.Items, "\n")+"\n")
+				}
+			}
+			s.set(lines)
+		}
+		finish = func() {
+			signal.Stop(resized)
+			s.set(nil)
+			Messages = s.out
+			log.SetOutput(logOutput)
+			for _, line := range status().lines() {
+				Println(line)
+			}
+		}
+	} else {
+		logger := log.New(MessageWriter, "", log.LstdFlags)
+		update = func() {
+			for _, line := range status().lines() {
+				logger.Print(line)
+			}
+		}
+		finish = update
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				update()
+			case <-resized:
+				update()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			finish()
+		})
+	}
+}
+
+// Report writes text to stderr in one go, making room for it among the status
+// lines when they're on the same terminal, and to Messages too when they're
+// going to a file rather than the terminal, so the text is in the log.
+func Report(text string) {
+	text = plain(redact.String(text))
+	if s, ok := Messages.(*statusWriter); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.clear()
+		if s.midLine {
+			text = "\n" + text
+		}
+		io.WriteString(os.Stderr, text)
+		s.draw()
+		return
+	}
+	io.WriteString(os.Stderr, text)
+	if Messages != io.Writer(os.Stderr) && !isTerminal(Messages) {
+		io.WriteString(Messages, text)
+	}
+}
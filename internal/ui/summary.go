@@ -0,0 +1,111 @@
+package ui
+
+import "fmt"
+
+// SummaryRow is one vault's line in the end-of-run summary.
+type SummaryRow struct {
+	Region   string
+	Vault    string
+	ARN      string // the vault's ARN, when it's known
+	Deleted  int    // archives deleted from the vault
+	Absent   int    // archives that were already gone
+	Err      error
+	Skipped  bool   // the user never answered the prompt for this vault
+	Filtered string // why a filter left the vault out of the run
+	Aborted  bool   // never started, or stopped part way, because another vault failed
+	Stopped  bool   // never started, or stopped part way, because the run was wrapped up early
+	// PossiblyIncomplete marks a vault whose inventory may have been missing
+	// recent uploads.
+	PossiblyIncomplete bool
+	// Unattempted counts the archives a tripped circuit breaker left
+	// untried.
+	Unattempted int
+	// Duplicates counts the archive IDs the inventory repeated, which
+	// weren't counted again.
+	Duplicates int
+	// FailedBy counts the archives that failed to delete by the class of
+	// their error, as "403 AccessDenied, 12 Throttling".
+	FailedBy string
+}
+
+// PrintSummary prints the outcome of every vault and returns the number of
+// vaults that failed. Failures are listed last, together, so they don't get
+// lost among a long run's successes.
+func PrintSummary(rows []SummaryRow) int {
+	var failures []SummaryRow
+	skipped, filtered, aborted, stopped := 0, 0, 0, 0
+	Printf("\n%sSummary%s\n", Bold, Reset)
+	for _, row := range rows {
+		switch {
+		case row.Skipped:
+			skipped++
+			Printf("%s  SKIPPED [%s] %s: no answer given%s\n", Yellow, row.Region, row.Vault, Reset)
+		case row.Filtered != "":
+			filtered++
+			Printf("%s  FILTERED [%s] %s: %s%s\n", Yellow, row.Region, row.Vault, row.Filtered, Reset)
+		case row.Aborted:
+			aborted++
+			deleted := ""
+			if row.Deleted > 0 {
+				deleted = fmt.Sprintf(", after deleting %d archive(s)", row.Deleted)
+			}
+			Printf("%s  ABORTED [%s] %s: %v%s%s\n", Yellow, row.Region, row.Vault, row.Err, deleted, Reset)
+		case row.Stopped:
+			stopped++
+			Printf("%s  STOPPED [%s] %s: %v%s\n", Yellow, row.Region, row.Vault, row.Err, Reset)
+		case row.Err != nil:
+			failures = append(failures, row)
+		case row.PossiblyIncomplete:
+			Printf("%s  OK?     [%s] %s: %s, possibly incomplete: the inventory may miss recent uploads%s%s\n", Yellow, row.Region, row.Vault, row.deleted(), row.arn(), Reset)
+		default:
+			Printf("%s  OK      [%s] %s: %s%s%s\n", Green, row.Region, row.Vault, row.deleted(), row.arn(), Reset)
+		}
+	}
+	if len(failures) > 0 {
+		Printf("\n%s%sFailures%s\n", Red, Bold, Reset)
+		for _, row := range failures {
+			Printf("%s  FAILED  [%s] %s: %v%s%s\n", Red, row.Region, row.Vault, row.Err, row.arn(), Reset)
+			if row.FailedBy != "" {
+				Printf("%s          failed archives: %s%s\n", Red, row.FailedBy, Reset)
+			}
+			if row.Unattempted > 0 {
+				Printf("%s          %d archive(s) weren't attempted; fix the cause and run again to retry them.%s\n", Red, row.Unattempted, Reset)
+			}
+		}
+	}
+
+	Printf("%d vault(s) processed, %d failed", len(rows)-skipped-filtered-aborted-stopped, len(failures))
+	if filtered > 0 {
+		Printf(", %d filtered out", filtered)
+	}
+	if aborted > 0 {
+		Printf(", %d aborted", aborted)
+	}
+	if stopped > 0 {
+		Printf(", %d stopped early", stopped)
+	}
+	Println()
+	if stopped > 0 {
+		Println("The inventory jobs of the vaults stopped part way are recorded; run 'ice-breaker resume' to finish them. Vaults not started need running again.")
+	}
+
+	return len(failures)
+}
+
+func (row SummaryRow) deleted() string {
+	deleted := fmt.Sprintf("%d archive(s) deleted", row.Deleted)
+	if row.Absent > 0 {
+		deleted += fmt.Sprintf(", %d already gone", row.Absent)
+	}
+	if row.Duplicates > 0 {
+		deleted += fmt.Sprintf(", %d duplicate(s) in the inventory", row.Duplicates)
+	}
+	return deleted
+}
+
+func (row SummaryRow) arn() string {
+	if row.ARN == "" {
+		return ""
+	}
+	return " (" + row.ARN + ")"
+}
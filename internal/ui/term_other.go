@@ -0,0 +1,20 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package ui
+
+import (
+	"errors"
+	"os"
+)
+
+var resizeSignals []os.Signal
+
+// terminalSize can't tell the size of a terminal here.
+func terminalSize(f *os.File) (width, height int, ok bool) {
+	return 0, 0, false
+}
+
+// echoOff can't stop a terminal echoing here.
+func echoOff(f *os.File) (restore func(), err error) {
+	return nil, errors.New("can't turn off the terminal's echo on this system")
+}
@@ -0,0 +1,72 @@
+// Package ui holds the terminal-facing pieces of ice-breaker: colors,
+// prompts, debug logging, and the end-of-run summary.
+package ui
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rdegges/ice-breaker/internal/redact"
+)
+
+const (
+	Red    = "\033[31m"
+	Green  = "\033[32m"
+	Yellow = "\033[33m"
+	Reset  = "\033[0m"
+	Bold   = "\033[1m"
+)
+
+// Colors enables the color codes in messages. With it off they're stripped,
+// leaving plain text.
+var Colors = true
+
+// DetectColors turns Colors off unless Messages is a terminal, or when the
+// NO_COLOR environment variable is set.
+func DetectColors() {
+	Colors = isTerminal(Messages) && os.Getenv("NO_COLOR") == ""
+}
+
+// plain strips the color codes from s when Colors is off.
+func plain(s string) string {
+	if Colors || !strings.Contains(s, "\033[") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+// Verbose enables Debugf output.
+var Verbose bool
+
+// Debugf logs a message only when --verbose is set.
+func Debugf(format string, args ...any) {
+	if Verbose {
+		log.Printf(format, args...)
+	}
+}
+
+// Messages is where progress and status messages go. Commands that write
+// machine-readable output to stdout point it at stderr instead.
+var Messages io.Writer = os.Stdout
+
+// Printf writes a progress or status message.
+func Printf(format string, args ...any) {
+	io.WriteString(Messages, plain(redact.String(fmt.Sprintf(format, args...))))
+}
+
+// Println writes a progress or status message followed by a newline.
+func Println(args ...any) {
+	io.WriteString(Messages, plain(redact.String(fmt.Sprintln(args...))))
+}
@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the output the tests get")
+
+// modes are the two ways messages are written: with colors, as to a
+// terminal, and without.
+var modes = []struct {
+	name   string
+	colors bool
+}{
+	{"tty", true},
+	{"plain", false},
+}
+
+// capture returns what print writes to Messages in each mode, by mode.
+func capture(t *testing.T, print func()) map[string]string {
+	t.Helper()
+	messages, colors := Messages, Colors
+	t.Cleanup(func() { Messages, Colors = messages, colors })
+
+	got := make(map[string]string)
+	for _, mode := range modes {
+		var buf bytes.Buffer
+		Messages, Colors = &buf, mode.colors
+		print()
+		got[mode.name] = buf.String()
+	}
+	return got
+}
+
+// golden compares got with testdata/name.golden, or rewrites the file with it
+// when -update is given.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s doesn't match; run go test -update if the change is intended\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestPrintSummaryGolden(t *testing.T) {
+	rows := []SummaryRow{
+		{Region: "us-east-1", Vault: "photos", ARN: "arn:aws:glacier:us-east-1:123456789012:vaults/photos", Deleted: 1204, Absent: 3},
+		{Region: "us-east-1", Vault: "logs-2019", Deleted: 50, Duplicates: 2, PossiblyIncomplete: true},
+		{Region: "us-east-1", Vault: "keep", Skipped: true},
+		{Region: "eu-west-1", Vault: "фото-архив", ARN: "arn:aws:glacier:eu-west-1:123456789012:vaults/фото-архив", Deleted: 7},
+		{Region: "eu-west-1", Vault: "写真", Err: errors.New("failed to delete 12 of 40 archives"), Deleted: 28, FailedBy: "10 AccessDenied, 2 Throttling"},
+		{Region: "eu-west-1", Vault: "tagged", Filtered: "tag env isn't prod"},
+		{Region: "ap-southeast-2", Vault: "backups", Err: errors.New("breaker tripped"), ARN: "arn:aws:glacier:ap-southeast-2:123456789012:vaults/backups", Unattempted: 975},
+		{Region: "ap-southeast-2", Vault: "later", Aborted: true, Err: errors.New("another vault failed"), Deleted: 5},
+		{Region: "ap-southeast-2", Vault: "wrapped", Stopped: true, Err: errors.New("wrapped up early")},
+	}
+	var failed int
+	for mode, got := range capture(t, func() { failed = PrintSummary(rows) }) {
+		golden(t, "summary."+mode, got)
+	}
+	if failed != 2 {
+		t.Errorf("PrintSummary returned %d, want 2", failed)
+	}
+}
+
+func TestPrintRegionsGolden(t *testing.T) {
+	rows := []RegionRow{
+		{Region: "us-east-1", Status: "scanned", Vaults: 12},
+		{Region: "eu-west-1", Status: "empty"},
+		{Region: "ap-southeast-2", Status: "skipped", ErrorClass: "AccessDenied", Reason: "not authorized to perform glacier:ListVaults"},
+		{Region: "me-central-1", Status: "skipped", ErrorClass: "OptIn", Reason: "the region isn't enabled for the account"},
+	}
+	for mode, got := range capture(t, func() { PrintRegions(rows) }) {
+		golden(t, "regions."+mode, got)
+	}
+}
+
+// TestStatusLinesGolden draws status lines below messages as a terminal
+// would see them: cleared before each message, left off while a prompt waits
+// for its answer, and cut to the width.
+func TestStatusLinesGolden(t *testing.T) {
+	for mode, got := range capture(t, func() {
+		s := &statusWriter{out: Messages}
+		s.set([]string{Bold + "2 vault(s): 1 in progress, 1 queued" + Reset, Green + "  [us-east-1] photos: 1204 deleted" + Reset})
+		io.WriteString(s, "Archive 1 deleted from фото-архив\n")
+		io.WriteString(s, "Delete vault keep? [y/N] ")
+		s.set([]string{Bold + "2 vault(s): 1 in progress, 1 queued" + Reset, Yellow + "  [eu-west-1] 写真: waiting for an answer" + Reset})
+		io.WriteString(s, "n\n")
+		s.set(nil)
+	}) {
+		golden(t, "status."+mode, got)
+	}
+}
+
+// TestShowStatusGolden logs the status lines as messages, as it does when
+// they don't go to a terminal.
+func TestShowStatusGolden(t *testing.T) {
+	timestamp := regexp.MustCompile(`(?m)^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d `)
+	for mode, got := range capture(t, func() {
+		stop := ShowStatus(time.Hour, func() Status {
+			return Status{
+				Summary: []string{"3 vault(s): 2 in progress, 1 queued"},
+				Items:   []string{Green + "  [us-east-1] photos: 1204 deleted" + Reset, Red + "  [eu-west-1] 写真: 12 failed" + Reset},
+			}
+		})
+		stop()
+	}) {
+		golden(t, "showstatus."+mode, timestamp.ReplaceAllString(got, "YYYY/MM/DD hh:mm:ss "))
+	}
+}